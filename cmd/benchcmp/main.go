@@ -0,0 +1,154 @@
+// Command benchcmp compares two runs of the benchmarks and fails when one got
+// slower, or allocates more, than the threshold. Each benchmark is summarised
+// by the median of its runs, so run them with -count to smooth out noise.
+//
+//	go test ./internal/... -run '^$' -bench . -benchmem -count 5 > old.txt
+//	go test ./internal/... -run '^$' -bench . -benchmem -count 5 > new.txt
+//	go run ./cmd/benchcmp -threshold 10 old.txt new.txt
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+)
+
+// units are the measures compared, in the order they are printed.
+var units = []string{"ns/op", "B/op", "allocs/op"}
+
+// procsSuffix is the GOMAXPROCS suffix of the benchmark names, dropped so runs
+// on machines with different CPU counts still compare.
+var procsSuffix = regexp.MustCompile(`-\d+$`)
+
+// samples are the measures of each benchmark by unit, one per run.
+type samples map[string]map[string][]float64
+
+func main() {
+	threshold := flag.Float64("threshold", 10, "percentage over which an increase fails the comparison")
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: benchcmp [-threshold percent] old.txt new.txt")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 2 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	old, err := parseFile(flag.Arg(0))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "benchcmp:", err)
+		os.Exit(2)
+	}
+	current, err := parseFile(flag.Arg(1))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "benchcmp:", err)
+		os.Exit(2)
+	}
+
+	if regressions := compare(os.Stdout, old, current, *threshold); regressions > 0 {
+		fmt.Printf("\n%d regressions over %.0f%%\n", regressions, *threshold)
+		os.Exit(1)
+	}
+}
+
+func parseFile(path string) (samples, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return parse(file)
+}
+
+// parse reads the benchmark lines of a go test output, e.g.
+//
+//	BenchmarkBroadcast/32-8   20000   67791 ns/op   12427 B/op   421 allocs/op
+func parse(reader io.Reader) (samples, error) {
+	parsed := samples{}
+	scanner := bufio.NewScanner(reader)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 4 || !strings.HasPrefix(fields[0], "Benchmark") {
+			continue
+		}
+		name := procsSuffix.ReplaceAllString(fields[0], "")
+		// fields[1] is the iteration count, then value and unit pairs
+		for i := 2; i+1 < len(fields); i += 2 {
+			if !slices.Contains(units, fields[i+1]) {
+				continue
+			}
+			value, err := strconv.ParseFloat(fields[i], 64)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %v", name, err)
+			}
+			if parsed[name] == nil {
+				parsed[name] = map[string][]float64{}
+			}
+			parsed[name][fields[i+1]] = append(parsed[name][fields[i+1]], value)
+		}
+	}
+	return parsed, scanner.Err()
+}
+
+// compare prints the medians of the benchmarks found in both runs and their
+// change, and returns how many increased by more than threshold percent.
+func compare(out io.Writer, old, current samples, threshold float64) int {
+	var names []string
+	for name := range current {
+		if _, ok := old[name]; ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	writer := tabwriter.NewWriter(out, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(writer, "benchmark\tunit\told\tnew\tdelta\t")
+	regressions := 0
+	for _, name := range names {
+		for _, unit := range units {
+			oldValues, newValues := old[name][unit], current[name][unit]
+			if len(oldValues) == 0 || len(newValues) == 0 {
+				continue
+			}
+			before, after := median(oldValues), median(newValues)
+			delta := "~"
+			if before != 0 {
+				change := (after - before) / before * 100
+				delta = fmt.Sprintf("%+.1f%%", change)
+				if change > threshold {
+					delta += " !"
+					regressions++
+				}
+			} else if after > 0 {
+				delta = "+inf !"
+				regressions++
+			}
+			fmt.Fprintf(writer, "%s\t%s\t%s\t%s\t%s\t\n", name, unit, format(before), format(after), delta)
+		}
+	}
+	writer.Flush()
+	return regressions
+}
+
+func median(values []float64) float64 {
+	sorted := slices.Clone(values)
+	slices.Sort(sorted)
+	middle := len(sorted) / 2
+	if len(sorted)%2 == 0 {
+		return (sorted[middle-1] + sorted[middle]) / 2
+	}
+	return sorted[middle]
+}
+
+func format(value float64) string {
+	return strconv.FormatFloat(value, 'f', -1, 64)
+}
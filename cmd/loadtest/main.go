@@ -0,0 +1,272 @@
+// Command loadtest measures the capacity of a deployment. It opens concurrent
+// WebSocket clients, groups them in rooms and relays synthetic offers and
+// candidates between room members at a target rate, then reports the relay
+// latency percentiles.
+//
+//	go run ./cmd/loadtest -url ws://localhost:8080/ -clients 200 -room-size 4 -rate 1000 -duration 30s
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"math/rand"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/lithammer/shortuuid"
+)
+
+// loadClient is a connected client taking part in the test.
+type loadClient struct {
+	id   string
+	conn *websocket.Conn
+	room string
+	// control receives the server responses other than relayed messages.
+	control chan map[string]interface{}
+}
+
+// results collects the relay latencies.
+type results struct {
+	mu        sync.Mutex
+	latencies []time.Duration
+	sent      atomic.Int64
+	errors    atomic.Int64
+}
+
+func (r *results) record(latency time.Duration) {
+	r.mu.Lock()
+	r.latencies = append(r.latencies, latency)
+	r.mu.Unlock()
+}
+
+func main() {
+	url := flag.String("url", "ws://localhost:8080/", "WebSocket URL of the server")
+	clientCount := flag.Int("clients", 100, "number of concurrent clients")
+	roomSize := flag.Int("room-size", 4, "number of clients per room")
+	rate := flag.Int("rate", 500, "relayed messages per second, across all clients")
+	duration := flag.Duration("duration", 30*time.Second, "how long messages are relayed")
+	payloadSize := flag.Int("payload", 512, "size of the synthetic SDP and candidates in bytes")
+	flag.Parse()
+
+	if *clientCount < 2 || *roomSize < 2 || *rate < 1 {
+		fmt.Fprintln(os.Stderr, "clients and room-size must be at least 2, rate at least 1")
+		os.Exit(2)
+	}
+
+	stats := &results{}
+	fmt.Printf("Connecting %d clients to %s\n", *clientCount, *url)
+	clients, err := connectAll(*url, *clientCount, stats)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "connect:", err)
+		os.Exit(1)
+	}
+	defer func() {
+		for _, client := range clients {
+			client.conn.Close()
+		}
+	}()
+
+	rooms, err := setupRooms(clients, *roomSize)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "rooms:", err)
+		os.Exit(1)
+	}
+	fmt.Printf("Joined %d rooms, relaying %d messages/s for %s\n", len(rooms), *rate, *duration)
+
+	relay(clients, rooms, *rate, *duration, strings.Repeat("a", *payloadSize), stats)
+	// let the last messages arrive
+	time.Sleep(time.Second)
+	report(stats, *duration)
+}
+
+// connectAll opens the connections concurrently and waits for the client details.
+func connectAll(url string, count int, stats *results) ([]*loadClient, error) {
+	clients := make([]*loadClient, count)
+	errs := make(chan error, count)
+	var wg sync.WaitGroup
+	for index := range clients {
+		wg.Add(1)
+		go func(index int) {
+			defer wg.Done()
+			client, err := dial(url, stats)
+			if err != nil {
+				errs <- err
+				return
+			}
+			clients[index] = client
+		}(index)
+	}
+	wg.Wait()
+	close(errs)
+	if err := <-errs; err != nil {
+		return nil, err
+	}
+	return clients, nil
+}
+
+func dial(url string, stats *results) (*loadClient, error) {
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		return nil, err
+	}
+	client := &loadClient{conn: conn, control: make(chan map[string]interface{}, 64)}
+	go client.read(stats)
+	details, err := client.await("Client_Details")
+	if err != nil {
+		return nil, err
+	}
+	client.id, _ = details["id"].(string)
+	return client, nil
+}
+
+// read records the latency of relayed messages and passes anything else on.
+func (client *loadClient) read(stats *results) {
+	for {
+		var msg map[string]interface{}
+		if err := client.conn.ReadJSON(&msg); err != nil {
+			close(client.control)
+			return
+		}
+		if _, relayed := msg["from"]; relayed {
+			if data, ok := msg["data"].(map[string]interface{}); ok {
+				if sentAt, ok := data["sent_at"].(float64); ok {
+					stats.record(time.Since(time.Unix(0, int64(sentAt))))
+				}
+			}
+			continue
+		}
+		if msg["type"] == "error" {
+			stats.errors.Add(1)
+		}
+		select {
+		case client.control <- msg:
+		default:
+		}
+	}
+}
+
+// await waits for a response with the event name and returns its data.
+func (client *loadClient) await(event string) (map[string]interface{}, error) {
+	timeout := time.After(10 * time.Second)
+	for {
+		select {
+		case msg, ok := <-client.control:
+			if !ok {
+				return nil, fmt.Errorf("connection closed while waiting for %s", event)
+			}
+			if msg["event"] == event {
+				data, _ := msg["data"].(map[string]interface{})
+				return data, nil
+			}
+			if msg["type"] == "error" {
+				return nil, fmt.Errorf("waiting for %s: %v %v", event, msg["event"], msg["data"])
+			}
+		case <-timeout:
+			return nil, fmt.Errorf("timed out waiting for %s", event)
+		}
+	}
+}
+
+// setupRooms creates a room per roomSize clients and joins the others to it.
+func setupRooms(clients []*loadClient, roomSize int) (map[string][]*loadClient, error) {
+	rooms := make(map[string][]*loadClient)
+	for start := 0; start < len(clients); start += roomSize {
+		members := clients[start:min(start+roomSize, len(clients))]
+		roomId := "loadtest-" + shortuuid.New()
+		creator := members[0]
+		creator.conn.WriteJSON(map[string]interface{}{"event": "Create_Room", "data": map[string]interface{}{"room": roomId}})
+		if _, err := creator.await("Room_Created"); err != nil {
+			return nil, err
+		}
+		for _, member := range members[1:] {
+			member.conn.WriteJSON(map[string]interface{}{"event": "Join_Room", "data": map[string]interface{}{"room": roomId}})
+			if err := member.awaitMembership(); err != nil {
+				return nil, err
+			}
+		}
+		for _, member := range members {
+			member.room = roomId
+		}
+		rooms[roomId] = members
+	}
+	return rooms, nil
+}
+
+// awaitMembership waits for the update listing the client in its new room.
+func (client *loadClient) awaitMembership() error {
+	for {
+		data, err := client.await("Client_Added")
+		if err != nil {
+			return err
+		}
+		if members, _ := data["clients"].([]interface{}); slices.Contains(members, interface{}(client.id)) {
+			return nil
+		}
+	}
+}
+
+// relay sends offers and candidates to random room peers at the target rate.
+func relay(clients []*loadClient, rooms map[string][]*loadClient, rate int, duration time.Duration, payload string, stats *results) {
+	ticker := time.NewTicker(time.Second / time.Duration(rate))
+	defer ticker.Stop()
+	deadline := time.After(duration)
+	events := []string{"Offer", "Candidate"}
+	for sequence := 0; ; sequence++ {
+		select {
+		case <-deadline:
+			return
+		case <-ticker.C:
+		}
+		sender := clients[sequence%len(clients)]
+		peers := rooms[sender.room]
+		if len(peers) < 2 {
+			continue
+		}
+		target := peers[rand.Intn(len(peers))]
+		for target == sender {
+			target = peers[rand.Intn(len(peers))]
+		}
+		msg := map[string]interface{}{
+			"event": events[sequence%len(events)],
+			"to":    target.id,
+			"room":  sender.room,
+			"data":  map[string]interface{}{"sdp": payload, "sent_at": time.Now().UnixNano()},
+		}
+		if err := sender.conn.WriteJSON(msg); err != nil {
+			stats.errors.Add(1)
+			continue
+		}
+		stats.sent.Add(1)
+	}
+}
+
+// report prints the throughput and the latency percentiles.
+func report(stats *results, duration time.Duration) {
+	stats.mu.Lock()
+	defer stats.mu.Unlock()
+	latencies := stats.latencies
+	slices.Sort(latencies)
+	sent, received := stats.sent.Load(), len(latencies)
+
+	summary := map[string]interface{}{
+		"sent":          sent,
+		"received":      received,
+		"errors":        stats.errors.Load(),
+		"throughput":    float64(received) / duration.Seconds(),
+		"delivery_rate": float64(received) / float64(max(sent, 1)),
+	}
+	if received > 0 {
+		for _, percentile := range []int{50, 90, 99} {
+			summary[fmt.Sprintf("p%d", percentile)] = latencies[(received-1)*percentile/100].String()
+		}
+		summary["max"] = latencies[received-1].String()
+	}
+	encoded, _ := json.MarshalIndent(summary, "", "  ")
+	fmt.Println(string(encoded))
+}
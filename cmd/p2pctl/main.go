@@ -0,0 +1,379 @@
+// Command p2pctl debugs a deployment from a terminal, through the WebSocket
+// protocol and the admin API.
+//
+//	p2pctl [flags] rooms                list the rooms
+//	p2pctl [flags] clients              list the connected clients
+//	p2pctl [flags] delete-room <room>   delete a room, its members are notified
+//	p2pctl [flags] events               print the server events as they happen
+//	p2pctl [flags] tap <client>         print copies of the frames of a client
+//	p2pctl [flags] tap-room <room>      print copies of the frames of the members of a room
+//	p2pctl [flags] join <room>          join a room as a test client and print what it receives
+//	p2pctl [flags] offer <client>       send a test offer to a client and wait for the answer
+//	p2pctl [flags] replay <file>...     replay recorded sessions and print what they receive
+//
+// The admin commands need the admin token, in -token or ADMIN_TOKEN.
+//
+//	go run ./cmd/p2pctl -url wss://peer2peerconnector.example.com/ rooms
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// testSDP is a minimal data channel offer, valid for the server SDP checks.
+const testSDP = "v=0\r\no=- 0 0 IN IP4 127.0.0.1\r\ns=-\r\nt=0 0\r\n" +
+	"m=application 9 UDP/DTLS/SCTP webrtc-datachannel\r\nc=IN IP4 0.0.0.0\r\na=mid:0\r\n"
+
+var (
+	serverURL = flag.String("url", "ws://localhost:8080/", "WebSocket URL of the server, the admin API is on the same host unless -admin-url is set")
+	adminBase = flag.String("admin-url", "", "URL of the admin API when the server has an ADMIN_ADDR, e.g. http://127.0.0.1:9090/")
+	token     = flag.String("token", os.Getenv("ADMIN_TOKEN"), "admin token, ADMIN_TOKEN by default")
+	password  = flag.String("password", "", "password of the room, for join")
+	create    = flag.Bool("create", false, "create the room if it doesn't exist, for join")
+	sdpFile   = flag.String("sdp", "", "file with the SDP to offer instead of a minimal one, for offer")
+	timeout   = flag.Duration("timeout", 10*time.Second, "how long offer waits for the answer, and replay for the last messages")
+	redaction = flag.String("redact", "", "comma separated fields hidden by tap: sdp, candidates, secrets, content or none, all by default")
+	speed     = flag.Float64("speed", 1, "playback speed of replay, 0 sends the frames without waiting")
+)
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: p2pctl [flags] rooms | clients | delete-room <room> | events | tap <client> | tap-room <room> | join <room> | offer <client> | replay <file>...")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	args := flag.Args()
+	if len(args) == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	var err error
+	switch command, arg := args[0], strings.Join(args[1:], " "); {
+	case command == "rooms":
+		err = listRooms()
+	case command == "clients":
+		err = listClients()
+	case command == "delete-room" && arg != "":
+		err = deleteRoom(arg)
+	case command == "events":
+		err = tailEvents()
+	case command == "tap" && arg != "":
+		err = tap("client", arg)
+	case command == "tap-room" && arg != "":
+		err = tap("room", arg)
+	case command == "join" && arg != "":
+		err = join(arg)
+	case command == "offer" && arg != "":
+		err = offer(arg)
+	case command == "replay" && arg != "":
+		err = replay(args[1:])
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "p2pctl:", err)
+		os.Exit(1)
+	}
+}
+
+// adminURL returns the URL of path on the admin API, on the host of the
+// WebSocket URL unless -admin-url is set.
+func adminURL(path string) (*url.URL, error) {
+	if *adminBase != "" {
+		base, err := url.Parse(*adminBase)
+		if err != nil {
+			return nil, err
+		}
+		base.Path = path
+		return base, nil
+	}
+	base, err := url.Parse(*serverURL)
+	if err != nil {
+		return nil, err
+	}
+	base.Scheme = strings.Replace(base.Scheme, "ws", "http", 1)
+	base.Path = path
+	return base, nil
+}
+
+// adminRequest calls the admin API.
+func adminRequest(method string, path string) (*http.Response, error) {
+	base, err := adminURL(path)
+	if err != nil {
+		return nil, err
+	}
+	request, err := http.NewRequest(method, base.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	request.Header.Set("Authorization", "Bearer "+*token)
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	if response.StatusCode >= 300 {
+		body, _ := io.ReadAll(response.Body)
+		response.Body.Close()
+		return nil, fmt.Errorf("%s %s: %s %s", method, path, response.Status, strings.TrimSpace(string(body)))
+	}
+	return response, nil
+}
+
+// adminGet decodes the JSON answer of a GET on the admin API into v.
+func adminGet(path string, v interface{}) error {
+	response, err := adminRequest(http.MethodGet, path)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	return json.NewDecoder(response.Body).Decode(v)
+}
+
+func listRooms() error {
+	var answer struct {
+		Rooms []struct {
+			Room       string   `json:"room"`
+			Name       string   `json:"name"`
+			Clients    []string `json:"clients"`
+			Locked     bool     `json:"locked"`
+			Persistent bool     `json:"persistent"`
+		} `json:"rooms"`
+	}
+	if err := adminGet("/admin/rooms", &answer); err != nil {
+		return err
+	}
+	table := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(table, "ROOM\tNAME\tMEMBERS\tLOCKED\tPERSISTENT")
+	for _, room := range answer.Rooms {
+		fmt.Fprintf(table, "%s\t%s\t%d\t%t\t%t\n", room.Room, room.Name, len(room.Clients), room.Locked, room.Persistent)
+	}
+	return table.Flush()
+}
+
+func listClients() error {
+	var answer struct {
+		Clients []struct {
+			Id          string    `json:"id"`
+			Status      string    `json:"status"`
+			ConnectedAt time.Time `json:"connected_at"`
+			RemoteAddr  string    `json:"remote_addr"`
+		} `json:"clients"`
+	}
+	if err := adminGet("/admin/clients", &answer); err != nil {
+		return err
+	}
+	sort.Slice(answer.Clients, func(i, j int) bool { return answer.Clients[i].ConnectedAt.Before(answer.Clients[j].ConnectedAt) })
+	table := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(table, "CLIENT\tSTATUS\tCONNECTED\tADDRESS")
+	for _, client := range answer.Clients {
+		fmt.Fprintf(table, "%s\t%s\t%s\t%s\n", client.Id, client.Status, time.Since(client.ConnectedAt).Round(time.Second), client.RemoteAddr)
+	}
+	return table.Flush()
+}
+
+func deleteRoom(roomId string) error {
+	response, err := adminRequest(http.MethodDelete, "/admin/rooms/"+url.PathEscape(roomId))
+	if err != nil {
+		return err
+	}
+	response.Body.Close()
+	fmt.Println("Deleted room", roomId)
+	return nil
+}
+
+// tailEvents prints the events streamed by /admin/events, one JSON object per line.
+func tailEvents() error {
+	response, err := adminRequest(http.MethodGet, "/admin/events")
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	scanner := bufio.NewScanner(response.Body)
+	for scanner.Scan() {
+		if data, ok := strings.CutPrefix(scanner.Text(), "data: "); ok {
+			fmt.Println(data)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	return errors.New("the server closed the stream")
+}
+
+// tap prints the frames of the client or room streamed by /admin/tap, one JSON
+// object per line, until the tap expires.
+func tap(kind string, id string) error {
+	target, err := adminURL("/admin/tap")
+	if err != nil {
+		return err
+	}
+	target.Scheme = strings.Replace(target.Scheme, "http", "ws", 1)
+	query := url.Values{kind: {id}}
+	if *redaction != "" {
+		query.Set("redact", *redaction)
+	}
+	target.RawQuery = query.Encode()
+	conn, response, err := websocket.DefaultDialer.Dial(target.String(), http.Header{"Authorization": {"Bearer " + *token}})
+	if err != nil {
+		if response != nil {
+			body, _ := io.ReadAll(response.Body)
+			return fmt.Errorf("tap %s: %s %s", id, response.Status, strings.TrimSpace(string(body)))
+		}
+		return err
+	}
+	defer conn.Close()
+	for {
+		_, data, err := conn.ReadMessage()
+		if websocket.IsCloseError(err, websocket.CloseNormalClosure) {
+			fmt.Fprintln(os.Stderr, "Tap expired")
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		fmt.Println(strings.TrimSpace(string(data)))
+	}
+}
+
+// testClient is a WebSocket connection to the server.
+type testClient struct {
+	id       string
+	conn     *websocket.Conn
+	messages chan map[string]interface{}
+}
+
+func dial() (*testClient, error) {
+	conn, _, err := websocket.DefaultDialer.Dial(*serverURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	client := &testClient{conn: conn, messages: make(chan map[string]interface{}, 64)}
+	go func() {
+		defer close(client.messages)
+		for {
+			var msg map[string]interface{}
+			if err := conn.ReadJSON(&msg); err != nil {
+				return
+			}
+			client.messages <- msg
+		}
+	}()
+	details, ok := <-client.messages
+	if !ok || details["event"] != "Client_Details" {
+		conn.Close()
+		return nil, errors.New("no Client_Details received")
+	}
+	client.id, _ = details["data"].(map[string]interface{})["id"].(string)
+	return client, nil
+}
+
+func (client *testClient) send(msg map[string]interface{}) error {
+	return client.conn.WriteJSON(msg)
+}
+
+// join joins the room and prints the messages received until interrupted.
+func join(roomId string) error {
+	client, err := dial()
+	if err != nil {
+		return err
+	}
+	defer client.conn.Close()
+	fmt.Fprintln(os.Stderr, "Connected as", client.id)
+
+	event := "Join_Room"
+	if *create {
+		event = "Create_Room"
+	}
+	data := map[string]interface{}{"room": roomId}
+	if *password != "" {
+		data["password"] = *password
+	}
+	if err := client.send(map[string]interface{}{"event": event, "data": data}); err != nil {
+		return err
+	}
+
+	interrupted := make(chan os.Signal, 1)
+	signal.Notify(interrupted, os.Interrupt)
+	for {
+		select {
+		case msg, ok := <-client.messages:
+			if !ok {
+				return errors.New("the server closed the connection")
+			}
+			// an existing room is joined instead
+			if *create && msg["type"] == "error" && msg["event"] == "Duplicate_Room" {
+				event = "Join_Room"
+				if err := client.send(map[string]interface{}{"event": event, "data": data}); err != nil {
+					return err
+				}
+				continue
+			}
+			printMessage(msg)
+		case <-interrupted:
+			return client.send(map[string]interface{}{"event": "Leave_Room", "data": map[string]interface{}{"room": roomId}})
+		}
+	}
+}
+
+// offer sends a test offer to the client and prints the answer or the error.
+func offer(target string) error {
+	sdp := testSDP
+	if *sdpFile != "" {
+		content, err := os.ReadFile(*sdpFile)
+		if err != nil {
+			return err
+		}
+		sdp = string(content)
+	}
+	client, err := dial()
+	if err != nil {
+		return err
+	}
+	defer client.conn.Close()
+	fmt.Fprintln(os.Stderr, "Connected as", client.id)
+
+	err = client.send(map[string]interface{}{"event": "Offer", "to": target, "data": map[string]interface{}{"type": "offer", "sdp": sdp}})
+	if err != nil {
+		return err
+	}
+	deadline := time.After(*timeout)
+	for {
+		select {
+		case msg, ok := <-client.messages:
+			if !ok {
+				return errors.New("the server closed the connection")
+			}
+			printMessage(msg)
+			if msg["type"] == "error" {
+				return fmt.Errorf("offer refused: %v", msg["event"])
+			}
+			if msg["event"] == "Answer" && msg["from"] == target {
+				return nil
+			}
+		case <-deadline:
+			return fmt.Errorf("no answer from %s within %s", target, *timeout)
+		}
+	}
+}
+
+func printMessage(msg map[string]interface{}) {
+	encoded, _ := json.Marshal(msg)
+	fmt.Println(string(encoded))
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/shankarammai/Peer2PeerConnector/internal/recorder"
+)
+
+// replayedSession is a recorded session played back on its own connection.
+type replayedSession struct {
+	header recorder.Header
+	frames []recorder.Frame
+	conn   *websocket.Conn
+	id     string
+}
+
+// replay plays the sessions recorded by RECORD_SESSIONS_DIR back against the
+// server. Each session gets its own connection and sends its recorded frames
+// with the recorded timing, scaled by -speed. The Ids of the recorded clients
+// are replaced by the ones of the replayed clients in the text frames, and
+// the messages received are printed after the Id of the receiving client.
+func replay(paths []string) error {
+	sessions := make([]*replayedSession, 0, len(paths))
+	for _, path := range paths {
+		header, frames, err := recorder.Open(path)
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		sessions = append(sessions, &replayedSession{header: header, frames: frames})
+	}
+	sort.Slice(sessions, func(i, j int) bool { return sessions[i].header.StartedAt.Before(sessions[j].header.StartedAt) })
+
+	var replacements []string
+	for _, session := range sessions {
+		if err := session.dial(); err != nil {
+			return err
+		}
+		defer session.conn.Close()
+		fmt.Fprintf(os.Stderr, "Replaying %s as %s\n", session.header.Client, session.id)
+		replacements = append(replacements, session.header.Client, session.id)
+	}
+	ids := strings.NewReplacer(replacements...)
+
+	start, origin := time.Now(), sessions[0].header.StartedAt
+	var sending sync.WaitGroup
+	failures := make(chan error, len(sessions))
+	for _, session := range sessions {
+		go session.print()
+		sending.Add(1)
+		go func(session *replayedSession) {
+			defer sending.Done()
+			if err := session.send(ids, start, session.header.StartedAt.Sub(origin)); err != nil {
+				failures <- fmt.Errorf("%s: %w", session.id, err)
+			}
+		}(session)
+	}
+	sending.Wait()
+	close(failures)
+	if err := <-failures; err != nil {
+		return err
+	}
+
+	// the last answers are printed until the timeout or an interrupt
+	interrupted := make(chan os.Signal, 1)
+	signal.Notify(interrupted, os.Interrupt)
+	select {
+	case <-time.After(*timeout):
+	case <-interrupted:
+	}
+	return nil
+}
+
+// dial connects with the query and subprotocol of the recording, without the
+// resume token of the recorded client, and reads the Id of the replayed one.
+func (session *replayedSession) dial() error {
+	target, err := url.Parse(*serverURL)
+	if err != nil {
+		return err
+	}
+	query, err := url.ParseQuery(session.header.Query)
+	if err != nil {
+		return err
+	}
+	query.Del("resume")
+	target.RawQuery = query.Encode()
+	dialer := *websocket.DefaultDialer
+	if session.header.Subprotocol != "" {
+		dialer.Subprotocols = []string{session.header.Subprotocol}
+	}
+	session.conn, _, err = dialer.Dial(target.String(), nil)
+	if err != nil {
+		return err
+	}
+	session.id = session.header.Client
+	_, data, err := session.conn.ReadMessage()
+	if err != nil {
+		return err
+	}
+	var details struct {
+		Event string `json:"event"`
+		Data  struct {
+			Id string `json:"id"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(data, &details); err != nil || details.Event != "Client_Details" {
+		fmt.Fprintf(os.Stderr, "No JSON Client_Details for %s, its Id is left as recorded\n", session.header.Client)
+		return nil
+	}
+	session.id = details.Data.Id
+	return nil
+}
+
+// send writes the frames the recorded client sent, each at its time since
+// start, offset by the start of the session.
+func (session *replayedSession) send(ids *strings.Replacer, start time.Time, offset time.Duration) error {
+	for _, frame := range session.frames {
+		if frame.Direction != recorder.In {
+			continue
+		}
+		if *speed > 0 {
+			time.Sleep(time.Until(start.Add(time.Duration(float64(offset+frame.At) / *speed))))
+		}
+		payload, err := frame.Payload()
+		if err != nil {
+			return err
+		}
+		messageType := websocket.BinaryMessage
+		if !frame.Binary {
+			messageType = websocket.TextMessage
+			payload = []byte(ids.Replace(string(payload)))
+		}
+		if err := session.conn.WriteMessage(messageType, payload); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// print prints the messages the replayed client receives.
+func (session *replayedSession) print() {
+	for {
+		messageType, data, err := session.conn.ReadMessage()
+		if err != nil {
+			var closeErr *websocket.CloseError
+			if errors.As(err, &closeErr) {
+				fmt.Fprintf(os.Stderr, "%s closed: %v\n", session.id, closeErr)
+			}
+			return
+		}
+		if messageType == websocket.BinaryMessage {
+			fmt.Printf("%s binary %d bytes\n", session.id, len(data))
+			continue
+		}
+		fmt.Printf("%s %s\n", session.id, data)
+	}
+}
@@ -0,0 +1,10 @@
+// Package docs embeds the documentation served by the server, so the binary
+// doesn't depend on the working directory.
+package docs
+
+import _ "embed"
+
+// Markdown is the documentation page, docs.md.
+//
+//go:embed docs.md
+var Markdown []byte
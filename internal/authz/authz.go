@@ -0,0 +1,47 @@
+// Package authz decides what clients may do, so deployments can enforce their
+// own policies without changing the message handlers.
+package authz
+
+import "errors"
+
+// ErrUnauthenticated is returned when a connection lacks a valid access token.
+var ErrUnauthenticated = errors.New("missing or invalid access token")
+
+// Subject is the client a decision is made for.
+type Subject struct {
+	ClientId string
+	// UserID and Claims come from the access token given when connecting,
+	// they are empty unless the authorizer is an Authenticator.
+	UserID string
+	Claims map[string]interface{}
+}
+
+// Authorizer is consulted by the handlers before acting on a message, on top of
+// their own checks (room membership, creator only actions...). A non nil error
+// denies the action, its message is sent back to the client.
+type Authorizer interface {
+	CanCreateRoom(subject Subject, roomId string) error
+	CanJoinRoom(subject Subject, roomId string) error
+	// CanRelay is consulted for messages sent to other clients. event is empty
+	// for binary frames, roomId is empty for relays not addressed through a room
+	// and target, the receiving client, is empty for broadcasts.
+	CanRelay(subject Subject, event string, roomId string, target string) error
+	CanEndRoom(subject Subject, roomId string) error
+}
+
+// Authenticator is implemented by authorizers deriving the subject from an
+// access token given when connecting. Connections without a valid token are refused.
+type Authenticator interface {
+	Authenticate(token string) (userId string, claims map[string]interface{}, err error)
+}
+
+// AllowAll lets every client do everything the handlers allow, it is the default.
+type AllowAll struct{}
+
+func (AllowAll) CanCreateRoom(Subject, string) error { return nil }
+
+func (AllowAll) CanJoinRoom(Subject, string) error { return nil }
+
+func (AllowAll) CanRelay(Subject, string, string, string) error { return nil }
+
+func (AllowAll) CanEndRoom(Subject, string) error { return nil }
@@ -0,0 +1,90 @@
+package authz
+
+import (
+	"errors"
+	"path"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// RoleHost is the role allowed to create and end rooms.
+const RoleHost = "host"
+
+// JWTClaims authorizes clients from the claims of the HS256 JWT they connect with:
+//
+//	"sub"   the user Id of the client.
+//	"role"  "host" may create and end rooms, other roles may only join.
+//	"rooms" glob patterns of the room Ids the client may create and join,
+//	        e.g. ["team-42-*"], every room when missing.
+//	"relay" false makes a viewer that can join rooms but not send to peers.
+type JWTClaims struct {
+	secret []byte
+	parser *jwt.Parser
+}
+
+// NewJWTClaims returns an authorizer verifying tokens signed with secret, and
+// issued by issuer for audience when they are not empty.
+func NewJWTClaims(secret string, issuer string, audience string) *JWTClaims {
+	options := []jwt.ParserOption{jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}), jwt.WithExpirationRequired()}
+	if issuer != "" {
+		options = append(options, jwt.WithIssuer(issuer))
+	}
+	if audience != "" {
+		options = append(options, jwt.WithAudience(audience))
+	}
+	return &JWTClaims{secret: []byte(secret), parser: jwt.NewParser(options...)}
+}
+
+// Authenticate verifies the token and returns its subject and claims.
+func (authorizer *JWTClaims) Authenticate(token string) (string, map[string]interface{}, error) {
+	claims := jwt.MapClaims{}
+	_, err := authorizer.parser.ParseWithClaims(token, claims, func(*jwt.Token) (interface{}, error) {
+		return authorizer.secret, nil
+	})
+	if err != nil {
+		return "", nil, errors.Join(ErrUnauthenticated, err)
+	}
+	userId, _ := claims.GetSubject()
+	return userId, claims, nil
+}
+
+func (authorizer *JWTClaims) CanCreateRoom(subject Subject, roomId string) error {
+	if subject.Claims["role"] != RoleHost {
+		return errors.New("only hosts can create rooms")
+	}
+	return checkRoomClaim(subject, roomId)
+}
+
+func (authorizer *JWTClaims) CanJoinRoom(subject Subject, roomId string) error {
+	return checkRoomClaim(subject, roomId)
+}
+
+func (authorizer *JWTClaims) CanRelay(subject Subject, event string, roomId string, target string) error {
+	if relay, ok := subject.Claims["relay"].(bool); ok && !relay {
+		return errors.New("viewers can't send messages to peers")
+	}
+	return nil
+}
+
+func (authorizer *JWTClaims) CanEndRoom(subject Subject, roomId string) error {
+	if subject.Claims["role"] != RoleHost {
+		return errors.New("only hosts can end rooms")
+	}
+	return nil
+}
+
+// checkRoomClaim checks roomId against the "rooms" claim of the subject.
+func checkRoomClaim(subject Subject, roomId string) error {
+	patterns, ok := subject.Claims["rooms"].([]interface{})
+	if !ok {
+		return nil
+	}
+	for _, pattern := range patterns {
+		if pattern, ok := pattern.(string); ok {
+			if matched, _ := path.Match(pattern, roomId); matched {
+				return nil
+			}
+		}
+	}
+	return errors.New("your access token doesn't grant access to room " + roomId)
+}
@@ -1,18 +1,369 @@
 package client
 
 import (
+	"errors"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+
 	"github.com/gorilla/websocket"
+	"github.com/shankarammai/Peer2PeerConnector/internal/directory"
+	"github.com/shankarammai/Peer2PeerConnector/internal/geo"
+	"github.com/shankarammai/Peer2PeerConnector/internal/protocol"
+)
+
+// Presence statuses a client can set.
+const (
+	StatusOnline = "online"
+	StatusBusy   = "busy"
+	StatusAway   = "away"
+	// StatusDND makes the server answer calls to the client with "Busy", see BusyPolicy.
+	StatusDND = "dnd"
 )
 
+// Capabilities are the media and codecs a client supports, peers use them to
+// decide how to negotiate before sending an offer.
+type Capabilities struct {
+	Audio       bool     `json:"audio"`
+	Video       bool     `json:"video"`
+	DataChannel bool     `json:"datachannel"`
+	ScreenShare bool     `json:"screen-share"`
+	Codecs      []string `json:"codecs"`
+}
+
+// MediaState is what the client is currently sending, so peers can render its
+// tile: camera on or off, microphone on or muted, screen being shared.
+type MediaState struct {
+	Camera      bool `json:"camera"`
+	Microphone  bool `json:"microphone"`
+	ScreenShare bool `json:"screen_share"`
+}
+
+// BusyPolicy tells when the server answers calls and connection requests to
+// the client with "Busy" rather than relaying them.
+type BusyPolicy struct {
+	// DND is busy while the status is StatusDND.
+	DND bool `json:"dnd"`
+	// InCall is busy while the client takes part in an accepted call.
+	InCall bool `json:"in_call"`
+}
+
+// ErrExpired is returned for a message dropped because its deadline passed
+// before it could be written.
+var ErrExpired = errors.New("message expired before delivery")
+
+// Deadline bounds the delivery of a message that is useless once late, e.g.
+// an ICE candidate relayed with a time to live.
+type Deadline struct {
+	At time.Time
+	// Now is the clock the deadline is checked against.
+	Now func() time.Time
+	// Expired is called when the message is dropped for its deadline.
+	Expired func()
+}
+
+// Passed tells if the deadline has passed, never for a nil deadline.
+func (deadline *Deadline) Passed() bool {
+	return deadline != nil && deadline.Now().After(deadline.At)
+}
+
+// Transport is the connection a client is reached through: a WebSocket
+// connection or one of the HTTP fallback transports.
+type Transport interface {
+	WriteMessage(messageType int, data []byte) error
+	Close() error
+}
+
+// deadlineWriter is implemented by transports queueing messages until the
+// client fetches them, they drop the queued messages whose deadline passed.
+type deadlineWriter interface {
+	WriteMessageBefore(messageType int, data []byte, deadline *Deadline) error
+}
+
+// compressor is implemented by transports supporting per-message compression.
+type compressor interface {
+	EnableWriteCompression(enable bool)
+}
+
 type Client struct {
 	Id         string
-	Connection *websocket.Conn
+	Connection Transport
+	Status     string
+	// Name is the display name given by the client when connecting, it may be empty.
+	Name string
+	// Capabilities are registered by the client with "set_capabilities", nil until then.
+	Capabilities *Capabilities
+	// Media is the media state announced with "media_state", nil until then.
+	Media *MediaState
+	// Busy is set with "set_busy_policy", nil for the default policy.
+	Busy *BusyPolicy
+	// PublicKey is the end-to-end encryption key published with "publish_key".
+	PublicKey string
+	// Tags label the client, e.g. "role:viewer", so it can be addressed by tag.
+	Tags []string
+	// Blocked are the clients whose messages are not relayed to this client.
+	Blocked []string
+	// Typing maps the rooms the client is typing in to when its last typing
+	// indicator was relayed there.
+	Typing map[string]time.Time
+	// LastActive is when the client last sent a message.
+	LastActive time.Time
+	// IdleWarned is set once the client was warned about an idle disconnection.
+	IdleWarned bool
+	// ResumeToken lets the client reclaim its Id and room memberships after a
+	// dropped connection, during the reconnect grace period.
+	ResumeToken string
+	// Kicked is set when the server closed the connection, the session of such
+	// a client can't be resumed.
+	Kicked bool
+
+	// ConnectedAt is when the client connected.
+	ConnectedAt time.Time
+	// UserID and Claims come from the access token verified by the authorizer,
+	// they are empty when it doesn't authenticate clients.
+	UserID string
+	Claims map[string]interface{}
+	// Device tells apart the connections of the same user, empty for clients
+	// that aren't authenticated.
+	Device string
+	// Profile is what the user directory knows about UserID, nil without a
+	// directory or when the lookup failed.
+	Profile *directory.Profile
+	// IP is the address of the client, used to enforce bans.
+	IP string
+	// RemoteAddr, UserAgent and Location are only recorded when client info is enabled.
+	RemoteAddr string
+	UserAgent  string
+	Location   *geo.Location
+	// Codec is the wire format negotiated with the client, JSON when nil.
+	Codec protocol.Codec
+	// Version is the protocol version negotiated with the client, the first one when nil.
+	Version protocol.Version
+	// CompressionThreshold is the size below which messages are sent uncompressed.
+	// It only matters when permessage-deflate was negotiated.
+	CompressionThreshold int
+
+	// QuotaWarned is set once the client was warned about its bandwidth quota.
+	QuotaWarned bool
+	// Challenge is the proof-of-work challenge issued to the client, valid until ChallengeExpires.
+	Challenge        string
+	ChallengeExpires time.Time
+	// Observe, when set, is called with every frame written to the client, e.g. for debug taps.
+	Observe func(client *Client, frameType int, data []byte)
+	// OutboundDepth is the number of queued messages from which low priority
+	// messages are dropped and OnSlowConsumer is called, once until the queue
+	// drains. At OutboundMax queued messages the queue is discarded and
+	// OnOverflow is called. 0 disables either bound, see Start.
+	OutboundDepth  int
+	OutboundMax    int
+	OnSlowConsumer func(client *Client)
+	OnOverflow     func(client *Client)
+	// Acks is set when the client acknowledges the high priority messages it
+	// receives, the server holds them until then to send them again when the
+	// client resumes its session.
+	Acks bool
+
+	// bytesIn and bytesOut count the bytes received from and sent to the client.
+	bytesIn  atomic.Int64
+	bytesOut atomic.Int64
+
+	// writeMu serialises writes, a websocket connection supports a single concurrent writer.
+	writeMu sync.Mutex
+	// outbox queues the messages written by the writer goroutine, nil until Start.
+	outbox *outbox
 }
 
-func (client Client) GetClientId() string {
+func (client *Client) GetClientId() string {
 	return client.Id
 }
 
-func (client Client) GetConnection() *websocket.Conn {
+func (client *Client) GetConnection() Transport {
 	return client.Connection
 }
+
+func (client *Client) GetStatus() string {
+	return client.Status
+}
+
+func (client *Client) SetStatus(status string) {
+	client.Status = status
+}
+
+// GetBusyPolicy returns the busy policy of the client, by default it is busy
+// both in DND and in a call.
+func (client *Client) GetBusyPolicy() BusyPolicy {
+	if client.Busy == nil {
+		return BusyPolicy{DND: true, InCall: true}
+	}
+	return *client.Busy
+}
+
+func (client *Client) SetBusyPolicy(policy BusyPolicy) {
+	client.Busy = &policy
+}
+
+func (client *Client) GetCapabilities() *Capabilities {
+	return client.Capabilities
+}
+
+func (client *Client) SetCapabilities(capabilities *Capabilities) {
+	client.Capabilities = capabilities
+}
+
+func (client *Client) GetMedia() *MediaState {
+	return client.Media
+}
+
+func (client *Client) SetMedia(media *MediaState) {
+	client.Media = media
+}
+
+func (client *Client) GetPublicKey() string {
+	return client.PublicKey
+}
+
+func (client *Client) SetPublicKey(publicKey string) {
+	client.PublicKey = publicKey
+}
+
+func (client *Client) GetTags() []string {
+	return client.Tags
+}
+
+func (client *Client) SetTags(tags []string) {
+	client.Tags = tags
+}
+
+// HasTag tells if the client carries tag.
+func (client *Client) HasTag(tag string) bool {
+	return slices.Contains(client.Tags, tag)
+}
+
+func (client *Client) GetName() string {
+	return client.Name
+}
+
+func (client *Client) GetDevice() string {
+	return client.Device
+}
+
+func (client *Client) GetProfile() *directory.Profile {
+	return client.Profile
+}
+
+func (client *Client) GetBlocked() []string {
+	return client.Blocked
+}
+
+// Block stops relaying the messages of clientId to the client, it returns
+// false if clientId was already blocked.
+func (client *Client) Block(clientId string) bool {
+	if slices.Contains(client.Blocked, clientId) {
+		return false
+	}
+	client.Blocked = append(client.Blocked, clientId)
+	return true
+}
+
+// Unblock relays the messages of clientId to the client again, it returns
+// false if clientId wasn't blocked.
+func (client *Client) Unblock(clientId string) bool {
+	index := slices.Index(client.Blocked, clientId)
+	if index == -1 {
+		return false
+	}
+	client.Blocked = slices.Delete(client.Blocked, index, index+1)
+	return true
+}
+
+// HasBlocked tells if the client blocked clientId.
+func (client *Client) HasBlocked(clientId string) bool {
+	return slices.Contains(client.Blocked, clientId)
+}
+
+func (client *Client) GetLastActive() time.Time {
+	return client.LastActive
+}
+
+// MarkActive records activity of the client at now and clears the idle warning.
+func (client *Client) MarkActive(now time.Time) {
+	client.LastActive = now
+	client.IdleWarned = false
+}
+
+// AddBytesIn records n bytes received from the client and returns the total.
+func (client *Client) AddBytesIn(n int) int64 {
+	return client.bytesIn.Add(int64(n))
+}
+
+func (client *Client) BytesIn() int64 {
+	return client.bytesIn.Load()
+}
+
+func (client *Client) BytesOut() int64 {
+	return client.bytesOut.Load()
+}
+
+func (client *Client) GetCodec() protocol.Codec {
+	if client.Codec == nil {
+		return protocol.JSON
+	}
+	return client.Codec
+}
+
+func (client *Client) GetVersion() protocol.Version {
+	if client.Version == nil {
+		return protocol.V1
+	}
+	return client.Version
+}
+
+// Send encodes v with the codec of the client and writes it to the connection.
+func (client *Client) Send(v interface{}) error {
+	return client.SendAs(v, PriorityNormal, nil)
+}
+
+// SendAs is Send in the outbound lane of priority, for a message dropped
+// rather than delivered after the deadline when there is one, e.g. when the
+// connection is congested. It returns ErrExpired when the deadline passed
+// before the message could be written.
+func (client *Client) SendAs(v interface{}, priority Priority, deadline *Deadline) error {
+	codec := client.GetCodec()
+	data, err := codec.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return client.enqueue(codec.FrameType(), data, priority, deadline)
+}
+
+// SendEncoded sends a message already encoded with the codec of the client,
+// in the outbound lane of priority.
+func (client *Client) SendEncoded(data []byte, priority Priority) error {
+	return client.enqueue(client.GetCodec().FrameType(), data, priority, nil)
+}
+
+// WriteBinary sends data to the client as a binary frame, in the low priority lane.
+func (client *Client) WriteBinary(data []byte) error {
+	return client.enqueue(websocket.BinaryMessage, data, PriorityLow, nil)
+}
+
+func (client *Client) write(frameType int, data []byte, deadline *Deadline) error {
+	client.writeMu.Lock()
+	defer client.writeMu.Unlock()
+	if deadline.Passed() {
+		deadline.Expired()
+		return ErrExpired
+	}
+	if connection, ok := client.Connection.(compressor); ok {
+		connection.EnableWriteCompression(len(data) >= client.CompressionThreshold)
+	}
+	client.bytesOut.Add(int64(len(data)))
+	if client.Observe != nil {
+		client.Observe(client, frameType, data)
+	}
+	if connection, ok := client.Connection.(deadlineWriter); ok && deadline != nil {
+		return connection.WriteMessageBefore(frameType, data, deadline)
+	}
+	return client.Connection.WriteMessage(frameType, data)
+}
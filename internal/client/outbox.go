@@ -0,0 +1,203 @@
+package client
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// Priority is the lane of the outbound queue a message waits in, see Client.Start.
+type Priority int
+
+// Lanes of the outbound queue, the writer empties the higher ones first.
+const (
+	// PriorityLow is for bulk traffic: chat, broadcasts, file chunks.
+	PriorityLow Priority = iota
+	// PriorityNormal is for the responses and updates of the server.
+	PriorityNormal
+	// PriorityHigh is for the signalling setting up calls: offers, answers and candidates.
+	PriorityHigh
+)
+
+// closeTimeout bounds the flush of the outbound queue when the connection closes.
+const closeTimeout = time.Second
+
+var (
+	// ErrClosed is returned for messages sent once the connection is closing.
+	ErrClosed = errors.New("connection closed")
+	// ErrCongested is returned for low priority messages dropped because the
+	// outbound queue is deeper than OutboundDepth.
+	ErrCongested = errors.New("outbound queue congested")
+)
+
+// outboundFrame is a frame waiting in the outbound queue.
+type outboundFrame struct {
+	frameType int
+	data      []byte
+	deadline  *Deadline
+}
+
+// outbox is the outbound queue of a client, one lane per priority.
+type outbox struct {
+	mu     sync.Mutex
+	lanes  [PriorityHigh + 1][]outboundFrame
+	queued int
+	closed bool
+	// discarded is set when the queued frames were dropped on closing.
+	discarded bool
+	// congested is set from when the queue reaches OutboundDepth until it is empty.
+	congested bool
+	// ready is signalled when a frame is queued or the outbox closes.
+	ready chan struct{}
+	// done is closed when the writer returns.
+	done chan struct{}
+}
+
+// Start queues the messages sent to the client and writes them from a
+// goroutine, higher priorities first, so a congested connection delays chat
+// rather than call setup. Without it messages are written by the goroutine
+// sending them. It must be called before the client is shared, Close stops
+// the writer.
+func (client *Client) Start() {
+	client.outbox = &outbox{ready: make(chan struct{}, 1), done: make(chan struct{})}
+	go client.writeQueued()
+}
+
+// Close writes the messages still queued, for up to a second, and closes the connection.
+func (client *Client) Close() error {
+	if box := client.outbox; box != nil && !box.close(false) {
+		select {
+		case <-box.done:
+		case <-time.After(closeTimeout):
+		}
+	}
+	return client.Connection.Close()
+}
+
+// Queued returns the number of messages waiting in the outbound queue.
+func (client *Client) Queued() int {
+	if client.outbox == nil {
+		return 0
+	}
+	client.outbox.mu.Lock()
+	defer client.outbox.mu.Unlock()
+	return client.outbox.queued
+}
+
+// enqueue writes the frame, or queues it in the lane of priority once the
+// client is started. Past OutboundDepth queued messages, low priority ones are
+// dropped and OnSlowConsumer is called. At OutboundMax the queue is discarded
+// and OnOverflow is called.
+func (client *Client) enqueue(frameType int, data []byte, priority Priority, deadline *Deadline) error {
+	box := client.outbox
+	if box == nil {
+		return client.write(frameType, data, deadline)
+	}
+	box.mu.Lock()
+	if box.closed {
+		box.mu.Unlock()
+		return ErrClosed
+	}
+	if client.OutboundMax > 0 && box.queued >= client.OutboundMax {
+		// closed under the same lock, so a single sender sees the overflow
+		box.closeLocked(true)
+		box.mu.Unlock()
+		if client.OnOverflow != nil {
+			client.OnOverflow(client)
+		}
+		return ErrClosed
+	}
+	slow := false
+	if client.OutboundDepth > 0 && box.queued >= client.OutboundDepth {
+		slow = !box.congested
+		box.congested = true
+	}
+	if box.congested && priority == PriorityLow {
+		box.mu.Unlock()
+		client.notifySlow(slow)
+		return ErrCongested
+	}
+	box.lanes[priority] = append(box.lanes[priority], outboundFrame{frameType: frameType, data: data, deadline: deadline})
+	box.queued++
+	box.signal()
+	box.mu.Unlock()
+	client.notifySlow(slow)
+	return nil
+}
+
+// notifySlow calls OnSlowConsumer when the queue just became congested.
+func (client *Client) notifySlow(slow bool) {
+	if slow && client.OnSlowConsumer != nil {
+		client.OnSlowConsumer(client)
+	}
+}
+
+// writeQueued writes the queued frames until the outbox is closed and empty,
+// or the connection fails.
+func (client *Client) writeQueued() {
+	box := client.outbox
+	defer close(box.done)
+	for {
+		frame, ok, closed := box.pop()
+		if closed {
+			return
+		}
+		if !ok {
+			<-box.ready
+			continue
+		}
+		if err := client.write(frame.frameType, frame.data, frame.deadline); err != nil && !errors.Is(err, ErrExpired) {
+			// the connection is broken, the reader cleans the client up
+			box.close(true)
+			return
+		}
+	}
+}
+
+// pop takes the next frame from the highest lane holding one. closed is set
+// once the outbox is closed and empty.
+func (box *outbox) pop() (frame outboundFrame, ok bool, closed bool) {
+	box.mu.Lock()
+	defer box.mu.Unlock()
+	for priority := PriorityHigh; priority >= PriorityLow; priority-- {
+		if lane := box.lanes[priority]; len(lane) > 0 {
+			frame = lane[0]
+			lane[0] = outboundFrame{}
+			box.lanes[priority] = lane[1:]
+			box.queued--
+			if box.queued == 0 {
+				box.congested = false
+			}
+			return frame, true, false
+		}
+	}
+	return frame, false, box.closed
+}
+
+// close refuses new frames, discarding the queued ones when discard is set.
+// It returns true when the queued frames were discarded, now or before.
+func (box *outbox) close(discard bool) bool {
+	box.mu.Lock()
+	defer box.mu.Unlock()
+	return box.closeLocked(discard)
+}
+
+// closeLocked is close with box.mu held.
+func (box *outbox) closeLocked(discard bool) bool {
+	box.closed = true
+	if discard {
+		box.lanes = [PriorityHigh + 1][]outboundFrame{}
+		box.queued = 0
+		box.discarded = true
+	}
+	box.signal()
+	return box.discarded
+}
+
+// signal wakes the writer up. box.mu must be held.
+func (box *outbox) signal() {
+	select {
+	case box.ready <- struct{}{}:
+	default:
+	}
+}
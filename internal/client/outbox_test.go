@@ -0,0 +1,147 @@
+package client
+
+import (
+	"errors"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+// gatedTransport records the frames written to it. The first write blocks
+// until release is closed, so the following frames wait in the outbound queue.
+type gatedTransport struct {
+	entered chan struct{}
+	release chan struct{}
+	mu      sync.Mutex
+	written []string
+}
+
+func newGatedTransport() *gatedTransport {
+	return &gatedTransport{entered: make(chan struct{}), release: make(chan struct{})}
+}
+
+func (transport *gatedTransport) WriteMessage(messageType int, data []byte) error {
+	transport.mu.Lock()
+	first := transport.written == nil
+	transport.written = append(transport.written, string(data))
+	transport.mu.Unlock()
+	if first {
+		close(transport.entered)
+		<-transport.release
+	}
+	return nil
+}
+
+func (transport *gatedTransport) Close() error { return nil }
+
+func (transport *gatedTransport) frames() []string {
+	transport.mu.Lock()
+	defer transport.mu.Unlock()
+	return slices.Clone(transport.written)
+}
+
+// gatedClient starts a client whose writer is blocked on a first frame.
+func gatedClient(t *testing.T, depth int, max int) (*Client, *gatedTransport) {
+	transport := newGatedTransport()
+	client := &Client{Id: "client", Connection: transport, OutboundDepth: depth, OutboundMax: max}
+	client.Start()
+	if err := client.SendEncoded([]byte("gate"), PriorityNormal); err != nil {
+		t.Fatal(err)
+	}
+	<-transport.entered
+	return client, transport
+}
+
+func TestOutboxWritesHigherPrioritiesFirst(t *testing.T) {
+	client, transport := gatedClient(t, 0, 0)
+	for _, frame := range []struct {
+		data     string
+		priority Priority
+	}{
+		{"low 1", PriorityLow},
+		{"normal", PriorityNormal},
+		{"high 1", PriorityHigh},
+		{"low 2", PriorityLow},
+		{"high 2", PriorityHigh},
+	} {
+		if err := client.SendEncoded([]byte(frame.data), frame.priority); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if queued := client.Queued(); queued != 5 {
+		t.Errorf("%d frames queued, want 5", queued)
+	}
+	close(transport.release)
+	client.Close()
+
+	want := []string{"gate", "high 1", "high 2", "normal", "low 1", "low 2"}
+	if got := transport.frames(); !slices.Equal(got, want) {
+		t.Errorf("written %q, want %q", got, want)
+	}
+}
+
+func TestOutboxDropsLowPriorityWhenCongested(t *testing.T) {
+	client, transport := gatedClient(t, 2, 0)
+	slow := 0
+	client.OnSlowConsumer = func(*Client) { slow++ }
+	for _, data := range []string{"low 1", "low 2"} {
+		if err := client.SendEncoded([]byte(data), PriorityLow); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := client.SendEncoded([]byte("low 3"), PriorityLow); !errors.Is(err, ErrCongested) {
+		t.Errorf("low priority frame on a congested queue: %v, want ErrCongested", err)
+	}
+	for _, priority := range []Priority{PriorityNormal, PriorityHigh} {
+		if err := client.SendEncoded([]byte("kept"), priority); err != nil {
+			t.Errorf("priority %d frame on a congested queue: %v", priority, err)
+		}
+	}
+	if err := client.SendEncoded([]byte("low 4"), PriorityLow); !errors.Is(err, ErrCongested) {
+		t.Errorf("low priority frame on a congested queue: %v, want ErrCongested", err)
+	}
+	if slow != 1 {
+		t.Errorf("OnSlowConsumer called %d times, want 1", slow)
+	}
+	close(transport.release)
+	client.Close()
+	if got := transport.frames(); len(got) != 5 || slices.Contains(got, "low 3") || slices.Contains(got, "low 4") {
+		t.Errorf("written %q", got)
+	}
+}
+
+func TestOutboxOverflowDiscardsTheQueue(t *testing.T) {
+	client, transport := gatedClient(t, 0, 2)
+	var overflows atomic.Int32
+	client.OnOverflow = func(*Client) { overflows.Add(1) }
+	for _, data := range []string{"first", "second"} {
+		if err := client.SendEncoded([]byte(data), PriorityNormal); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var senders sync.WaitGroup
+	for range 8 {
+		senders.Add(1)
+		go func() {
+			defer senders.Done()
+			if err := client.SendEncoded([]byte("over"), PriorityHigh); !errors.Is(err, ErrClosed) {
+				t.Errorf("frame past OutboundMax: %v, want ErrClosed", err)
+			}
+		}()
+	}
+	senders.Wait()
+	if count := overflows.Load(); count != 1 {
+		t.Errorf("OnOverflow called %d times, want 1", count)
+	}
+	if queued := client.Queued(); queued != 0 {
+		t.Errorf("%d frames still queued", queued)
+	}
+	close(transport.release)
+	client.Close()
+	if got := transport.frames(); !slices.Equal(got, []string{"gate"}) {
+		t.Errorf("written %q after the overflow, want the gate only", got)
+	}
+}
@@ -0,0 +1,50 @@
+// Package clock abstracts the time the server reads, so expiries, grace periods
+// and rate limits can be tested by moving a fake clock instead of sleeping.
+package clock
+
+import (
+	"time"
+)
+
+// Clock tells the time and schedules work.
+type Clock interface {
+	Now() time.Time
+	// AfterFunc calls f in its own goroutine after d.
+	AfterFunc(d time.Duration, f func()) Timer
+	NewTicker(d time.Duration) Ticker
+}
+
+// Timer is a call scheduled with AfterFunc.
+type Timer interface {
+	// Stop cancels the call, it returns false if the call already happened or was stopped.
+	Stop() bool
+}
+
+// Ticker delivers the time on its channel every period.
+type Ticker interface {
+	Chan() <-chan time.Time
+	Stop()
+}
+
+// Real is the clock of the system.
+type Real struct{}
+
+func (Real) Now() time.Time {
+	return time.Now()
+}
+
+func (Real) AfterFunc(d time.Duration, f func()) Timer {
+	return time.AfterFunc(d, f)
+}
+
+func (Real) NewTicker(d time.Duration) Ticker {
+	return realTicker{time.NewTicker(d)}
+}
+
+type realTicker struct {
+	*time.Ticker
+}
+
+func (ticker realTicker) Chan() <-chan time.Time {
+	return ticker.C
+}
@@ -0,0 +1,123 @@
+package clock
+
+import (
+	"sync"
+	"time"
+)
+
+// Fake is a clock that only moves when told to. Timers and tickers fire while
+// Advance goes past their time, in the order of their deadlines.
+type Fake struct {
+	mu      sync.Mutex
+	now     time.Time
+	waiters []*fakeWaiter
+}
+
+// fakeWaiter is a timer, or a ticker when period is set.
+type fakeWaiter struct {
+	clock  *Fake
+	at     time.Time
+	period time.Duration
+	f      func()
+	c      chan time.Time
+}
+
+// NewFake returns a fake clock set to now.
+func NewFake(now time.Time) *Fake {
+	return &Fake{now: now}
+}
+
+func (clock *Fake) Now() time.Time {
+	clock.mu.Lock()
+	defer clock.mu.Unlock()
+	return clock.now
+}
+
+func (clock *Fake) AfterFunc(d time.Duration, f func()) Timer {
+	return clock.schedule(&fakeWaiter{clock: clock, f: f}, d)
+}
+
+func (clock *Fake) NewTicker(d time.Duration) Ticker {
+	if d <= 0 {
+		panic("clock: non-positive interval for NewTicker")
+	}
+	return fakeTicker{clock.schedule(&fakeWaiter{clock: clock, period: d, c: make(chan time.Time, 1)}, d)}
+}
+
+func (clock *Fake) schedule(waiter *fakeWaiter, d time.Duration) *fakeWaiter {
+	clock.mu.Lock()
+	defer clock.mu.Unlock()
+	waiter.at = clock.now.Add(d)
+	clock.waiters = append(clock.waiters, waiter)
+	return waiter
+}
+
+// Advance moves the clock forward by d, firing the timers and tickers due on
+// the way. Timer functions run before Advance returns, ticks are dropped like
+// with time.Ticker when the previous one wasn't received yet.
+func (clock *Fake) Advance(d time.Duration) {
+	clock.mu.Lock()
+	target := clock.now.Add(d)
+	for {
+		next := -1
+		for index, waiter := range clock.waiters {
+			if !waiter.at.After(target) && (next < 0 || waiter.at.Before(clock.waiters[next].at)) {
+				next = index
+			}
+		}
+		if next < 0 {
+			break
+		}
+		waiter := clock.waiters[next]
+		clock.now = waiter.at
+		if waiter.period > 0 {
+			waiter.at = waiter.at.Add(waiter.period)
+		} else {
+			clock.waiters = append(clock.waiters[:next], clock.waiters[next+1:]...)
+		}
+		now := clock.now
+		clock.mu.Unlock()
+		if waiter.f != nil {
+			waiter.f()
+		} else {
+			select {
+			case waiter.c <- now:
+			default:
+			}
+		}
+		clock.mu.Lock()
+	}
+	clock.now = target
+	clock.mu.Unlock()
+}
+
+// Set moves the clock to now, which must not be earlier than the current time.
+func (clock *Fake) Set(now time.Time) {
+	clock.Advance(now.Sub(clock.Now()))
+}
+
+func (waiter *fakeWaiter) Stop() bool {
+	clock := waiter.clock
+	clock.mu.Lock()
+	defer clock.mu.Unlock()
+	for index, scheduled := range clock.waiters {
+		if scheduled == waiter {
+			clock.waiters = append(clock.waiters[:index], clock.waiters[index+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// fakeTicker is a periodic fakeWaiter.
+type fakeTicker struct {
+	*fakeWaiter
+}
+
+func (ticker fakeTicker) Chan() <-chan time.Time {
+	return ticker.c
+}
+
+func (ticker fakeTicker) Stop() {
+	ticker.fakeWaiter.Stop()
+}
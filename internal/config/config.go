@@ -0,0 +1,784 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Config holds the runtime settings of the server.
+// Every value can be overridden with an environment variable so the
+// binary can be configured the same way locally and inside Docker.
+type Config struct {
+	// LogLevel is the minimum level written to the log (debug, info, warn, error).
+	LogLevel string
+	// LogFormat selects the log output format, either "text" or "json".
+	LogFormat string
+	// LogPayloads adds the payload of the received messages to their debug log entries.
+	LogPayloads bool
+	// LogRedact lists the kinds of fields hidden in the logs, see package redact.
+	LogRedact string
+	// LogMaxFieldLength truncates longer texts in the logs, 0 keeps them whole.
+	LogMaxFieldLength int
+	// LogRequests writes an access log entry for every HTTP request.
+	LogRequests bool
+
+	// WebhookURLs receive a POST for every server event. Empty disables webhooks.
+	WebhookURLs []string
+	// WebhookSecret signs webhook bodies with HMAC-SHA256 when set.
+	WebhookSecret string
+	// WebhookMaxRetries is how many times a failed delivery is retried.
+	WebhookMaxRetries int
+	// WebhookTimeout bounds a single delivery attempt.
+	WebhookTimeout time.Duration
+
+	// StunURLs are the STUN servers handed to clients.
+	StunURLs []string
+	// TurnURLs are the TURN servers handed to clients, they need TurnSecret.
+	TurnURLs []string
+	// TurnSecret is the coturn static-auth-secret used to generate TURN credentials.
+	TurnSecret string
+	// TurnTTL is how long generated TURN credentials stay valid.
+	TurnTTL time.Duration
+
+	// StunServerEnabled starts the built-in STUN listener.
+	StunServerEnabled bool
+	// StunServerAddr is the UDP address of the built-in STUN listener.
+	StunServerAddr string
+
+	// JanitorInterval is how often expired rooms are cleaned up.
+	JanitorInterval time.Duration
+
+	// StorePath is the BoltDB file persistent rooms are stored in. Empty disables persistence.
+	StorePath string
+
+	// HistorySize is how many messages a room with history retains.
+	HistorySize int
+	// HistoryMaxBytes is the total payload size a room with history retains.
+	HistoryMaxBytes int
+
+	// CompressionEnabled negotiates permessage-deflate with clients that support it.
+	CompressionEnabled bool
+	// CompressionLevel is the flate level, from -2 (huffman only) to 9 (best compression).
+	CompressionLevel int
+	// CompressionThreshold is the message size in bytes below which compression is skipped.
+	CompressionThreshold int
+
+	// OutboundQueueDepth is the number of messages queued for a WebSocket client
+	// from which its low priority messages are dropped, 0 means no limit.
+	OutboundQueueDepth int
+	// OutboundQueueMax is the number of queued messages at which the client is
+	// disconnected, 0 means no limit.
+	OutboundQueueMax int
+
+	// MessageWorkers is the number of goroutines handling the messages of the
+	// WebSocket clients.
+	MessageWorkers int
+	// InboundQueueDepth is the number of messages of a WebSocket client waiting
+	// for a worker, past which the client stops being read.
+	InboundQueueDepth int
+	// DedupWindow is how long the "message_id" of a client message is
+	// remembered to drop retransmissions, 0 disables it.
+	DedupWindow time.Duration
+
+	// AckRetention is how long the unacknowledged messages of a dropped client
+	// connected with "acks=true" are held for it to resume, 0 disables
+	// acknowledgements.
+	AckRetention time.Duration
+	// AckMaxPending bounds the unacknowledged messages held per client.
+	AckMaxPending int
+
+	// PollTimeout is the longest a long-poll request waits for messages.
+	PollTimeout time.Duration
+	// PollSessionTimeout disconnects HTTP clients that stopped polling for that long.
+	PollSessionTimeout time.Duration
+	// PollQueueSize is how many messages are buffered for an HTTP client.
+	PollQueueSize int
+
+	// MeshMaxPeers is the largest room for which a full mesh is recommended.
+	MeshMaxPeers int
+	// CandidateDebounce coalesces the candidates sent to the same peer within that
+	// window into a single "Candidates" message, 0 relays every candidate at once.
+	CandidateDebounce time.Duration
+
+	// SDPValidation checks the SDP of offers and answers before relaying them.
+	SDPValidation bool
+	// SDPMaxSize is the largest accepted SDP in bytes when validating.
+	SDPMaxSize int
+	// SDPCodecs is the codec allow-list when validating, empty allows all codecs.
+	SDPCodecs []string
+
+	// AdminToken is the bearer token of the admin API and metrics, empty disables them.
+	AdminToken string
+
+	// MaxRooms caps the number of rooms on the server, 0 means no limit.
+	MaxRooms int
+	// MaxRoomsPerClient caps the rooms a client is a member of, 0 means no limit.
+	MaxRoomsPerClient int
+
+	// IdleTimeout disconnects clients that sent no message for that long, 0 disables it.
+	IdleTimeout time.Duration
+	// IdleWarning is how long before the idle disconnection clients are warned.
+	IdleWarning time.Duration
+
+	// ReconnectGrace keeps the room memberships of a dropped client for that long
+	// so it can resume them, 0 removes the client from its rooms at once.
+	ReconnectGrace time.Duration
+
+	// TrustedProxies are the CIDR ranges of the reverse proxies whose forwarding headers are trusted.
+	TrustedProxies []string
+	// ClientInfoEnabled records the address and user agent of clients for the admin API.
+	ClientInfoEnabled bool
+	// GeoIPDatabase is the path of a MaxMind City database used to locate clients.
+	GeoIPDatabase string
+
+	// AllowedOrigins are the origins allowed to open a WebSocket connection,
+	// "self" stands for the origin of the server. Empty allows any origin.
+	AllowedOrigins []string
+	// CORSOrigins are the origins whose pages may call the HTTP endpoints,
+	// "self" stands for the origin of the server and "*" for any. Empty disables CORS.
+	CORSOrigins []string
+	// CORSMethods are the methods allowed in cross-origin requests.
+	CORSMethods []string
+	// CORSHeaders are the request headers allowed in cross-origin requests.
+	CORSHeaders []string
+	// CORSAdmin lets the CORSOrigins call the admin API too.
+	CORSAdmin bool
+
+	// DrainEndpoints are the servers clients are sent to when the node is drained.
+	DrainEndpoints []string
+	// DrainTimeout is how long the clients sent away by a drain have to leave
+	// before their connections are closed, zero keeps them open.
+	DrainTimeout time.Duration
+
+	// EventStreamURL is the NATS server server activity is published to, empty disables it.
+	EventStreamURL string
+	// EventStreamSubject prefixes the subjects of the published events.
+	EventStreamSubject string
+	// EventStreamInterval is how often relay counts are published.
+	EventStreamInterval time.Duration
+
+	// Bandwidth quotas in bytes, 0 means no quota. Clients and rooms going over
+	// the soft quota are warned, over the hard quota they are disconnected or ended.
+	ClientQuotaSoft int64
+	ClientQuotaHard int64
+	RoomQuotaSoft   int64
+	RoomQuotaHard   int64
+
+	// ClientIdFormat is the generator of client Ids: shortuuid, ulid or numeric.
+	ClientIdFormat string
+	// CustomClientIds lets clients ask for their Id or an Id prefix when connecting.
+	CustomClientIds bool
+
+	// TypingInterval is the shortest time between two typing indicators of a client in a room.
+	TypingInterval time.Duration
+	// CallTimeout cancels the call invitations nobody answered for that long.
+	CallTimeout time.Duration
+	// MissedCallsLimit is how many missed calls are kept for an offline user
+	// until the user connects, 0 disables them.
+	MissedCallsLimit int
+	// PushWebhookURL receives the push notifications of offline users, e.g. of
+	// their missed calls.
+	PushWebhookURL string
+
+	// FileRelayEnabled lets clients relay file chunks through the server when
+	// their data channel fails.
+	FileRelayEnabled bool
+	// FileRelayMaxSize is the largest file relayed, in bytes.
+	FileRelayMaxSize int
+	// FileRelayChunkSize is the largest chunk relayed, in bytes.
+	FileRelayChunkSize int
+	// FileRelayRate is the bandwidth of the chunks a client relays, in bytes per second.
+	FileRelayRate int
+
+	// RoomTemplatesFile is a JSON file of named room settings "create_room" can refer to.
+	RoomTemplatesFile string
+
+	// JoinURL is the deep link opening the application on a room, "{code}" is
+	// replaced by the join code of the room.
+	JoinURL string
+
+	// RoomCallbackHosts are the hosts rooms may post their events to with a
+	// "callback_url", empty disables room callbacks.
+	RoomCallbackHosts []string
+
+	// Authorizer is the policy deciding what clients may do: allow_all or jwt.
+	Authorizer string
+	// JWTSecret verifies the HS256 access tokens of the jwt authorizer.
+	JWTSecret string
+	// JWTIssuer and JWTAudience are the expected "iss" and "aud" of the tokens, unchecked when empty.
+	JWTIssuer   string
+	JWTAudience string
+	// DuplicateConnections is what happens when an authenticated user connects
+	// while already connected: devices, reject or kick.
+	DuplicateConnections string
+
+	// OIDCIssuer enables the OpenID Connect login of operators to the admin API and the docs.
+	OIDCIssuer       string
+	OIDCClientID     string
+	OIDCClientSecret string
+	// OIDCRedirectURL is the callback URL registered with the provider, ending in /auth/callback.
+	OIDCRedirectURL string
+	// OIDCGroupsClaim is the ID token claim listing the groups of the operator.
+	OIDCGroupsClaim string
+	// OIDCAdminGroups may use the whole admin API, OIDCViewerGroups only read it.
+	OIDCAdminGroups  []string
+	OIDCViewerGroups []string
+	// OIDCProtectDocs requires a login to read the docs.
+	OIDCProtectDocs bool
+	// OIDCSessionSecret signs the session cookies, random when empty.
+	OIDCSessionSecret string
+	OIDCSessionTTL    time.Duration
+
+	// TLSDomains get certificates from Let's Encrypt, cached in TLSCacheDir.
+	TLSDomains  []string
+	TLSCacheDir string
+	// TLSEmail is the contact address given to Let's Encrypt, optional.
+	TLSEmail string
+	// TLSCertFile and TLSKeyFile serve HTTPS with a certificate of their own.
+	TLSCertFile string
+	TLSKeyFile  string
+	// TLSAddr is the HTTPS address, TLSRedirectAddr the plain HTTP address
+	// redirecting to it and answering the ACME challenges, "off" to disable it.
+	TLSAddr         string
+	TLSRedirectAddr string
+	// HTTPAddr is the plain HTTP address when TLS is not configured.
+	HTTPAddr string
+	// AdminAddr and MetricsAddr serve the admin API and the metrics on
+	// listeners of their own, empty serves them with the WebSocket endpoint.
+	AdminAddr   string
+	MetricsAddr string
+
+	// HTTPReadHeaderTimeout bounds the time to send the request headers, against slowloris attacks.
+	HTTPReadHeaderTimeout time.Duration
+	// HTTPIdleTimeout closes keep-alive connections waiting for a next request.
+	HTTPIdleTimeout time.Duration
+	// HTTPMaxHeaderBytes is the largest size of the request headers.
+	HTTPMaxHeaderBytes int
+	// HTTP2Enabled offers HTTP/2 on TLS connections.
+	HTTP2Enabled bool
+
+	// MaxConnections caps the open WebSocket connections, 0 means no limit.
+	MaxConnections int
+	// ConnectionRetryAfter is the Retry-After hint of connections refused over the cap.
+	ConnectionRetryAfter time.Duration
+	// CapacityThresholds are the percentages of MaxConnections whose crossing
+	// is announced with the capacity events, for autoscaling.
+	CapacityThresholds []int
+
+	// PowDifficulty is the number of leading zero bits of the proof-of-work
+	// anonymous clients solve to create a room, 0 disables it.
+	PowDifficulty int
+	// PowTTL is how long a challenge can be solved.
+	PowTTL time.Duration
+
+	// DemoEnabled serves the demo video call at /demo.
+	DemoEnabled bool
+
+	// DebugConsole is the Unix socket path or loopback address of the debug
+	// console, disabled when empty.
+	DebugConsole string
+
+	// DiagnosticsAddr is the loopback address serving the pprof profiles and
+	// the runtime summary without authentication, disabled when empty.
+	DiagnosticsAddr string
+
+	// DirectoryURL looks the user Id of authenticated clients up in a user
+	// directory, "{id}" is replaced by the Id. Disabled when empty.
+	DirectoryURL string
+	// DirectoryToken is sent as a bearer token to the directory.
+	DirectoryToken string
+	// DirectoryTimeout bounds a directory lookup, connections wait for it.
+	DirectoryTimeout time.Duration
+
+	// TurnRegionURLs are the TURN servers recommended to the members of rooms
+	// in a region, as "<region>=<url> <url>..." entries.
+	TurnRegionURLs []string
+
+	// FakeClock runs the server on a fake clock moved through the admin API,
+	// for end-to-end tests of expiries. Never set it in production.
+	FakeClock bool
+
+	// RecordSessionsDir is where the frames of every WebSocket connection are
+	// recorded, for p2pctl replay. Recording is off when it is empty.
+	RecordSessionsDir string
+
+	// RoomArchiveRetention keeps deleted rooms that long for the admin API,
+	// in the store when persistence is enabled. 0 disables the archive.
+	RoomArchiveRetention time.Duration
+}
+
+// Default returns the configuration used when no environment variables are set.
+func Default() *Config {
+	return &Config{
+		LogLevel:              "debug",
+		LogFormat:             "text",
+		LogRedact:             "sdp,candidates,secrets,content",
+		LogMaxFieldLength:     256,
+		LogRequests:           true,
+		WebhookMaxRetries:     3,
+		WebhookTimeout:        5 * time.Second,
+		TurnTTL:               24 * time.Hour,
+		StunServerAddr:        ":3478",
+		JanitorInterval:       10 * time.Second,
+		HistorySize:           50,
+		HistoryMaxBytes:       64 * 1024,
+		CompressionLevel:      1,
+		CompressionThreshold:  1024,
+		OutboundQueueDepth:    256,
+		OutboundQueueMax:      1024,
+		MessageWorkers:        256,
+		InboundQueueDepth:     64,
+		DedupWindow:           30 * time.Second,
+		AckRetention:          2 * time.Minute,
+		AckMaxPending:         256,
+		PollTimeout:           25 * time.Second,
+		PollSessionTimeout:    60 * time.Second,
+		PollQueueSize:         256,
+		MeshMaxPeers:          4,
+		SDPMaxSize:            65536,
+		IdleWarning:           time.Minute,
+		EventStreamSubject:    "p2p.events",
+		EventStreamInterval:   time.Minute,
+		ClientIdFormat:        "shortuuid",
+		TypingInterval:        2 * time.Second,
+		CallTimeout:           30 * time.Second,
+		MissedCallsLimit:      20,
+		FileRelayMaxSize:      64 << 20,
+		FileRelayChunkSize:    64 << 10,
+		FileRelayRate:         1 << 20,
+		Authorizer:            "allow_all",
+		DuplicateConnections:  "devices",
+		OIDCGroupsClaim:       "groups",
+		OIDCSessionTTL:        8 * time.Hour,
+		TLSCacheDir:           "certs",
+		TLSAddr:               ":443",
+		TLSRedirectAddr:       ":80",
+		HTTPAddr:              ":8080",
+		HTTPReadHeaderTimeout: 10 * time.Second,
+		HTTPIdleTimeout:       2 * time.Minute,
+		HTTPMaxHeaderBytes:    64 << 10,
+		HTTP2Enabled:          true,
+		ConnectionRetryAfter:  5 * time.Second,
+		CapacityThresholds:    []int{80},
+		CORSMethods:           []string{"GET", "POST", "DELETE"},
+		CORSHeaders:           []string{"Authorization", "Content-Type"},
+		DrainTimeout:          time.Minute,
+		PowTTL:                2 * time.Minute,
+		DirectoryTimeout:      2 * time.Second,
+	}
+}
+
+// Load builds the configuration from the defaults, the environment and
+// CONFIG_FILE, whose settings take precedence over the environment.
+// It fails if a variable is set to a value that cannot be parsed.
+func Load() (*Config, error) {
+	loadMu.Lock()
+	defer loadMu.Unlock()
+	var err error
+	if fileValues, err = readConfigFile(os.Getenv("CONFIG_FILE")); err != nil {
+		return nil, err
+	}
+	cfg := Default()
+	cfg.LogLevel = getEnv("LOG_LEVEL", cfg.LogLevel)
+	cfg.LogFormat = getEnv("LOG_FORMAT", cfg.LogFormat)
+	if cfg.LogPayloads, err = getEnvBool("LOG_PAYLOADS", cfg.LogPayloads); err != nil {
+		return nil, err
+	}
+	cfg.LogRedact = getEnv("LOG_REDACT", cfg.LogRedact)
+	if cfg.LogMaxFieldLength, err = getEnvInt("LOG_MAX_FIELD_LENGTH", cfg.LogMaxFieldLength); err != nil {
+		return nil, err
+	}
+	if cfg.LogRequests, err = getEnvBool("LOG_REQUESTS", cfg.LogRequests); err != nil {
+		return nil, err
+	}
+
+	cfg.WebhookURLs = getEnvList("WEBHOOK_URLS", cfg.WebhookURLs)
+	cfg.WebhookSecret = getEnv("WEBHOOK_SECRET", cfg.WebhookSecret)
+	if cfg.WebhookMaxRetries, err = getEnvInt("WEBHOOK_MAX_RETRIES", cfg.WebhookMaxRetries); err != nil {
+		return nil, err
+	}
+	if cfg.WebhookTimeout, err = getEnvDuration("WEBHOOK_TIMEOUT", cfg.WebhookTimeout); err != nil {
+		return nil, err
+	}
+
+	cfg.StunURLs = getEnvList("STUN_URLS", cfg.StunURLs)
+	cfg.TurnURLs = getEnvList("TURN_URLS", cfg.TurnURLs)
+	cfg.TurnSecret = getEnv("TURN_SECRET", cfg.TurnSecret)
+	if cfg.TurnTTL, err = getEnvDuration("TURN_TTL", cfg.TurnTTL); err != nil {
+		return nil, err
+	}
+
+	if cfg.StunServerEnabled, err = getEnvBool("STUN_SERVER_ENABLED", cfg.StunServerEnabled); err != nil {
+		return nil, err
+	}
+	cfg.StunServerAddr = getEnv("STUN_SERVER_ADDR", cfg.StunServerAddr)
+
+	if cfg.JanitorInterval, err = getEnvDuration("JANITOR_INTERVAL", cfg.JanitorInterval); err != nil {
+		return nil, err
+	}
+
+	cfg.StorePath = getEnv("STORE_PATH", cfg.StorePath)
+
+	if cfg.HistorySize, err = getEnvInt("HISTORY_SIZE", cfg.HistorySize); err != nil {
+		return nil, err
+	}
+	if cfg.HistoryMaxBytes, err = getEnvInt("HISTORY_MAX_BYTES", cfg.HistoryMaxBytes); err != nil {
+		return nil, err
+	}
+
+	if cfg.CompressionEnabled, err = getEnvBool("COMPRESSION_ENABLED", cfg.CompressionEnabled); err != nil {
+		return nil, err
+	}
+	if cfg.CompressionLevel, err = getEnvInt("COMPRESSION_LEVEL", cfg.CompressionLevel); err != nil {
+		return nil, err
+	}
+	if cfg.CompressionThreshold, err = getEnvInt("COMPRESSION_THRESHOLD", cfg.CompressionThreshold); err != nil {
+		return nil, err
+	}
+	if cfg.OutboundQueueDepth, err = getEnvInt("OUTBOUND_QUEUE_DEPTH", cfg.OutboundQueueDepth); err != nil {
+		return nil, err
+	}
+	if cfg.OutboundQueueMax, err = getEnvInt("OUTBOUND_QUEUE_MAX", cfg.OutboundQueueMax); err != nil {
+		return nil, err
+	}
+	if cfg.MessageWorkers, err = getEnvInt("MESSAGE_WORKERS", cfg.MessageWorkers); err != nil {
+		return nil, err
+	}
+	if cfg.InboundQueueDepth, err = getEnvInt("INBOUND_QUEUE_DEPTH", cfg.InboundQueueDepth); err != nil {
+		return nil, err
+	}
+	if cfg.DedupWindow, err = getEnvDuration("DEDUP_WINDOW", cfg.DedupWindow); err != nil {
+		return nil, err
+	}
+	if cfg.AckRetention, err = getEnvDuration("ACK_RETENTION", cfg.AckRetention); err != nil {
+		return nil, err
+	}
+	if cfg.AckMaxPending, err = getEnvInt("ACK_MAX_PENDING", cfg.AckMaxPending); err != nil {
+		return nil, err
+	}
+	if cfg.AckMaxPending < 0 {
+		return nil, fmt.Errorf("ACK_MAX_PENDING: %d is negative", cfg.AckMaxPending)
+	}
+
+	if cfg.PollTimeout, err = getEnvDuration("POLL_TIMEOUT", cfg.PollTimeout); err != nil {
+		return nil, err
+	}
+	if cfg.PollSessionTimeout, err = getEnvDuration("POLL_SESSION_TIMEOUT", cfg.PollSessionTimeout); err != nil {
+		return nil, err
+	}
+	if cfg.PollQueueSize, err = getEnvInt("POLL_QUEUE_SIZE", cfg.PollQueueSize); err != nil {
+		return nil, err
+	}
+
+	if cfg.MeshMaxPeers, err = getEnvInt("MESH_MAX_PEERS", cfg.MeshMaxPeers); err != nil {
+		return nil, err
+	}
+	if cfg.CandidateDebounce, err = getEnvDuration("CANDIDATE_DEBOUNCE", cfg.CandidateDebounce); err != nil {
+		return nil, err
+	}
+
+	if cfg.SDPValidation, err = getEnvBool("SDP_VALIDATION", cfg.SDPValidation); err != nil {
+		return nil, err
+	}
+	if cfg.SDPMaxSize, err = getEnvInt("SDP_MAX_SIZE", cfg.SDPMaxSize); err != nil {
+		return nil, err
+	}
+	cfg.SDPCodecs = getEnvList("SDP_CODECS", cfg.SDPCodecs)
+
+	cfg.AdminToken = getEnv("ADMIN_TOKEN", cfg.AdminToken)
+
+	if cfg.MaxRooms, err = getEnvInt("MAX_ROOMS", cfg.MaxRooms); err != nil {
+		return nil, err
+	}
+	if cfg.MaxRoomsPerClient, err = getEnvInt("MAX_ROOMS_PER_CLIENT", cfg.MaxRoomsPerClient); err != nil {
+		return nil, err
+	}
+
+	if cfg.IdleTimeout, err = getEnvDuration("IDLE_TIMEOUT", cfg.IdleTimeout); err != nil {
+		return nil, err
+	}
+	if cfg.IdleWarning, err = getEnvDuration("IDLE_WARNING", cfg.IdleWarning); err != nil {
+		return nil, err
+	}
+
+	if cfg.ReconnectGrace, err = getEnvDuration("RECONNECT_GRACE", cfg.ReconnectGrace); err != nil {
+		return nil, err
+	}
+
+	cfg.TrustedProxies = getEnvList("TRUSTED_PROXIES", cfg.TrustedProxies)
+	if cfg.ClientInfoEnabled, err = getEnvBool("CLIENT_INFO_ENABLED", cfg.ClientInfoEnabled); err != nil {
+		return nil, err
+	}
+	cfg.GeoIPDatabase = getEnv("GEOIP_DATABASE", cfg.GeoIPDatabase)
+	cfg.AllowedOrigins = getEnvList("ALLOWED_ORIGINS", cfg.AllowedOrigins)
+	cfg.CORSOrigins = getEnvList("CORS_ORIGINS", cfg.CORSOrigins)
+	cfg.CORSMethods = getEnvList("CORS_METHODS", cfg.CORSMethods)
+	cfg.CORSHeaders = getEnvList("CORS_HEADERS", cfg.CORSHeaders)
+	if cfg.CORSAdmin, err = getEnvBool("CORS_ADMIN", cfg.CORSAdmin); err != nil {
+		return nil, err
+	}
+	cfg.DrainEndpoints = getEnvList("DRAIN_ENDPOINTS", cfg.DrainEndpoints)
+	if cfg.DrainTimeout, err = getEnvDuration("DRAIN_TIMEOUT", cfg.DrainTimeout); err != nil {
+		return nil, err
+	}
+
+	cfg.EventStreamURL = getEnv("EVENT_STREAM_URL", cfg.EventStreamURL)
+	cfg.EventStreamSubject = getEnv("EVENT_STREAM_SUBJECT", cfg.EventStreamSubject)
+	if cfg.EventStreamInterval, err = getEnvDuration("EVENT_STREAM_INTERVAL", cfg.EventStreamInterval); err != nil {
+		return nil, err
+	}
+
+	quotas := map[string]*int64{
+		"CLIENT_QUOTA_SOFT": &cfg.ClientQuotaSoft,
+		"CLIENT_QUOTA_HARD": &cfg.ClientQuotaHard,
+		"ROOM_QUOTA_SOFT":   &cfg.RoomQuotaSoft,
+		"ROOM_QUOTA_HARD":   &cfg.RoomQuotaHard,
+	}
+	for key, quota := range quotas {
+		value, err := getEnvInt(key, int(*quota))
+		if err != nil {
+			return nil, err
+		}
+		*quota = int64(value)
+	}
+
+	cfg.ClientIdFormat = getEnv("CLIENT_ID_FORMAT", cfg.ClientIdFormat)
+	if cfg.CustomClientIds, err = getEnvBool("CUSTOM_CLIENT_IDS", cfg.CustomClientIds); err != nil {
+		return nil, err
+	}
+	if cfg.TypingInterval, err = getEnvDuration("TYPING_INTERVAL", cfg.TypingInterval); err != nil {
+		return nil, err
+	}
+	if cfg.CallTimeout, err = getEnvDuration("CALL_TIMEOUT", cfg.CallTimeout); err != nil {
+		return nil, err
+	}
+	if cfg.CallTimeout <= 0 {
+		// a call would be cancelled before it rings
+		return nil, fmt.Errorf("CALL_TIMEOUT: %s is not a positive duration", cfg.CallTimeout)
+	}
+	if cfg.MissedCallsLimit, err = getEnvInt("MISSED_CALLS_LIMIT", cfg.MissedCallsLimit); err != nil {
+		return nil, err
+	}
+	cfg.PushWebhookURL = getEnv("PUSH_WEBHOOK_URL", cfg.PushWebhookURL)
+
+	if cfg.FileRelayEnabled, err = getEnvBool("FILE_RELAY_ENABLED", cfg.FileRelayEnabled); err != nil {
+		return nil, err
+	}
+	if cfg.FileRelayMaxSize, err = getEnvInt("FILE_RELAY_MAX_SIZE", cfg.FileRelayMaxSize); err != nil {
+		return nil, err
+	}
+	if cfg.FileRelayChunkSize, err = getEnvInt("FILE_RELAY_CHUNK_SIZE", cfg.FileRelayChunkSize); err != nil {
+		return nil, err
+	}
+	if cfg.FileRelayRate, err = getEnvInt("FILE_RELAY_RATE", cfg.FileRelayRate); err != nil {
+		return nil, err
+	}
+
+	cfg.RoomTemplatesFile = getEnv("ROOM_TEMPLATES_FILE", cfg.RoomTemplatesFile)
+	cfg.JoinURL = getEnv("JOIN_URL", cfg.JoinURL)
+	cfg.RoomCallbackHosts = getEnvList("ROOM_CALLBACK_HOSTS", cfg.RoomCallbackHosts)
+
+	cfg.Authorizer = getEnv("AUTHORIZER", cfg.Authorizer)
+	cfg.JWTSecret = getEnv("JWT_SECRET", cfg.JWTSecret)
+	cfg.JWTIssuer = getEnv("JWT_ISSUER", cfg.JWTIssuer)
+	cfg.JWTAudience = getEnv("JWT_AUDIENCE", cfg.JWTAudience)
+	cfg.DuplicateConnections = getEnv("DUPLICATE_CONNECTIONS", cfg.DuplicateConnections)
+
+	cfg.OIDCIssuer = getEnv("OIDC_ISSUER", cfg.OIDCIssuer)
+	cfg.OIDCClientID = getEnv("OIDC_CLIENT_ID", cfg.OIDCClientID)
+	cfg.OIDCClientSecret = getEnv("OIDC_CLIENT_SECRET", cfg.OIDCClientSecret)
+	cfg.OIDCRedirectURL = getEnv("OIDC_REDIRECT_URL", cfg.OIDCRedirectURL)
+	cfg.OIDCGroupsClaim = getEnv("OIDC_GROUPS_CLAIM", cfg.OIDCGroupsClaim)
+	cfg.OIDCAdminGroups = getEnvList("OIDC_ADMIN_GROUPS", cfg.OIDCAdminGroups)
+	cfg.OIDCViewerGroups = getEnvList("OIDC_VIEWER_GROUPS", cfg.OIDCViewerGroups)
+	if cfg.OIDCProtectDocs, err = getEnvBool("OIDC_PROTECT_DOCS", cfg.OIDCProtectDocs); err != nil {
+		return nil, err
+	}
+	cfg.OIDCSessionSecret = getEnv("OIDC_SESSION_SECRET", cfg.OIDCSessionSecret)
+	if cfg.OIDCSessionTTL, err = getEnvDuration("OIDC_SESSION_TTL", cfg.OIDCSessionTTL); err != nil {
+		return nil, err
+	}
+
+	cfg.TLSDomains = getEnvList("TLS_DOMAINS", cfg.TLSDomains)
+	cfg.TLSCacheDir = getEnv("TLS_CACHE_DIR", cfg.TLSCacheDir)
+	cfg.TLSEmail = getEnv("TLS_EMAIL", cfg.TLSEmail)
+	cfg.TLSCertFile = getEnv("TLS_CERT_FILE", cfg.TLSCertFile)
+	cfg.TLSKeyFile = getEnv("TLS_KEY_FILE", cfg.TLSKeyFile)
+	cfg.TLSAddr = getEnv("TLS_ADDR", cfg.TLSAddr)
+	cfg.TLSRedirectAddr = getEnv("TLS_REDIRECT_ADDR", cfg.TLSRedirectAddr)
+	cfg.HTTPAddr = getEnv("HTTP_ADDR", cfg.HTTPAddr)
+	cfg.AdminAddr = getEnv("ADMIN_ADDR", cfg.AdminAddr)
+	cfg.MetricsAddr = getEnv("METRICS_ADDR", cfg.MetricsAddr)
+
+	if cfg.HTTPReadHeaderTimeout, err = getEnvDuration("HTTP_READ_HEADER_TIMEOUT", cfg.HTTPReadHeaderTimeout); err != nil {
+		return nil, err
+	}
+	if cfg.HTTPIdleTimeout, err = getEnvDuration("HTTP_IDLE_TIMEOUT", cfg.HTTPIdleTimeout); err != nil {
+		return nil, err
+	}
+	if cfg.HTTPMaxHeaderBytes, err = getEnvInt("HTTP_MAX_HEADER_BYTES", cfg.HTTPMaxHeaderBytes); err != nil {
+		return nil, err
+	}
+	if cfg.HTTP2Enabled, err = getEnvBool("HTTP2_ENABLED", cfg.HTTP2Enabled); err != nil {
+		return nil, err
+	}
+
+	if cfg.MaxConnections, err = getEnvInt("MAX_CONNECTIONS", cfg.MaxConnections); err != nil {
+		return nil, err
+	}
+	if cfg.ConnectionRetryAfter, err = getEnvDuration("CONNECTION_RETRY_AFTER", cfg.ConnectionRetryAfter); err != nil {
+		return nil, err
+	}
+	if cfg.CapacityThresholds, err = getEnvIntList("CAPACITY_THRESHOLDS", cfg.CapacityThresholds); err != nil {
+		return nil, err
+	}
+
+	if cfg.PowDifficulty, err = getEnvInt("POW_DIFFICULTY", cfg.PowDifficulty); err != nil {
+		return nil, err
+	}
+	if cfg.PowTTL, err = getEnvDuration("POW_TTL", cfg.PowTTL); err != nil {
+		return nil, err
+	}
+
+	if cfg.DemoEnabled, err = getEnvBool("DEMO_ENABLED", cfg.DemoEnabled); err != nil {
+		return nil, err
+	}
+
+	cfg.DebugConsole = getEnv("DEBUG_CONSOLE", cfg.DebugConsole)
+	cfg.DiagnosticsAddr = getEnv("DIAGNOSTICS_ADDR", cfg.DiagnosticsAddr)
+
+	cfg.DirectoryURL = getEnv("DIRECTORY_URL", cfg.DirectoryURL)
+	cfg.DirectoryToken = getEnv("DIRECTORY_TOKEN", cfg.DirectoryToken)
+	if cfg.DirectoryTimeout, err = getEnvDuration("DIRECTORY_TIMEOUT", cfg.DirectoryTimeout); err != nil {
+		return nil, err
+	}
+
+	cfg.TurnRegionURLs = getEnvList("TURN_REGION_URLS", cfg.TurnRegionURLs)
+	if cfg.FakeClock, err = getEnvBool("FAKE_CLOCK", cfg.FakeClock); err != nil {
+		return nil, err
+	}
+	cfg.RecordSessionsDir = getEnv("RECORD_SESSIONS_DIR", cfg.RecordSessionsDir)
+	if cfg.RoomArchiveRetention, err = getEnvDuration("ROOM_ARCHIVE_RETENTION", cfg.RoomArchiveRetention); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+var (
+	// fileValues are the settings of CONFIG_FILE while Load runs.
+	fileValues map[string]string
+	loadMu     sync.Mutex
+)
+
+// readConfigFile reads the KEY=VALUE lines of the file at path, skipping empty
+// lines and "#" comments. An empty path reads nothing.
+func readConfigFile(path string) (map[string]string, error) {
+	if path == "" {
+		return nil, nil
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("CONFIG_FILE: %w", err)
+	}
+	values := make(map[string]string)
+	for number, line := range strings.Split(string(content), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		key, value, ok := strings.Cut(line, "=")
+		if !ok || strings.TrimSpace(key) == "" {
+			return nil, fmt.Errorf("CONFIG_FILE: line %d is not KEY=VALUE", number+1)
+		}
+		values[strings.TrimSpace(key)] = value
+	}
+	return values, nil
+}
+
+// getEnv returns the trimmed value of key in CONFIG_FILE or in the environment,
+// or fallback if it is unset.
+func getEnv(key string, fallback string) string {
+	value, ok := fileValues[key]
+	if !ok {
+		value, ok = os.LookupEnv(key)
+	}
+	if !ok || strings.TrimSpace(value) == "" {
+		return fallback
+	}
+	return strings.TrimSpace(value)
+}
+
+// getEnvList splits a comma separated environment variable, dropping empty items.
+func getEnvList(key string, fallback []string) []string {
+	value := getEnv(key, "")
+	if value == "" {
+		return fallback
+	}
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// getEnvIntList parses a comma separated list of integers.
+func getEnvIntList(key string, fallback []int) ([]int, error) {
+	items := getEnvList(key, nil)
+	if items == nil {
+		return fallback, nil
+	}
+	parsed := make([]int, len(items))
+	for i, item := range items {
+		value, err := strconv.Atoi(item)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %q is not a valid integer", key, item)
+		}
+		parsed[i] = value
+	}
+	return parsed, nil
+}
+
+// getEnvInt parses an integer environment variable.
+func getEnvInt(key string, fallback int) (int, error) {
+	value := getEnv(key, "")
+	if value == "" {
+		return fallback, nil
+	}
+	parsed, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %q is not a valid integer", key, value)
+	}
+	return parsed, nil
+}
+
+// getEnvBool parses a boolean environment variable such as "true" or "0".
+func getEnvBool(key string, fallback bool) (bool, error) {
+	value := getEnv(key, "")
+	if value == "" {
+		return fallback, nil
+	}
+	parsed, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, fmt.Errorf("%s: %q is not a valid boolean", key, value)
+	}
+	return parsed, nil
+}
+
+// getEnvDuration parses a duration environment variable such as "5s" or "2m".
+func getEnvDuration(key string, fallback time.Duration) (time.Duration, error) {
+	value := getEnv(key, "")
+	if value == "" {
+		return fallback, nil
+	}
+	parsed, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %q is not a valid duration", key, value)
+	}
+	return parsed, nil
+}
@@ -0,0 +1,913 @@
+package conformance
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestClientDetails(t *testing.T) {
+	client := connect(t)
+	if client.id == "" {
+		t.Fatal("Client_Details has no id")
+	}
+}
+
+func TestUnsupportedEvent(t *testing.T) {
+	client := connect(t)
+	client.request("Not_An_Event", map[string]interface{}{})
+	events, _ := client.expect("Unsupported_Event").data()["events"].([]interface{})
+	if !slices.Contains(events, interface{}("Create_Room")) {
+		t.Fatalf("Unsupported_Event does not list the events: %s", dump(events))
+	}
+}
+
+func TestCreateRoom(t *testing.T) {
+	client := connect(t)
+	roomId := newRoomId()
+	client.request("Create_Room", map[string]interface{}{"room": roomId, "name": "Conformance"})
+	created := client.expect("Room_Created", "room", roomId, "name", "Conformance")
+	if members, _ := created.data()["clients"].([]interface{}); !slices.Contains(members, interface{}(client.id)) {
+		t.Fatalf("creator is not a member: %s", dump(created))
+	}
+
+	client.request("Create_Room", map[string]interface{}{"room": roomId})
+	client.expect("Duplicate_Room")
+
+	client.send(map[string]interface{}{"event": "Create_Room"})
+	client.expect("Missing_Fields")
+
+	client.request("Create_Room", map[string]interface{}{"room": newRoomId(), "template": "unknown-template"})
+	client.expect("Not_Found")
+}
+
+func TestJoinRoom(t *testing.T) {
+	creator, joiner := connect(t), connect(t)
+	roomId := creator.createRoom(nil)
+
+	joiner.joinRoom(roomId)
+	creator.expect("Client_Added", "room", roomId)
+
+	joiner.request("Join_Room", map[string]interface{}{"room": roomId})
+	joiner.expect("Already_Exists")
+
+	joiner.request("Join_Room", map[string]interface{}{"room": newRoomId()})
+	joiner.expect("Not_Found")
+
+	joiner.request("Join_Room", map[string]interface{}{})
+	joiner.expect("Missing_Fields")
+}
+
+func TestRoomFull(t *testing.T) {
+	creator, joiner := connect(t), connect(t)
+	roomId := creator.createRoom(map[string]interface{}{"max_clients": 1})
+
+	joiner.request("Join_Room", map[string]interface{}{"room": roomId})
+	joiner.expect("Room_Full")
+}
+
+func TestRoomPassword(t *testing.T) {
+	creator, joiner := connect(t), connect(t)
+	roomId := creator.createRoom(map[string]interface{}{"password": "secret"})
+
+	joiner.request("Join_Room", map[string]interface{}{"room": roomId, "password": "wrong"})
+	joiner.expect("Invalid_Password")
+
+	joiner.request("Join_Room", map[string]interface{}{"room": roomId, "password": "secret"})
+	joiner.expect("Client_Added", "room", roomId, "protected", true)
+}
+
+func TestLockRoomAndInvite(t *testing.T) {
+	creator, member, guest := connect(t), connect(t), connect(t)
+	roomId := creator.createRoom(nil)
+	member.joinRoom(roomId)
+
+	member.request("Lock_Room", map[string]interface{}{"room": roomId})
+	member.expect("Unauthorised")
+
+	creator.request("Lock_Room", map[string]interface{}{"room": roomId})
+	creator.expect("Room_Locked", "room", roomId, "locked", true)
+	guest.request("Join_Room", map[string]interface{}{"room": roomId})
+	guest.expect("Room_Locked")
+
+	creator.request("Invite", map[string]interface{}{"room": roomId, "to": guest.id})
+	creator.expect("Invite_Sent")
+	token := guest.expect("Room_Invite", "room", roomId, "from", creator.id).data()["token"]
+	guest.request("Join_Room", map[string]interface{}{"room": roomId, "token": token})
+	guest.expect("Client_Added", "room", roomId)
+
+	creator.request("Unlock_Room", map[string]interface{}{"room": roomId})
+	creator.expect("Room_Unlocked", "room", roomId, "locked", false)
+}
+
+func TestLeaveAndEndRoom(t *testing.T) {
+	creator, member := connect(t), connect(t)
+	roomId := creator.createRoom(nil)
+	member.joinRoom(roomId)
+
+	member.request("End_Room", map[string]interface{}{"room": roomId})
+	member.expect("Unauthorised")
+
+	member.request("Leave_Room", map[string]interface{}{"room": roomId})
+	member.expect("Room_Left", "room", roomId)
+	creator.expect("Client_Removed", "room", roomId)
+
+	member.request("Leave_Room", map[string]interface{}{"room": roomId})
+	member.expect("Not_Found")
+
+	creator.request("End_Room", map[string]interface{}{"room": roomId})
+	creator.expect("Room_Deleted", "room", roomId)
+	creator.request("Join_Room", map[string]interface{}{"room": roomId})
+	creator.expect("Not_Found")
+}
+
+func TestRoomExpiry(t *testing.T) {
+	creator, member := connect(t), connect(t)
+	roomId := creator.createRoom(map[string]interface{}{"expires_in": 60})
+	member.joinRoom(roomId)
+
+	advance(t, 59*time.Second-settings.JanitorInterval)
+	member.expectNone("Room_Expired")
+	advance(t, time.Second)
+	member.expect("Room_Expired", "room", roomId)
+	creator.expect("Room_Expired", "room", roomId)
+	creator.request("Join_Room", map[string]interface{}{"room": roomId})
+	creator.expect("Not_Found")
+}
+
+func TestEndWhenCreatorLeaves(t *testing.T) {
+	creator, member := connect(t), connect(t)
+	roomId := creator.createRoom(map[string]interface{}{"end_when_creator_leaves": true})
+	member.joinRoom(roomId)
+
+	creator.close()
+	member.expect("Room_Deleted", "room", roomId)
+	member.request("Join_Room", map[string]interface{}{"room": roomId})
+	member.expect("Not_Found")
+
+	other := connect(t)
+	roomId = member.createRoom(nil)
+	other.joinRoom(roomId)
+	member.request("Leave_Room", map[string]interface{}{"room": roomId})
+	other.expect("Client_Removed", "room", roomId)
+	other.expectNone("Room_Deleted")
+}
+
+func TestRelay(t *testing.T) {
+	sender, receiver, outsider := connect(t), connect(t), connect(t)
+
+	for _, event := range []string{"Offer", "Answer", "Candidate", "Message"} {
+		sender.send(map[string]interface{}{"event": event, "to": receiver.id, "data": "payload"})
+		relayed := receiver.expect(event)
+		if relayed["from"] != sender.id || relayed["data"] != "payload" {
+			t.Fatalf("unexpected %s relay: %s", event, dump(relayed))
+		}
+	}
+
+	sender.send(map[string]interface{}{"event": "Message", "to": "unknown-client", "data": "payload"})
+	sender.expect("Not_Found")
+	sender.send(map[string]interface{}{"event": "Message", "data": "payload"})
+	sender.expect("Missing_Fields")
+
+	roomId := sender.createRoom(nil)
+	receiver.joinRoom(roomId)
+	sender.send(map[string]interface{}{"event": "Message", "to": receiver.id, "room": roomId, "data": "in room"})
+	if relayed := receiver.expect("Message"); relayed["room"] != roomId {
+		t.Fatalf("room not passed on: %s", dump(relayed))
+	}
+	sender.send(map[string]interface{}{"event": "Message", "to": outsider.id, "room": roomId, "data": "in room"})
+	sender.expect("Unauthorised")
+	outsider.expectNone("Message")
+
+	sender.send(map[string]interface{}{"event": "Message", "to": receiver.id, "encrypted": "yes", "data": "payload"})
+	sender.expect("Invalid_Fields")
+}
+
+func TestSignalOrdering(t *testing.T) {
+	sender, receiver, other := connect(t), connect(t), connect(t)
+	events := []string{"Offer", "Candidate", "Candidate", "Answer", "Candidate", "Candidate"}
+	for index, event := range events {
+		sender.send(map[string]interface{}{"event": event, "to": receiver.id, "data": strconv.Itoa(index)})
+	}
+	for index, event := range events {
+		relayed := receiver.expect(event)
+		if relayed["data"] != strconv.Itoa(index) || relayed["seq"] != float64(index+1) {
+			t.Fatalf("%s %d relayed out of order: %s", event, index, dump(relayed))
+		}
+	}
+
+	other.send(map[string]interface{}{"event": "Offer", "to": receiver.id, "data": "other"})
+	if relayed := receiver.expect("Offer"); relayed["from"] != other.id || relayed["seq"] != float64(1) {
+		t.Fatalf("streams of different senders share their numbers: %s", dump(relayed))
+	}
+	sender.send(map[string]interface{}{"event": "Message", "to": receiver.id, "data": "chat"})
+	if relayed := receiver.expect("Message"); relayed["seq"] != nil {
+		t.Fatalf("chat numbered with the signalling: %s", dump(relayed))
+	}
+}
+
+func TestDuplicateMessages(t *testing.T) {
+	sender, receiver := connect(t), connect(t)
+	messageId := "offer-" + sender.id
+	offer := map[string]interface{}{"event": "Offer", "to": receiver.id, "message_id": messageId, "data": "payload"}
+	sender.send(offer)
+	sender.send(offer)
+	sender.expect("Duplicate", "message_id", messageId, "event", "Offer")
+	if relayed := receiver.expect("Offer"); relayed["message_id"] != messageId {
+		t.Fatalf("message_id not passed on: %s", dump(relayed))
+	}
+	receiver.expectNone("Offer")
+
+	sender.send(map[string]interface{}{"event": "Offer", "to": receiver.id, "message_id": 42, "data": "payload"})
+	sender.expect("Invalid_Fields")
+
+	advance(t, 31*time.Second)
+	sender.send(offer)
+	receiver.expect("Offer")
+	sender.expectNone("Duplicate")
+}
+
+func TestAcks(t *testing.T) {
+	receiver, sender := connectWith(t, "?acks=true"), connect(t)
+	if receiver.details["acks"] != true {
+		t.Fatalf("acknowledgements not enabled: %s", dump(receiver.details))
+	}
+	sender.send(map[string]interface{}{"event": "Offer", "to": receiver.id, "message_id": "mine", "data": "offer"})
+	sender.send(map[string]interface{}{"event": "Candidate", "to": receiver.id, "data": "candidate"})
+	offer, candidate := receiver.expect("Offer"), receiver.expect("Candidate")
+	if offer["message_id"] == "mine" || offer["message_id"] == nil || candidate["message_id"] == nil {
+		t.Fatalf("relayed without a message_id of the server: %s %s", dump(offer), dump(candidate))
+	}
+	receiver.request("Ack", map[string]interface{}{"message_ids": []interface{}{offer["message_id"]}})
+	receiver.request("Time_Sync", map[string]interface{}{})
+	receiver.expect("Time_Sync")
+	receiver.close()
+	// the server noticed the drop once the receiver can't be reached
+	for deadline := time.Now().Add(timeout); ; time.Sleep(20 * time.Millisecond) {
+		sender.send(map[string]interface{}{"event": "Ping_Peer", "to": receiver.id, "data": map[string]interface{}{}})
+		if _, gone := sender.take("Not_Found", nil); gone {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("the server didn't notice the receiver dropped")
+		}
+	}
+
+	resumed := connectWith(t, "?acks=true&resume="+receiver.details["resume_token"].(string))
+	if resumed.id != receiver.id {
+		t.Fatalf("resumed as %s, want %s", resumed.id, receiver.id)
+	}
+	resumed.expect("Session_Resumed")
+	if again := resumed.expect("Candidate"); again["message_id"] != candidate["message_id"] {
+		t.Fatalf("retransmitted %s, want the unacked candidate", dump(again))
+	}
+	resumed.expectNone("Offer")
+}
+
+func TestConnect(t *testing.T) {
+	caller, callee := connect(t), connect(t)
+	caller.send(map[string]interface{}{
+		"event": "Connect",
+		"to":    callee.id,
+		"data":  map[string]interface{}{"sdp": "v=0", "Candidate": "candidate"},
+	})
+	offer := callee.expect("Offer", "from", caller.id)
+	role := caller.expect("Negotiation_Role", "peer", callee.id).data()["role"]
+	if role == offer.data()["role"] {
+		t.Fatalf("both peers got the %v role", role)
+	}
+
+	caller.send(map[string]interface{}{"event": "Connect", "to": callee.id, "data": map[string]interface{}{}})
+	caller.expect("Missing_Fields")
+}
+
+func TestCandidates(t *testing.T) {
+	sender, receiver := connect(t), connect(t)
+	candidates := []interface{}{"candidate:1", "candidate:2"}
+	sender.send(map[string]interface{}{"event": "Candidates", "to": receiver.id, "data": map[string]interface{}{"candidates": candidates}})
+	if relayed := receiver.expect("Candidates"); relayed["from"] != sender.id {
+		t.Fatalf("unexpected relay: %s", dump(relayed))
+	}
+
+	sender.send(map[string]interface{}{"event": "Candidates", "to": receiver.id, "data": "candidate:1"})
+	sender.expect("Invalid_Candidates")
+}
+
+func TestMessageTTL(t *testing.T) {
+	sender := connect(t)
+	base := "http" + strings.TrimPrefix(endpoint, "ws") + "poll/"
+	response, err := http.Post(base+"connect", "application/json", nil)
+	if err != nil {
+		t.Fatalf("connect over long-poll: %v", err)
+	}
+	var session struct{ Id, Token string }
+	json.NewDecoder(response.Body).Decode(&session)
+	response.Body.Close()
+
+	sender.send(map[string]interface{}{"event": "Candidate", "to": session.Id, "ttl_ms": -1, "data": "candidate:1"})
+	sender.expect("Invalid_Fields")
+	sender.send(map[string]interface{}{"event": "Candidate", "to": session.Id, "ttl_ms": 1000, "data": "candidate:1"})
+	sender.send(map[string]interface{}{"event": "Message", "to": session.Id, "data": "hello"})
+	sender.expectNone("Expired")
+	advance(t, 2*time.Second)
+
+	response, err = http.Get(base + "receive?timeout=100ms&id=" + session.Id + "&token=" + session.Token)
+	if err != nil {
+		t.Fatalf("receive over long-poll: %v", err)
+	}
+	body, _ := io.ReadAll(response.Body)
+	response.Body.Close()
+	if strings.Contains(string(body), "candidate:1") || !strings.Contains(string(body), "hello") {
+		t.Fatalf("expired candidate delivered or message lost: %s", body)
+	}
+	sender.expect("Expired", "to", session.Id, "event", "Candidate")
+}
+
+func TestIceServers(t *testing.T) {
+	client := connect(t)
+	client.request("Get_Ice_Servers", map[string]interface{}{})
+	if servers := client.expect("Ice_Servers").data(); servers["ice_servers"] == nil {
+		t.Fatalf("no ice_servers: %s", dump(servers))
+	}
+}
+
+func TestCORS(t *testing.T) {
+	if fakeClock == nil {
+		t.Skip("the CORS origins of a deployment are unknown")
+	}
+	base := "http" + strings.TrimPrefix(endpoint, "ws")
+	preflight, _ := http.NewRequest(http.MethodOptions, base+"admin/stats", nil)
+	preflight.Header.Set("Origin", corsOrigin)
+	preflight.Header.Set("Access-Control-Request-Method", http.MethodGet)
+	preflight.Header.Set("Access-Control-Request-Headers", "authorization")
+	response, err := http.DefaultClient.Do(preflight)
+	if err != nil {
+		t.Fatalf("preflight: %v", err)
+	}
+	response.Body.Close()
+	if response.StatusCode != http.StatusNoContent || response.Header.Get("Access-Control-Allow-Origin") != corsOrigin ||
+		!strings.Contains(response.Header.Get("Access-Control-Allow-Headers"), "Authorization") {
+		t.Fatalf("preflight not allowed: %d %v", response.StatusCode, response.Header)
+	}
+
+	for origin, allowed := range map[string]string{corsOrigin: corsOrigin, "https://evil.example.com": ""} {
+		request, _ := http.NewRequest(http.MethodGet, base+"ice-servers", nil)
+		request.Header.Set("Origin", origin)
+		response, err := http.DefaultClient.Do(request)
+		if err != nil {
+			t.Fatalf("get ICE servers: %v", err)
+		}
+		response.Body.Close()
+		if response.StatusCode != http.StatusOK || response.Header.Get("Access-Control-Allow-Origin") != allowed {
+			t.Fatalf("origin %s: %d, Access-Control-Allow-Origin %q", origin, response.StatusCode, response.Header.Get("Access-Control-Allow-Origin"))
+		}
+	}
+}
+
+func TestConfigReload(t *testing.T) {
+	if fakeClock == nil {
+		t.Skip("the configuration of a deployment can't be changed")
+	}
+	file := filepath.Join(t.TempDir(), "p2p.env")
+	t.Setenv("CONFIG_FILE", file)
+	reload := func(settings string) []interface{} {
+		t.Helper()
+		// the settings of the server started in process are kept
+		if err := os.WriteFile(file, []byte("CORS_ORIGINS="+corsOrigin+"\n"+settings), 0o600); err != nil {
+			t.Fatalf("write config file: %v", err)
+		}
+		request, _ := http.NewRequest(http.MethodPost, "http"+strings.TrimPrefix(endpoint, "ws")+"admin/config", nil)
+		request.Header.Set("Authorization", "Bearer "+adminToken)
+		response, err := http.DefaultClient.Do(request)
+		if err != nil {
+			t.Fatalf("reload: %v", err)
+		}
+		defer response.Body.Close()
+		var reloaded struct{ Changed []interface{} }
+		if err := json.NewDecoder(response.Body).Decode(&reloaded); err != nil {
+			t.Fatalf("decode reload: %d %v", response.StatusCode, err)
+		}
+		return reloaded.Changed
+	}
+
+	client := connect(t)
+	if changed := reload("MAX_ROOMS_PER_CLIENT=1\n"); !slices.Equal(changed, []interface{}{"MAX_ROOMS_PER_CLIENT"}) {
+		t.Fatalf("changed settings: %v", changed)
+	}
+	defer reload("")
+	client.request("Create_Room", map[string]interface{}{"room": newRoomId()})
+	client.expect("Room_Created")
+	client.request("Create_Room", map[string]interface{}{"room": newRoomId()})
+	client.expect("Limit_Exceeded", "limit", "max_rooms_per_client")
+}
+
+func TestPresence(t *testing.T) {
+	creator, member := connect(t), connect(t)
+	roomId := creator.createRoom(nil)
+	member.joinRoom(roomId)
+
+	creator.request("Set_Status", map[string]interface{}{"status": "busy"})
+	member.expect("Presence_Update", "room", roomId, "client", creator.id, "status", "busy")
+
+	creator.request("Set_Status", map[string]interface{}{"status": "sleeping"})
+	creator.expect("Invalid_Status")
+}
+
+func TestTopology(t *testing.T) {
+	creator, member, outsider := connect(t), connect(t), connect(t)
+	roomId := creator.createRoom(nil)
+	member.joinRoom(roomId)
+
+	creator.request("Get_Topology", map[string]interface{}{"room": roomId})
+	topology := creator.expect("Topology", "room", roomId, "topology", "mesh")
+	if edges, _ := topology.data()["edges"].([]interface{}); len(edges) != 1 {
+		t.Fatalf("expected one edge: %s", dump(topology))
+	}
+
+	outsider.request("Get_Topology", map[string]interface{}{"room": roomId})
+	outsider.expect("Unauthorised")
+}
+
+func TestCapabilities(t *testing.T) {
+	creator, member := connect(t), connect(t)
+	roomId := creator.createRoom(nil)
+	member.joinRoom(roomId)
+
+	creator.request("Set_Capabilities", map[string]interface{}{"audio": true, "codecs": []string{"opus"}})
+	creator.expect("Capabilities_Set")
+	member.expect("Capabilities_Update", "room", roomId, "client", creator.id)
+
+	creator.request("Set_Capabilities", map[string]interface{}{"audio": "yes"})
+	creator.expect("Invalid_Capabilities")
+}
+
+func TestEncryptionKeys(t *testing.T) {
+	creator, member := connect(t), connect(t)
+	roomId := creator.createRoom(nil)
+	member.joinRoom(roomId)
+
+	creator.request("Publish_Key", map[string]interface{}{"key": "public-key"})
+	creator.expect("Key_Published")
+	member.expect("Public_Key", "room", roomId, "client", creator.id, "key", "public-key")
+
+	member.request("Rotate_Key", map[string]interface{}{"room": roomId})
+	creator.expect("Key_Epoch", "room", roomId, "epoch", 1)
+	member.expect("Key_Epoch", "room", roomId, "epoch", 1)
+
+	creator.request("Publish_Key", map[string]interface{}{})
+	creator.expect("Missing_Fields")
+}
+
+func TestReportStats(t *testing.T) {
+	client := connect(t)
+	roomId := client.createRoom(nil)
+
+	client.request("Report_Stats", map[string]interface{}{"room": roomId, "rtt": 40, "packet_loss": 0.01, "bitrate": 800000})
+	client.expectNone("Invalid_Stats")
+
+	client.request("Report_Stats", map[string]interface{}{"room": roomId, "rtt": 40, "packet_loss": 3, "bitrate": 800000})
+	client.expect("Invalid_Stats")
+}
+
+func TestBroadcast(t *testing.T) {
+	creator, member := connect(t), connect(t)
+	roomId := creator.createRoom(map[string]interface{}{"announce_only": true})
+	member.joinRoom(roomId)
+
+	member.send(map[string]interface{}{"event": "Broadcast", "room": roomId, "data": "hello"})
+	member.expect("Unauthorised")
+
+	creator.send(map[string]interface{}{"event": "Broadcast", "room": roomId, "data": "welcome"})
+	if broadcast := member.expect("Broadcast"); broadcast["from"] != creator.id || broadcast["data"] != "welcome" {
+		t.Fatalf("unexpected broadcast: %s", dump(broadcast))
+	}
+
+	creator.request("Set_Moderator", map[string]interface{}{"room": roomId, "client": member.id})
+	member.expect("Moderators_Updated", "room", roomId)
+	member.send(map[string]interface{}{"event": "Broadcast", "room": roomId, "data": "hello"})
+	creator.expect("Broadcast")
+}
+
+func TestTags(t *testing.T) {
+	creator, viewer, other := connect(t), connect(t), connect(t)
+	roomId := creator.createRoom(nil)
+	viewer.joinRoom(roomId)
+	other.joinRoom(roomId)
+
+	viewer.request("Set_Tags", map[string]interface{}{"tags": []string{"role:viewer"}})
+	viewer.expect("Tags_Set")
+
+	creator.send(map[string]interface{}{"event": "Broadcast_To_Tag", "room": roomId, "tag": "role:viewer", "data": "hi viewers"})
+	viewer.expect("Broadcast_To_Tag")
+	other.expectNone("Broadcast_To_Tag")
+
+	viewer.request("Set_Tags", map[string]interface{}{"tags": []interface{}{1}})
+	viewer.expect("Invalid_Tags")
+}
+
+func TestJoinApproval(t *testing.T) {
+	creator, guest := connect(t), connect(t)
+	roomId := creator.createRoom(map[string]interface{}{"approval": true})
+
+	guest.request("Join_Room", map[string]interface{}{"room": roomId})
+	guest.expect("Join_Pending", "room", roomId)
+	creator.expect("Join_Request", "room", roomId, "client", guest.id)
+	creator.request("Reject_Join", map[string]interface{}{"room": roomId, "client": guest.id})
+	guest.expect("Join_Rejected", "room", roomId)
+
+	guest.request("Join_Room", map[string]interface{}{"room": roomId})
+	creator.expect("Join_Request", "room", roomId, "client", guest.id)
+	guest.request("Approve_Join", map[string]interface{}{"room": roomId, "client": guest.id})
+	guest.expect("Unauthorised")
+	creator.request("Approve_Join", map[string]interface{}{"room": roomId, "client": guest.id})
+	guest.expect("Client_Added", "room", roomId)
+}
+
+func TestBlocking(t *testing.T) {
+	blocker, blocked := connect(t), connect(t)
+
+	blocker.request("Block_Client", map[string]interface{}{"client": blocked.id})
+	blocker.expect("Client_Blocked", "client", blocked.id)
+	blocked.send(map[string]interface{}{"event": "Message", "to": blocker.id, "data": "payload"})
+	blocked.expect("Blocked", "to", blocker.id)
+	blocker.expectNone("Message")
+
+	blocker.request("Unblock_Client", map[string]interface{}{"client": blocked.id})
+	blocker.expect("Client_Unblocked", "client", blocked.id)
+	blocked.send(map[string]interface{}{"event": "Message", "to": blocker.id, "data": "payload"})
+	blocker.expect("Message")
+
+	blocker.request("Block_Client", map[string]interface{}{"client": blocker.id})
+	blocker.expect("Missing_Fields")
+}
+
+func TestRoomMessage(t *testing.T) {
+	sender, member := connect(t), connect(t)
+	roomId := sender.createRoom(nil)
+	member.joinRoom(roomId)
+
+	sender.send(map[string]interface{}{"event": "Room_Message", "room": roomId, "data": "hello"})
+	for _, receiver := range []*testClient{sender, member} {
+		chat := receiver.expect("Room_Message")
+		if chat["from"] != sender.id || chat["room"] != roomId || chat["data"] != "hello" || chat["timestamp"] == nil {
+			t.Fatalf("unexpected room message: %s", dump(chat))
+		}
+	}
+
+	sender.send(map[string]interface{}{"event": "Room_Message", "room": newRoomId(), "data": "hello"})
+	sender.expect("Not_Found")
+}
+
+func TestCalls(t *testing.T) {
+	caller, callee, outsider := connect(t), connect(t), connect(t)
+
+	caller.send(map[string]interface{}{"event": "Call_Invite", "to": callee.id, "data": map[string]interface{}{"video": true}})
+	callId := caller.expect("Call_Pending").data()["id"]
+	invite := callee.expect("Call_Invite", "id", callId, "from", caller.id)
+	if invite.data()["data"].(map[string]interface{})["video"] != true {
+		t.Fatalf("call data not passed on: %s", dump(invite))
+	}
+	outsider.request("Call_Accept", map[string]interface{}{"id": callId})
+	outsider.expect("Unauthorised")
+	callee.request("Call_Ringing", map[string]interface{}{"id": callId})
+	caller.expect("Call_Ringing", "id", callId)
+	callee.request("Call_Accept", map[string]interface{}{"id": callId})
+	caller.expect("Call_Accept", "id", callId, "from", callee.id)
+	caller.request("Call_Cancel", map[string]interface{}{"id": callId})
+	caller.expect("Not_Found")
+	callee.request("Call_End", map[string]interface{}{"id": callId})
+	caller.expect("Call_End", "id", callId)
+
+	caller.send(map[string]interface{}{"event": "Call_Invite", "to": callee.id})
+	callId = caller.expect("Call_Pending").data()["id"]
+	callee.expect("Call_Invite", "id", callId)
+	advance(t, settings.CallTimeout)
+	caller.expect("Call_Cancel", "id", callId, "reason", "timeout")
+	callee.expect("Call_Cancel", "id", callId, "reason", "timeout")
+}
+
+func TestBusy(t *testing.T) {
+	caller, callee, other := connect(t), connect(t), connect(t)
+
+	// the status is applied once the rooms of the callee hear of it
+	callee.createRoom(nil)
+	callee.request("Set_Status", map[string]interface{}{"status": "dnd"})
+	callee.expect("Presence_Update", "status", "dnd")
+	caller.send(map[string]interface{}{"event": "Call_Invite", "to": callee.id})
+	caller.expect("Busy", "to", callee.id, "reason", "dnd")
+	callee.expectNone("Call_Invite")
+	callee.request("Set_Status", map[string]interface{}{"status": "online"})
+	callee.expect("Presence_Update", "status", "online")
+
+	caller.send(map[string]interface{}{"event": "Call_Invite", "to": callee.id})
+	callId := caller.expect("Call_Pending").data()["id"]
+	callee.request("Call_Accept", map[string]interface{}{"id": callId})
+	caller.expect("Call_Accept", "id", callId)
+	other.send(map[string]interface{}{"event": "Call_Invite", "to": callee.id})
+	other.expect("Busy", "to", callee.id, "reason", "in_call")
+
+	callee.request("Set_Busy_Policy", map[string]interface{}{"in_call": false})
+	callee.expect("Busy_Policy_Set", "dnd", true, "in_call", false)
+	other.send(map[string]interface{}{"event": "Call_Invite", "to": callee.id})
+	other.expect("Call_Pending")
+
+	caller.request("Call_End", map[string]interface{}{"id": callId})
+	callee.expect("Call_End", "id", callId, "from", caller.id)
+}
+
+func TestTypingIndicators(t *testing.T) {
+	typist, member := connect(t), connect(t)
+	roomId := typist.createRoom(nil)
+	member.joinRoom(roomId)
+
+	typist.send(map[string]interface{}{"event": "Typing_Start", "room": roomId})
+	member.expect("Typing_Start")
+	typist.send(map[string]interface{}{"event": "Typing_Start", "room": roomId})
+	member.expectNone("Typing_Start")
+	typist.send(map[string]interface{}{"event": "Typing_Stop", "room": roomId})
+	if stop := member.expect("Typing_Stop"); stop["from"] != typist.id || stop["room"] != roomId {
+		t.Fatalf("unexpected typing indicator: %s", dump(stop))
+	}
+	typist.send(map[string]interface{}{"event": "Typing_Stop", "room": roomId})
+	member.expectNone("Typing_Stop")
+}
+
+func TestTypingRateLimitExpires(t *testing.T) {
+	typist, member := connect(t), connect(t)
+	roomId := typist.createRoom(nil)
+	member.joinRoom(roomId)
+
+	typist.send(map[string]interface{}{"event": "Typing_Start", "room": roomId})
+	member.expect("Typing_Start")
+	advance(t, settings.TypingInterval)
+	typist.send(map[string]interface{}{"event": "Typing_Start", "room": roomId})
+	member.expect("Typing_Start")
+}
+
+func TestFileTransfer(t *testing.T) {
+	sender, receiver := connect(t), connect(t)
+
+	offer := map[string]interface{}{"id": "transfer-1", "name": "report.pdf", "size": 482133, "hash": "sha256:9f86d0"}
+	sender.send(map[string]interface{}{"event": "File_Offer", "to": receiver.id, "data": offer})
+	if relayed := receiver.expect("File_Offer", "name", "report.pdf"); relayed["from"] != sender.id {
+		t.Fatalf("unexpected file offer: %s", dump(relayed))
+	}
+	receiver.send(map[string]interface{}{"event": "File_Accept", "to": sender.id, "data": map[string]interface{}{"id": "transfer-1"}})
+	sender.expect("File_Accept", "id", "transfer-1")
+	receiver.send(map[string]interface{}{"event": "File_Reject", "to": sender.id, "data": map[string]interface{}{"id": "transfer-1"}})
+	sender.expect("File_Reject", "id", "transfer-1")
+
+	sender.send(map[string]interface{}{"event": "File_Offer", "to": receiver.id, "data": map[string]interface{}{"id": "transfer-2", "name": "../etc/passwd", "size": 1}})
+	sender.expect("Invalid_File")
+	receiver.expectNone("File_Offer")
+}
+
+func TestFileRelayDisabled(t *testing.T) {
+	sender, receiver := connect(t), connect(t)
+
+	sender.send(map[string]interface{}{"event": "File_Relay", "to": receiver.id, "data": map[string]interface{}{"id": "transfer-1", "size": 1024}})
+	sender.expect("Unsupported_Event")
+	receiver.expectNone("File_Relay")
+}
+
+func TestMediaState(t *testing.T) {
+	presenter, viewer := connect(t), connect(t)
+	roomId := presenter.createRoom(nil)
+	viewer.joinRoom(roomId)
+
+	presenter.request("Media_State", map[string]interface{}{"camera": true, "screen_share": true})
+	presenter.expect("Media_State_Set")
+	update := viewer.expect("Media_State_Update", "room", roomId, "client", presenter.id)
+	if media, _ := update.data()["media"].(map[string]interface{}); media["camera"] != true || media["microphone"] != false || media["screen_share"] != true {
+		t.Fatalf("unexpected media state: %s", dump(update))
+	}
+
+	presenter.request("Media_State", map[string]interface{}{"camera": "on"})
+	presenter.expect("Invalid_Media_State")
+}
+
+func TestRecording(t *testing.T) {
+	creator, member := connect(t), connect(t)
+	roomId := creator.createRoom(nil)
+	member.joinRoom(roomId)
+
+	member.request("Recording_Started", map[string]interface{}{"room": roomId})
+	member.expect("Unauthorised")
+	creator.request("Recording_Started", map[string]interface{}{"room": roomId})
+	member.expect("Recording_Started", "room", roomId, "recording", true)
+
+	newcomer := connect(t)
+	newcomer.request("Join_Room", map[string]interface{}{"room": roomId})
+	newcomer.expect("Client_Added", "room", roomId, "recording", true)
+
+	creator.request("Recording_Stopped", map[string]interface{}{"room": roomId})
+	newcomer.expect("Recording_Stopped", "room", roomId, "recording", false)
+}
+
+func TestPolls(t *testing.T) {
+	creator, voter, outsider := connect(t), connect(t), connect(t)
+	roomId := creator.createRoom(nil)
+	voter.joinRoom(roomId)
+
+	voter.request("Create_Poll", map[string]interface{}{"room": roomId, "question": "Lunch?", "options": []string{"Yes"}})
+	voter.expect("Invalid_Poll")
+	outsider.request("Create_Poll", map[string]interface{}{"room": roomId, "question": "Lunch?", "options": []string{"Yes", "No"}})
+	outsider.expect("Unauthorised")
+
+	voter.request("Create_Poll", map[string]interface{}{"room": roomId, "question": "Lunch?", "options": []string{"Yes", "No"}})
+	voter.expect("Poll_Created", "room", roomId)
+	created := creator.expect("Poll_Created", "room", roomId)
+	poll, _ := created.data()["poll"].(map[string]interface{})
+	pollId, _ := poll["id"].(string)
+	if pollId == "" || poll["question"] != "Lunch?" || poll["creator"] != voter.id {
+		t.Fatalf("unexpected poll: %s", dump(created))
+	}
+
+	creator.request("Vote", map[string]interface{}{"room": roomId, "poll": pollId, "option": 2})
+	creator.expect("Invalid_Vote")
+	creator.request("Vote", map[string]interface{}{"room": roomId, "poll": pollId, "option": 1})
+	results := voter.expect("Poll_Results", "room", roomId)
+	if poll, _ := results.data()["poll"].(map[string]interface{}); fmt.Sprint(poll["counts"]) != "[0 1]" || poll["votes"] != float64(1) {
+		t.Fatalf("unexpected tally: %s", dump(results))
+	}
+	creator.request("Vote", map[string]interface{}{"room": roomId, "poll": pollId, "option": 0})
+	creator.expect("Already_Voted", "poll", pollId)
+
+	voter.request("Close_Poll", map[string]interface{}{"room": roomId, "poll": pollId})
+	closed := creator.expect("Poll_Closed", "room", roomId)
+	if poll, _ := closed.data()["poll"].(map[string]interface{}); poll["closed"] != true || fmt.Sprint(poll["counts"]) != "[0 1]" {
+		t.Fatalf("unexpected final tally: %s", dump(closed))
+	}
+	voter.request("Vote", map[string]interface{}{"room": roomId, "poll": pollId, "option": 0})
+	voter.expect("Poll_Closed", "poll", pollId)
+}
+
+func TestFloor(t *testing.T) {
+	creator, speaker, listener := connect(t), connect(t), connect(t)
+	roomId := creator.createRoom(nil)
+	speaker.joinRoom(roomId)
+	listener.joinRoom(roomId)
+
+	speaker.request("Request_Floor", map[string]interface{}{"room": roomId})
+	listener.expect("Floor_Changed", "room", roomId, "floor", speaker.id)
+	listener.request("Request_Floor", map[string]interface{}{"room": roomId})
+	listener.expect("Floor_Taken", "holder", speaker.id)
+	listener.request("Release_Floor", map[string]interface{}{"room": roomId})
+	listener.expect("Unauthorised")
+	listener.request("Grant_Floor", map[string]interface{}{"room": roomId, "client": listener.id})
+	listener.expect("Unauthorised")
+
+	creator.request("Grant_Floor", map[string]interface{}{"room": roomId, "client": listener.id})
+	speaker.expect("Floor_Changed", "room", roomId, "floor", listener.id)
+	listener.request("Release_Floor", map[string]interface{}{"room": roomId})
+	creator.expect("Floor_Changed", "room", roomId, "floor", "")
+}
+
+func TestRaisedHands(t *testing.T) {
+	creator, first, second := connect(t), connect(t), connect(t)
+	roomId := creator.createRoom(nil)
+	first.joinRoom(roomId)
+	second.joinRoom(roomId)
+
+	first.request("Raise_Hand", map[string]interface{}{"room": roomId})
+	first.expect("Hand_Raised", "room", roomId, "position", 1)
+	creator.expect("Hand_Queue", "room", roomId, "queue", []string{first.id})
+	second.request("Raise_Hand", map[string]interface{}{"room": roomId})
+	second.expect("Hand_Raised", "room", roomId, "position", 2)
+	creator.expect("Hand_Queue", "room", roomId, "queue", []string{first.id, second.id})
+	second.expectNone("Hand_Queue")
+
+	second.request("Lower_Hand", map[string]interface{}{"room": roomId, "client": first.id})
+	second.expect("Unauthorised")
+	creator.request("Lower_Hand", map[string]interface{}{"room": roomId, "client": first.id})
+	first.expect("Hand_Lowered", "room", roomId)
+	creator.expect("Hand_Queue", "room", roomId, "queue", []string{second.id})
+
+	// the floor answers the hand
+	creator.request("Grant_Floor", map[string]interface{}{"room": roomId, "client": second.id})
+	second.expect("Hand_Lowered", "room", roomId)
+	creator.expect("Hand_Queue", "room", roomId, "queue", []string{})
+}
+
+func TestMyRooms(t *testing.T) {
+	creator, member := connect(t), connect(t)
+	roomId := creator.createRoom(nil)
+	member.joinRoom(roomId)
+
+	member.send(map[string]interface{}{"event": "My_Rooms"})
+	rooms, _ := member.expect("My_Rooms").data()["rooms"].([]interface{})
+	if len(rooms) != 1 {
+		t.Fatalf("unexpected rooms: %s", dump(rooms))
+	}
+	if summary, _ := rooms[0].(map[string]interface{}); summary["room"] != roomId || summary["role"] != "member" || summary["members"] != float64(2) {
+		t.Fatalf("unexpected room summary: %s", dump(summary))
+	}
+}
+
+func TestFindRoom(t *testing.T) {
+	client := connect(t)
+	marker := newRoomId()
+	for _, name := range []string{marker + " Standup", marker + " Retro", marker + " standup two"} {
+		client.createRoom(map[string]interface{}{"name": name, "public": true})
+	}
+	client.createRoom(map[string]interface{}{"name": marker + " Standup private"})
+
+	client.request("Find_Room", map[string]interface{}{"query": marker + " STAND", "limit": 1})
+	found := client.expect("Rooms_Found", "total", float64(2))
+	if rooms, _ := found.data()["rooms"].([]interface{}); len(rooms) != 1 || rooms[0].(map[string]interface{})["name"] != marker+" Standup" {
+		t.Fatalf("unexpected prefix search: %s", dump(found))
+	}
+
+	client.request("Find_Room", map[string]interface{}{"query": marker[len(marker)-6:] + " STANDUP T", "match": "substring"})
+	found = client.expect("Rooms_Found", "total", float64(1))
+	if rooms, _ := found.data()["rooms"].([]interface{}); len(rooms) != 1 || rooms[0].(map[string]interface{})["name"] != marker+" standup two" {
+		t.Fatalf("unexpected substring search: %s", dump(found))
+	}
+
+	client.request("Find_Room", map[string]interface{}{"query": marker, "limit": 1000})
+	client.expect("Invalid_Fields")
+}
+
+func TestJoinCode(t *testing.T) {
+	creator, guest := connect(t), connect(t)
+	roomId := newRoomId()
+	creator.request("Create_Room", map[string]interface{}{"room": roomId})
+	code, _ := creator.expect("Room_Created", "room", roomId).data()["join_code"].(string)
+	if len(code) != 6 {
+		t.Fatalf("unexpected join code %q", code)
+	}
+
+	guest.request("Join_Room", map[string]interface{}{"code": strings.ToLower(code)})
+	guest.expect("Client_Added", "room", roomId)
+
+	guest.request("Join_Room", map[string]interface{}{"code": "no-such-code"})
+	guest.expect("Not_Found")
+}
+
+func TestRoomCallbackRejected(t *testing.T) {
+	client := connect(t)
+	client.request("Create_Room", map[string]interface{}{"room": newRoomId(), "callback_url": "ftp://127.0.0.1/events"})
+	client.expect("Invalid_Callback")
+}
+
+func TestProtocolVersion(t *testing.T) {
+	conn, _, err := websocket.DefaultDialer.Dial(endpoint+"?version=1", nil)
+	if err != nil {
+		t.Fatalf("dial with version 1: %v", err)
+	}
+	defer conn.Close()
+	var details message
+	if err := conn.ReadJSON(&details); err != nil {
+		t.Fatalf("read Client_Details: %v", err)
+	}
+	if details.data()["version"] != "1" {
+		t.Fatalf("negotiated version %v, want 1: %s", details.data()["version"], dump(details))
+	}
+
+	_, response, err := websocket.DefaultDialer.Dial(endpoint+"?version=unknown", nil)
+	if err == nil || response == nil || response.StatusCode != http.StatusBadRequest {
+		t.Fatalf("unknown version not refused with 400: %v", err)
+	}
+}
+
+func TestSpec(t *testing.T) {
+	response, err := http.Get("http" + strings.TrimPrefix(endpoint, "ws") + "spec")
+	if err != nil {
+		t.Fatalf("get spec: %v", err)
+	}
+	defer response.Body.Close()
+	var document struct {
+		AsyncAPI   string `json:"asyncapi"`
+		Components struct {
+			Messages map[string]interface{} `json:"messages"`
+		} `json:"components"`
+	}
+	if err := json.NewDecoder(response.Body).Decode(&document); err != nil {
+		t.Fatalf("decode spec: %v", err)
+	}
+	if document.AsyncAPI == "" || document.Components.Messages["Create_Room"] == nil {
+		t.Fatalf("spec has no Create_Room message: %s", dump(document))
+	}
+}
+
+func TestSDK(t *testing.T) {
+	response, err := http.Get("http" + strings.TrimPrefix(endpoint, "ws") + "sdk/p2pconnector.js")
+	if err != nil {
+		t.Fatalf("get SDK: %v", err)
+	}
+	defer response.Body.Close()
+	body, _ := io.ReadAll(response.Body)
+	if response.StatusCode != http.StatusOK || !strings.Contains(string(body), "createRoom(data)") {
+		t.Fatalf("SDK has no createRoom method: %d %.200s", response.StatusCode, body)
+	}
+}
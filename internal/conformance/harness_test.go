@@ -0,0 +1,233 @@
+package conformance
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/lithammer/shortuuid"
+	"github.com/shankarammai/Peer2PeerConnector/internal/clock"
+	"github.com/shankarammai/Peer2PeerConnector/internal/config"
+	"github.com/shankarammai/Peer2PeerConnector/internal/logging"
+	"github.com/shankarammai/Peer2PeerConnector/internal/server"
+)
+
+// timeout bounds the wait for an expected message.
+const timeout = 3 * time.Second
+
+// endpoint is the WebSocket URL under test: CONFORMANCE_URL when set, which runs
+// the suite as a smoke test against a deployment, or a server started in process.
+var endpoint string
+
+// fakeClock is the time of the server started in process, nil against a deployment.
+var fakeClock *clock.Fake
+
+// settings is the configuration of the server started in process.
+var settings = config.Default()
+
+// corsOrigin is the origin allowed to call the HTTP endpoints of the server started in process.
+const corsOrigin = "https://app.example.com"
+
+// adminToken is the admin token of the server started in process.
+const adminToken = "conformance"
+
+func TestMain(m *testing.M) {
+	endpoint = os.Getenv("CONFORMANCE_URL")
+	if endpoint == "" {
+		logging.Logger.SetOutput(io.Discard)
+		fakeClock = clock.NewFake(time.Now())
+		server.SetClock(fakeClock)
+		settings.CORSOrigins = []string{corsOrigin}
+		settings.CORSAdmin = true
+		settings.AdminToken = adminToken
+		if err := server.Init(settings); err != nil {
+			fmt.Fprintln(os.Stderr, "failed to initialise server:", err)
+			os.Exit(1)
+		}
+		httpServer := httptest.NewServer(server.NewHandler())
+		endpoint = "ws" + strings.TrimPrefix(httpServer.URL, "http") + "/"
+		code := m.Run()
+		httpServer.Close()
+		os.Exit(code)
+	}
+	os.Exit(m.Run())
+}
+
+// message is a message received from the server.
+type message map[string]interface{}
+
+func (msg message) data() map[string]interface{} {
+	data, _ := msg["data"].(map[string]interface{})
+	return data
+}
+
+// testClient is a scripted WebSocket client. The server handles messages
+// concurrently, so expectations match received messages in any order.
+type testClient struct {
+	t        *testing.T
+	id       string
+	conn     *websocket.Conn
+	mu       sync.Mutex
+	received []message
+	arrived  chan struct{}
+	// details is the data of Client_Details.
+	details map[string]interface{}
+}
+
+// connect opens a connection and waits for the client details.
+func connect(t *testing.T) *testClient {
+	t.Helper()
+	return connectWith(t, "")
+}
+
+// connectWith opens a connection with the query parameters and waits for the
+// client details.
+func connectWith(t *testing.T, query string) *testClient {
+	t.Helper()
+	conn, _, err := websocket.DefaultDialer.Dial(endpoint+query, nil)
+	if err != nil {
+		t.Fatalf("dial %s: %v", endpoint, err)
+	}
+	client := &testClient{t: t, conn: conn, arrived: make(chan struct{}, 1)}
+	go client.read()
+	t.Cleanup(client.close)
+	client.details = client.expect("Client_Details").data()
+	client.id, _ = client.details["id"].(string)
+	return client
+}
+
+func (client *testClient) read() {
+	for {
+		var msg message
+		if err := client.conn.ReadJSON(&msg); err != nil {
+			return
+		}
+		client.mu.Lock()
+		client.received = append(client.received, msg)
+		client.mu.Unlock()
+		select {
+		case client.arrived <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func (client *testClient) close() {
+	client.conn.Close()
+}
+
+// send writes a message to the server.
+func (client *testClient) send(msg map[string]interface{}) {
+	client.t.Helper()
+	if err := client.conn.WriteJSON(msg); err != nil {
+		client.t.Fatalf("send %v: %v", msg["event"], err)
+	}
+}
+
+// expect consumes the first received message with the event name, and if given
+// the data fields, failing the test when none arrives in time.
+func (client *testClient) expect(event string, fields ...interface{}) message {
+	client.t.Helper()
+	deadline := time.After(timeout)
+	for {
+		if msg, ok := client.take(event, fields); ok {
+			return msg
+		}
+		select {
+		case <-client.arrived:
+		case <-deadline:
+			client.mu.Lock()
+			defer client.mu.Unlock()
+			client.t.Fatalf("no %s %v received, got %v", event, fields, client.received)
+			return nil
+		}
+	}
+}
+
+// expectNone fails the test if a message with the event name arrives shortly.
+func (client *testClient) expectNone(event string) {
+	client.t.Helper()
+	time.Sleep(200 * time.Millisecond)
+	if msg, ok := client.take(event, nil); ok {
+		client.t.Fatalf("unexpected %s: %v", event, msg)
+	}
+}
+
+func (client *testClient) take(event string, fields []interface{}) (message, bool) {
+	client.mu.Lock()
+	defer client.mu.Unlock()
+	for index, msg := range client.received {
+		if msg["event"] != event || !matches(msg.data(), fields) {
+			continue
+		}
+		client.received = append(client.received[:index], client.received[index+1:]...)
+		return msg, true
+	}
+	return nil, false
+}
+
+// matches checks the key/value pairs of fields against data.
+func matches(data map[string]interface{}, fields []interface{}) bool {
+	for index := 0; index+1 < len(fields); index += 2 {
+		if fmt.Sprint(data[fields[index].(string)]) != fmt.Sprint(fields[index+1]) {
+			return false
+		}
+	}
+	return true
+}
+
+// request sends a message with the event name and data.
+func (client *testClient) request(event string, data interface{}) {
+	client.t.Helper()
+	client.send(map[string]interface{}{"event": event, "data": data})
+}
+
+// newRoomId returns a room Id unique to the run, remote deployments are shared.
+func newRoomId() string {
+	return "conformance-" + shortuuid.New()
+}
+
+// createRoom creates a room with the options and waits for the confirmation.
+func (client *testClient) createRoom(options map[string]interface{}) string {
+	client.t.Helper()
+	roomId := newRoomId()
+	data := map[string]interface{}{"room": roomId}
+	for key, value := range options {
+		data[key] = value
+	}
+	client.request("Create_Room", data)
+	client.expect("Room_Created", "room", roomId)
+	return roomId
+}
+
+// joinRoom joins the room and waits until the client is added.
+func (client *testClient) joinRoom(roomId string) {
+	client.t.Helper()
+	client.request("Join_Room", map[string]interface{}{"room": roomId})
+	client.expect("Client_Added", "room", roomId)
+}
+
+// advance moves the time of the server d forward, then one janitor interval
+// more so the janitor has cleaned up at the new time. Tests against a
+// deployment can't move its time and are skipped.
+func advance(t *testing.T, d time.Duration) {
+	t.Helper()
+	if fakeClock == nil {
+		t.Skip("the time of a deployment can't be moved")
+	}
+	fakeClock.Advance(d)
+	fakeClock.Advance(settings.JanitorInterval)
+}
+
+// dump renders a message for failure messages.
+func dump(v interface{}) string {
+	encoded, _ := json.Marshal(v)
+	return string(encoded)
+}
@@ -0,0 +1,80 @@
+// Package directory resolves the user Ids of authenticated clients against an
+// external user directory, so peers see names and avatars the deployment
+// vouches for rather than the ones clients pick for themselves.
+package directory
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// ErrNotFound is returned when the directory has no user with the Id.
+var ErrNotFound = errors.New("user not found in the directory")
+
+// Profile is what the directory knows about a user.
+type Profile struct {
+	DisplayName string `json:"display_name,omitempty"`
+	AvatarURL   string `json:"avatar_url,omitempty"`
+}
+
+// Directory looks users up by the user Id of their access token. It is
+// consulted once per connection, implementations backed by LDAP or another
+// store can be plugged with server.SetDirectory.
+type Directory interface {
+	Lookup(ctx context.Context, userId string) (*Profile, error)
+}
+
+// HTTP looks users up with a GET on a URL template where "{id}" is replaced by
+// the escaped user Id, e.g. "https://users.example.com/api/users/{id}". The
+// answer is a JSON Profile, 404 when the user doesn't exist.
+type HTTP struct {
+	urlTemplate string
+	token       string
+	httpClient  *http.Client
+}
+
+// NewHTTP returns a directory calling urlTemplate, with token as a bearer
+// token when it is not empty.
+func NewHTTP(urlTemplate string, token string, timeout time.Duration) (*HTTP, error) {
+	if !strings.Contains(urlTemplate, "{id}") {
+		return nil, errors.New("the directory URL must contain {id}")
+	}
+	if _, err := url.Parse(strings.ReplaceAll(urlTemplate, "{id}", "id")); err != nil {
+		return nil, err
+	}
+	return &HTTP{urlTemplate: urlTemplate, token: token, httpClient: &http.Client{Timeout: timeout}}, nil
+}
+
+func (directory *HTTP) Lookup(ctx context.Context, userId string) (*Profile, error) {
+	lookupURL := strings.ReplaceAll(directory.urlTemplate, "{id}", url.PathEscape(userId))
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, lookupURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	request.Header.Set("Accept", "application/json")
+	if directory.token != "" {
+		request.Header.Set("Authorization", "Bearer "+directory.token)
+	}
+	response, err := directory.httpClient.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+	switch {
+	case response.StatusCode == http.StatusNotFound:
+		return nil, ErrNotFound
+	case response.StatusCode >= 300:
+		return nil, fmt.Errorf("directory lookup of %s: %s", userId, response.Status)
+	}
+	var profile Profile
+	if err := json.NewDecoder(response.Body).Decode(&profile); err != nil {
+		return nil, fmt.Errorf("directory lookup of %s: %w", userId, err)
+	}
+	return &profile, nil
+}
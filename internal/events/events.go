@@ -0,0 +1,52 @@
+package events
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+// Event is a structured record of server activity.
+type Event struct {
+	Type      string                 `json:"type"`
+	Data      map[string]interface{} `json:"data"`
+	Timestamp time.Time              `json:"timestamp"`
+}
+
+// Sink receives the events of the server, e.g. to feed an analytics pipeline.
+type Sink interface {
+	Publish(event Event) error
+	Close() error
+}
+
+// NATSSink publishes events to NATS, on the subject "<prefix>.<event type>".
+type NATSSink struct {
+	conn   *nats.Conn
+	prefix string
+}
+
+// ConnectNATS connects to the NATS server at url. The client reconnects on its
+// own, events published while disconnected are buffered.
+func ConnectNATS(url string, prefix string) (*NATSSink, error) {
+	conn, err := nats.Connect(url, nats.Name("Peer2PeerConnector"), nats.MaxReconnects(-1))
+	if err != nil {
+		return nil, err
+	}
+	return &NATSSink{conn: conn, prefix: prefix}, nil
+}
+
+func (sink *NATSSink) Publish(event Event) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	return sink.conn.Publish(sink.prefix+"."+event.Type, body)
+}
+
+// Close flushes the buffered events and closes the connection.
+func (sink *NATSSink) Close() error {
+	err := sink.conn.Flush()
+	sink.conn.Close()
+	return err
+}
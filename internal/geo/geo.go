@@ -0,0 +1,50 @@
+package geo
+
+import (
+	"net"
+
+	"github.com/oschwald/geoip2-golang"
+)
+
+// Location is where an IP address is located.
+type Location struct {
+	Country   string  `json:"country,omitempty"`
+	City      string  `json:"city,omitempty"`
+	Latitude  float64 `json:"latitude,omitempty"`
+	Longitude float64 `json:"longitude,omitempty"`
+}
+
+// Locator looks IP addresses up in a MaxMind GeoIP2 or GeoLite2 City database.
+type Locator struct {
+	db *geoip2.Reader
+}
+
+func Open(path string) (*Locator, error) {
+	db, err := geoip2.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	return &Locator{db: db}, nil
+}
+
+// Lookup returns the location of ip, nil when the locator is nil or ip is unknown.
+func (locator *Locator) Lookup(ip string) *Location {
+	parsed := net.ParseIP(ip)
+	if locator == nil || parsed == nil {
+		return nil
+	}
+	record, err := locator.db.City(parsed)
+	if err != nil || record.Country.IsoCode == "" {
+		return nil
+	}
+	return &Location{
+		Country:   record.Country.IsoCode,
+		City:      record.City.Names["en"],
+		Latitude:  record.Location.Latitude,
+		Longitude: record.Location.Longitude,
+	}
+}
+
+func (locator *Locator) Close() error {
+	return locator.db.Close()
+}
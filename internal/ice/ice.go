@@ -0,0 +1,43 @@
+package ice
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base64"
+	"strconv"
+	"time"
+)
+
+// Server is an entry of the RTCPeerConnection iceServers list.
+type Server struct {
+	URLs       []string `json:"urls"`
+	Username   string   `json:"username,omitempty"`
+	Credential string   `json:"credential,omitempty"`
+}
+
+// Servers builds the ICE server list handed to clients. STUN servers are returned
+// as is, TURN servers get time-limited credentials for user when a secret is configured.
+func Servers(stunURLs []string, turnURLs []string, secret string, ttl time.Duration, user string) []Server {
+	servers := []Server{}
+	if len(stunURLs) > 0 {
+		servers = append(servers, Server{URLs: stunURLs})
+	}
+	if len(turnURLs) > 0 && secret != "" {
+		username, credential := TURNCredentials(secret, user, time.Now().Add(ttl))
+		servers = append(servers, Server{URLs: turnURLs, Username: username, Credential: credential})
+	}
+	return servers
+}
+
+// TURNCredentials generates credentials following the coturn REST API scheme
+// (use-auth-secret): the username is "<expiry unix time>:<user>" and the credential
+// is the base64 encoded HMAC-SHA1 of the username keyed with the shared secret.
+func TURNCredentials(secret string, user string, expires time.Time) (string, string) {
+	username := strconv.FormatInt(expires.Unix(), 10)
+	if user != "" {
+		username += ":" + user
+	}
+	mac := hmac.New(sha1.New, []byte(secret))
+	mac.Write([]byte(username))
+	return username, base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,86 @@
+package ids
+
+import (
+	"crypto/rand"
+	"fmt"
+	"math/big"
+
+	"github.com/lithammer/shortuuid"
+	"github.com/oklog/ulid/v2"
+)
+
+// codeAlphabet leaves out the characters that are easily mistaken for others, e.g. 0 and O.
+const codeAlphabet = "ABCDEFGHJKMNPQRSTUVWXYZ23456789"
+
+// MaxLength is the longest client Id accepted from a client.
+const MaxLength = 64
+
+// numericDigits is the length of the Ids made by the numeric generator.
+const numericDigits = 16
+
+// Generator makes a new random Id.
+type Generator func() string
+
+// ShortUUID makes base57 encoded UUIDs, e.g. WMYFTzZoX778PaiwjyZd59.
+func ShortUUID() string {
+	return shortuuid.New()
+}
+
+// ULID makes lexicographically sortable Ids, e.g. 01J4ZK6V2F8Q7N3M5R1T9XWY0C.
+func ULID() string {
+	return ulid.Make().String()
+}
+
+// Numeric makes random decimal Ids that don't start with a zero, e.g. 4830129475610382.
+func Numeric() string {
+	limit := new(big.Int).Exp(big.NewInt(10), big.NewInt(numericDigits-1), nil)
+	n, err := rand.Int(rand.Reader, new(big.Int).Mul(limit, big.NewInt(9)))
+	if err != nil {
+		panic(err)
+	}
+	return n.Add(n, limit).String()
+}
+
+// Code makes a random code of length characters that is easy to read out and
+// type, e.g. K7QX3M.
+func Code(length int) string {
+	code := make([]byte, length)
+	for i := range code {
+		n, err := rand.Int(rand.Reader, big.NewInt(int64(len(codeAlphabet))))
+		if err != nil {
+			panic(err)
+		}
+		code[i] = codeAlphabet[n.Int64()]
+	}
+	return string(code)
+}
+
+// NewGenerator returns the generator named format: shortuuid, ulid or numeric.
+func NewGenerator(format string) (Generator, error) {
+	switch format {
+	case "", "shortuuid":
+		return ShortUUID, nil
+	case "ulid":
+		return ULID, nil
+	case "numeric":
+		return Numeric, nil
+	}
+	return nil, fmt.Errorf("unknown id format %q", format)
+}
+
+// Valid tells if id can be used as a client Id: 1 to MaxLength letters,
+// digits, dashes, underscores or dots.
+func Valid(id string) bool {
+	if id == "" || len(id) > MaxLength {
+		return false
+	}
+	for _, char := range id {
+		switch {
+		case char >= 'a' && char <= 'z', char >= 'A' && char <= 'Z', char >= '0' && char <= '9':
+		case char == '-', char == '_', char == '.':
+		default:
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,265 @@
+// Package jsgen generates the JavaScript client SDK and its TypeScript
+// declarations from the messages of the protocol, so browser integrators get a
+// method per message instead of hand-coding the message shapes.
+package jsgen
+
+import (
+	"bytes"
+	"reflect"
+	"slices"
+	"strings"
+	"text/template"
+
+	"github.com/shankarammai/Peer2PeerConnector/internal/spec"
+)
+
+// method is a method of the SDK sending a message. Its parameters are the
+// fields of the message besides "event", in the order of the Go type.
+type method struct {
+	Name    string
+	Event   string
+	Summary string
+	Params  []param
+	// Handler is the method registering a handler for the message, set for
+	// relayed messages which reach the target client as they were sent.
+	Handler string
+}
+
+type param struct {
+	Name string
+	Type string
+}
+
+func (m method) ParamNames() string {
+	names := make([]string, len(m.Params))
+	for i, p := range m.Params {
+		names[i] = p.Name
+	}
+	return strings.Join(names, ", ")
+}
+
+func (m method) TypedParams() string {
+	typed := make([]string, len(m.Params))
+	for i, p := range m.Params {
+		typed[i] = p.Name + ": " + p.Type
+	}
+	return strings.Join(typed, ", ")
+}
+
+// methods lists the SDK methods of the messages.
+func methods(messages []spec.Message) []method {
+	listed := make([]method, 0, len(messages))
+	for _, message := range messages {
+		m := method{Name: methodName(message.Name), Event: message.Name, Summary: message.Summary}
+		t := reflect.TypeOf(message.Payload)
+		properties, _ := spec.Schema(message.Payload)["properties"].(map[string]interface{})
+		for i := 0; i < t.NumField(); i++ {
+			name, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ",")
+			schema, _ := properties[name].(map[string]interface{})
+			m.Params = append(m.Params, param{Name: name, Type: tsType(schema)})
+			if name == "to" {
+				m.Handler = "on" + strings.ReplaceAll(message.Name, "_", "")
+			}
+		}
+		listed = append(listed, m)
+	}
+	return listed
+}
+
+// methodName turns an event name like "Create_Room" into "createRoom".
+func methodName(event string) string {
+	words := strings.Split(event, "_")
+	words[0] = strings.ToLower(words[0])
+	return strings.Join(words, "")
+}
+
+// tsType returns the TypeScript type of a JSON schema made by spec.Schema.
+func tsType(schema map[string]interface{}) string {
+	switch schema["type"] {
+	case "string":
+		return "string"
+	case "boolean":
+		return "boolean"
+	case "integer", "number":
+		return "number"
+	case "array":
+		items, _ := schema["items"].(map[string]interface{})
+		return "Array<" + tsType(items) + ">"
+	case "object":
+		properties, ok := schema["properties"].(map[string]interface{})
+		if !ok {
+			values, _ := schema["additionalProperties"].(map[string]interface{})
+			return "Record<string, " + tsType(values) + ">"
+		}
+		required, _ := schema["required"].([]string)
+		names := make([]string, 0, len(properties))
+		for name := range properties {
+			names = append(names, name)
+		}
+		slices.Sort(names)
+		fields := make([]string, len(names))
+		for i, name := range names {
+			optional := "?"
+			if slices.Contains(required, name) {
+				optional = ""
+			}
+			fields[i] = quoteName(name) + optional + ": " + tsType(properties[name].(map[string]interface{}))
+		}
+		return "{ " + strings.Join(fields, "; ") + " }"
+	}
+	return "any"
+}
+
+// quoteName quotes the property names that aren't identifiers, like "screen-share".
+func quoteName(name string) string {
+	if strings.ContainsAny(name, "-. ") {
+		return `"` + name + `"`
+	}
+	return name
+}
+
+type sdk struct {
+	Version string
+	Methods []method
+}
+
+// JavaScript returns the SDK, an ES module exporting the P2PConnector class.
+func JavaScript(version string, messages []spec.Message) []byte {
+	return render(javaScript, sdk{Version: version, Methods: methods(messages)})
+}
+
+// TypeScript returns the TypeScript declarations of the SDK.
+func TypeScript(version string, messages []spec.Message) []byte {
+	return render(typeScript, sdk{Version: version, Methods: methods(messages)})
+}
+
+func render(tmpl *template.Template, data sdk) []byte {
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		// the templates are fixed, only a bug makes them fail
+		panic(err)
+	}
+	return buf.Bytes()
+}
+
+var javaScript = template.Must(template.New("p2pconnector.js").Parse(`// Peer2PeerConnector client SDK for protocol version {{.Version}}.
+// Generated by the server from the protocol messages, do not edit.
+//
+//   import { P2PConnector } from "https://<server>/sdk/p2pconnector.js";
+//   const connector = new P2PConnector("wss://<server>/");
+//   connector.onOffer((offer) => ...);
+//   const details = await connector.open();
+//   connector.createRoom({ room: "123456" });
+
+export const PROTOCOL_VERSION = "{{.Version}}";
+
+export class P2PConnector {
+    /**
+     * @param {string} url WebSocket URL of the server.
+     * @param {object} [options] the "id", "resume" token and "accessToken" of the connection.
+     */
+    constructor(url, options = {}) {
+        this.url = url;
+        this.options = options;
+        this.handlers = new Map();
+        this.socket = null;
+        this.id = null;
+    }
+
+    /**
+     * Opens the connection, resolving with the Client_Details data.
+     * @returns {Promise<object>}
+     */
+    open() {
+        const url = new URL(this.url);
+        url.searchParams.set("version", PROTOCOL_VERSION);
+        if (this.options.id) url.searchParams.set("id", this.options.id);
+        if (this.options.resume) url.searchParams.set("resume", this.options.resume);
+        if (this.options.accessToken) url.searchParams.set("access_token", this.options.accessToken);
+        return new Promise((resolve, reject) => {
+            this.socket = new WebSocket(url.toString());
+            this.socket.onmessage = (message) => {
+                const msg = JSON.parse(message.data);
+                if (msg.event === "Client_Details") {
+                    this.id = msg.data.id;
+                    resolve(msg.data);
+                }
+                this.dispatch(msg);
+            };
+            this.socket.onerror = () => reject(new Error("Could not connect to " + this.url));
+            this.socket.onclose = (event) => this.dispatch({ type: "info", event: "close", data: { code: event.code, reason: event.reason } });
+        });
+    }
+
+    /** Closes the connection. */
+    close() {
+        if (this.socket) this.socket.close();
+    }
+
+    /**
+     * Calls handler with the data and the message of each message with the
+     * event name, "*" receives every message. Error messages are named by their error.
+     * @returns {() => void} a function removing the handler.
+     */
+    on(event, handler) {
+        if (!this.handlers.has(event)) this.handlers.set(event, new Set());
+        this.handlers.get(event).add(handler);
+        return () => this.handlers.get(event).delete(handler);
+    }
+
+    dispatch(msg) {
+        for (const event of [msg.event, "*"]) {
+            for (const handler of this.handlers.get(event) || []) handler(msg.data, msg);
+        }
+    }
+
+    /** Sends a message with the event name and fields. */
+    send(event, fields = {}) {
+        this.socket.send(JSON.stringify(Object.assign({ event: event }, fields)));
+    }
+{{range .Methods}}
+    /** {{.Summary}} */
+    {{.Name}}({{.ParamNames}}) {
+        this.send("{{.Event}}", { {{.ParamNames}} });
+    }
+{{end}}{{range .Methods}}{{if .Handler}}
+    /** Receives the {{.Event}} messages relayed from other clients, the sender is in msg.from. */
+    {{.Handler}}(handler) {
+        return this.on("{{.Event}}", handler);
+    }
+{{end}}{{end}}}
+`))
+
+var typeScript = template.Must(template.New("p2pconnector.d.ts").Parse(`// Peer2PeerConnector client SDK for protocol version {{.Version}}.
+// Generated by the server from the protocol messages, do not edit.
+
+export declare const PROTOCOL_VERSION: "{{.Version}}";
+
+/** A message received from the server. */
+export interface ServerMessage<T = any> {
+    type: "info" | "update" | "error";
+    event: string;
+    data: T;
+    from?: string;
+    timestamp: string;
+    message_id: string;
+}
+
+export interface ConnectOptions {
+    id?: string;
+    resume?: string;
+    accessToken?: string;
+}
+
+export declare class P2PConnector {
+    constructor(url: string, options?: ConnectOptions);
+    id: string | null;
+    open(): Promise<{ id: string; resume_token?: string; version: string; versions: string[] }>;
+    close(): void;
+    on<T = any>(event: string, handler: (data: T, msg: ServerMessage<T>) => void): () => void;
+    send(event: string, fields?: Record<string, any>): void;
+{{range .Methods}}    /** {{.Summary}} */
+    {{.Name}}({{.TypedParams}}): void;
+{{end}}{{range .Methods}}{{if .Handler}}    {{.Handler}}(handler: (data: any, msg: ServerMessage) => void): () => void;
+{{end}}{{end}}}
+`))
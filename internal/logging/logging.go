@@ -0,0 +1,109 @@
+package logging
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/shankarammai/Peer2PeerConnector/internal/redact"
+	"github.com/sirupsen/logrus"
+)
+
+// Field names attached to log entries, kept stable so log pipelines (ELK, Loki)
+// can index and filter on them.
+const (
+	FieldClientID    = "client_id"
+	FieldRoomID      = "room_id"
+	FieldMessageType = "message_type"
+	FieldPayload     = "payload"
+)
+
+const timestampFormat = "2006-01-02 15:04:05"
+
+// Logger is the logger shared by every package of the server.
+var Logger = &logrus.Logger{
+	Out:       os.Stdout,
+	Hooks:     make(logrus.LevelHooks),
+	Level:     logrus.DebugLevel,
+	Formatter: textFormatter(),
+}
+
+// Configure sets the level and output format ("text" or "json") of the shared logger.
+func Configure(level string, format string) error {
+	parsedLevel, err := logrus.ParseLevel(level)
+	if err != nil {
+		return err
+	}
+
+	switch strings.ToLower(format) {
+	case "text":
+		Logger.SetFormatter(textFormatter())
+	case "json":
+		Logger.SetFormatter(&logrus.JSONFormatter{
+			TimestampFormat: timestampFormat,
+		})
+	default:
+		return fmt.Errorf("unknown log format %q, expected text or json", format)
+	}
+	Logger.SetLevel(parsedLevel)
+	return nil
+}
+
+// Redact filters every log entry, so payloads can be logged without leaking
+// SDP, secrets or user content.
+func Redact(filter *redact.Filter) {
+	Logger.AddHook(redactHook{filter: filter})
+}
+
+// redactHook applies a redaction filter to the message and fields of the entries.
+type redactHook struct {
+	filter *redact.Filter
+}
+
+func (hook redactHook) Levels() []logrus.Level {
+	return logrus.AllLevels
+}
+
+func (hook redactHook) Fire(entry *logrus.Entry) error {
+	entry.Message = hook.filter.Truncate(entry.Message)
+	data := make(logrus.Fields, len(entry.Data))
+	for key, value := range entry.Data {
+		switch key {
+		case FieldClientID, FieldRoomID, FieldMessageType:
+			// the fields indexed by log pipelines are kept whole
+			data[key] = value
+		default:
+			data[key] = hook.filter.Field(key, value)
+		}
+	}
+	entry.Data = data
+	return nil
+}
+
+// ForClient returns a log entry carrying the client id.
+func ForClient(clientId string) *logrus.Entry {
+	return Logger.WithFields(logrus.Fields{FieldClientID: clientId})
+}
+
+// DebugEnabled tells if debug entries are logged, so hot paths can skip
+// building them.
+func DebugEnabled() bool {
+	return Logger.IsLevelEnabled(logrus.DebugLevel)
+}
+
+// ForRoom returns a log entry carrying both the client id and the room id.
+func ForRoom(clientId string, roomId string) *logrus.Entry {
+	return Logger.WithFields(logrus.Fields{
+		FieldClientID: clientId,
+		FieldRoomID:   roomId,
+	})
+}
+
+func textFormatter() *logrus.TextFormatter {
+	return &logrus.TextFormatter{
+		DisableColors:   false,
+		TimestampFormat: timestampFormat,
+		FullTimestamp:   true,
+		ForceColors:     true,
+	}
+}
@@ -0,0 +1,66 @@
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// ContentType is the content type of the Prometheus text exposition format.
+const ContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// Metric types of the exposition format.
+const (
+	Counter = "counter"
+	Gauge   = "gauge"
+)
+
+// Label is a name/value pair attached to a sample.
+type Label struct {
+	Name  string
+	Value string
+}
+
+// Writer writes metrics in the Prometheus text exposition format.
+type Writer struct {
+	out io.Writer
+	err error
+}
+
+func NewWriter(out io.Writer) *Writer {
+	return &Writer{out: out}
+}
+
+// Header writes the HELP and TYPE lines of a metric, they must precede its samples.
+func (writer *Writer) Header(name string, kind string, help string) {
+	writer.printf("# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+}
+
+// Sample writes a sample of the metric name.
+func (writer *Writer) Sample(name string, value float64, labels ...Label) {
+	if len(labels) == 0 {
+		writer.printf("%s %s\n", name, formatValue(value))
+		return
+	}
+	pairs := make([]string, 0, len(labels))
+	for _, label := range labels {
+		pairs = append(pairs, label.Name+"="+strconv.Quote(label.Value))
+	}
+	writer.printf("%s{%s} %s\n", name, strings.Join(pairs, ","), formatValue(value))
+}
+
+// Err returns the first error met while writing.
+func (writer *Writer) Err() error {
+	return writer.err
+}
+
+func (writer *Writer) printf(format string, args ...interface{}) {
+	if writer.err == nil {
+		_, writer.err = fmt.Fprintf(writer.out, format, args...)
+	}
+}
+
+func formatValue(value float64) string {
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
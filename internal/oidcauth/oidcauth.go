@@ -0,0 +1,252 @@
+// Package oidcauth logs operators in with an OpenID Connect provider, for the
+// pages meant for people rather than clients: the admin API and the docs.
+package oidcauth
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"golang.org/x/oauth2"
+)
+
+const (
+	sessionCookie = "p2p_session"
+	stateCookie   = "p2p_oidc_state"
+	// stateTTL bounds the time spent on the provider login page.
+	stateTTL = 10 * time.Minute
+)
+
+// Session is a logged in operator.
+type Session struct {
+	Subject string    `json:"sub"`
+	Email   string    `json:"email,omitempty"`
+	Groups  []string  `json:"groups,omitempty"`
+	Expires time.Time `json:"exp"`
+}
+
+// InGroup tells if the operator belongs to one of the groups.
+func (session *Session) InGroup(groups []string) bool {
+	for _, group := range session.Groups {
+		if slices.Contains(groups, group) {
+			return true
+		}
+	}
+	return false
+}
+
+// pendingLogin is kept in a cookie while the operator logs in with the provider.
+type pendingLogin struct {
+	State   string    `json:"state"`
+	Nonce   string    `json:"nonce"`
+	Next    string    `json:"next"`
+	Expires time.Time `json:"exp"`
+}
+
+// Config configures the login with a provider.
+type Config struct {
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	// RedirectURL is the callback URL registered with the provider.
+	RedirectURL string
+	// GroupsClaim is the ID token claim listing the groups of the operator.
+	GroupsClaim string
+	// SessionSecret signs the session cookies, a random one is used when empty
+	// and sessions then end with a restart.
+	SessionSecret string
+	SessionTTL    time.Duration
+}
+
+// Provider logs operators in with an OpenID Connect provider and keeps their
+// session in a signed cookie.
+type Provider struct {
+	oauth       oauth2.Config
+	verifier    *oidc.IDTokenVerifier
+	groupsClaim string
+	key         []byte
+	sessionTTL  time.Duration
+	secure      bool
+}
+
+// New discovers the provider at the issuer URL.
+func New(ctx context.Context, config Config) (*Provider, error) {
+	provider, err := oidc.NewProvider(ctx, config.Issuer)
+	if err != nil {
+		return nil, err
+	}
+	key := []byte(config.SessionSecret)
+	if len(key) == 0 {
+		key = make([]byte, 32)
+		if _, err := rand.Read(key); err != nil {
+			return nil, err
+		}
+	}
+	return &Provider{
+		oauth: oauth2.Config{
+			ClientID:     config.ClientID,
+			ClientSecret: config.ClientSecret,
+			RedirectURL:  config.RedirectURL,
+			Endpoint:     provider.Endpoint(),
+			Scopes:       []string{oidc.ScopeOpenID, "profile", "email"},
+		},
+		verifier:    provider.Verifier(&oidc.Config{ClientID: config.ClientID}),
+		groupsClaim: config.GroupsClaim,
+		key:         key,
+		sessionTTL:  config.SessionTTL,
+		secure:      strings.HasPrefix(config.RedirectURL, "https://"),
+	}, nil
+}
+
+// Session returns the session of the request, if it carries a valid one.
+func (provider *Provider) Session(request *http.Request) (*Session, bool) {
+	var session Session
+	if !provider.readCookie(request, sessionCookie, &session) || time.Now().After(session.Expires) {
+		return nil, false
+	}
+	return &session, true
+}
+
+// RedirectToLogin sends the operator to the login, coming back to the
+// requested page afterwards.
+func (provider *Provider) RedirectToLogin(writer http.ResponseWriter, request *http.Request) {
+	http.Redirect(writer, request, "/auth/login?next="+url.QueryEscape(request.URL.RequestURI()), http.StatusFound)
+}
+
+// HandleLogin starts the login with the provider.
+func (provider *Provider) HandleLogin(writer http.ResponseWriter, request *http.Request) {
+	next := request.URL.Query().Get("next")
+	// only local paths, the login must not redirect elsewhere
+	if !strings.HasPrefix(next, "/") || strings.HasPrefix(next, "//") {
+		next = "/"
+	}
+	pending := pendingLogin{State: randomToken(), Nonce: randomToken(), Next: next, Expires: time.Now().Add(stateTTL)}
+	if err := provider.writeCookie(writer, stateCookie, pending, stateTTL); err != nil {
+		http.Error(writer, "Login failed", http.StatusInternalServerError)
+		return
+	}
+	http.Redirect(writer, request, provider.oauth.AuthCodeURL(pending.State, oidc.Nonce(pending.Nonce)), http.StatusFound)
+}
+
+// HandleCallback completes the login and opens the session.
+func (provider *Provider) HandleCallback(writer http.ResponseWriter, request *http.Request) {
+	var pending pendingLogin
+	if !provider.readCookie(request, stateCookie, &pending) || time.Now().After(pending.Expires) ||
+		request.URL.Query().Get("state") != pending.State {
+		http.Error(writer, "Invalid login state, try again", http.StatusBadRequest)
+		return
+	}
+	provider.clearCookie(writer, stateCookie)
+
+	session, err := provider.exchange(request.Context(), request.URL.Query().Get("code"), pending.Nonce)
+	if err != nil {
+		http.Error(writer, "Login failed", http.StatusUnauthorized)
+		return
+	}
+	if err := provider.writeCookie(writer, sessionCookie, session, provider.sessionTTL); err != nil {
+		http.Error(writer, "Login failed", http.StatusInternalServerError)
+		return
+	}
+	http.Redirect(writer, request, pending.Next, http.StatusFound)
+}
+
+// HandleLogout ends the session.
+func (provider *Provider) HandleLogout(writer http.ResponseWriter, request *http.Request) {
+	provider.clearCookie(writer, sessionCookie)
+	http.Redirect(writer, request, "/", http.StatusFound)
+}
+
+// exchange redeems the authorization code and reads the operator from the ID token.
+func (provider *Provider) exchange(ctx context.Context, code string, nonce string) (*Session, error) {
+	token, err := provider.oauth.Exchange(ctx, code)
+	if err != nil {
+		return nil, err
+	}
+	rawIDToken, ok := token.Extra("id_token").(string)
+	if !ok {
+		return nil, errors.New("no id_token in the token response")
+	}
+	idToken, err := provider.verifier.Verify(ctx, rawIDToken)
+	if err != nil {
+		return nil, err
+	}
+	if idToken.Nonce != nonce {
+		return nil, errors.New("invalid nonce")
+	}
+	var claims map[string]interface{}
+	if err := idToken.Claims(&claims); err != nil {
+		return nil, err
+	}
+	session := &Session{Subject: idToken.Subject, Expires: time.Now().Add(provider.sessionTTL)}
+	session.Email, _ = claims["email"].(string)
+	groups, _ := claims[provider.groupsClaim].([]interface{})
+	for _, group := range groups {
+		if group, ok := group.(string); ok {
+			session.Groups = append(session.Groups, group)
+		}
+	}
+	return session, nil
+}
+
+// writeCookie stores value in a cookie signed with the session key.
+func (provider *Provider) writeCookie(writer http.ResponseWriter, name string, value interface{}, ttl time.Duration) error {
+	payload, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	encoded := base64.RawURLEncoding.EncodeToString(payload)
+	http.SetCookie(writer, &http.Cookie{
+		Name:     name,
+		Value:    encoded + "." + provider.sign(name, encoded),
+		Path:     "/",
+		MaxAge:   int(ttl.Seconds()),
+		HttpOnly: true,
+		Secure:   provider.secure,
+		SameSite: http.SameSiteLaxMode,
+	})
+	return nil
+}
+
+// readCookie decodes a cookie written by writeCookie into value, it returns
+// false when the cookie is missing or its signature is wrong.
+func (provider *Provider) readCookie(request *http.Request, name string, value interface{}) bool {
+	cookie, err := request.Cookie(name)
+	if err != nil {
+		return false
+	}
+	encoded, signature, ok := strings.Cut(cookie.Value, ".")
+	if !ok || !hmac.Equal([]byte(signature), []byte(provider.sign(name, encoded))) {
+		return false
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(encoded)
+	return err == nil && json.Unmarshal(payload, value) == nil
+}
+
+func (provider *Provider) clearCookie(writer http.ResponseWriter, name string) {
+	http.SetCookie(writer, &http.Cookie{Name: name, Value: "", Path: "/", MaxAge: -1, HttpOnly: true, Secure: provider.secure})
+}
+
+// sign returns the HMAC-SHA256 of the cookie value with the session key. The
+// name is signed too, so a cookie can't be passed off as another one.
+func (provider *Provider) sign(name string, value string) string {
+	mac := hmac.New(sha256.New, provider.key)
+	mac.Write([]byte(name + "=" + value))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// randomToken returns an unguessable token for the state and the nonce.
+func randomToken() string {
+	token := make([]byte, 16)
+	rand.Read(token)
+	return base64.RawURLEncoding.EncodeToString(token)
+}
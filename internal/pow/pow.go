@@ -0,0 +1,45 @@
+// Package pow implements the proof-of-work puzzles anonymous clients solve
+// before creating rooms: find a nonce such that the SHA-256 of the challenge
+// followed by the nonce starts with a number of zero bits.
+package pow
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"math/bits"
+	"strconv"
+)
+
+// NewChallenge returns a random challenge.
+func NewChallenge() string {
+	challenge := make([]byte, 16)
+	rand.Read(challenge)
+	return hex.EncodeToString(challenge)
+}
+
+// Verify tells if nonce solves the challenge at the difficulty, in leading zero bits.
+func Verify(challenge string, nonce string, difficulty int) bool {
+	return leadingZeroBits(sha256.Sum256([]byte(challenge+nonce))) >= difficulty
+}
+
+// Solve finds a nonce for the challenge by brute force, as clients do.
+func Solve(challenge string, difficulty int) string {
+	for counter := 0; ; counter++ {
+		nonce := strconv.Itoa(counter)
+		if Verify(challenge, nonce, difficulty) {
+			return nonce
+		}
+	}
+}
+
+func leadingZeroBits(sum [sha256.Size]byte) int {
+	zeros := 0
+	for _, b := range sum {
+		if b != 0 {
+			return zeros + bits.LeadingZeros8(b)
+		}
+		zeros += 8
+	}
+	return zeros
+}
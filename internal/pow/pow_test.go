@@ -0,0 +1,43 @@
+package pow
+
+import (
+	"crypto/sha256"
+	"testing"
+)
+
+func TestLeadingZeroBits(t *testing.T) {
+	tests := []struct {
+		first []byte
+		zeros int
+	}{
+		{[]byte{0x80}, 0},
+		{[]byte{0x01}, 7},
+		{[]byte{0x00, 0x40}, 9},
+		{[]byte{0x00, 0x00, 0x0f}, 20},
+		{nil, 256},
+	}
+	for _, test := range tests {
+		var sum [sha256.Size]byte
+		copy(sum[:], test.first)
+		if zeros := leadingZeroBits(sum); zeros != test.zeros {
+			t.Errorf("%x: %d zero bits, want %d", test.first, zeros, test.zeros)
+		}
+	}
+}
+
+func TestSolveAndVerify(t *testing.T) {
+	challenge := NewChallenge()
+	if len(challenge) != 32 || challenge == NewChallenge() {
+		t.Fatalf("challenge %q isn't 16 random bytes in hex", challenge)
+	}
+	nonce := Solve(challenge, 12)
+	if !Verify(challenge, nonce, 12) {
+		t.Errorf("nonce %q doesn't solve %s", nonce, challenge)
+	}
+	if Verify(NewChallenge(), nonce, 64) {
+		t.Errorf("nonce %q solves another challenge at 64 bits", nonce)
+	}
+	if !Verify(challenge, "any", 0) {
+		t.Error("difficulty 0 refused a nonce")
+	}
+}
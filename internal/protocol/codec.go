@@ -0,0 +1,114 @@
+package protocol
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/gorilla/websocket"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// Codec encodes the messages exchanged with a client in one wire format.
+// The format is negotiated with the Sec-WebSocket-Protocol header.
+type Codec interface {
+	// Name is the WebSocket subprotocol selecting the codec.
+	Name() string
+	// FrameType is the WebSocket frame type the encoded messages are sent in.
+	FrameType() int
+	Marshal(v interface{}) ([]byte, error)
+	Unmarshal(data []byte, v interface{}) error
+}
+
+var (
+	JSON    Codec = jsonCodec{}
+	MsgPack Codec = msgpackCodec{}
+	CBOR    Codec = newCBORCodec()
+)
+
+// codecs lists the supported codecs in order of preference.
+var codecs = []Codec{JSON, MsgPack, CBOR}
+
+// Subprotocols returns the subprotocol names to advertise in the WebSocket upgrade.
+func Subprotocols() []string {
+	names := make([]string, len(codecs))
+	for i, codec := range codecs {
+		names[i] = codec.Name()
+	}
+	return names
+}
+
+// ForSubprotocol returns the codec negotiated with the client, JSON when none was.
+func ForSubprotocol(subprotocol string) Codec {
+	for _, codec := range codecs {
+		if codec.Name() == subprotocol {
+			return codec
+		}
+	}
+	return JSON
+}
+
+type jsonCodec struct{}
+
+func (jsonCodec) Name() string   { return "json" }
+func (jsonCodec) FrameType() int { return websocket.TextMessage }
+
+func (jsonCodec) Marshal(v interface{}) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (jsonCodec) Unmarshal(data []byte, v interface{}) error {
+	return json.Unmarshal(data, v)
+}
+
+// msgpackCodec reuses the json struct tags so messages have the same field names in every format.
+type msgpackCodec struct{}
+
+func (msgpackCodec) Name() string   { return "msgpack" }
+func (msgpackCodec) FrameType() int { return websocket.BinaryMessage }
+
+func (msgpackCodec) Marshal(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	encoder := msgpack.NewEncoder(&buf)
+	encoder.SetCustomStructTag("json")
+	if err := encoder.Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (msgpackCodec) Unmarshal(data []byte, v interface{}) error {
+	decoder := msgpack.NewDecoder(bytes.NewReader(data))
+	decoder.SetCustomStructTag("json")
+	return decoder.Decode(v)
+}
+
+type cborCodec struct {
+	encMode cbor.EncMode
+	decMode cbor.DecMode
+}
+
+func newCBORCodec() cborCodec {
+	encMode, err := cbor.EncOptions{Time: cbor.TimeRFC3339Nano}.EncMode()
+	if err != nil {
+		panic(err)
+	}
+	// decode maps with string keys, like encoding/json, so handlers see the same types
+	decMode, err := cbor.DecOptions{DefaultMapType: reflect.TypeOf(map[string]interface{}(nil))}.DecMode()
+	if err != nil {
+		panic(err)
+	}
+	return cborCodec{encMode: encMode, decMode: decMode}
+}
+
+func (cborCodec) Name() string   { return "cbor" }
+func (cborCodec) FrameType() int { return websocket.BinaryMessage }
+
+func (codec cborCodec) Marshal(v interface{}) ([]byte, error) {
+	return codec.encMode.Marshal(v)
+}
+
+func (codec cborCodec) Unmarshal(data []byte, v interface{}) error {
+	return codec.decMode.Unmarshal(data, v)
+}
@@ -0,0 +1,46 @@
+package protocol
+
+import "testing"
+
+func FuzzUnmarshal(f *testing.F) {
+	for _, codec := range []Codec{JSON, MsgPack, CBOR} {
+		encoded, err := codec.Marshal(map[string]interface{}{
+			"event": "Message",
+			"to":    "peer",
+			"data":  map[string]interface{}{"sdp": "v=0", "size": 3},
+		})
+		if err != nil {
+			f.Fatal(err)
+		}
+		f.Add(encoded)
+	}
+	f.Add([]byte(`{"event":`))
+	f.Fuzz(func(t *testing.T, message []byte) {
+		for _, codec := range []Codec{JSON, MsgPack, CBOR} {
+			var decoded map[string]interface{}
+			codec.Unmarshal(message, &decoded)
+		}
+	})
+}
+
+func BenchmarkUnmarshal(b *testing.B) {
+	for _, codec := range []Codec{JSON, MsgPack, CBOR} {
+		encoded, err := codec.Marshal(map[string]interface{}{
+			"event": "Candidate",
+			"to":    "peer",
+			"data":  map[string]interface{}{"candidate": "candidate:1 1 UDP 2122252543 192.168.1.2 49203 typ host", "sdpMid": "0", "sdpMLineIndex": 0},
+		})
+		if err != nil {
+			b.Fatal(err)
+		}
+		b.Run(codec.Name(), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				var decoded map[string]interface{}
+				if err := codec.Unmarshal(encoded, &decoded); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
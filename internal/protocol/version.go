@@ -0,0 +1,57 @@
+package protocol
+
+import "errors"
+
+// ErrUnsupportedVersion is returned for a protocol version the server doesn't speak.
+var ErrUnsupportedVersion = errors.New("unsupported protocol version")
+
+// Version decodes the messages of one version of the signalling protocol into
+// the messages of the current version, which the handlers understand. A breaking
+// change of the protocol adds a version, so clients written for older versions
+// keep working.
+type Version interface {
+	// Name is the version clients ask for when connecting.
+	Name() string
+	// Decode converts a received message to the current version.
+	Decode(msg map[string]interface{}) (map[string]interface{}, error)
+}
+
+// V1 is the first version of the protocol, and the current one.
+var V1 Version = v1{}
+
+// versions lists the supported versions, oldest first.
+var versions = []Version{V1}
+
+// Current is the latest version of the protocol.
+var Current = versions[len(versions)-1]
+
+// Versions returns the names of the supported versions, oldest first.
+func Versions() []string {
+	names := make([]string, len(versions))
+	for i, version := range versions {
+		names[i] = version.Name()
+	}
+	return names
+}
+
+// ForVersion returns the version named by the client. Clients that don't name
+// one predate versioning and speak the first version.
+func ForVersion(name string) (Version, error) {
+	if name == "" {
+		return versions[0], nil
+	}
+	for _, version := range versions {
+		if version.Name() == name {
+			return version, nil
+		}
+	}
+	return nil, ErrUnsupportedVersion
+}
+
+type v1 struct{}
+
+func (v1) Name() string { return "1" }
+
+func (v1) Decode(msg map[string]interface{}) (map[string]interface{}, error) {
+	return msg, nil
+}
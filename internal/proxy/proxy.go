@@ -0,0 +1,113 @@
+package proxy
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// Resolver derives the address of the client from the forwarding headers set
+// by trusted reverse proxies.
+type Resolver struct {
+	trusted []*net.IPNet
+}
+
+// NewResolver trusts the proxies in the given CIDR ranges or single IP addresses.
+func NewResolver(proxies []string) (*Resolver, error) {
+	resolver := &Resolver{}
+	for _, proxy := range proxies {
+		if !strings.Contains(proxy, "/") {
+			if ip := net.ParseIP(proxy); ip != nil && ip.To4() != nil {
+				proxy += "/32"
+			} else {
+				proxy += "/128"
+			}
+		}
+		_, network, err := net.ParseCIDR(proxy)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy %q: %w", proxy, err)
+		}
+		resolver.trusted = append(resolver.trusted, network)
+	}
+	return resolver, nil
+}
+
+// Trusts tells if ip is the address of a trusted proxy.
+func (resolver *Resolver) Trusts(ip string) bool {
+	parsed := net.ParseIP(ip)
+	if resolver == nil || parsed == nil {
+		return false
+	}
+	for _, network := range resolver.trusted {
+		if network.Contains(parsed) {
+			return true
+		}
+	}
+	return false
+}
+
+// ClientIP returns the IP address of the client. X-Forwarded-For is only read
+// when the request comes from a trusted proxy, the client is the right-most
+// address of the chain that is not a trusted proxy.
+func (resolver *Resolver) ClientIP(request *http.Request) string {
+	ip := remoteIP(request.RemoteAddr)
+	if !resolver.Trusts(ip) {
+		return ip
+	}
+	var chain []string
+	for _, header := range request.Header.Values("X-Forwarded-For") {
+		for _, hop := range strings.Split(header, ",") {
+			if hop = strings.TrimSpace(hop); hop != "" {
+				chain = append(chain, hop)
+			}
+		}
+	}
+	for index := len(chain) - 1; index >= 0; index-- {
+		ip = remoteIP(chain[index])
+		if !resolver.Trusts(ip) {
+			return ip
+		}
+	}
+	return ip
+}
+
+// Scheme returns the scheme the client used, "http" or "https". X-Forwarded-Proto
+// is only read when the request comes from a trusted proxy.
+func (resolver *Resolver) Scheme(request *http.Request) string {
+	if resolver.Trusts(remoteIP(request.RemoteAddr)) {
+		if proto := firstValue(request.Header.Get("X-Forwarded-Proto")); proto != "" {
+			return strings.ToLower(proto)
+		}
+	}
+	if request.TLS != nil {
+		return "https"
+	}
+	return "http"
+}
+
+// Host returns the host the client connected to. X-Forwarded-Host is only read
+// when the request comes from a trusted proxy.
+func (resolver *Resolver) Host(request *http.Request) string {
+	if resolver.Trusts(remoteIP(request.RemoteAddr)) {
+		if host := firstValue(request.Header.Get("X-Forwarded-Host")); host != "" {
+			return host
+		}
+	}
+	return request.Host
+}
+
+// firstValue returns the first item of a comma separated header, set by the
+// proxy closest to the client.
+func firstValue(header string) string {
+	value, _, _ := strings.Cut(header, ",")
+	return strings.TrimSpace(value)
+}
+
+// remoteIP strips the port from a "host:port" address.
+func remoteIP(address string) string {
+	if host, _, err := net.SplitHostPort(address); err == nil {
+		return host
+	}
+	return address
+}
@@ -0,0 +1,51 @@
+// Package push notifies users who aren't connected, e.g. of a missed call,
+// through a provider such as FCM, APNs or a webhook.
+package push
+
+import (
+	"time"
+
+	"github.com/shankarammai/Peer2PeerConnector/internal/webhook"
+)
+
+// Types of notifications.
+const (
+	TypeMissedCall = "call_missed"
+)
+
+// Notification is about something that happened to a user while offline.
+type Notification struct {
+	// UserID is the authenticated user to notify, the provider looks up the
+	// devices it registered.
+	UserID string
+	Type   string
+	Data   map[string]interface{}
+}
+
+// Notifier delivers notifications. Deployments plug their provider in, e.g.
+// FCM or APNs, a non nil error is logged by the server.
+type Notifier interface {
+	Notify(notification Notification) error
+}
+
+// Webhook posts the notifications to a URL, in the format of the server
+// webhooks, for a service of the deployment to forward them.
+type Webhook struct {
+	url        string
+	dispatcher *webhook.Dispatcher
+}
+
+// NewWebhook creates a notifier posting to url. An empty secret disables signing.
+func NewWebhook(url string, secret string, maxRetries int, timeout time.Duration) *Webhook {
+	return &Webhook{url: url, dispatcher: webhook.NewDispatcher(nil, secret, maxRetries, timeout)}
+}
+
+// Notify posts the notification in the background, failed deliveries are retried.
+func (notifier *Webhook) Notify(notification Notification) error {
+	data := map[string]interface{}{"user": notification.UserID}
+	for key, value := range notification.Data {
+		data[key] = value
+	}
+	notifier.dispatcher.EmitTo(notifier.url, notification.Type, data)
+	return nil
+}
@@ -0,0 +1,143 @@
+// Package recorder dumps the frames of a connection to a file with their
+// timing, so the session can be replayed against a test server.
+//
+// A recording is a JSON Lines file: a Header, then one Frame per line.
+package recorder
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// Directions of the frames.
+const (
+	In  = "in"
+	Out = "out"
+)
+
+// Header describes the recorded connection.
+type Header struct {
+	Client string `json:"client"`
+	// Query is the query string of the WebSocket upgrade request.
+	Query       string    `json:"query,omitempty"`
+	Subprotocol string    `json:"subprotocol,omitempty"`
+	StartedAt   time.Time `json:"started_at"`
+}
+
+// Frame is a WebSocket message sent by the client ("in") or by the server ("out").
+type Frame struct {
+	// At is the time of the frame since the start of the recording, in nanoseconds.
+	At        time.Duration `json:"at"`
+	Direction string        `json:"direction"`
+	Binary    bool          `json:"binary,omitempty"`
+	// Data is the text of the frame, base64 encoded for binary frames.
+	Data string `json:"data"`
+}
+
+// Payload returns the content of the frame as sent on the connection.
+func (frame Frame) Payload() ([]byte, error) {
+	if frame.Binary {
+		return base64.StdEncoding.DecodeString(frame.Data)
+	}
+	return []byte(frame.Data), nil
+}
+
+// Recorder writes the frames of one connection to its file.
+type Recorder struct {
+	mu      sync.Mutex
+	file    *os.File
+	encoder *json.Encoder
+	start   time.Time
+	failed  bool
+}
+
+// Create starts the recording of a connection in a new file of dir, named
+// after the start time and the client Id.
+func Create(dir string, header Header) (*Recorder, error) {
+	name := fmt.Sprintf("%s-%s.jsonl", header.StartedAt.UTC().Format("20060102T150405.000"), filepath.Base(header.Client))
+	file, err := os.OpenFile(filepath.Join(dir, name), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	// every frame is written at once, so a recording survives a crash of the server
+	recorder := &Recorder{file: file, encoder: json.NewEncoder(file), start: header.StartedAt}
+	if err := recorder.encoder.Encode(header); err != nil {
+		file.Close()
+		return nil, err
+	}
+	return recorder, nil
+}
+
+// Name returns the path of the recording.
+func (recorder *Recorder) Name() string {
+	return recorder.file.Name()
+}
+
+// Record appends a frame of the WebSocket message type to the recording. After
+// a write error the recording stops, the connection must not suffer from it.
+func (recorder *Recorder) Record(direction string, messageType int, data []byte) {
+	frame := Frame{At: time.Since(recorder.start), Direction: direction, Data: string(data)}
+	if messageType == websocket.BinaryMessage {
+		frame.Binary = true
+		frame.Data = base64.StdEncoding.EncodeToString(data)
+	}
+	recorder.mu.Lock()
+	defer recorder.mu.Unlock()
+	if recorder.failed {
+		return
+	}
+	if err := recorder.encoder.Encode(frame); err != nil {
+		recorder.failed = true
+	}
+}
+
+// Close ends the recording.
+func (recorder *Recorder) Close() error {
+	recorder.mu.Lock()
+	defer recorder.mu.Unlock()
+	return recorder.file.Close()
+}
+
+// Read decodes a recording.
+func Read(reader io.Reader) (Header, []Frame, error) {
+	decoder := json.NewDecoder(reader)
+	var header Header
+	if err := decoder.Decode(&header); err != nil {
+		return header, nil, fmt.Errorf("invalid recording header: %w", err)
+	}
+	var frames []Frame
+	for {
+		var frame Frame
+		err := decoder.Decode(&frame)
+		if err == io.EOF {
+			return header, frames, nil
+		}
+		if err != nil {
+			// the server may have stopped in the middle of a frame
+			if errors.Is(err, io.ErrUnexpectedEOF) {
+				return header, frames, nil
+			}
+			return header, frames, fmt.Errorf("invalid frame %d: %w", len(frames)+1, err)
+		}
+		frames = append(frames, frame)
+	}
+}
+
+// Open reads the recording in the file at path.
+func Open(path string) (Header, []Frame, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return Header{}, nil, err
+	}
+	defer file.Close()
+	return Read(file)
+}
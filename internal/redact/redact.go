@@ -0,0 +1,112 @@
+// Package redact hides SDP, secrets and user content in the copies of messages
+// shown to operators, e.g. by debug taps and in the logs.
+package redact
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Kinds of fields a Filter can redact.
+const (
+	SDP        = "sdp"
+	Candidates = "candidates"
+	Secrets    = "secrets"
+	Content    = "content"
+)
+
+// kindFields are the message fields of each kind, at any depth.
+var kindFields = map[string][]string{
+	SDP:        {"sdp"},
+	Candidates: {"candidate", "candidates"},
+	Secrets:    {"token", "resume_token", "password", "key", "nonce", "challenge", "code", "join_code"},
+	Content:    {"text", "chunk"},
+}
+
+// Filter replaces the values of the fields of some kinds.
+type Filter struct {
+	fields map[string]bool
+	// MaxLength truncates the longer texts left in the messages, when set.
+	MaxLength int
+}
+
+// Parse returns the filter of a comma separated list of kinds, "none" redacts nothing.
+func Parse(list string) (*Filter, error) {
+	filter := &Filter{fields: make(map[string]bool)}
+	for _, kind := range strings.Split(list, ",") {
+		kind = strings.TrimSpace(kind)
+		if kind == "" || kind == "none" {
+			continue
+		}
+		fields, ok := kindFields[kind]
+		if !ok {
+			return nil, fmt.Errorf("unknown redaction %q, use %s", kind, strings.Join(Kinds(), ", "))
+		}
+		for _, field := range fields {
+			filter.fields[field] = true
+		}
+	}
+	return filter, nil
+}
+
+// Kinds returns the kinds of fields that can be redacted.
+func Kinds() []string {
+	kinds := make([]string, 0, len(kindFields))
+	for kind := range kindFields {
+		kinds = append(kinds, kind)
+	}
+	slices.Sort(kinds)
+	return kinds
+}
+
+// Apply returns a copy of the message with the values of the filtered fields
+// replaced by their size, the message itself is left untouched.
+func (filter *Filter) Apply(msg map[string]interface{}) map[string]interface{} {
+	return filter.apply(msg).(map[string]interface{})
+}
+
+// Field returns the value of the field key as Apply leaves it.
+func (filter *Filter) Field(key string, value interface{}) interface{} {
+	if filter.fields[key] {
+		return placeholder(value)
+	}
+	return filter.apply(value)
+}
+
+func (filter *Filter) apply(value interface{}) interface{} {
+	switch value := value.(type) {
+	case map[string]interface{}:
+		redacted := make(map[string]interface{}, len(value))
+		for key, item := range value {
+			redacted[key] = filter.Field(key, item)
+		}
+		return redacted
+	case []interface{}:
+		redacted := make([]interface{}, len(value))
+		for index, item := range value {
+			redacted[index] = filter.apply(item)
+		}
+		return redacted
+	case string:
+		return filter.Truncate(value)
+	default:
+		return value
+	}
+}
+
+// Truncate cuts text to MaxLength bytes, telling the size of the whole text.
+func (filter *Filter) Truncate(text string) string {
+	if filter.MaxLength <= 0 || len(text) <= filter.MaxLength {
+		return text
+	}
+	return fmt.Sprintf("%s…[%d bytes]", strings.ToValidUTF8(text[:filter.MaxLength], ""), len(text))
+}
+
+// placeholder stands for a redacted value, telling its size to help debugging.
+func placeholder(value interface{}) string {
+	if text, ok := value.(string); ok {
+		return fmt.Sprintf("[redacted %d bytes]", len(text))
+	}
+	return "[redacted]"
+}
@@ -0,0 +1,89 @@
+package redact
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		list   string
+		fields []string
+		valid  bool
+	}{
+		{"", nil, true},
+		{"none", nil, true},
+		{"sdp", []string{"sdp"}, true},
+		{" sdp , candidates ", []string{"sdp", "candidate", "candidates"}, true},
+		{"content,secrets", []string{"text", "chunk", "token", "password"}, true},
+		{"sdp,bodies", nil, false},
+	}
+	for _, test := range tests {
+		filter, err := Parse(test.list)
+		if (err == nil) != test.valid {
+			t.Errorf("%q: error %v", test.list, err)
+			continue
+		}
+		if filter == nil {
+			continue
+		}
+		for _, field := range test.fields {
+			if !filter.fields[field] {
+				t.Errorf("%q doesn't redact %s", test.list, field)
+			}
+		}
+		if test.fields == nil && len(filter.fields) != 0 {
+			t.Errorf("%q redacts %v", test.list, filter.fields)
+		}
+	}
+}
+
+func TestApply(t *testing.T) {
+	filter, err := Parse("sdp,secrets")
+	if err != nil {
+		t.Fatal(err)
+	}
+	filter.MaxLength = 5
+	msg := map[string]interface{}{
+		"event": "Offer",
+		"data": map[string]interface{}{
+			"sdp":     "v=0\r\n",
+			"token":   42.0,
+			"answers": []interface{}{map[string]interface{}{"password": "secret"}, "a long text"},
+		},
+	}
+	want := map[string]interface{}{
+		"event": "Offer",
+		"data": map[string]interface{}{
+			"sdp":     "[redacted 5 bytes]",
+			"token":   "[redacted]",
+			"answers": []interface{}{map[string]interface{}{"password": "[redacted 6 bytes]"}, "a lon…[11 bytes]"},
+		},
+	}
+	if got := filter.Apply(msg); !reflect.DeepEqual(got, want) {
+		t.Errorf("redacted %v, want %v", got, want)
+	}
+	if msg["data"].(map[string]interface{})["sdp"] != "v=0\r\n" {
+		t.Error("the message itself was redacted")
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	tests := []struct {
+		maxLength int
+		text      string
+		want      string
+	}{
+		{0, "unlimited", "unlimited"},
+		{4, "four", "four"},
+		{4, "longer", "long…[6 bytes]"},
+		// the cut in the middle of é is left out
+		{2, "hé!", "h…[4 bytes]"},
+	}
+	for _, test := range tests {
+		filter := &Filter{MaxLength: test.maxLength}
+		if got := filter.Truncate(test.text); got != test.want {
+			t.Errorf("Truncate(%q) at %d = %q, want %q", test.text, test.maxLength, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,51 @@
+package room
+
+import "time"
+
+// MaxTimelineEntries bounds the join/leave timeline kept for a room.
+const MaxTimelineEntries = 1000
+
+// Timeline events.
+const (
+	TimelineJoined = "joined"
+	TimelineLeft   = "left"
+)
+
+// TimelineEntry is a client joining or leaving a room.
+type TimelineEntry struct {
+	Client string    `json:"client"`
+	Event  string    `json:"event"`
+	At     time.Time `json:"at"`
+}
+
+// Activity records what happened in a room, for the summary sent when it is deleted.
+type Activity struct {
+	CreatedAt       time.Time
+	PeakMembers     int
+	MessagesRelayed int64
+	Timeline        []TimelineEntry
+	// TimelineTruncated is set when entries were dropped past MaxTimelineEntries.
+	TimelineTruncated bool
+}
+
+// record appends an entry to the timeline and tracks the peak number of members.
+func (activity *Activity) record(clientId string, event string, members int) {
+	activity.PeakMembers = max(activity.PeakMembers, members)
+	if len(activity.Timeline) >= MaxTimelineEntries {
+		activity.TimelineTruncated = true
+		return
+	}
+	activity.Timeline = append(activity.Timeline, TimelineEntry{Client: clientId, Event: event, At: time.Now()})
+}
+
+// CountMessage records a message relayed in the room.
+func (room *Room) CountMessage() {
+	room.Activity.MessagesRelayed++
+}
+
+// Close records the remaining members leaving the room as it is deleted.
+func (room *Room) Close() {
+	for _, clientId := range room.Clients {
+		room.Activity.record(clientId, TimelineLeft, 0)
+	}
+}
@@ -0,0 +1,49 @@
+package room
+
+import "time"
+
+// HistoryEntry is a message retained in the history of a room.
+type HistoryEntry struct {
+	From      string      `json:"from"`
+	Data      interface{} `json:"data"`
+	Timestamp time.Time   `json:"timestamp"`
+	size      int
+}
+
+// History keeps the most recent messages of a room, bounded both by the number
+// of messages and by their total encoded size.
+type History struct {
+	entries    []HistoryEntry
+	size       int
+	maxEntries int
+	maxBytes   int
+}
+
+// NewHistory creates a history retaining at most maxEntries messages and maxBytes
+// bytes of payload. A zero limit means no limit of that kind.
+func NewHistory(maxEntries int, maxBytes int) *History {
+	return &History{maxEntries: maxEntries, maxBytes: maxBytes}
+}
+
+// Add appends a message of the given encoded size, dropping the oldest messages
+// until the history fits its limits again. A message larger than the byte budget is not kept.
+func (history *History) Add(from string, data interface{}, size int) {
+	if history.maxBytes > 0 && size > history.maxBytes {
+		return
+	}
+	history.entries = append(history.entries, HistoryEntry{From: from, Data: data, Timestamp: time.Now(), size: size})
+	history.size += size
+	for len(history.entries) > 0 &&
+		((history.maxEntries > 0 && len(history.entries) > history.maxEntries) ||
+			(history.maxBytes > 0 && history.size > history.maxBytes)) {
+		history.size -= history.entries[0].size
+		history.entries = history.entries[1:]
+	}
+}
+
+// Entries returns a copy of the retained messages, oldest first.
+func (history *History) Entries() []HistoryEntry {
+	entries := make([]HistoryEntry, len(history.entries))
+	copy(entries, history.entries)
+	return entries
+}
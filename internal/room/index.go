@@ -0,0 +1,144 @@
+package room
+
+import (
+	"sort"
+	"strings"
+)
+
+// indexEntry is a room in the sorted list of an Index.
+type indexEntry struct {
+	name string
+	id   string
+}
+
+func (entry indexEntry) less(other indexEntry) bool {
+	return entry.name < other.name || (entry.name == other.name && entry.id < other.id)
+}
+
+// Index finds rooms by name. It is updated as rooms come and go, so queries
+// don't go through every room: prefix queries binary search the names sorted
+// case-insensitively, substring queries only check the rooms sharing the
+// trigrams of the query. An Index is not safe for concurrent use.
+type Index struct {
+	entries  []indexEntry
+	names    map[string]string
+	trigrams map[string]map[string]struct{}
+}
+
+func NewIndex() *Index {
+	return &Index{
+		names:    make(map[string]string),
+		trigrams: make(map[string]map[string]struct{}),
+	}
+}
+
+// Add indexes the room id under name, replacing its previous name.
+func (index *Index) Add(id string, name string) {
+	index.Remove(id)
+	entry := indexEntry{name: strings.ToLower(name), id: id}
+	position := sort.Search(len(index.entries), func(i int) bool { return !index.entries[i].less(entry) })
+	index.entries = append(index.entries, indexEntry{})
+	copy(index.entries[position+1:], index.entries[position:])
+	index.entries[position] = entry
+	index.names[id] = entry.name
+	for _, trigram := range trigramsOf(entry.name) {
+		if index.trigrams[trigram] == nil {
+			index.trigrams[trigram] = make(map[string]struct{})
+		}
+		index.trigrams[trigram][id] = struct{}{}
+	}
+}
+
+// Remove takes the room id out of the index.
+func (index *Index) Remove(id string) {
+	name, exists := index.names[id]
+	if !exists {
+		return
+	}
+	delete(index.names, id)
+	entry := indexEntry{name: name, id: id}
+	position := sort.Search(len(index.entries), func(i int) bool { return !index.entries[i].less(entry) })
+	if position < len(index.entries) && index.entries[position] == entry {
+		index.entries = append(index.entries[:position], index.entries[position+1:]...)
+	}
+	for _, trigram := range trigramsOf(name) {
+		delete(index.trigrams[trigram], id)
+		if len(index.trigrams[trigram]) == 0 {
+			delete(index.trigrams, trigram)
+		}
+	}
+}
+
+// Len returns the number of indexed rooms.
+func (index *Index) Len() int {
+	return len(index.entries)
+}
+
+// Prefix returns the Ids of the rooms whose name starts with query, ignoring
+// case and sorted by name, skipping offset rooms and returning at most limit.
+// It also returns the number of matching rooms.
+func (index *Index) Prefix(query string, offset int, limit int) ([]string, int) {
+	query = strings.ToLower(query)
+	start := sort.Search(len(index.entries), func(i int) bool { return index.entries[i].name >= query })
+	end := start + sort.Search(len(index.entries)-start, func(i int) bool {
+		return !strings.HasPrefix(index.entries[start+i].name, query)
+	})
+	return page(index.entries[start:end], offset, limit)
+}
+
+// Substring returns the Ids of the rooms whose name contains query, ignoring
+// case and sorted by name, skipping offset rooms and returning at most limit.
+// It also returns the number of matching rooms.
+func (index *Index) Substring(query string, offset int, limit int) ([]string, int) {
+	query = strings.ToLower(query)
+	trigrams := trigramsOf(query)
+	if len(trigrams) == 0 {
+		// too short for the trigrams, every name has to be checked
+		var matches []indexEntry
+		for _, entry := range index.entries {
+			if strings.Contains(entry.name, query) {
+				matches = append(matches, entry)
+			}
+		}
+		return page(matches, offset, limit)
+	}
+
+	// the candidates are the rooms having the rarest trigram of the query
+	rarest := index.trigrams[trigrams[0]]
+	for _, trigram := range trigrams[1:] {
+		if len(index.trigrams[trigram]) < len(rarest) {
+			rarest = index.trigrams[trigram]
+		}
+	}
+	matches := make([]indexEntry, 0, len(rarest))
+	for id := range rarest {
+		if name := index.names[id]; strings.Contains(name, query) {
+			matches = append(matches, indexEntry{name: name, id: id})
+		}
+	}
+	sort.Slice(matches, func(i, j int) bool { return matches[i].less(matches[j]) })
+	return page(matches, offset, limit)
+}
+
+// page returns the Ids of entries[offset:offset+limit] and the number of entries.
+func page(entries []indexEntry, offset int, limit int) ([]string, int) {
+	ids := []string{}
+	for i := offset; i < len(entries) && i < offset+limit; i++ {
+		ids = append(ids, entries[i].id)
+	}
+	return ids, len(entries)
+}
+
+// trigramsOf returns the distinct three byte sequences of name.
+func trigramsOf(name string) []string {
+	var trigrams []string
+	seen := make(map[string]bool)
+	for i := 0; i+3 <= len(name); i++ {
+		trigram := name[i : i+3]
+		if !seen[trigram] {
+			seen[trigram] = true
+			trigrams = append(trigrams, trigram)
+		}
+	}
+	return trigrams
+}
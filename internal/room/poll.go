@@ -0,0 +1,54 @@
+package room
+
+// Poll is a question put to the members of a room. Votes are counted by the
+// server, each client votes once.
+type Poll struct {
+	Id       string
+	Question string
+	Options  []string
+	Creator  string
+	Closed   bool
+	// votes maps the clients who voted to the index of their option.
+	votes map[string]int
+}
+
+// PollResults is the tally of a poll shared with the members, it doesn't tell who voted what.
+type PollResults struct {
+	Id       string   `json:"id"`
+	Question string   `json:"question"`
+	Options  []string `json:"options"`
+	Counts   []int    `json:"counts"`
+	Votes    int      `json:"votes"`
+	Creator  string   `json:"creator"`
+	Closed   bool     `json:"closed"`
+}
+
+func NewPoll(id string, question string, options []string, creator string) *Poll {
+	return &Poll{Id: id, Question: question, Options: options, Creator: creator, votes: make(map[string]int)}
+}
+
+// Vote records the vote of clientId for the option at index, it returns false
+// if the client already voted.
+func (poll *Poll) Vote(clientId string, index int) bool {
+	if _, voted := poll.votes[clientId]; voted {
+		return false
+	}
+	poll.votes[clientId] = index
+	return true
+}
+
+func (poll *Poll) Results() PollResults {
+	counts := make([]int, len(poll.Options))
+	for _, index := range poll.votes {
+		counts[index]++
+	}
+	return PollResults{
+		Id:       poll.Id,
+		Question: poll.Question,
+		Options:  poll.Options,
+		Counts:   counts,
+		Votes:    len(poll.votes),
+		Creator:  poll.Creator,
+		Closed:   poll.Closed,
+	}
+}
@@ -1,23 +1,80 @@
 package room
 
 import (
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
 	"golang.org/x/exp/slices"
 )
 
 type Room struct {
-	Id      string
-	Name    string
-	Clients []string
-	Creator string
+	Id           string
+	Name         string
+	Clients      []string
+	Creator      string
+	MaxClients   int
+	PasswordHash string
+	Locked       bool
+	// ExpiresAt is when the room is deleted automatically, zero means never.
+	ExpiresAt time.Time
+	// Metadata is free-form data attached to the room by its creator.
+	Metadata map[string]interface{}
+	// Persistent rooms are stored across restarts and are not deleted when empty.
+	Persistent bool
+	// History retains recent messages for new joiners, nil when disabled.
+	History *History
+	// AutoNegotiate makes the server tell members who offers to whom when a client joins.
+	AutoNegotiate bool
+	// Invites maps pending invite tokens to the client they were issued for.
+	Invites map[string]string
+	// KeyEpoch counts the key rotations of members doing end-to-end encryption.
+	KeyEpoch int
+	// AnnounceOnly rooms only accept broadcasts from the creator and the moderators.
+	AnnounceOnly bool
+	// EndWhenCreatorLeaves rooms are ended when their creator leaves or is
+	// gone past the reconnect grace period.
+	EndWhenCreatorLeaves bool
+	// Moderators are members given moderation rights by the creator.
+	Moderators []string
+	// RequireApproval puts joining clients in Pending until a moderator approves them.
+	RequireApproval bool
+	// Pending are the clients waiting for approval to join.
+	Pending []string
+	// BytesRelayed counts the bytes of the messages relayed in the room.
+	BytesRelayed int64
+	// QuotaWarned is set once the members were warned about the bandwidth quota.
+	QuotaWarned bool
+	// Recording is set while the creator records the room.
+	Recording bool
+	// Public rooms can be found by name with "find_room".
+	Public bool
+	// JoinCode is a short code that can be typed instead of the room Id to join.
+	JoinCode string
+	// CallbackURL receives the events of the room, empty when it has none.
+	CallbackURL string
+	// Activity is summarised when the room is deleted.
+	Activity Activity
+	// FloorHolder is the member allowed to speak, empty when the floor is free.
+	FloorHolder string
+	// RaisedHands are the members with a raised hand, in the order they raised it.
+	RaisedHands []string
+	// Polls are the polls of the room, in the order they were created.
+	Polls []*Poll
+	// Region is where the members of the room are, e.g. "eu-west", empty when unknown.
+	Region string
 }
 
 func NewRoom(Id string, Name string, Creator string) *Room {
-	return &Room{
-		Id:      Id,
-		Name:    Name,
-		Creator: Creator,
-		Clients: []string{Creator},
+	room := &Room{
+		Id:       Id,
+		Name:     Name,
+		Creator:  Creator,
+		Clients:  []string{Creator},
+		Invites:  make(map[string]string),
+		Activity: Activity{CreatedAt: time.Now()},
 	}
+	room.Activity.record(Creator, TimelineJoined, 1)
+	return room
 }
 
 func (room Room) GetId() string {
@@ -36,18 +93,321 @@ func (room *Room) SetName(name string) {
 	room.Name = name
 }
 
+func (room Room) GetMaxClients() int {
+	return room.MaxClients
+}
+
+// SetMaxClients limits how many clients can be in the room, 0 means no limit.
+func (room *Room) SetMaxClients(maxClients int) {
+	room.MaxClients = maxClients
+}
+
+// IsFull reports whether the room has reached its client limit.
+func (room Room) IsFull() bool {
+	return room.MaxClients > 0 && len(room.Clients) >= room.MaxClients
+}
+
+// SetPassword protects the room with password, an empty password removes the protection.
+func (room *Room) SetPassword(password string) error {
+	if password == "" {
+		room.PasswordHash = ""
+		return nil
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+	room.PasswordHash = string(hash)
+	return nil
+}
+
+func (room Room) HasPassword() bool {
+	return room.PasswordHash != ""
+}
+
+// CheckPassword reports whether password unlocks the room.
+func (room Room) CheckPassword(password string) bool {
+	return CheckPasswordHash(room.PasswordHash, password)
+}
+
+// CheckPasswordHash reports whether password unlocks a room with the password
+// hash, so the slow comparison can run without holding the lock of the rooms.
+func CheckPasswordHash(passwordHash string, password string) bool {
+	if passwordHash == "" {
+		return true
+	}
+	return bcrypt.CompareHashAndPassword([]byte(passwordHash), []byte(password)) == nil
+}
+
+func (room Room) IsLocked() bool {
+	return room.Locked
+}
+
+// SetLocked locks or unlocks the room, a locked room can't be joined.
+func (room *Room) SetLocked(locked bool) {
+	room.Locked = locked
+}
+
+// SetExpiry schedules the automatic deletion of the room at expiresAt.
+func (room *Room) SetExpiry(expiresAt time.Time) {
+	room.ExpiresAt = expiresAt
+}
+
+// IsExpired reports whether the room has an expiry that is past at now.
+func (room Room) IsExpired(now time.Time) bool {
+	return !room.ExpiresAt.IsZero() && !now.Before(room.ExpiresAt)
+}
+
+// TimeLeft returns how long the room lives from now, and false if it never expires.
+func (room Room) TimeLeft(now time.Time) (time.Duration, bool) {
+	if room.ExpiresAt.IsZero() {
+		return 0, false
+	}
+	if left := room.ExpiresAt.Sub(now); left > 0 {
+		return left, true
+	}
+	return 0, true
+}
+
+func (room Room) GetMetadata() map[string]interface{} {
+	return room.Metadata
+}
+
+func (room *Room) SetMetadata(metadata map[string]interface{}) {
+	room.Metadata = metadata
+}
+
+func (room Room) IsPersistent() bool {
+	return room.Persistent
+}
+
+func (room *Room) SetPersistent(persistent bool) {
+	room.Persistent = persistent
+}
+
+func (room Room) GetHistory() *History {
+	return room.History
+}
+
+// EnableHistory starts retaining messages relayed in the room.
+func (room *Room) EnableHistory(maxEntries int, maxBytes int) {
+	room.History = NewHistory(maxEntries, maxBytes)
+}
+
+func (room Room) IsAutoNegotiate() bool {
+	return room.AutoNegotiate
+}
+
+func (room *Room) SetAutoNegotiate(autoNegotiate bool) {
+	room.AutoNegotiate = autoNegotiate
+}
+
+func (room Room) GetKeyEpoch() int {
+	return room.KeyEpoch
+}
+
+// NextKeyEpoch starts a new key epoch and returns it.
+func (room *Room) NextKeyEpoch() int {
+	room.KeyEpoch++
+	return room.KeyEpoch
+}
+
+func (room Room) IsAnnounceOnly() bool {
+	return room.AnnounceOnly
+}
+
+func (room *Room) SetAnnounceOnly(announceOnly bool) {
+	room.AnnounceOnly = announceOnly
+}
+
+func (room Room) EndsWithCreator() bool {
+	return room.EndWhenCreatorLeaves
+}
+
+func (room *Room) SetEndsWithCreator(endsWithCreator bool) {
+	room.EndWhenCreatorLeaves = endsWithCreator
+}
+
+func (room Room) GetModerators() []string {
+	return room.Moderators
+}
+
+// IsModerator tells if clientId moderates the room, the creator always does.
+func (room Room) IsModerator(clientId string) bool {
+	return clientId == room.Creator || slices.Contains(room.Moderators, clientId)
+}
+
+// SetModerator grants or revokes the moderation rights of clientId.
+func (room *Room) SetModerator(clientId string, moderator bool) {
+	index := slices.Index(room.Moderators, clientId)
+	if moderator && index == -1 {
+		room.Moderators = append(room.Moderators, clientId)
+	} else if !moderator && index != -1 {
+		room.Moderators = slices.Delete(room.Moderators, index, index+1)
+	}
+}
+
+func (room Room) RequiresApproval() bool {
+	return room.RequireApproval
+}
+
+func (room *Room) SetRequireApproval(requireApproval bool) {
+	room.RequireApproval = requireApproval
+}
+
+func (room Room) GetPending() []string {
+	return room.Pending
+}
+
+// AddPending puts clientId in the waiting list, it returns false if it already was.
+func (room *Room) AddPending(clientId string) bool {
+	if slices.Contains(room.Pending, clientId) {
+		return false
+	}
+	room.Pending = append(room.Pending, clientId)
+	return true
+}
+
+// RemovePending takes clientId out of the waiting list, it returns false if it wasn't waiting.
+func (room *Room) RemovePending(clientId string) bool {
+	index := slices.Index(room.Pending, clientId)
+	if index == -1 {
+		return false
+	}
+	room.Pending = slices.Delete(room.Pending, index, index+1)
+	return true
+}
+
+func (room Room) IsRecording() bool {
+	return room.Recording
+}
+
+func (room *Room) SetRecording(recording bool) {
+	room.Recording = recording
+}
+
+func (room Room) GetJoinCode() string {
+	return room.JoinCode
+}
+
+func (room *Room) SetJoinCode(joinCode string) {
+	room.JoinCode = joinCode
+}
+
+func (room Room) GetCallbackURL() string {
+	return room.CallbackURL
+}
+
+func (room *Room) SetCallbackURL(callbackURL string) {
+	room.CallbackURL = callbackURL
+}
+
+func (room Room) IsPublic() bool {
+	return room.Public
+}
+
+func (room *Room) SetPublic(public bool) {
+	room.Public = public
+}
+
+func (room Room) GetFloorHolder() string {
+	return room.FloorHolder
+}
+
+func (room *Room) SetFloorHolder(clientId string) {
+	room.FloorHolder = clientId
+}
+
+func (room Room) GetRaisedHands() []string {
+	return room.RaisedHands
+}
+
+// RaiseHand puts clientId at the end of the hand queue, it returns false if
+// its hand was already raised.
+func (room *Room) RaiseHand(clientId string) bool {
+	if slices.Contains(room.RaisedHands, clientId) {
+		return false
+	}
+	room.RaisedHands = append(room.RaisedHands, clientId)
+	return true
+}
+
+// LowerHand takes clientId out of the hand queue, it returns false if its hand wasn't raised.
+func (room *Room) LowerHand(clientId string) bool {
+	index := slices.Index(room.RaisedHands, clientId)
+	if index == -1 {
+		return false
+	}
+	room.RaisedHands = slices.Delete(room.RaisedHands, index, index+1)
+	return true
+}
+
+func (room Room) GetRegion() string {
+	return room.Region
+}
+
+func (room *Room) SetRegion(region string) {
+	room.Region = region
+}
+
+func (room Room) GetPolls() []*Poll {
+	return room.Polls
+}
+
+// GetPoll returns the poll with the Id, nil if the room has none.
+func (room Room) GetPoll(pollId string) *Poll {
+	for _, poll := range room.Polls {
+		if poll.Id == pollId {
+			return poll
+		}
+	}
+	return nil
+}
+
+func (room *Room) AddPoll(poll *Poll) {
+	room.Polls = append(room.Polls, poll)
+}
+
+// AddBytesRelayed records n bytes relayed in the room and returns the total.
+func (room *Room) AddBytesRelayed(n int) int64 {
+	room.BytesRelayed += int64(n)
+	return room.BytesRelayed
+}
+
+// AddInvite registers an invite token for clientId.
+func (room *Room) AddInvite(token string, clientId string) {
+	room.Invites[token] = clientId
+}
+
+// UseInvite consumes the invite token if it was issued for clientId.
+func (room *Room) UseInvite(token string, clientId string) bool {
+	invitee, ok := room.Invites[token]
+	if !ok || invitee != clientId {
+		return false
+	}
+	delete(room.Invites, token)
+	return true
+}
+
 func (room Room) GetClients() []string {
 	return room.Clients
 }
 
 func (room *Room) AddClient(clientId string) {
 	room.Clients = append(room.Clients, clientId)
+	room.Activity.record(clientId, TimelineJoined, len(room.Clients))
 }
 
 func (room *Room) RemoveClient(clientId string) []string {
 	indexToRemove := slices.Index(room.Clients, clientId)
 	if indexToRemove != -1 {
 		room.Clients = slices.Delete(room.Clients, indexToRemove, indexToRemove+1)
+		room.Activity.record(clientId, TimelineLeft, len(room.Clients))
+	}
+	room.SetModerator(clientId, false)
+	if room.FloorHolder == clientId {
+		room.FloorHolder = ""
 	}
+	room.LowerHand(clientId)
 	return room.Clients
 }
@@ -0,0 +1,85 @@
+package sdp
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Reasons an SDP is rejected for.
+const (
+	ReasonTooLarge        = "too_large"
+	ReasonMalformed       = "malformed"
+	ReasonCodecNotAllowed = "codec_not_allowed"
+)
+
+// Error describes why an SDP was rejected.
+type Error struct {
+	Reason  string
+	Message string
+}
+
+func (err *Error) Error() string {
+	return err.Message
+}
+
+// Policy is what a valid SDP has to comply with.
+type Policy struct {
+	// MaxSize is the largest accepted SDP in bytes, 0 means no limit.
+	MaxSize int
+	// Codecs is the allow-list of codec names (as in a=rtpmap), empty allows all.
+	Codecs []string
+}
+
+// Validate runs basic structural checks on an SDP (RFC 8866): the version line
+// comes first, the origin, session name and timing lines are present, every line
+// is "<type>=<value>" and there is at least one media description. The codecs of
+// the a=rtpmap attributes must be in the allow-list of the policy.
+func Validate(sdp string, policy Policy) error {
+	if policy.MaxSize > 0 && len(sdp) > policy.MaxSize {
+		return &Error{ReasonTooLarge, fmt.Sprintf("SDP is %d bytes, the limit is %d.", len(sdp), policy.MaxSize)}
+	}
+
+	lines := strings.Split(strings.ReplaceAll(strings.TrimRight(sdp, "\r\n"), "\r\n", "\n"), "\n")
+	if lines[0] != "v=0" {
+		return &Error{ReasonMalformed, "SDP must start with 'v=0'."}
+	}
+	seen := map[byte]bool{}
+	for number, line := range lines {
+		if len(line) < 2 || line[1] != '=' || line[0] < 'a' || line[0] > 'z' {
+			return &Error{ReasonMalformed, fmt.Sprintf("Line %d is not a '<type>=<value>' line.", number+1)}
+		}
+		seen[line[0]] = true
+
+		codec, isRtpmap := rtpmapCodec(line)
+		if isRtpmap && codec == "" {
+			return &Error{ReasonMalformed, fmt.Sprintf("Line %d is not a valid rtpmap attribute.", number+1)}
+		}
+		if isRtpmap && len(policy.Codecs) > 0 && !slices.ContainsFunc(policy.Codecs, func(allowed string) bool {
+			return strings.EqualFold(allowed, codec)
+		}) {
+			return &Error{ReasonCodecNotAllowed, fmt.Sprintf("Codec %s is not allowed.", codec)}
+		}
+	}
+	for _, required := range []byte{'o', 's', 't', 'm'} {
+		if !seen[required] {
+			return &Error{ReasonMalformed, fmt.Sprintf("SDP has no '%c=' line.", required)}
+		}
+	}
+	return nil
+}
+
+// rtpmapCodec returns the encoding name of an "a=rtpmap:<payload type> <name>/<clock rate>"
+// line. The name is empty when the line is a malformed rtpmap.
+func rtpmapCodec(line string) (string, bool) {
+	value, isRtpmap := strings.CutPrefix(line, "a=rtpmap:")
+	if !isRtpmap {
+		return "", false
+	}
+	_, encoding, found := strings.Cut(value, " ")
+	if !found {
+		return "", true
+	}
+	name, _, _ := strings.Cut(encoding, "/")
+	return strings.TrimSpace(name), true
+}
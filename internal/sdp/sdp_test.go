@@ -0,0 +1,71 @@
+package sdp
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+const offer = "v=0\r\n" +
+	"o=- 4611731400430051336 2 IN IP4 127.0.0.1\r\n" +
+	"s=-\r\n" +
+	"t=0 0\r\n" +
+	"m=audio 9 UDP/TLS/RTP/SAVPF 111 0\r\n" +
+	"a=rtpmap:111 opus/48000/2\r\n" +
+	"a=rtpmap:0 PCMU/8000\r\n"
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		sdp    string
+		policy Policy
+		reason string
+	}{
+		{"valid", offer, Policy{}, ""},
+		{"LF line endings", strings.ReplaceAll(offer, "\r\n", "\n"), Policy{}, ""},
+		{"allowed codecs", offer, Policy{Codecs: []string{"OPUS", "pcmu"}}, ""},
+		{"at the size limit", offer, Policy{MaxSize: len(offer)}, ""},
+		{"too large", offer, Policy{MaxSize: len(offer) - 1}, ReasonTooLarge},
+		{"empty", "", Policy{}, ReasonMalformed},
+		{"no version first", strings.Replace(offer, "v=0\r\n", "", 1) + "v=0\r\n", Policy{}, ReasonMalformed},
+		{"other version", strings.Replace(offer, "v=0", "v=1", 1), Policy{}, ReasonMalformed},
+		{"line without type", offer + "hello\r\n", Policy{}, ReasonMalformed},
+		{"uppercase type", offer + "A=recvonly\r\n", Policy{}, ReasonMalformed},
+		{"blank line", strings.Replace(offer, "s=-\r\n", "s=-\r\n\r\n", 1), Policy{}, ReasonMalformed},
+		{"no origin", strings.Replace(offer, "o=- 4611731400430051336 2 IN IP4 127.0.0.1\r\n", "", 1), Policy{}, ReasonMalformed},
+		{"no media", strings.Split(offer, "m=")[0], Policy{}, ReasonMalformed},
+		{"malformed rtpmap", offer + "a=rtpmap:96\r\n", Policy{}, ReasonMalformed},
+		{"codec not allowed", offer, Policy{Codecs: []string{"opus"}}, ReasonCodecNotAllowed},
+	}
+	for _, test := range tests {
+		err := Validate(test.sdp, test.policy)
+		if test.reason == "" {
+			if err != nil {
+				t.Errorf("%s: %v", test.name, err)
+			}
+			continue
+		}
+		var rejected *Error
+		if !errors.As(err, &rejected) || rejected.Reason != test.reason {
+			t.Errorf("%s: error %v, want reason %s", test.name, err, test.reason)
+		}
+	}
+}
+
+func TestRtpmapCodec(t *testing.T) {
+	tests := []struct {
+		line     string
+		codec    string
+		isRtpmap bool
+	}{
+		{"a=rtpmap:111 opus/48000/2", "opus", true},
+		{"a=rtpmap:96 VP8/90000", "VP8", true},
+		{"a=rtpmap:96", "", true},
+		{"a=fmtp:111 minptime=10", "", false},
+	}
+	for _, test := range tests {
+		if codec, isRtpmap := rtpmapCodec(test.line); codec != test.codec || isRtpmap != test.isRtpmap {
+			t.Errorf("%q: %q %t, want %q %t", test.line, codec, isRtpmap, test.codec, test.isRtpmap)
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package server
+
+import (
+	"bufio"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// statusRecorder remembers the status written by a handler. It passes
+// flushes and hijacks through, so SSE streams and WebSocket upgrades work,
+// and calls upgraded once a connection is hijacked.
+type statusRecorder struct {
+	http.ResponseWriter
+	status   int
+	upgraded func()
+}
+
+func (recorder *statusRecorder) WriteHeader(status int) {
+	if recorder.status == 0 {
+		recorder.status = status
+	}
+	recorder.ResponseWriter.WriteHeader(status)
+}
+
+func (recorder *statusRecorder) Write(body []byte) (int, error) {
+	if recorder.status == 0 {
+		recorder.status = http.StatusOK
+	}
+	return recorder.ResponseWriter.Write(body)
+}
+
+func (recorder *statusRecorder) Flush() {
+	if flusher, ok := recorder.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+func (recorder *statusRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	conn, buffer, err := http.NewResponseController(recorder.ResponseWriter).Hijack()
+	if err == nil {
+		// the upgrader writes the 101 on the hijacked connection itself
+		recorder.status = http.StatusSwitchingProtocols
+		if recorder.upgraded != nil {
+			recorder.upgraded()
+		}
+	}
+	return conn, buffer, err
+}
+
+func (recorder *statusRecorder) Unwrap() http.ResponseWriter {
+	return recorder.ResponseWriter
+}
+
+// LogRequests writes an access log entry for every request served by handler,
+// unless LOG_REQUESTS is off. WebSocket upgrades are logged when the
+// connection is upgraded, with status 101, other requests when the handler
+// returns, SSE streams and long polls once they end. The latency is measured
+// with the real clock, FAKE_CLOCK would stop it. The query is left out, it
+// can carry tokens.
+func LogRequests(handler http.Handler) http.Handler {
+	if !cfg().LogRequests {
+		return handler
+	}
+	return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		started := time.Now()
+		logged := false
+		logRequest := func(status int) {
+			logged = true
+			logger.WithFields(logrus.Fields{
+				"method":     request.Method,
+				"path":       request.URL.Path,
+				"status":     status,
+				"latency_ms": time.Since(started).Milliseconds(),
+				"remote_ip":  proxies.ClientIP(request),
+			}).Info("HTTP request")
+		}
+		recorder := &statusRecorder{ResponseWriter: writer}
+		recorder.upgraded = func() { logRequest(http.StatusSwitchingProtocols) }
+		defer func() {
+			if logged {
+				return
+			}
+			status := recorder.status
+			if status == 0 {
+				status = http.StatusOK
+			}
+			logRequest(status)
+		}()
+		handler.ServeHTTP(recorder, request)
+	})
+}
@@ -0,0 +1,100 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/shankarammai/Peer2PeerConnector/internal/config"
+	"github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
+)
+
+func TestStatusRecorder(t *testing.T) {
+	tests := []struct {
+		name   string
+		serve  func(http.ResponseWriter)
+		status int
+	}{
+		{"write", func(writer http.ResponseWriter) { writer.Write([]byte("ok")) }, http.StatusOK},
+		{"write header", func(writer http.ResponseWriter) { writer.WriteHeader(http.StatusNotFound) }, http.StatusNotFound},
+		{"first header", func(writer http.ResponseWriter) {
+			writer.WriteHeader(http.StatusForbidden)
+			writer.WriteHeader(http.StatusOK)
+		}, http.StatusForbidden},
+		{"header then write", func(writer http.ResponseWriter) {
+			writer.WriteHeader(http.StatusCreated)
+			writer.Write([]byte("created"))
+		}, http.StatusCreated},
+	}
+	for _, test := range tests {
+		recorder := &statusRecorder{ResponseWriter: httptest.NewRecorder()}
+		test.serve(recorder)
+		if recorder.status != test.status {
+			t.Errorf("%s: status %d, want %d", test.name, recorder.status, test.status)
+		}
+	}
+}
+
+func TestStatusRecorderHijack(t *testing.T) {
+	recorded := make(chan int, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		recorder := &statusRecorder{ResponseWriter: writer}
+		conn, _, err := recorder.Hijack()
+		if err != nil {
+			t.Errorf("hijack: %v", err)
+		} else {
+			conn.Write([]byte("HTTP/1.1 101 Switching Protocols\r\n\r\n"))
+			conn.Close()
+		}
+		recorded <- recorder.status
+	}))
+	defer server.Close()
+
+	if response, err := http.Get(server.URL); err == nil {
+		response.Body.Close()
+	}
+	if status := <-recorded; status != http.StatusSwitchingProtocols {
+		t.Errorf("hijacked status %d, want %d", status, http.StatusSwitchingProtocols)
+	}
+}
+
+func TestLogRequestsLogsUpgradesAtOnce(t *testing.T) {
+	settings := *config.Default()
+	settings.LogRequests = true
+	current.Store(&settings)
+	hook := new(test.Hook)
+	previous := logger.ReplaceHooks(logrus.LevelHooks{})
+	logger.AddHook(hook)
+	t.Cleanup(func() {
+		current.Store(nil)
+		logger.ReplaceHooks(previous)
+	})
+
+	logged := make(chan []*logrus.Entry, 1)
+	server := httptest.NewServer(LogRequests(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		conn, _, err := http.NewResponseController(writer).Hijack()
+		if err != nil {
+			t.Errorf("hijack: %v", err)
+			logged <- nil
+			return
+		}
+		conn.Write([]byte("HTTP/1.1 101 Switching Protocols\r\n\r\n"))
+		conn.Close()
+		// the session goes on here, the upgrade is already logged
+		logged <- hook.AllEntries()
+	})))
+	defer server.Close()
+
+	if response, err := http.Get(server.URL); err == nil {
+		response.Body.Close()
+	}
+	entries := <-logged
+	if len(entries) != 1 || entries[0].Data["status"] != http.StatusSwitchingProtocols {
+		t.Fatalf("entries at upgrade %v, want one with status 101", entries)
+	}
+	server.Close()
+	if count := len(hook.AllEntries()); count != 1 {
+		t.Errorf("%d entries once the session ended, want 1", count)
+	}
+}
@@ -0,0 +1,236 @@
+package server
+
+import (
+	"net/http"
+	"sync"
+	"sync/atomic"
+
+	"github.com/lithammer/shortuuid"
+	"github.com/shankarammai/Peer2PeerConnector/internal/client"
+	"github.com/shankarammai/Peer2PeerConnector/internal/clock"
+	"github.com/shankarammai/Peer2PeerConnector/internal/logging"
+	responsemessage "github.com/shankarammai/Peer2PeerConnector/internal/response"
+)
+
+// unackedMessage is a high priority message sent to a client connected with
+// acknowledgements, kept until the client acks its "message_id".
+type unackedMessage struct {
+	id       string
+	message  interface{}
+	deadline *client.Deadline
+	// from is the sender of a signalling message numbered in its stream to the
+	// client, renumber returns the message with another "seq" for the stream
+	// of the resumed connection. Both are unset for other messages.
+	from     string
+	renumber func(seq uint64) interface{}
+}
+
+// unackedQueue holds the unacked messages of a client, oldest first.
+type unackedQueue struct {
+	messages []unackedMessage
+	// token is the resume token that claims the messages once the client
+	// dropped, and timer forgets them after ACK_RETENTION. Both are unset
+	// while the client is connected.
+	token string
+	timer clock.Timer
+	// dropped is the client whose messages are held, relays to its Id are
+	// held for the resumed connection.
+	dropped *client.Client
+}
+
+var (
+	// unacked are keyed by client Id.
+	unacked   = make(map[string]*unackedQueue)
+	unackedMu sync.Mutex
+	// retransmitted counts the messages sent again to a reconnected client.
+	retransmitted atomic.Int64
+)
+
+// wantsAcks tells if the connection asks for acknowledgements with "acks=true".
+func wantsAcks(request *http.Request) bool {
+	return cfg().AckRetention > 0 && request.URL.Query().Get("acks") == "true"
+}
+
+// newAckId returns the "message_id" of a relayed message to a client with
+// acknowledgements, it replaces the one of the sender.
+func newAckId() string {
+	return shortuuid.New()
+}
+
+// retainUnacked keeps a message sent to a client with acknowledgements until
+// the client acks it, forgetting the oldest past ACK_MAX_PENDING.
+func retainUnacked(target *client.Client, pending unackedMessage) {
+	if !target.Acks {
+		return
+	}
+	unackedMu.Lock()
+	defer unackedMu.Unlock()
+	queue, exists := unacked[target.GetClientId()]
+	if !exists {
+		queue = &unackedQueue{}
+		unacked[target.GetClientId()] = queue
+	}
+	queue.messages = append(queue.messages, pending)
+	if overflow := len(queue.messages) - cfg().AckMaxPending; overflow > 0 {
+		logging.ForClient(target.GetClientId()).Warnf("%d unacked messages, forgetting the oldest", len(queue.messages))
+		queue.messages = queue.messages[overflow:]
+	}
+}
+
+// holdUnacked keeps the unacked messages of a dropped client for ACK_RETENTION,
+// for the connection resuming its session. The messages of a client the
+// server disconnected are forgotten, it can't resume its session.
+func holdUnacked(clientId string) {
+	mu.Lock()
+	droppedClient, exists := clients[clientId]
+	kicked := exists && droppedClient.Kicked
+	mu.Unlock()
+	unackedMu.Lock()
+	defer unackedMu.Unlock()
+	queue, held := unacked[clientId]
+	if !held {
+		return
+	}
+	if !exists || kicked || len(queue.messages) == 0 {
+		delete(unacked, clientId)
+		return
+	}
+	queue.token = droppedClient.ResumeToken
+	queue.dropped = droppedClient
+	queue.timer = clk.AfterFunc(cfg().AckRetention, func() { forgetUnacked(clientId, queue) })
+	logging.ForClient(clientId).Infof("Holding %d unacked messages for %s", len(queue.messages), cfg().AckRetention)
+}
+
+// forgetUnacked drops the messages of a client that did not come back in time.
+func forgetUnacked(clientId string, queue *unackedQueue) {
+	unackedMu.Lock()
+	defer unackedMu.Unlock()
+	if unacked[clientId] == queue && queue.timer != nil {
+		delete(unacked, clientId)
+		logging.ForClient(clientId).Infof("Dropped %d unacked messages", len(queue.messages))
+	}
+}
+
+// resumeUnacked returns the Id of the dropped client the resume token was
+// issued to, if its unacked messages are still held. It resumes the sessions of
+// clients in no room, whose memberships aren't held.
+func resumeUnacked(token string) (string, bool) {
+	if token == "" {
+		return "", false
+	}
+	unackedMu.Lock()
+	defer unackedMu.Unlock()
+	for clientId, queue := range unacked {
+		if queue.timer != nil && queue.token == token {
+			return clientId, true
+		}
+	}
+	return "", false
+}
+
+// unackedHeld tells if the unacked messages of a dropped client are held.
+func unackedHeld(clientId string) bool {
+	_, held := unackedClient(clientId)
+	return held
+}
+
+// unackedClient returns the dropped client whose unacked messages are held, so
+// the messages relayed to it are held with them until it resumes its session.
+func unackedClient(clientId string) (*client.Client, bool) {
+	unackedMu.Lock()
+	defer unackedMu.Unlock()
+	queue, exists := unacked[clientId]
+	if !exists || queue.timer == nil {
+		return nil, false
+	}
+	return queue.dropped, true
+}
+
+// retransmitUnacked sends the held messages of a resumed client again, in
+// order, skipping those whose time to live passed. They are kept until acked.
+// Signalling messages are numbered again in the streams of the resumed
+// connection, which start over at 1.
+func retransmitUnacked(resumed *client.Client) {
+	unackedMu.Lock()
+	queue, exists := unacked[resumed.GetClientId()]
+	if !exists {
+		unackedMu.Unlock()
+		return
+	}
+	if queue.timer != nil {
+		queue.timer.Stop()
+		queue.token, queue.timer, queue.dropped = "", nil, nil
+	}
+	if !resumed.Acks {
+		delete(unacked, resumed.GetClientId())
+		unackedMu.Unlock()
+		return
+	}
+	live := queue.messages[:0]
+	for _, pending := range queue.messages {
+		if !pending.deadline.Passed() {
+			live = append(live, pending)
+		}
+	}
+	queue.messages = live
+	pending := append([]unackedMessage(nil), live...)
+	unackedMu.Unlock()
+
+	for _, message := range pending {
+		var err error
+		if message.renumber != nil {
+			err = resendSignal(message.from, resumed, message.renumber)
+		} else {
+			err = resumed.SendAs(message.message, client.PriorityHigh, nil)
+		}
+		if err != nil {
+			logging.ForClient(resumed.Id).Debugf("Failed to retransmit %s: %v", message.id, err)
+			return
+		}
+		retransmitted.Add(1)
+	}
+	logging.ForClient(resumed.Id).Infof("Retransmitted %d unacked messages", len(pending))
+}
+
+// countUnacked returns the messages waiting for an ack, for the metrics.
+func countUnacked() int {
+	unackedMu.Lock()
+	defer unackedMu.Unlock()
+	count := 0
+	for _, queue := range unacked {
+		count += len(queue.messages)
+	}
+	return count
+}
+
+// handleAckMessage processes an "ack" message.
+// A client connected with acknowledgements lists the "message_id" of the high
+// priority messages it received in "data""message_ids", the server stops
+// holding them for a retransmission.
+func handleAckMessage(client *client.Client, msg map[string]interface{}) {
+	data, _ := msg["data"].(map[string]interface{})
+	messageIds, ok := data["message_ids"].([]interface{})
+	if !ok {
+		client.Send(responsemessage.ErrorMessage("Missing_Fields", map[string]interface{}{"message": "'data''message_ids' must be a list of message Ids."}))
+		return
+	}
+	acked := make(map[string]bool, len(messageIds))
+	for _, messageId := range messageIds {
+		if id, ok := messageId.(string); ok {
+			acked[id] = true
+		}
+	}
+	unackedMu.Lock()
+	defer unackedMu.Unlock()
+	queue, exists := unacked[client.GetClientId()]
+	if !exists {
+		return
+	}
+	left := queue.messages[:0]
+	for _, pending := range queue.messages {
+		if !acked[pending.id] {
+			left = append(left, pending)
+		}
+	}
+	queue.messages = left
+}
@@ -0,0 +1,193 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/shankarammai/Peer2PeerConnector/internal/client"
+	"github.com/shankarammai/Peer2PeerConnector/internal/clock"
+	"github.com/shankarammai/Peer2PeerConnector/internal/config"
+)
+
+// recordingTransport keeps the messages written to it, or fails every write
+// once closed.
+type recordingTransport struct {
+	mu       sync.Mutex
+	closed   bool
+	messages []map[string]interface{}
+}
+
+func (transport *recordingTransport) WriteMessage(messageType int, data []byte) error {
+	transport.mu.Lock()
+	defer transport.mu.Unlock()
+	if transport.closed {
+		return errors.New("connection closed")
+	}
+	var message map[string]interface{}
+	if err := json.Unmarshal(data, &message); err != nil {
+		return err
+	}
+	transport.messages = append(transport.messages, message)
+	return nil
+}
+
+func (transport *recordingTransport) Close() error {
+	transport.mu.Lock()
+	defer transport.mu.Unlock()
+	transport.closed = true
+	return nil
+}
+
+func (transport *recordingTransport) written() []map[string]interface{} {
+	transport.mu.Lock()
+	defer transport.mu.Unlock()
+	return append([]map[string]interface{}(nil), transport.messages...)
+}
+
+// ackSettings installs a configuration with acknowledgements and a fake clock
+// for the duration of the test.
+func ackSettings(t *testing.T, maxPending int) {
+	settings := *config.Default()
+	settings.AckRetention = time.Minute
+	settings.AckMaxPending = maxPending
+	settings.SDPValidation = false
+	current.Store(&settings)
+	previous := clk
+	clk = clock.NewFake(time.Date(2024, 8, 10, 17, 0, 0, 0, time.UTC))
+	t.Cleanup(func() {
+		current.Store(nil)
+		clk = previous
+	})
+}
+
+// ackClient registers a client connected with acknowledgements.
+func ackClient(t *testing.T, id string) (*client.Client, *recordingTransport) {
+	transport := &recordingTransport{}
+	acker := &client.Client{Id: id, Connection: transport, Status: client.StatusOnline, Acks: true, ResumeToken: id + "-token"}
+	mu.Lock()
+	clients[id] = acker
+	mu.Unlock()
+	t.Cleanup(func() {
+		removeClient(id)
+		dropSignalStreams(id)
+		unackedMu.Lock()
+		delete(unacked, id)
+		unackedMu.Unlock()
+	})
+	return acker, transport
+}
+
+func offerFrom(from string, sdp string) map[string]interface{} {
+	return map[string]interface{}{"event": MsgTypeOffer, "from": from, "data": map[string]interface{}{"sdp": sdp}}
+}
+
+func TestRetainUnackedForgetsTheOldest(t *testing.T) {
+	ackSettings(t, 2)
+	acker, _ := ackClient(t, "acker")
+
+	for _, sdp := range []string{"first", "second", "third"} {
+		if err := relaySignal("peer", acker, sendSignal(acker, offerFrom("peer", sdp), nil)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	unackedMu.Lock()
+	messages := unacked["acker"].messages
+	unackedMu.Unlock()
+	if len(messages) != 2 {
+		t.Fatalf("%d messages held, want 2", len(messages))
+	}
+	if sdp := messages[0].message.(map[string]interface{})["data"].(map[string]interface{})["sdp"]; sdp != "second" {
+		t.Errorf("oldest held message is %v, want second", sdp)
+	}
+}
+
+func TestSendSignalRetainsFailedWrites(t *testing.T) {
+	ackSettings(t, 8)
+	acker, transport := ackClient(t, "acker")
+	transport.Close()
+
+	if err := relaySignal("peer", acker, sendSignal(acker, offerFrom("peer", "offer"), nil)); err != nil {
+		t.Fatalf("relay to a dropping client failed: %v", err)
+	}
+	if count := countUnacked(); count != 1 {
+		t.Errorf("%d messages held, want 1", count)
+	}
+}
+
+func TestRelayToHeldClient(t *testing.T) {
+	ackSettings(t, 8)
+	sender := fuzzClients()
+	acker, transport := ackClient(t, "acker")
+	transport.Close()
+	if err := relaySignal("fuzzer", acker, sendSignal(acker, offerFrom("fuzzer", "first"), nil)); err != nil {
+		t.Fatal(err)
+	}
+	holdUnacked("acker")
+	removeClient("acker")
+
+	relayMessageToTarget(sender, map[string]interface{}{"event": MsgTypeOffer, "to": "acker", "data": map[string]interface{}{"sdp": "second"}})
+	if count := countUnacked(); count != 2 {
+		t.Errorf("%d messages held, want 2", count)
+	}
+}
+
+func TestHoldUnackedForgetsKickedClients(t *testing.T) {
+	ackSettings(t, 8)
+	acker, _ := ackClient(t, "acker")
+	if err := relaySignal("peer", acker, sendSignal(acker, offerFrom("peer", "offer"), nil)); err != nil {
+		t.Fatal(err)
+	}
+	mu.Lock()
+	acker.Kicked = true
+	mu.Unlock()
+
+	holdUnacked("acker")
+	if unackedHeld("acker") {
+		t.Error("messages of a kicked client are held")
+	}
+	if _, resumed := resumeUnacked(acker.ResumeToken); resumed {
+		t.Error("kicked client resumed its session")
+	}
+}
+
+func TestRetransmitUnackedRenumbers(t *testing.T) {
+	ackSettings(t, 8)
+	acker, transport := ackClient(t, "acker")
+	for _, sdp := range []string{"first", "second"} {
+		if err := relaySignal("peer", acker, sendSignal(acker, offerFrom("peer", sdp), nil)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// the second offer only is lost with the connection, the first is acked
+	sent := transport.written()
+	handleAckMessage(acker, map[string]interface{}{"data": map[string]interface{}{"message_ids": []interface{}{sent[0]["message_id"]}}})
+	transport.Close()
+	dropSignalStreams("acker")
+	holdUnacked("acker")
+
+	if err := relaySignal("peer", acker, sendSignal(acker, offerFrom("peer", "third"), nil)); err != nil {
+		t.Fatal(err)
+	}
+	dropSignalStreams("acker")
+	resumed, resumedTransport := ackClient(t, "acker")
+	retransmitUnacked(resumed)
+
+	got := resumedTransport.written()
+	if len(got) != 2 {
+		t.Fatalf("%d messages retransmitted, want 2", len(got))
+	}
+	for i, sdp := range []string{"second", "third"} {
+		if got[i]["data"].(map[string]interface{})["sdp"] != sdp {
+			t.Errorf("message %d is %v, want %s", i, got[i]["data"], sdp)
+		}
+		if got[i]["seq"] != float64(i+1) {
+			t.Errorf("message %d has seq %v, want %d", i, got[i]["seq"], i+1)
+		}
+	}
+	if got[0]["message_id"] != sent[1]["message_id"] {
+		t.Errorf("retransmitted message_id %v, want %v", got[0]["message_id"], sent[1]["message_id"])
+	}
+}
@@ -0,0 +1,293 @@
+package server
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/shankarammai/Peer2PeerConnector/internal/logging"
+	"github.com/shankarammai/Peer2PeerConnector/internal/metrics"
+	"github.com/shankarammai/Peer2PeerConnector/internal/room"
+)
+
+// checkAdmin authorises requests to the admin API and the metrics endpoint
+// with the ADMIN_TOKEN bearer token, or the OIDC session of an operator for
+// requests without one. Both are disabled when neither is configured.
+func checkAdmin(writer http.ResponseWriter, request *http.Request) bool {
+	if cfg().AdminToken == "" && operators == nil {
+		http.NotFound(writer, request)
+		return false
+	}
+	token, bearer := strings.CutPrefix(request.Header.Get("Authorization"), "Bearer ")
+	if operators != nil && !bearer {
+		return checkOperator(writer, request)
+	}
+	if cfg().AdminToken == "" || subtle.ConstantTimeCompare([]byte(cfg().AdminToken), []byte(token)) != 1 {
+		writer.Header().Set("WWW-Authenticate", "Bearer")
+		http.Error(writer, "Unauthorised", http.StatusUnauthorized)
+		return false
+	}
+	return true
+}
+
+// HandleAdmin serves the admin API below /admin/.
+//
+//	GET /admin/stats returns the aggregated connection statistics of each room.
+//	GET /admin/clients returns the connected clients.
+//	GET /admin/bandwidth returns the bytes relayed per client and per room.
+//	GET /admin/summaries returns the activity summaries of the last deleted rooms.
+//	GET /admin/archive lists the archived rooms, GET or DELETE /admin/archive/<id> one of them.
+//	GET /admin/drain tells whether the node is drained, POST drains it, DELETE cancels it.
+//	GET /admin/bans lists the bans, POST /admin/bans adds one, DELETE /admin/bans/<id> lifts it.
+//	GET /admin/rooms lists the rooms, DELETE /admin/rooms/<id> deletes one.
+//	GET /admin/events streams the server events.
+//	GET /admin/tap streams copies of the frames of a client or room over WebSocket.
+//	GET /admin/clock returns the time of the fake clock, POST advances it.
+//	GET /admin/runtime returns the goroutines and the heap and GC figures.
+//	GET /admin/config returns the reloadable settings, POST reloads them.
+//	GET /admin/debug/pprof/ serves the net/http/pprof profiles.
+func HandleAdmin(writer http.ResponseWriter, request *http.Request) {
+	if !checkAdmin(writer, request) {
+		return
+	}
+	switch request.URL.Path {
+	case "/admin/stats":
+		if request.Method != http.MethodGet {
+			http.Error(writer, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		writeJSONResponse(writer, http.StatusOK, map[string]interface{}{"rooms": summarizeRoomStats()})
+	case "/admin/clients":
+		if request.Method != http.MethodGet {
+			http.Error(writer, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		writeJSONResponse(writer, http.StatusOK, map[string]interface{}{"clients": describeClients()})
+	case "/admin/bandwidth":
+		if request.Method != http.MethodGet {
+			http.Error(writer, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		writeJSONResponse(writer, http.StatusOK, describeBandwidth())
+	case "/admin/summaries":
+		serveRoomSummaries(writer, request)
+	case "/admin/archive":
+		handleArchivesRequest(writer, request)
+	case "/admin/drain":
+		handleDrainRequest(writer, request)
+	case "/admin/bans":
+		handleBansRequest(writer, request)
+	case "/admin/rooms":
+		if request.Method != http.MethodGet {
+			http.Error(writer, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		writeJSONResponse(writer, http.StatusOK, map[string]interface{}{"rooms": describeRooms()})
+	case "/admin/events":
+		serveAdminEvents(writer, request)
+	case "/admin/tap":
+		serveTap(writer, request)
+	case "/admin/clock":
+		handleClockRequest(writer, request)
+	case "/admin/runtime":
+		handleRuntimeRequest(writer, request)
+	case "/admin/config":
+		handleConfigRequest(writer, request)
+	default:
+		if strings.HasPrefix(request.URL.Path, "/admin/bans/") {
+			handleBanRequest(writer, request)
+			return
+		}
+		if strings.HasPrefix(request.URL.Path, "/admin/rooms/") {
+			handleRoomRequest(writer, request)
+			return
+		}
+		if strings.HasPrefix(request.URL.Path, "/admin/archive/") {
+			handleArchiveRequest(writer, request)
+			return
+		}
+		if strings.HasPrefix(request.URL.Path, "/admin/debug/pprof/") {
+			handlePprofRequest(writer, request, "/admin/debug/pprof/")
+			return
+		}
+		http.NotFound(writer, request)
+	}
+}
+
+// describeClients lists the connected clients for the admin API, including the
+// address, user agent and location recorded when client info is enabled.
+func describeClients() []map[string]interface{} {
+	mu.Lock()
+	defer mu.Unlock()
+	described := make([]map[string]interface{}, 0, len(clients))
+	for _, clientItem := range clients {
+		details := map[string]interface{}{
+			"id":           clientItem.GetClientId(),
+			"status":       clientItem.GetStatus(),
+			"connected_at": clientItem.ConnectedAt,
+			"last_active":  clientItem.GetLastActive(),
+		}
+		if clientItem.RemoteAddr != "" {
+			details["remote_addr"] = clientItem.RemoteAddr
+			details["user_agent"] = clientItem.UserAgent
+		}
+		if clientItem.Location != nil {
+			details["location"] = clientItem.Location
+		}
+		if clientItem.UserID != "" {
+			details["user_id"] = clientItem.UserID
+		}
+		if clientItem.GetDevice() != "" {
+			details["device"] = clientItem.GetDevice()
+		}
+		if clientItem.GetProfile() != nil {
+			details["profile"] = clientItem.GetProfile()
+		}
+		described = append(described, details)
+	}
+	slices.SortFunc(described, func(a, b map[string]interface{}) int {
+		return strings.Compare(a["id"].(string), b["id"].(string))
+	})
+	return described
+}
+
+// describeRooms lists the rooms for the admin API, with the details members get.
+func describeRooms() []map[string]interface{} {
+	mu.Lock()
+	listed := make([]*room.Room, 0, len(rooms))
+	for _, roomItem := range rooms {
+		listed = append(listed, roomItem)
+	}
+	mu.Unlock()
+	described := make([]map[string]interface{}, 0, len(listed))
+	for _, roomItem := range listed {
+		described = append(described, roomDetails(roomItem))
+	}
+	slices.SortFunc(described, func(a, b map[string]interface{}) int {
+		return strings.Compare(a["room"].(string), b["room"].(string))
+	})
+	return described
+}
+
+// handleRoomRequest serves /admin/rooms/<id>: DELETE ends the room like its
+// creator would, the members get a "Room_Deleted" update.
+func handleRoomRequest(writer http.ResponseWriter, request *http.Request) {
+	if request.Method != http.MethodDelete {
+		http.Error(writer, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	roomId := strings.TrimPrefix(request.URL.Path, "/admin/rooms/")
+	if !adminDeleteRoom(roomId) {
+		http.NotFound(writer, request)
+		return
+	}
+	logger.Infof("Room %s deleted through the admin API", roomId)
+	writer.WriteHeader(http.StatusNoContent)
+}
+
+// adminDeleteRoom deletes a room on behalf of an operator, after notifying its
+// members. It returns false when the room doesn't exist.
+func adminDeleteRoom(roomId string) bool {
+	mu.Lock()
+	_, exists := rooms[roomId]
+	mu.Unlock()
+	if !exists {
+		return false
+	}
+	notifyUpdateIntheRoom(roomId, "Room_Deleted")
+	deleteRoom(roomId, "admin")
+	return true
+}
+
+// sortedKeys returns the keys of m in order, so metrics are listed in a stable order.
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+// ServeMetrics exposes the server metrics in the Prometheus text format.
+func ServeMetrics(writer http.ResponseWriter, request *http.Request) {
+	if !checkAdmin(writer, request) {
+		return
+	}
+	mu.Lock()
+	connected, roomCount := len(clients), len(rooms)
+	mu.Unlock()
+	summaries := summarizeRoomStats()
+	roomIds := sortedKeys(summaries)
+
+	writer.Header().Set("Content-Type", metrics.ContentType)
+	out := metrics.NewWriter(writer)
+	out.Header("p2p_clients_connected", metrics.Gauge, "Number of connected clients.")
+	out.Sample("p2p_clients_connected", float64(connected))
+	out.Header("p2p_rooms", metrics.Gauge, "Number of rooms.")
+	out.Sample("p2p_rooms", float64(roomCount))
+	out.Header("p2p_websocket_connections", metrics.Gauge, "Number of open WebSocket connections.")
+	out.Sample("p2p_websocket_connections", float64(webSocketConnections.Load()))
+	out.Header("p2p_connections_rejected_total", metrics.Counter, "WebSocket connections refused over the connection cap.")
+	out.Sample("p2p_connections_rejected_total", float64(rejectedConnections.Load()))
+	if cfg().MaxConnections > 0 {
+		out.Header("p2p_max_connections", metrics.Gauge, "Cap of the open WebSocket connections.")
+		out.Sample("p2p_max_connections", float64(cfg().MaxConnections))
+		out.Header("p2p_capacity_usage_percent", metrics.Gauge, "Open WebSocket connections in percent of the cap.")
+		out.Sample("p2p_capacity_usage_percent", capacityUsage())
+		out.Header("p2p_capacity_threshold_percent", metrics.Gauge, "Highest capacity threshold reached, 0 when none is.")
+		out.Sample("p2p_capacity_threshold_percent", float64(reachedThreshold()))
+	}
+	drained := 0.0
+	if isDraining() {
+		drained = 1
+	}
+	out.Header("p2p_draining", metrics.Gauge, "1 while the server is drained.")
+	out.Sample("p2p_draining", drained)
+	out.Header("p2p_slow_consumers_total", metrics.Counter, "Clients whose outbound queue went over OUTBOUND_QUEUE_DEPTH.")
+	out.Sample("p2p_slow_consumers_total", float64(slowConsumers.Load()))
+	out.Header("p2p_slow_consumers_disconnected_total", metrics.Counter, "Clients disconnected at OUTBOUND_QUEUE_MAX.")
+	out.Sample("p2p_slow_consumers_disconnected_total", float64(slowConsumersDisconnected.Load()))
+	out.Header("p2p_duplicates_dropped_total", metrics.Counter, "Messages dropped because their message_id was sent within DEDUP_WINDOW.")
+	out.Sample("p2p_duplicates_dropped_total", float64(duplicatesDropped.Load()))
+	out.Header("p2p_unacked_messages", metrics.Gauge, "Messages held until the client connected with acks=true acknowledges them.")
+	out.Sample("p2p_unacked_messages", float64(countUnacked()))
+	out.Header("p2p_retransmitted_total", metrics.Counter, "Unacknowledged messages sent again to a client resuming its session.")
+	out.Sample("p2p_retransmitted_total", float64(retransmitted.Load()))
+
+	roomGauges := []struct {
+		name  string
+		help  string
+		value func(roomStatsSummary) float64
+	}{
+		{"p2p_room_stats_reporters", "Members that reported connection statistics.", func(s roomStatsSummary) float64 { return float64(s.Reporters) }},
+		{"p2p_room_rtt_milliseconds", "Average round-trip time reported by the members.", func(s roomStatsSummary) float64 { return s.RTT }},
+		{"p2p_room_packet_loss_ratio", "Average packet loss reported by the members.", func(s roomStatsSummary) float64 { return s.PacketLoss }},
+		{"p2p_room_bitrate_bits_per_second", "Average bitrate reported by the members.", func(s roomStatsSummary) float64 { return s.Bitrate }},
+	}
+	for _, gauge := range roomGauges {
+		out.Header(gauge.name, metrics.Gauge, gauge.help)
+		for _, roomId := range roomIds {
+			out.Sample(gauge.name, gauge.value(summaries[roomId]), metrics.Label{Name: "room", Value: roomId})
+		}
+	}
+	bandwidth := describeBandwidth()
+	clientIds := sortedKeys(bandwidth.Clients)
+	out.Header("p2p_client_received_bytes_total", metrics.Counter, "Bytes received from the client.")
+	for _, clientId := range clientIds {
+		out.Sample("p2p_client_received_bytes_total", float64(bandwidth.Clients[clientId].BytesIn), metrics.Label{Name: "client", Value: clientId})
+	}
+	out.Header("p2p_client_sent_bytes_total", metrics.Counter, "Bytes sent to the client.")
+	for _, clientId := range clientIds {
+		out.Sample("p2p_client_sent_bytes_total", float64(bandwidth.Clients[clientId].BytesOut), metrics.Label{Name: "client", Value: clientId})
+	}
+	out.Header("p2p_room_relayed_bytes_total", metrics.Counter, "Bytes of the messages relayed in the room.")
+	for _, roomId := range sortedKeys(bandwidth.Rooms) {
+		out.Sample("p2p_room_relayed_bytes_total", float64(bandwidth.Rooms[roomId]), metrics.Label{Name: "room", Value: roomId})
+	}
+
+	if err := out.Err(); err != nil {
+		logging.Logger.Debug("Failed to write metrics: ", err)
+	}
+}
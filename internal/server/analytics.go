@@ -0,0 +1,74 @@
+package server
+
+import (
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/shankarammai/Peer2PeerConnector/internal/room"
+)
+
+// EventRoomSummary carries the activity of a deleted room, for billing and analytics.
+const EventRoomSummary = "room_summary"
+
+// maxRoomSummaries is the number of summaries kept for the admin API.
+const maxRoomSummaries = 100
+
+var (
+	// roomSummaries are the summaries of the last deleted rooms, oldest first.
+	roomSummaries   []map[string]interface{}
+	roomSummariesMu sync.Mutex
+)
+
+// summarizeRoom describes the activity of a room being deleted. The caller must
+// hold mu, the remaining members are recorded as leaving.
+func summarizeRoom(myRoom *room.Room, reason string) map[string]interface{} {
+	myRoom.Close()
+	activity := myRoom.Activity
+	deletedAt := time.Now()
+	return map[string]interface{}{
+		"room":               myRoom.GetId(),
+		"name":               myRoom.GetName(),
+		"reason":             reason,
+		"created_at":         activity.CreatedAt,
+		"deleted_at":         deletedAt,
+		"duration":           deletedAt.Sub(activity.CreatedAt).Seconds(),
+		"peak_members":       activity.PeakMembers,
+		"messages_relayed":   activity.MessagesRelayed,
+		"bytes_relayed":      myRoom.BytesRelayed,
+		"timeline":           slices.Clone(activity.Timeline),
+		"timeline_truncated": activity.TimelineTruncated,
+	}
+}
+
+// emitRoomSummary sends the summary of a deleted room to the webhooks and the
+// event stream, and keeps it for the admin API.
+func emitRoomSummary(summary map[string]interface{}) {
+	roomSummariesMu.Lock()
+	roomSummaries = append(roomSummaries, summary)
+	if len(roomSummaries) > maxRoomSummaries {
+		roomSummaries = slices.Delete(roomSummaries, 0, len(roomSummaries)-maxRoomSummaries)
+	}
+	roomSummariesMu.Unlock()
+	emitEvent(EventRoomSummary, summary)
+}
+
+// serveRoomSummaries answers GET /admin/summaries with the summaries of the last
+// deleted rooms, newest first, optionally only those of the ?room= Id.
+func serveRoomSummaries(writer http.ResponseWriter, request *http.Request) {
+	if request.Method != http.MethodGet {
+		http.Error(writer, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	roomId := request.URL.Query().Get("room")
+	roomSummariesMu.Lock()
+	summaries := make([]map[string]interface{}, 0, len(roomSummaries))
+	for index := len(roomSummaries) - 1; index >= 0; index-- {
+		if roomId == "" || roomSummaries[index]["room"] == roomId {
+			summaries = append(summaries, roomSummaries[index])
+		}
+	}
+	roomSummariesMu.Unlock()
+	writeJSONResponse(writer, http.StatusOK, map[string]interface{}{"summaries": summaries})
+}
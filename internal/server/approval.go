@@ -0,0 +1,100 @@
+package server
+
+import (
+	"slices"
+
+	"github.com/shankarammai/Peer2PeerConnector/internal/client"
+	"github.com/shankarammai/Peer2PeerConnector/internal/logging"
+	responsemessage "github.com/shankarammai/Peer2PeerConnector/internal/response"
+	"github.com/shankarammai/Peer2PeerConnector/internal/room"
+)
+
+// requestJoinApproval puts the client in the waiting list of the room and asks
+// the creator and the moderators to approve it.
+func requestJoinApproval(client *client.Client, myRoom *room.Room) {
+	from := client.GetClientId()
+	mu.Lock()
+	added := myRoom.AddPending(from)
+	moderators := []string{}
+	for _, member := range myRoom.GetClients() {
+		if myRoom.IsModerator(member) {
+			moderators = append(moderators, member)
+		}
+	}
+	mu.Unlock()
+
+	client.Send(responsemessage.InfoMessage("Join_Pending", map[string]interface{}{"room": myRoom.GetId()}))
+	if !added {
+		return
+	}
+	logging.ForRoom(from, myRoom.GetId()).Info("Client waiting for approval")
+	request := responsemessage.InfoMessage("Join_Request", map[string]interface{}{
+		"room":   myRoom.GetId(),
+		"client": from,
+	})
+	for _, moderator := range connectedClients(moderators) {
+		moderator.Send(request)
+	}
+}
+
+// handleJoinDecisionMessage processes "approve_join" and "reject_join" messages.
+// A moderator of the room admits a waiting client or turns it away.
+func handleJoinDecisionMessage(client *client.Client, msg map[string]interface{}) {
+	if !checkRoomInJSON(client, msg) {
+		return
+	}
+	data := msg["data"].(map[string]interface{})
+	roomId, _ := data["room"].(string)
+	from := client.GetClientId()
+	target, ok := data["client"].(string)
+	if !ok {
+		client.Send(responsemessage.ErrorMessage("Missing_Fields", map[string]interface{}{"message": "'data''client' field is missing or is not a client Id."}))
+		return
+	}
+	approved := msg["event"] == MsgTypeApproveJoin
+
+	mu.Lock()
+	myRoom, exists := rooms[roomId]
+	if !exists {
+		mu.Unlock()
+		client.Send(responsemessage.ErrorMessage("Not_Found", map[string]interface{}{"message": "Room with Id " + roomId + " does not exist."}))
+		return
+	}
+	if !myRoom.IsModerator(from) || !slices.Contains(myRoom.GetClients(), from) {
+		mu.Unlock()
+		client.Send(responsemessage.ErrorMessage("Unauthorised", map[string]interface{}{"message": "You need to moderate the room to approve or reject clients."}))
+		return
+	}
+	if approved && myRoom.IsFull() {
+		mu.Unlock()
+		client.Send(responsemessage.ErrorMessage("Room_Full", map[string]interface{}{"message": "Room " + roomId + " is full."}))
+		return
+	}
+	waiting := myRoom.RemovePending(target)
+	targetClient, connected := clients[target]
+	mu.Unlock()
+
+	if !waiting || !connected {
+		client.Send(responsemessage.ErrorMessage("Not_Found", map[string]interface{}{"message": "Client " + target + " is not waiting to join the room."}))
+		return
+	}
+	if approved {
+		logging.ForRoom(from, roomId).Info("Join approved: ", target)
+		admitClient(targetClient, myRoom)
+		return
+	}
+	logging.ForRoom(from, roomId).Info("Join rejected: ", target)
+	targetClient.Send(responsemessage.ErrorMessage("Join_Rejected", map[string]interface{}{
+		"room":    roomId,
+		"message": "Your request to join room " + roomId + " was rejected.",
+	}))
+}
+
+// withdrawJoinRequests takes a disconnecting client out of every waiting list.
+func withdrawJoinRequests(clientId string) {
+	mu.Lock()
+	defer mu.Unlock()
+	for _, roomItem := range rooms {
+		roomItem.RemovePending(clientId)
+	}
+}
@@ -0,0 +1,148 @@
+package server
+
+import (
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lithammer/shortuuid"
+	"github.com/shankarammai/Peer2PeerConnector/internal/room"
+	"github.com/shankarammai/Peer2PeerConnector/internal/store"
+)
+
+var (
+	// archives maps archive Ids to the deleted rooms kept for ROOM_ARCHIVE_RETENTION.
+	archives   = make(map[string]store.ArchiveRecord)
+	archivesMu sync.Mutex
+)
+
+// restoreArchives loads the archived rooms from the store.
+func restoreArchives() error {
+	records, err := roomStore.LoadArchives()
+	if err != nil {
+		return err
+	}
+	archivesMu.Lock()
+	defer archivesMu.Unlock()
+	for _, record := range records {
+		archives[record.Id] = record
+	}
+	logger.Infof("Restored %d archived rooms", len(records))
+	return nil
+}
+
+// archiveRecord describes the deleted room for the archive, or returns nil
+// when the archive is disabled. mu must be held.
+func archiveRecord(myRoom *room.Room, reason string, summary map[string]interface{}) *store.ArchiveRecord {
+	if cfg().RoomArchiveRetention <= 0 {
+		return nil
+	}
+	deletedAt := clk.Now()
+	record := store.RecordFromRoom(myRoom)
+	// the archive is for the operators, not for joining again
+	record.PasswordHash = ""
+	return &store.ArchiveRecord{
+		Id:        shortuuid.New(),
+		Room:      record,
+		Reason:    reason,
+		DeletedAt: deletedAt,
+		ExpiresAt: deletedAt.Add(cfg().RoomArchiveRetention),
+		Summary:   summary,
+	}
+}
+
+// archiveRoom keeps the record of a deleted room, in the store too when
+// persistence is enabled.
+func archiveRoom(record *store.ArchiveRecord) {
+	if record == nil {
+		return
+	}
+	archivesMu.Lock()
+	archives[record.Id] = *record
+	archivesMu.Unlock()
+	if roomStore != nil {
+		if err := roomStore.SaveArchive(*record); err != nil {
+			logger.Errorf("Failed to archive room %s in the store: %v", record.Room.Id, err)
+		}
+	}
+}
+
+// expireArchives forgets the archived rooms whose retention has passed.
+func expireArchives(now time.Time) {
+	archivesMu.Lock()
+	var expired []string
+	for archiveId, record := range archives {
+		if now.After(record.ExpiresAt) {
+			expired = append(expired, archiveId)
+			delete(archives, archiveId)
+		}
+	}
+	archivesMu.Unlock()
+	for _, archiveId := range expired {
+		forgetArchive(archiveId)
+	}
+}
+
+// forgetArchive removes an archived room from the store.
+func forgetArchive(archiveId string) {
+	if roomStore == nil {
+		return
+	}
+	if err := roomStore.DeleteArchive(archiveId); err != nil {
+		logger.Errorf("Failed to delete archive %s from the store: %v", archiveId, err)
+	}
+}
+
+// listArchives returns the archived rooms, of one room Id when roomId is not
+// empty, newest first.
+func listArchives(roomId string) []store.ArchiveRecord {
+	archivesMu.Lock()
+	defer archivesMu.Unlock()
+	listed := make([]store.ArchiveRecord, 0, len(archives))
+	for _, record := range archives {
+		if (roomId == "" || record.Room.Id == roomId) && !clk.Now().After(record.ExpiresAt) {
+			listed = append(listed, record)
+		}
+	}
+	slices.SortFunc(listed, func(a, b store.ArchiveRecord) int { return b.DeletedAt.Compare(a.DeletedAt) })
+	return listed
+}
+
+// handleArchivesRequest serves /admin/archive: GET lists the archived rooms,
+// ?room= keeps those of one room Id.
+func handleArchivesRequest(writer http.ResponseWriter, request *http.Request) {
+	if request.Method != http.MethodGet {
+		http.Error(writer, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	writeJSONResponse(writer, http.StatusOK, map[string]interface{}{"archives": listArchives(request.URL.Query().Get("room"))})
+}
+
+// handleArchiveRequest serves /admin/archive/<id>: GET returns the archived
+// room, DELETE forgets it before its retention has passed.
+func handleArchiveRequest(writer http.ResponseWriter, request *http.Request) {
+	if request.Method != http.MethodGet && request.Method != http.MethodDelete {
+		http.Error(writer, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	archiveId := strings.TrimPrefix(request.URL.Path, "/admin/archive/")
+	archivesMu.Lock()
+	record, exists := archives[archiveId]
+	if exists && request.Method == http.MethodDelete {
+		delete(archives, archiveId)
+	}
+	archivesMu.Unlock()
+	if !exists || clk.Now().After(record.ExpiresAt) {
+		http.NotFound(writer, request)
+		return
+	}
+	if request.Method == http.MethodDelete {
+		forgetArchive(archiveId)
+		logger.Infof("Archive %s of room %s deleted", archiveId, record.Room.Id)
+		writer.WriteHeader(http.StatusNoContent)
+		return
+	}
+	writeJSONResponse(writer, http.StatusOK, record)
+}
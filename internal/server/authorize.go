@@ -0,0 +1,71 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/shankarammai/Peer2PeerConnector/internal/authz"
+	"github.com/shankarammai/Peer2PeerConnector/internal/client"
+	"github.com/shankarammai/Peer2PeerConnector/internal/logging"
+	responsemessage "github.com/shankarammai/Peer2PeerConnector/internal/response"
+)
+
+// authorizer is consulted by the handlers before acting on a message.
+var authorizer authz.Authorizer = authz.AllowAll{}
+
+// SetAuthorizer replaces the authorizer chosen by the configuration with a
+// custom policy. It must be called after Init and before the server starts
+// accepting connections.
+func SetAuthorizer(custom authz.Authorizer) {
+	authorizer = custom
+}
+
+// newAuthorizer returns the authorizer named by the configuration.
+func newAuthorizer() (authz.Authorizer, error) {
+	switch cfg().Authorizer {
+	case "", "allow_all":
+		return authz.AllowAll{}, nil
+	case "jwt":
+		if cfg().JWTSecret == "" {
+			return nil, errors.New("the jwt authorizer needs JWT_SECRET")
+		}
+		return authz.NewJWTClaims(cfg().JWTSecret, cfg().JWTIssuer, cfg().JWTAudience), nil
+	default:
+		return nil, errors.New("unknown authorizer " + cfg().Authorizer + ", use allow_all or jwt")
+	}
+}
+
+// authenticate verifies the access token of a connection, given in the
+// access_token query parameter or as a bearer token, when the authorizer
+// authenticates clients. It returns the user Id and the claims of the token.
+func authenticate(request *http.Request) (string, map[string]interface{}, error) {
+	authenticator, ok := authorizer.(authz.Authenticator)
+	if !ok {
+		return "", nil, nil
+	}
+	token := request.URL.Query().Get("access_token")
+	if token == "" {
+		token, _ = strings.CutPrefix(request.Header.Get("Authorization"), "Bearer ")
+	}
+	if token == "" {
+		return "", nil, authz.ErrUnauthenticated
+	}
+	return authenticator.Authenticate(token)
+}
+
+// subjectOf describes the client to the authorizer.
+func subjectOf(client *client.Client) authz.Subject {
+	return authz.Subject{ClientId: client.GetClientId(), UserID: client.UserID, Claims: client.Claims}
+}
+
+// checkAuthorized reports an action denied by the authorizer to the client, it
+// returns true when err is nil.
+func checkAuthorized(client *client.Client, event string, err error) bool {
+	if err == nil {
+		return true
+	}
+	logging.ForClient(client.Id).WithField(logging.FieldMessageType, event).Debug("Denied by the authorizer: ", err)
+	client.Send(responsemessage.ErrorMessage("Unauthorised", map[string]interface{}{"message": err.Error(), "event": event}))
+	return false
+}
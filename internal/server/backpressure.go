@@ -0,0 +1,39 @@
+package server
+
+import (
+	"sync/atomic"
+
+	"github.com/shankarammai/Peer2PeerConnector/internal/client"
+	"github.com/shankarammai/Peer2PeerConnector/internal/logging"
+	responsemessage "github.com/shankarammai/Peer2PeerConnector/internal/response"
+)
+
+var (
+	// slowConsumers counts the clients whose outbound queue went over OUTBOUND_QUEUE_DEPTH.
+	slowConsumers atomic.Int64
+	// slowConsumersDisconnected counts the clients disconnected at OUTBOUND_QUEUE_MAX.
+	slowConsumersDisconnected atomic.Int64
+)
+
+// warnSlowConsumer tells a client whose outbound queue went over
+// OUTBOUND_QUEUE_DEPTH that its low priority messages are dropped until it
+// catches up, and notifies the webhooks.
+func warnSlowConsumer(client *client.Client) {
+	queued := client.Queued()
+	slowConsumers.Add(1)
+	logging.ForClient(client.Id).Warnf("Slow consumer, %d messages queued, dropping low priority messages", queued)
+	client.Send(responsemessage.UpdateMessage("Slow_Consumer", map[string]interface{}{
+		"message": "Your connection can't keep up, chat messages are dropped until it catches up.",
+		"queued":  queued,
+		"max":     cfg().OutboundQueueMax,
+	}))
+	emitEvent(EventSlowConsumer, map[string]interface{}{"client": client.GetClientId(), "queued": queued})
+}
+
+// disconnectSlowConsumer disconnects a client whose outbound queue reached
+// OUTBOUND_QUEUE_MAX, rather than keep queueing messages it can't receive.
+func disconnectSlowConsumer(client *client.Client) {
+	slowConsumersDisconnected.Add(1)
+	logging.ForClient(client.Id).Warn("Disconnecting slow consumer, its outbound queue is full")
+	disconnectClient(client)
+}
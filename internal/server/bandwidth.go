@@ -0,0 +1,122 @@
+package server
+
+import (
+	"slices"
+
+	"github.com/shankarammai/Peer2PeerConnector/internal/client"
+	"github.com/shankarammai/Peer2PeerConnector/internal/logging"
+	responsemessage "github.com/shankarammai/Peer2PeerConnector/internal/response"
+)
+
+// roomRelayEvents are the messages counted towards the bandwidth and the
+// activity summary of their room.
+var roomRelayEvents = []string{
+	MsgTypeOffer, MsgTypeAnswer, MsgTypeCandidate, MsgTypeCandidates, MsgTypeMessage,
+	MsgTypeBroadcast, MsgTypeBroadcastToTag, MsgTypeRoomMessage,
+}
+
+// accountTraffic records the size of a message received from the client, and for
+// relays addressed to a room, the bytes relayed in the room. It enforces the
+// bandwidth quotas and returns false when the client or its room went over the
+// hard quota, the message must then be dropped.
+func accountTraffic(client *client.Client, msg map[string]interface{}, size int) bool {
+	if !accountClientTraffic(client, size) {
+		return false
+	}
+	roomId, scoped := msg["room"].(string)
+	event, _ := msg["event"].(string)
+	if !scoped || !slices.Contains(roomRelayEvents, event) {
+		return true
+	}
+
+	mu.Lock()
+	myRoom, exists := rooms[roomId]
+	if !exists || !slices.Contains(myRoom.GetClients(), client.GetClientId()) {
+		mu.Unlock()
+		return true
+	}
+	relayed := myRoom.AddBytesRelayed(size)
+	myRoom.CountMessage()
+	warn := cfg().RoomQuotaSoft > 0 && relayed > cfg().RoomQuotaSoft && !myRoom.QuotaWarned
+	if warn {
+		myRoom.QuotaWarned = true
+	}
+	members := slices.Clone(myRoom.GetClients())
+	mu.Unlock()
+
+	if cfg().RoomQuotaHard > 0 && relayed > cfg().RoomQuotaHard {
+		logging.ForRoom(client.Id, roomId).Warn("Room went over its bandwidth quota")
+		notifyUpdateIntheRoom(roomId, "Quota_Exceeded")
+		deleteRoom(roomId, "quota")
+		return false
+	}
+	if warn {
+		warning := responsemessage.UpdateMessage("Quota_Warning", map[string]interface{}{
+			"room":  roomId,
+			"bytes": relayed,
+			"quota": cfg().RoomQuotaSoft,
+		})
+		for _, member := range connectedClients(members) {
+			member.Send(warning)
+		}
+	}
+	return true
+}
+
+// accountClientTraffic records the bytes received from the client and enforces its quotas.
+func accountClientTraffic(client *client.Client, size int) bool {
+	received := client.AddBytesIn(size)
+	if cfg().ClientQuotaHard > 0 && received > cfg().ClientQuotaHard {
+		logging.ForClient(client.Id).Warn("Client went over its bandwidth quota")
+		client.Send(responsemessage.ErrorMessage("Quota_Exceeded", map[string]interface{}{
+			"message": "You sent more data than allowed and are disconnected.",
+			"bytes":   received,
+			"quota":   cfg().ClientQuotaHard,
+		}))
+		disconnectClient(client)
+		return false
+	}
+
+	mu.Lock()
+	warn := cfg().ClientQuotaSoft > 0 && received > cfg().ClientQuotaSoft && !client.QuotaWarned
+	if warn {
+		client.QuotaWarned = true
+	}
+	mu.Unlock()
+	if warn {
+		client.Send(responsemessage.UpdateMessage("Quota_Warning", map[string]interface{}{
+			"bytes": received,
+			"quota": cfg().ClientQuotaSoft,
+		}))
+	}
+	return true
+}
+
+// clientBandwidth is the traffic of a client.
+type clientBandwidth struct {
+	BytesIn  int64 `json:"bytes_in"`
+	BytesOut int64 `json:"bytes_out"`
+}
+
+// bandwidthReport is the traffic per client and the bytes relayed per room.
+type bandwidthReport struct {
+	Clients map[string]clientBandwidth `json:"clients"`
+	Rooms   map[string]int64           `json:"rooms"`
+}
+
+// describeBandwidth reports the bytes relayed per client and per room for the admin API.
+func describeBandwidth() bandwidthReport {
+	mu.Lock()
+	defer mu.Unlock()
+	report := bandwidthReport{
+		Clients: make(map[string]clientBandwidth, len(clients)),
+		Rooms:   make(map[string]int64, len(rooms)),
+	}
+	for id, clientItem := range clients {
+		report.Clients[id] = clientBandwidth{BytesIn: clientItem.BytesIn(), BytesOut: clientItem.BytesOut()}
+	}
+	for id, roomItem := range rooms {
+		report.Rooms[id] = roomItem.BytesRelayed
+	}
+	return report
+}
@@ -0,0 +1,246 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/netip"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lithammer/shortuuid"
+	"github.com/shankarammai/Peer2PeerConnector/internal/client"
+	"github.com/shankarammai/Peer2PeerConnector/internal/logging"
+	responsemessage "github.com/shankarammai/Peer2PeerConnector/internal/response"
+	"github.com/shankarammai/Peer2PeerConnector/internal/store"
+)
+
+// errBanned refuses connections of clients banned from the server.
+var errBanned = errors.New("banned from the server")
+
+// maxBanLifetime bounds the expires_in of a ban in seconds, so its expiry
+// can't overflow. Longer bans are meant to be permanent.
+const maxBanLifetime = 365 * 24 * 60 * 60
+
+var (
+	// bans maps ban ids to the bans from rooms and from the server.
+	bans   = make(map[string]store.BanRecord)
+	bansMu sync.Mutex
+)
+
+// restoreBans loads the bans from the store.
+func restoreBans() error {
+	records, err := roomStore.LoadBans()
+	if err != nil {
+		return err
+	}
+	bansMu.Lock()
+	defer bansMu.Unlock()
+	for _, record := range records {
+		bans[record.Id] = record
+	}
+	logger.Infof("Restored %d bans", len(records))
+	return nil
+}
+
+// banMatches tells if the ban applies to the client Id or the address in the room,
+// an empty roomId only matches bans from the server.
+func banMatches(ban store.BanRecord, roomId string, clientId string, ip string) bool {
+	if ban.Room != "" && ban.Room != roomId {
+		return false
+	}
+	if !ban.ExpiresAt.IsZero() && clk.Now().After(ban.ExpiresAt) {
+		return false
+	}
+	if ban.ClientId != "" && ban.ClientId == clientId {
+		return true
+	}
+	return ban.IP != "" && ipMatches(ban.IP, ip)
+}
+
+// ipMatches tells if the address ip is the address or in the CIDR range banned.
+func ipMatches(banned string, ip string) bool {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return false
+	}
+	if prefix, err := netip.ParsePrefix(banned); err == nil {
+		return prefix.Contains(addr.Unmap())
+	}
+	bannedAddr, err := netip.ParseAddr(banned)
+	return err == nil && bannedAddr.Unmap() == addr.Unmap()
+}
+
+// isBanned tells if the client Id or the address is banned from the room,
+// bans from the server apply to every room.
+func isBanned(roomId string, clientId string, ip string) bool {
+	bansMu.Lock()
+	defer bansMu.Unlock()
+	for _, ban := range bans {
+		if banMatches(ban, roomId, clientId, ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkNotBanned reports to the client that it is banned from the room.
+func checkNotBanned(client *client.Client, roomId string) bool {
+	if !isBanned(roomId, client.GetClientId(), client.IP) {
+		return true
+	}
+	logging.ForRoom(client.Id, roomId).Debug("Banned client refused")
+	client.Send(responsemessage.ErrorMessage("Banned", map[string]interface{}{"message": "You are banned from room " + roomId + ".", "room": roomId}))
+	return false
+}
+
+// addBan records the ban and applies it: the matching clients are disconnected
+// from the server, or removed from the room.
+func addBan(ban store.BanRecord) {
+	bansMu.Lock()
+	bans[ban.Id] = ban
+	bansMu.Unlock()
+	if roomStore != nil {
+		if err := roomStore.SaveBan(ban); err != nil {
+			logger.Error("Failed to persist ban: ", err)
+		}
+	}
+
+	mu.Lock()
+	var banned []*client.Client
+	for _, clientItem := range clients {
+		if banMatches(ban, ban.Room, clientItem.GetClientId(), clientItem.IP) {
+			banned = append(banned, clientItem)
+		}
+	}
+	member := func(clientId string) bool { return false }
+	if myRoom, exists := rooms[ban.Room]; exists {
+		members := slices.Clone(myRoom.GetClients())
+		member = func(clientId string) bool { return slices.Contains(members, clientId) }
+	}
+	mu.Unlock()
+
+	for _, clientItem := range banned {
+		if ban.Room == "" {
+			clientItem.Send(responsemessage.ErrorMessage("Banned", map[string]interface{}{"message": "You are banned from the server."}))
+			disconnectClient(clientItem)
+		} else if member(clientItem.GetClientId()) {
+			clientItem.Send(responsemessage.UpdateMessage("Banned", map[string]interface{}{"room": ban.Room}))
+			removeClientFromRoom(clientItem.GetClientId(), false, ban.Room)
+		}
+	}
+}
+
+// removeBan lifts a ban, it returns false if there is no such ban.
+func removeBan(banId string) bool {
+	bansMu.Lock()
+	_, exists := bans[banId]
+	delete(bans, banId)
+	bansMu.Unlock()
+	if exists && roomStore != nil {
+		if err := roomStore.DeleteBan(banId); err != nil {
+			logger.Error("Failed to delete ban from the store: ", err)
+		}
+	}
+	return exists
+}
+
+// expireBans lifts the bans whose expiry has passed.
+func expireBans(now time.Time) {
+	bansMu.Lock()
+	var expired []string
+	for banId, ban := range bans {
+		if !ban.ExpiresAt.IsZero() && now.After(ban.ExpiresAt) {
+			expired = append(expired, banId)
+		}
+	}
+	bansMu.Unlock()
+
+	for _, banId := range expired {
+		if removeBan(banId) {
+			logger.Infof("Ban %s expired", banId)
+		}
+	}
+}
+
+// listBans returns the bans in effect, of one room when roomId is not empty,
+// oldest first.
+func listBans(roomId string) []store.BanRecord {
+	bansMu.Lock()
+	defer bansMu.Unlock()
+	listed := make([]store.BanRecord, 0, len(bans))
+	for _, ban := range bans {
+		if (roomId == "" || ban.Room == roomId) && (ban.ExpiresAt.IsZero() || clk.Now().Before(ban.ExpiresAt)) {
+			listed = append(listed, ban)
+		}
+	}
+	slices.SortFunc(listed, func(a, b store.BanRecord) int { return a.CreatedAt.Compare(b.CreatedAt) })
+	return listed
+}
+
+// banRequest is the body of POST /admin/bans.
+type banRequest struct {
+	Room      string `json:"room,omitempty" doc:"Room the ban applies to, the whole server when empty."`
+	ClientId  string `json:"client_id,omitempty"`
+	IP        string `json:"ip,omitempty" doc:"Address or CIDR range."`
+	Reason    string `json:"reason,omitempty"`
+	ExpiresIn int    `json:"expires_in,omitempty" doc:"Seconds until the ban is lifted, never when 0."`
+}
+
+// handleBansRequest serves /admin/bans: GET lists the bans, ?room= keeps those
+// of one room, POST adds one.
+func handleBansRequest(writer http.ResponseWriter, request *http.Request) {
+	switch request.Method {
+	case http.MethodGet:
+		writeJSONResponse(writer, http.StatusOK, map[string]interface{}{"bans": listBans(request.URL.Query().Get("room"))})
+	case http.MethodPost:
+		var body banRequest
+		if err := json.NewDecoder(http.MaxBytesReader(writer, request.Body, maxPollMessageSize)).Decode(&body); err != nil {
+			http.Error(writer, "Invalid body", http.StatusBadRequest)
+			return
+		}
+		if body.ClientId == "" && body.IP == "" {
+			http.Error(writer, "Set 'client_id' or 'ip'", http.StatusBadRequest)
+			return
+		}
+		if _, addrErr := netip.ParseAddr(body.IP); body.IP != "" && addrErr != nil {
+			if _, prefixErr := netip.ParsePrefix(body.IP); prefixErr != nil {
+				http.Error(writer, "'ip' must be an address or a CIDR range", http.StatusBadRequest)
+				return
+			}
+		}
+		ban := store.BanRecord{
+			Id:        shortuuid.New(),
+			Room:      body.Room,
+			ClientId:  body.ClientId,
+			IP:        body.IP,
+			Reason:    body.Reason,
+			CreatedAt: clk.Now(),
+		}
+		if body.ExpiresIn > 0 {
+			ban.ExpiresAt = ban.CreatedAt.Add(time.Duration(min(body.ExpiresIn, maxBanLifetime)) * time.Second)
+		}
+		addBan(ban)
+		logger.Infof("Ban %s added (room %q, client %q, ip %q)", ban.Id, ban.Room, ban.ClientId, ban.IP)
+		writeJSONResponse(writer, http.StatusCreated, ban)
+	default:
+		http.Error(writer, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleBanRequest serves /admin/bans/<id>: DELETE lifts the ban.
+func handleBanRequest(writer http.ResponseWriter, request *http.Request) {
+	if request.Method != http.MethodDelete {
+		http.Error(writer, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	banId := strings.TrimPrefix(request.URL.Path, "/admin/bans/")
+	if !removeBan(banId) {
+		http.NotFound(writer, request)
+		return
+	}
+	logger.Infof("Ban %s lifted", banId)
+	writer.WriteHeader(http.StatusNoContent)
+}
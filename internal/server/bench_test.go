@@ -0,0 +1,111 @@
+package server
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/shankarammai/Peer2PeerConnector/internal/client"
+	"github.com/shankarammai/Peer2PeerConnector/internal/logging"
+	"github.com/shankarammai/Peer2PeerConnector/internal/room"
+	"github.com/sirupsen/logrus"
+)
+
+// The benchmarks run the handlers against clients whose connection drops
+// every message, so they measure the server rather than the network.
+
+var candidateMessage = []byte(`{"event":"Candidate","to":"peer","data":{"candidate":"candidate:1 1 UDP 2122252543 192.168.1.2 49203 typ host","sdpMid":"0","sdpMLineIndex":0}}`)
+
+// benchClients registers the fuzz clients with logging at the info level, as
+// in production, for the duration of the benchmark.
+func benchClients(b *testing.B) *client.Client {
+	sender := fuzzClients()
+	logging.Logger.SetLevel(logrus.InfoLevel)
+	b.Cleanup(func() { logging.Logger.SetLevel(logrus.DebugLevel) })
+	return sender
+}
+
+// benchRoom creates a room of the sender and size other members.
+func benchRoom(b *testing.B, sender *client.Client, size int) string {
+	roomId := fmt.Sprintf("bench-%s-%d", b.Name(), size)
+	benchRoom := room.NewRoom(roomId, "Bench", sender.Id)
+	memberIds := make([]string, 0, size)
+	mu.Lock()
+	for i := 0; i < size; i++ {
+		member := &client.Client{Id: fmt.Sprintf("%s-%d", roomId, i), Connection: discardTransport{}, Status: client.StatusOnline}
+		clients[member.Id] = member
+		benchRoom.AddClient(member.Id)
+		memberIds = append(memberIds, member.Id)
+	}
+	rooms[roomId] = benchRoom
+	mu.Unlock()
+	b.Cleanup(func() {
+		mu.Lock()
+		defer mu.Unlock()
+		delete(rooms, roomId)
+		for _, memberId := range memberIds {
+			delete(clients, memberId)
+		}
+	})
+	return roomId
+}
+
+// BenchmarkRelayCandidate compares relaying a candidate on the raw bytes with
+// decoding it and encoding it again.
+func BenchmarkRelayCandidate(b *testing.B) {
+	sender := benchClients(b)
+
+	b.Run("raw", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if !relayFast(sender, candidateMessage) {
+				b.Fatal("candidate not relayed on the raw bytes")
+			}
+		}
+	})
+	b.Run("decoded", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			var msg map[string]interface{}
+			if err := sender.GetCodec().Unmarshal(candidateMessage, &msg); err != nil {
+				b.Fatal(err)
+			}
+			relayMessageToTarget(sender, msg)
+		}
+	})
+}
+
+// BenchmarkJoinLeave joins a room of 8 members and leaves it again.
+func BenchmarkJoinLeave(b *testing.B) {
+	sender := benchClients(b)
+	member := &client.Client{Id: "bench-joiner", Connection: discardTransport{}, Status: client.StatusOnline}
+	mu.Lock()
+	clients[member.Id] = member
+	mu.Unlock()
+	b.Cleanup(func() { removeClient(member.Id) })
+	roomId := benchRoom(b, sender, 8)
+	join := []byte(`{"event":"Join_Room","data":{"room":"` + roomId + `"}}`)
+	leave := []byte(`{"event":"Leave_Room","data":{"room":"` + roomId + `"}}`)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		handleMessage(member, join)
+		handleMessage(member, leave)
+	}
+}
+
+// BenchmarkBroadcast fans a broadcast out to rooms of growing size.
+func BenchmarkBroadcast(b *testing.B) {
+	sender := benchClients(b)
+	for _, size := range []int{4, 32, 256} {
+		b.Run(fmt.Sprint(size), func(b *testing.B) {
+			roomId := benchRoom(b, sender, size)
+			broadcast := []byte(`{"event":"Broadcast","room":"` + roomId + `","data":{"text":"hello everyone"}}`)
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				handleMessage(sender, broadcast)
+			}
+		})
+	}
+}
@@ -0,0 +1,75 @@
+package server
+
+import (
+	"errors"
+
+	"github.com/gorilla/websocket"
+	"github.com/shankarammai/Peer2PeerConnector/internal/client"
+	"github.com/shankarammai/Peer2PeerConnector/internal/logging"
+	responsemessage "github.com/shankarammai/Peer2PeerConnector/internal/response"
+)
+
+var errInvalidEnvelope = errors.New("binary frame must start with the length of the client id followed by the client id")
+
+// decodeEnvelope splits a binary frame into the client id and the opaque payload.
+// The frame starts with one byte holding the length of the client id, then the id.
+func decodeEnvelope(frame []byte) (string, []byte, error) {
+	if len(frame) < 1 {
+		return "", nil, errInvalidEnvelope
+	}
+	idLength := int(frame[0])
+	if idLength == 0 || len(frame) < 1+idLength {
+		return "", nil, errInvalidEnvelope
+	}
+	return string(frame[1 : 1+idLength]), frame[1+idLength:], nil
+}
+
+// encodeEnvelope builds a binary frame carrying payload for (or from) the client id.
+func encodeEnvelope(clientId string, payload []byte) []byte {
+	frame := make([]byte, 0, 1+len(clientId)+len(payload))
+	frame = append(frame, byte(len(clientId)))
+	frame = append(frame, clientId...)
+	return append(frame, payload...)
+}
+
+// handleBinaryMessage relays a binary frame to the client named in its envelope.
+// The target receives the same envelope with the sender id in place of its own,
+// so binary data can be exchanged without the base64 cost of the JSON path.
+func handleBinaryMessage(client *client.Client, frame []byte) {
+	markActive(client)
+	tapIncoming(client, nil, len(frame))
+	if !accountClientTraffic(client, len(frame)) {
+		return
+	}
+	targetID, payload, err := decodeEnvelope(frame)
+	if err != nil {
+		client.Send(responsemessage.ErrorMessage("Invalid_Envelope", map[string]interface{}{"message": err.Error()}))
+		return
+	}
+
+	mu.Lock()
+	targetClient, exists := clients[targetID]
+	mu.Unlock()
+	if !exists {
+		logging.ForClient(client.Id).Debugf("Target client %s not found", targetID)
+		client.Send(responsemessage.ErrorMessage("Not_Found", map[string]interface{}{"message": "Client with given " + targetID + " not found"}))
+		return
+	}
+	if refuseBlocked(client, targetClient) {
+		return
+	}
+	// clients with a binary codec read every binary frame as a message
+	if codec := targetClient.GetCodec(); codec.FrameType() == websocket.BinaryMessage {
+		client.Send(responsemessage.ErrorMessage("Invalid_Envelope", map[string]interface{}{"message": "Client " + targetID + " uses the " + codec.Name() + " encoding and can't receive binary envelopes"}))
+		return
+	}
+	if !checkAuthorized(client, "", authorizer.CanRelay(subjectOf(client), "", "", targetID)) {
+		return
+	}
+
+	if err := targetClient.WriteBinary(encodeEnvelope(client.GetClientId(), payload)); err != nil {
+		logging.ForClient(client.Id).Debugf("Failed to relay binary frame to target client %s: %v", targetID, err)
+	} else {
+		countRelay(1)
+	}
+}
@@ -0,0 +1,72 @@
+package server
+
+import (
+	"github.com/shankarammai/Peer2PeerConnector/internal/client"
+	"github.com/shankarammai/Peer2PeerConnector/internal/logging"
+	responsemessage "github.com/shankarammai/Peer2PeerConnector/internal/response"
+)
+
+// maxBlockedClients bounds the block list of a client.
+const maxBlockedClients = 1024
+
+// handleBlockMessage processes "block_client" and "unblock_client" messages.
+// Messages from a blocked client are no longer relayed to the client, the
+// blocked client is not told about it until it tries to send one.
+func handleBlockMessage(client *client.Client, msg map[string]interface{}) {
+	data, dataOk := msg["data"].(map[string]interface{})
+	if !dataOk {
+		client.Send(responsemessage.ErrorMessage("Missing_Fields", map[string]interface{}{"message": "'data' field is missing or is not object in the request."}))
+		return
+	}
+	target, ok := data["client"].(string)
+	if !ok || target == "" || target == client.GetClientId() {
+		client.Send(responsemessage.ErrorMessage("Missing_Fields", map[string]interface{}{"message": "'data''client' field is missing or is not the Id of another client."}))
+		return
+	}
+
+	event, _ := msg["event"].(string)
+	mu.Lock()
+	if event == MsgTypeBlockClient && len(client.GetBlocked()) >= maxBlockedClients {
+		mu.Unlock()
+		client.Send(responsemessage.ErrorMessage("Limit_Exceeded", map[string]interface{}{
+			"message": "Too many blocked clients.",
+			"limit":   maxBlockedClients,
+		}))
+		return
+	}
+	if event == MsgTypeBlockClient {
+		client.Block(target)
+	} else {
+		client.Unblock(target)
+	}
+	mu.Unlock()
+
+	if event == MsgTypeBlockClient {
+		logging.ForClient(client.Id).Info("Blocked client: ", target)
+		client.Send(responsemessage.InfoMessage("Client_Blocked", map[string]interface{}{"client": target}))
+	} else {
+		logging.ForClient(client.Id).Info("Unblocked client: ", target)
+		client.Send(responsemessage.InfoMessage("Client_Unblocked", map[string]interface{}{"client": target}))
+	}
+}
+
+// blockedBy tells if target blocked the client with Id from.
+func blockedBy(target *client.Client, from string) bool {
+	mu.Lock()
+	defer mu.Unlock()
+	return target.HasBlocked(from)
+}
+
+// refuseBlocked answers the client with a "Blocked" error when target blocked
+// it. It returns false when the message can be relayed.
+func refuseBlocked(client *client.Client, target *client.Client) bool {
+	if !blockedBy(target, client.GetClientId()) {
+		return false
+	}
+	logging.ForClient(client.Id).Debugf("Not relaying to %s, blocked", target.GetClientId())
+	client.Send(responsemessage.ErrorMessage("Blocked", map[string]interface{}{
+		"message": "Client " + target.GetClientId() + " does not accept your messages.",
+		"to":      target.GetClientId(),
+	}))
+	return true
+}
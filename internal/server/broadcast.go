@@ -0,0 +1,134 @@
+package server
+
+import (
+	"slices"
+	"time"
+
+	"github.com/shankarammai/Peer2PeerConnector/internal/client"
+	"github.com/shankarammai/Peer2PeerConnector/internal/logging"
+	responsemessage "github.com/shankarammai/Peer2PeerConnector/internal/response"
+)
+
+// handleBroadcastMessage processes "broadcast", "broadcast_to_tag" and "room_message" messages.
+// It relays the data to every other member of the room named by the "room" field,
+// or only to the members carrying the "tag" field for "broadcast_to_tag".
+// A "room_message" is chat: it also goes back to the sender, stamped with the
+// sender name and the server time so every member orders it the same way.
+// In announce-only rooms only the creator and the moderators may broadcast.
+// Members who blocked the sender are skipped.
+func handleBroadcastMessage(client *client.Client, msg map[string]interface{}) {
+	roomId, ok := msg["room"].(string)
+	if !ok {
+		client.Send(responsemessage.ErrorMessage("Missing_Fields", map[string]interface{}{"message": "'room' field is missing or is not a room Id."}))
+		return
+	}
+	from := client.GetClientId()
+	event, _ := msg["event"].(string)
+	tag, tagged := msg["tag"].(string)
+	if event == MsgTypeBroadcastToTag && !tagged {
+		client.Send(responsemessage.ErrorMessage("Missing_Fields", map[string]interface{}{"message": "'tag' field is missing or is not a string."}))
+		return
+	}
+
+	mu.Lock()
+	myRoom, exists := rooms[roomId]
+	var members []string
+	var member, allowed bool
+	if exists {
+		members = slices.Clone(myRoom.GetClients())
+		member = slices.Contains(members, from)
+		allowed = !myRoom.IsAnnounceOnly() || myRoom.IsModerator(from)
+	}
+	mu.Unlock()
+
+	if !exists {
+		client.Send(responsemessage.ErrorMessage("Not_Found", map[string]interface{}{"message": "Room with Id " + roomId + " does not exist."}))
+		return
+	}
+	if !member || !allowed {
+		logging.ForRoom(from, roomId).Debug("Refusing broadcast")
+		client.Send(responsemessage.ErrorMessage("Unauthorised", map[string]interface{}{"message": "You are not allowed to broadcast in room " + roomId + "."}))
+		return
+	}
+	if !checkAuthorized(client, event, authorizer.CanRelay(subjectOf(client), event, roomId, "")) {
+		return
+	}
+
+	broadcast := map[string]interface{}{
+		"event": event,
+		"from":  from,
+		"room":  roomId,
+		"data":  msg["data"],
+	}
+	if event == MsgTypeBroadcastToTag {
+		broadcast["tag"] = tag
+	}
+	if event == MsgTypeRoomMessage {
+		mu.Lock()
+		broadcast["name"] = client.GetName()
+		mu.Unlock()
+		broadcast["timestamp"] = time.Now()
+	}
+	for _, memberClient := range connectedClients(members) {
+		if memberClient.GetClientId() == from && event != MsgTypeRoomMessage {
+			continue
+		}
+		mu.Lock()
+		excluded := (event == MsgTypeBroadcastToTag && !memberClient.HasTag(tag)) || memberClient.HasBlocked(from)
+		mu.Unlock()
+		if !excluded && memberClient.SendAs(broadcast, relayPriority(event), nil) == nil {
+			countRelay(1)
+		}
+	}
+	recordRoomHistory(client, broadcast)
+}
+
+// handleSetModeratorMessage processes a "set_moderator" message.
+// The creator grants or revokes the moderation rights of a member.
+func handleSetModeratorMessage(client *client.Client, msg map[string]interface{}) {
+	if !checkRoomInJSON(client, msg) {
+		return
+	}
+	data := msg["data"].(map[string]interface{})
+	roomId, _ := data["room"].(string)
+	from := client.GetClientId()
+	target, ok := data["client"].(string)
+	if !ok {
+		client.Send(responsemessage.ErrorMessage("Missing_Fields", map[string]interface{}{"message": "'data''client' field is missing or is not a client Id."}))
+		return
+	}
+	moderator, ok := data["moderator"].(bool)
+	if !ok {
+		moderator = true
+	}
+	if !checkAuthorized(client, MsgTypeSetModerator, authorizer.CanRelay(subjectOf(client), MsgTypeSetModerator, roomId, target)) {
+		return
+	}
+
+	mu.Lock()
+	myRoom, exists := rooms[roomId]
+	if !exists {
+		mu.Unlock()
+		client.Send(responsemessage.ErrorMessage("Not_Found", map[string]interface{}{"message": "Room with Id " + roomId + " does not exist."}))
+		return
+	}
+	if myRoom.GetCreator() != from {
+		mu.Unlock()
+		client.Send(responsemessage.ErrorMessage("Unauthorised", map[string]interface{}{"message": "You need to be creator of room to change its moderators."}))
+		return
+	}
+	if !slices.Contains(myRoom.GetClients(), target) {
+		mu.Unlock()
+		client.Send(responsemessage.ErrorMessage("Not_Found", map[string]interface{}{"message": "Client " + target + " is not in the room."}))
+		return
+	}
+	myRoom.SetModerator(target, moderator)
+	mu.Unlock()
+	persistRoom(myRoom)
+
+	logging.ForRoom(from, roomId).Info("Moderator changed: ", target)
+	notifyUpdateIntheRoom(roomId, "Moderators_Updated")
+	if moderator {
+		notifyHandQueue(myRoom)
+	}
+}
@@ -0,0 +1,70 @@
+package server
+
+import (
+	"github.com/shankarammai/Peer2PeerConnector/internal/client"
+	"github.com/shankarammai/Peer2PeerConnector/internal/logging"
+	responsemessage "github.com/shankarammai/Peer2PeerConnector/internal/response"
+)
+
+// Reasons of a "Busy" answer.
+const (
+	busyDND    = "dnd"
+	busyInCall = "in_call"
+)
+
+// handleSetBusyPolicyMessage processes a "set_busy_policy" message.
+// The client tells whether the server answers calls and connection requests
+// with "Busy" while its status is "dnd" ("data""dnd") and while it is in a
+// call ("data""in_call"). Both are on by default, missing fields are unchanged.
+func handleSetBusyPolicyMessage(client *client.Client, msg map[string]interface{}) {
+	data, dataOk := msg["data"].(map[string]interface{})
+	if !dataOk {
+		client.Send(responsemessage.ErrorMessage("Missing_Fields", map[string]interface{}{"message": "'data' field is missing or is not object in the request."}))
+		return
+	}
+	mu.Lock()
+	policy := client.GetBusyPolicy()
+	if dnd, ok := data["dnd"].(bool); ok {
+		policy.DND = dnd
+	}
+	if inCall, ok := data["in_call"].(bool); ok {
+		policy.InCall = inCall
+	}
+	client.SetBusyPolicy(policy)
+	mu.Unlock()
+	logging.ForClient(client.Id).Debugf("Busy policy set to %+v", policy)
+
+	client.Send(responsemessage.InfoMessage("Busy_Policy_Set", map[string]interface{}{"dnd": policy.DND, "in_call": policy.InCall}))
+}
+
+// busyReason tells why the target is busy under its busy policy, or returns
+// an empty string when it can be disturbed.
+func busyReason(target *client.Client) string {
+	mu.Lock()
+	policy := target.GetBusyPolicy()
+	dnd := target.GetStatus() == client.StatusDND
+	mu.Unlock()
+	switch {
+	case policy.DND && dnd:
+		return busyDND
+	case policy.InCall && inCall(target.GetClientId()):
+		return busyInCall
+	}
+	return ""
+}
+
+// refuseBusy answers the client with "Busy" when the target is busy, without
+// disturbing it. It returns false when the event can be relayed.
+func refuseBusy(client *client.Client, target *client.Client, event string) bool {
+	reason := busyReason(target)
+	if reason == "" {
+		return false
+	}
+	logging.ForClient(client.Id).Debugf("Not relaying %s to %s, busy (%s)", event, target.GetClientId(), reason)
+	client.Send(responsemessage.InfoMessage("Busy", map[string]interface{}{
+		"to":     target.GetClientId(),
+		"reason": reason,
+		"event":  event,
+	}))
+	return true
+}
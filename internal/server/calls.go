@@ -0,0 +1,305 @@
+package server
+
+import (
+	"slices"
+	"sync"
+
+	"github.com/lithammer/shortuuid"
+	"github.com/shankarammai/Peer2PeerConnector/internal/client"
+	"github.com/shankarammai/Peer2PeerConnector/internal/clock"
+	"github.com/shankarammai/Peer2PeerConnector/internal/logging"
+	responsemessage "github.com/shankarammai/Peer2PeerConnector/internal/response"
+)
+
+// Reasons of a "Call_Cancel" sent by the server.
+const (
+	callCancelled         = "cancelled"
+	callTimeout           = "timeout"
+	callAnsweredElsewhere = "answered_elsewhere"
+	callRejectedElsewhere = "rejected_elsewhere"
+	callDisconnected      = "disconnected"
+)
+
+// call is a call invitation ringing on the devices of the callee, until one of
+// them accepts or rejects it, the caller cancels it or it times out.
+type call struct {
+	caller string
+	// callees are the clients the invitation rings on.
+	callees []string
+	timer   clock.Timer
+}
+
+// activeCall is a call accepted by a callee, until either side ends it.
+type activeCall struct {
+	caller string
+	callee string
+}
+
+var (
+	// calls are keyed by the call Id.
+	calls = make(map[string]*call)
+	// activeCalls are keyed by the call Id too.
+	activeCalls = make(map[string]activeCall)
+	callsMu     sync.Mutex
+)
+
+// handleCallInviteMessage processes a "call_invite" message.
+// The invitation rings on the client "to", or on every device of the user
+// "to_user", with a "Call_Invite" carrying a call Id and the "data" of the
+// caller. The caller gets the Id in "Call_Pending". Unanswered invitations are
+// cancelled after CALL_TIMEOUT. Invitations to a user with no connected device
+// are recorded as missed calls.
+func handleCallInviteMessage(client *client.Client, msg map[string]interface{}) {
+	from := client.GetClientId()
+	data, _ := msg["data"].(map[string]interface{})
+	roomId, _ := data["room"].(string)
+
+	var callees []string
+	if targetID, ok := msg["to"].(string); ok {
+		mu.Lock()
+		targetClient, exists := clients[targetID]
+		mu.Unlock()
+		if !exists {
+			client.Send(responsemessage.ErrorMessage("Not_Found", map[string]interface{}{"message": "Client with given " + targetID + " not found"}))
+			return
+		}
+		if refuseBlocked(client, targetClient) {
+			return
+		}
+		if !checkAuthorized(client, MsgTypeCallInvite, authorizer.CanRelay(subjectOf(client), MsgTypeCallInvite, roomId, targetID)) {
+			return
+		}
+		if refuseBusy(client, targetClient, MsgTypeCallInvite) {
+			return
+		}
+		callees = append(callees, targetID)
+	} else if userId, ok := msg["to_user"].(string); ok {
+		// busy devices don't ring, the caller hears busy if they all are
+		devices := userClients(userId, from)
+		if len(devices) == 0 && userId != client.UserID {
+			if !checkAuthorized(client, MsgTypeCallInvite, authorizer.CanRelay(subjectOf(client), MsgTypeCallInvite, roomId, "")) {
+				return
+			}
+			if missCall(client, userId, data) {
+				return
+			}
+		}
+		var busy string
+		for _, device := range devices {
+			if blockedBy(device, from) || authorizer.CanRelay(subjectOf(client), MsgTypeCallInvite, roomId, device.GetClientId()) != nil {
+				continue
+			}
+			if reason := busyReason(device); reason != "" {
+				busy = reason
+				continue
+			}
+			callees = append(callees, device.GetClientId())
+		}
+		if len(callees) == 0 && busy != "" {
+			client.Send(responsemessage.InfoMessage("Busy", map[string]interface{}{"to_user": userId, "reason": busy, "event": MsgTypeCallInvite}))
+			return
+		}
+		if len(callees) == 0 {
+			client.Send(responsemessage.ErrorMessage("Not_Found", map[string]interface{}{"message": "User " + userId + " has no connected device to receive the call."}))
+			return
+		}
+	} else {
+		client.Send(responsemessage.ErrorMessage("Missing_Fields", map[string]interface{}{"message": "'to' or 'to_user' field not found"}))
+		return
+	}
+
+	callId := shortuuid.New()
+	pending := &call{caller: from, callees: slices.Clone(callees)}
+	callsMu.Lock()
+	calls[callId] = pending
+	pending.timer = clk.AfterFunc(cfg().CallTimeout, func() { endCall(callId, callTimeout) })
+	callsMu.Unlock()
+	logging.ForClient(from).Debugf("Call %s rings on %v", callId, callees)
+
+	invite := map[string]interface{}{
+		"id":         callId,
+		"from":       from,
+		"expires_in": cfg().CallTimeout.Seconds(),
+	}
+	if client.UserID != "" {
+		invite["from_user"] = client.UserID
+	}
+	if data != nil {
+		invite["data"] = data
+	}
+	sendToClients(callees, responsemessage.InfoMessage(MsgTypeCallInvite, invite))
+	client.Send(responsemessage.InfoMessage("Call_Pending", map[string]interface{}{
+		"id":         callId,
+		"callees":    callees,
+		"expires_in": cfg().CallTimeout.Seconds(),
+	}))
+}
+
+// handleCallMessage processes "call_ringing", "call_accept", "call_reject" and
+// "call_cancel" messages about the call "data""id". The callees tell the caller
+// the invitation rings, accept or reject it, the caller cancels it. Accepting
+// or rejecting on one device cancels the invitation on the others.
+func handleCallMessage(client *client.Client, msg map[string]interface{}) {
+	from := client.GetClientId()
+	event, _ := msg["event"].(string)
+	data, _ := msg["data"].(map[string]interface{})
+	callId, _ := data["id"].(string)
+
+	callsMu.Lock()
+	pending, exists := calls[callId]
+	var caller string
+	var callees []string
+	if exists {
+		caller, callees = pending.caller, slices.Clone(pending.callees)
+	}
+	callsMu.Unlock()
+	if !exists {
+		client.Send(responsemessage.ErrorMessage("Not_Found", map[string]interface{}{"message": "No call " + callId + " is ringing."}))
+		return
+	}
+	if (event == MsgTypeCallCancel && caller != from) || (event != MsgTypeCallCancel && !slices.Contains(callees, from)) {
+		client.Send(responsemessage.ErrorMessage("Unauthorised", map[string]interface{}{"message": "Only the caller cancels a call, only the callees answer it."}))
+		return
+	}
+
+	update := map[string]interface{}{"id": callId, "from": from}
+	if reason, ok := data["reason"].(string); ok {
+		update["reason"] = reason
+	}
+	switch event {
+	case MsgTypeCallRinging:
+		sendToClients([]string{caller}, responsemessage.InfoMessage(MsgTypeCallRinging, update))
+	case MsgTypeCallAccept, MsgTypeCallReject:
+		elsewhere := callAnsweredElsewhere
+		if event == MsgTypeCallReject {
+			elsewhere = callRejectedElsewhere
+		}
+		if !removeCall(callId) {
+			return
+		}
+		if event == MsgTypeCallAccept {
+			callsMu.Lock()
+			activeCalls[callId] = activeCall{caller: caller, callee: from}
+			callsMu.Unlock()
+		}
+		sendToClients([]string{caller}, responsemessage.InfoMessage(event, update))
+		others := slices.DeleteFunc(callees, func(callee string) bool { return callee == from })
+		sendToClients(others, responsemessage.InfoMessage(MsgTypeCallCancel, map[string]interface{}{"id": callId, "reason": elsewhere}))
+		logging.ForClient(from).Debugf("%s call %s", event, callId)
+	case MsgTypeCallCancel:
+		endCall(callId, callCancelled)
+	}
+}
+
+// handleCallEndMessage processes a "call_end" message.
+// Either side of an accepted call hangs up, the other side receives "Call_End".
+func handleCallEndMessage(client *client.Client, msg map[string]interface{}) {
+	from := client.GetClientId()
+	data, _ := msg["data"].(map[string]interface{})
+	callId, _ := data["id"].(string)
+
+	callsMu.Lock()
+	active, exists := activeCalls[callId]
+	isParty := exists && (active.caller == from || active.callee == from)
+	if isParty {
+		delete(activeCalls, callId)
+	}
+	callsMu.Unlock()
+	if !isParty {
+		client.Send(responsemessage.ErrorMessage("Not_Found", map[string]interface{}{"message": "You are in no call " + callId + "."}))
+		return
+	}
+	other := active.caller
+	if other == from {
+		other = active.callee
+	}
+	logging.ForClient(from).Debugf("Call %s ended", callId)
+	sendToClients([]string{other}, responsemessage.InfoMessage(MsgTypeCallEnd, map[string]interface{}{"id": callId, "from": from}))
+}
+
+// inCall tells if the client takes part in an accepted call.
+func inCall(clientId string) bool {
+	callsMu.Lock()
+	defer callsMu.Unlock()
+	for _, active := range activeCalls {
+		if active.caller == clientId || active.callee == clientId {
+			return true
+		}
+	}
+	return false
+}
+
+// removeCall forgets a call and stops its timeout, it returns false when the
+// call already ended.
+func removeCall(callId string) bool {
+	callsMu.Lock()
+	defer callsMu.Unlock()
+	pending, exists := calls[callId]
+	if !exists {
+		return false
+	}
+	pending.timer.Stop()
+	delete(calls, callId)
+	return true
+}
+
+// endCall cancels a call that rings, telling the caller and the callees why.
+func endCall(callId string, reason string) {
+	callsMu.Lock()
+	pending, exists := calls[callId]
+	if exists {
+		pending.timer.Stop()
+		delete(calls, callId)
+	}
+	callsMu.Unlock()
+	if !exists {
+		return
+	}
+	logging.ForClient(pending.caller).Debugf("Call %s ended: %s", callId, reason)
+	cancel := responsemessage.InfoMessage(MsgTypeCallCancel, map[string]interface{}{"id": callId, "reason": reason})
+	sendToClients(append([]string{pending.caller}, pending.callees...), cancel)
+}
+
+// dropCalls ends the calls of a disconnected client: the calls it places are
+// cancelled, the calls ringing on it are cancelled once no callee is left, and
+// the other side of its accepted calls receives "Call_End".
+func dropCalls(clientId string) {
+	callsMu.Lock()
+	hungUp := map[string]string{}
+	for callId, active := range activeCalls {
+		if active.caller == clientId || active.callee == clientId {
+			hungUp[callId] = active.caller
+			if active.caller == clientId {
+				hungUp[callId] = active.callee
+			}
+			delete(activeCalls, callId)
+		}
+	}
+	var ended []string
+	for callId, pending := range calls {
+		if pending.caller == clientId {
+			ended = append(ended, callId)
+			continue
+		}
+		if slices.Contains(pending.callees, clientId) {
+			pending.callees = slices.DeleteFunc(pending.callees, func(callee string) bool { return callee == clientId })
+			if len(pending.callees) == 0 {
+				ended = append(ended, callId)
+			}
+		}
+	}
+	callsMu.Unlock()
+	for _, callId := range ended {
+		endCall(callId, callDisconnected)
+	}
+	for callId, other := range hungUp {
+		sendToClients([]string{other}, responsemessage.InfoMessage(MsgTypeCallEnd, map[string]interface{}{"id": callId, "from": clientId, "reason": callDisconnected}))
+	}
+}
+
+// sendToClients sends the message to the connected clients among clientIds.
+func sendToClients(clientIds []string, message responsemessage.Message) {
+	for _, clientItem := range connectedClients(clientIds) {
+		clientItem.Send(message)
+	}
+}
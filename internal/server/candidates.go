@@ -0,0 +1,153 @@
+package server
+
+import (
+	"maps"
+	"sync"
+	"time"
+
+	"github.com/shankarammai/Peer2PeerConnector/internal/client"
+	"github.com/shankarammai/Peer2PeerConnector/internal/logging"
+	responsemessage "github.com/shankarammai/Peer2PeerConnector/internal/response"
+)
+
+// candidateBatch holds the candidates queued for a peer during the debounce window.
+type candidateBatch struct {
+	from     string
+	target   *client.Client
+	messages []map[string]interface{}
+	// deadlines of the messages, nil for those sent without a time to live.
+	deadlines []*client.Deadline
+}
+
+var (
+	candidateBatches   = make(map[string]*candidateBatch)
+	candidateBatchesMu sync.Mutex
+)
+
+// checkCandidates checks that a "candidates" message carries a list of candidates
+// and reports the problem to the client otherwise.
+func checkCandidates(client *client.Client, msg map[string]interface{}) bool {
+	data, ok := msg["data"].(map[string]interface{})
+	if ok {
+		_, ok = data["candidates"].([]interface{})
+	}
+	if !ok {
+		client.Send(responsemessage.ErrorMessage("Invalid_Candidates", map[string]interface{}{"message": "'data''candidates' must be a list of candidates."}))
+	}
+	return ok
+}
+
+// queueCandidate delays a relayed "candidate" message by the debounce window.
+// Candidates sent from the same client to the same target (and room) within the
+// window are coalesced into a single "candidates" message.
+func queueCandidate(target *client.Client, msg map[string]interface{}, deadline *client.Deadline) {
+	from, _ := msg["from"].(string)
+	roomId, _ := msg["room"].(string)
+	key := from + "\x00" + target.GetClientId() + "\x00" + roomId
+
+	candidateBatchesMu.Lock()
+	defer candidateBatchesMu.Unlock()
+	if batch, exists := candidateBatches[key]; exists {
+		batch.messages = append(batch.messages, msg)
+		batch.deadlines = append(batch.deadlines, deadline)
+		return
+	}
+	candidateBatches[key] = &candidateBatch{from: from, target: target, messages: []map[string]interface{}{msg}, deadlines: []*client.Deadline{deadline}}
+	time.AfterFunc(cfg().CandidateDebounce, func() { flushCandidates(key) })
+}
+
+// flushCandidates relays the candidates queued under key at the end of the
+// debounce window, unless a signalling message relayed them already.
+func flushCandidates(key string) {
+	candidateBatchesMu.Lock()
+	batch := candidateBatches[key]
+	candidateBatchesMu.Unlock()
+	if batch == nil {
+		return
+	}
+	stream := signalStreamOf(batch.from, batch.target.GetClientId())
+	stream.mu.Lock()
+	defer stream.mu.Unlock()
+	candidateBatchesMu.Lock()
+	batch = candidateBatches[key]
+	delete(candidateBatches, key)
+	candidateBatchesMu.Unlock()
+	if batch != nil {
+		sendCandidateBatch(stream, batch)
+	}
+}
+
+// takeCandidateBatches removes the candidates queued from one client to another.
+func takeCandidateBatches(from, to string) []*candidateBatch {
+	candidateBatchesMu.Lock()
+	defer candidateBatchesMu.Unlock()
+	var taken []*candidateBatch
+	for key, batch := range candidateBatches {
+		if batch.from == from && batch.target.GetClientId() == to {
+			taken = append(taken, batch)
+			delete(candidateBatches, key)
+		}
+	}
+	return taken
+}
+
+// sendCandidateBatch relays queued candidates on their stream, as is when a
+// single candidate was queued and as one "candidates" message otherwise.
+// Candidates whose time to live passed during the window are dropped.
+// stream.mu must be held.
+func sendCandidateBatch(stream *signalStream, batch *candidateBatch) {
+	var messages []map[string]interface{}
+	var deadlines []*client.Deadline
+	for i, queued := range batch.messages {
+		if batch.deadlines[i].Passed() {
+			batch.deadlines[i].Expired()
+			continue
+		}
+		messages = append(messages, queued)
+		deadlines = append(deadlines, batch.deadlines[i])
+	}
+	if len(messages) == 0 {
+		return
+	}
+
+	msg, deadline := messages[0], deadlines[0]
+	if len(messages) > 1 {
+		candidates := make([]interface{}, 0, len(messages))
+		for _, queued := range messages {
+			candidates = append(candidates, queued["data"])
+		}
+		msg = maps.Clone(msg)
+		msg["event"] = MsgTypeCandidates
+		msg["data"] = map[string]interface{}{"candidates": candidates}
+		deadline = batchDeadline(deadlines)
+	}
+	if err := stream.send(sendSignal(batch.target, msg, deadline)); err != nil {
+		logging.ForClient(batch.target.GetClientId()).Debugf("Failed to relay %d candidates: %v", len(messages), err)
+	} else {
+		countRelay(1)
+	}
+}
+
+// batchDeadline is the deadline of coalesced candidates: the latest one, so no
+// candidate is dropped before its time, or none when a candidate has none.
+// The sender is told of every candidate when the batch expires.
+func batchDeadline(deadlines []*client.Deadline) *client.Deadline {
+	latest := deadlines[0]
+	for _, deadline := range deadlines {
+		if deadline == nil {
+			return nil
+		}
+		if deadline.At.After(latest.At) {
+			latest = deadline
+		}
+	}
+	return &client.Deadline{
+		At:  latest.At,
+		Now: latest.Now,
+		Expired: func() {
+			for _, deadline := range deadlines {
+				deadline.Expired()
+			}
+		},
+	}
+}
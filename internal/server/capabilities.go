@@ -0,0 +1,147 @@
+package server
+
+import (
+	"github.com/shankarammai/Peer2PeerConnector/internal/client"
+	"github.com/shankarammai/Peer2PeerConnector/internal/logging"
+	responsemessage "github.com/shankarammai/Peer2PeerConnector/internal/response"
+)
+
+// handleSetCapabilitiesMessage processes a "set_capabilities" message.
+// It stores the capabilities of the client, which are then part of the room
+// membership data, and broadcasts them to every room the client is in.
+func handleSetCapabilitiesMessage(client *client.Client, msg map[string]interface{}) {
+	data, dataOk := msg["data"].(map[string]interface{})
+	if !dataOk {
+		client.Send(responsemessage.ErrorMessage("Missing_Fields", map[string]interface{}{"message": "'data' field is missing or is not object in the request."}))
+		return
+	}
+	capabilities, ok := parseCapabilities(data)
+	if !ok {
+		client.Send(responsemessage.ErrorMessage("Invalid_Capabilities", map[string]interface{}{"message": "'audio', 'video', 'datachannel' and 'screen-share' must be booleans and 'codecs' a list of strings."}))
+		return
+	}
+
+	mu.Lock()
+	client.SetCapabilities(capabilities)
+	mu.Unlock()
+	logging.ForClient(client.Id).Debug("Capabilities registered")
+
+	client.Send(responsemessage.InfoMessage("Capabilities_Set", map[string]interface{}{"capabilities": capabilities}))
+	for _, roomItem := range roomsOfClient(client.GetClientId()) {
+		update := responsemessage.UpdateMessage("Capabilities_Update", map[string]interface{}{
+			"room":         roomItem.GetId(),
+			"client":       client.GetClientId(),
+			"capabilities": capabilities,
+		})
+		for _, member := range connectedClients(roomItem.GetClients()) {
+			if member.GetClientId() != client.GetClientId() {
+				member.Send(update)
+			}
+		}
+	}
+}
+
+// parseCapabilities reads the capabilities from the data of a "set_capabilities"
+// message, missing flags default to false.
+func parseCapabilities(data map[string]interface{}) (*client.Capabilities, bool) {
+	capabilities := &client.Capabilities{Codecs: []string{}}
+	flags := map[string]*bool{
+		"audio":        &capabilities.Audio,
+		"video":        &capabilities.Video,
+		"datachannel":  &capabilities.DataChannel,
+		"screen-share": &capabilities.ScreenShare,
+	}
+	for key, flag := range flags {
+		value, exists := data[key]
+		if !exists {
+			continue
+		}
+		enabled, ok := value.(bool)
+		if !ok {
+			return nil, false
+		}
+		*flag = enabled
+	}
+
+	if value, exists := data["codecs"]; exists {
+		codecs, ok := value.([]interface{})
+		if !ok {
+			return nil, false
+		}
+		for _, codec := range codecs {
+			name, ok := codec.(string)
+			if !ok {
+				return nil, false
+			}
+			capabilities.Codecs = append(capabilities.Codecs, name)
+		}
+	}
+	return capabilities, true
+}
+
+// handleMediaStateMessage processes a "media_state" message.
+// It updates the media state of the client with the flags present in the
+// message, which is then part of the room membership data next to the
+// capabilities, and broadcasts it to every room the client is in.
+func handleMediaStateMessage(client *client.Client, msg map[string]interface{}) {
+	data, dataOk := msg["data"].(map[string]interface{})
+	if !dataOk {
+		client.Send(responsemessage.ErrorMessage("Missing_Fields", map[string]interface{}{"message": "'data' field is missing or is not object in the request."}))
+		return
+	}
+	if !checkAuthorized(client, MsgTypeMediaState, authorizer.CanRelay(subjectOf(client), MsgTypeMediaState, "", "")) {
+		return
+	}
+
+	mu.Lock()
+	media, ok := parseMediaState(client.GetMedia(), data)
+	if ok {
+		client.SetMedia(media)
+	}
+	mu.Unlock()
+	if !ok {
+		client.Send(responsemessage.ErrorMessage("Invalid_Media_State", map[string]interface{}{"message": "'camera', 'microphone' and 'screen_share' must be booleans."}))
+		return
+	}
+	logging.ForClient(client.Id).Debugf("Media state changed: %+v", *media)
+
+	client.Send(responsemessage.InfoMessage("Media_State_Set", map[string]interface{}{"media": media}))
+	for _, roomItem := range roomsOfClient(client.GetClientId()) {
+		update := responsemessage.UpdateMessage("Media_State_Update", map[string]interface{}{
+			"room":   roomItem.GetId(),
+			"client": client.GetClientId(),
+			"media":  media,
+		})
+		for _, member := range connectedClients(roomItem.GetClients()) {
+			if member.GetClientId() != client.GetClientId() {
+				member.Send(update)
+			}
+		}
+	}
+}
+
+// parseMediaState applies the flags present in the data of a "media_state"
+// message to a copy of the current media state, which may be nil.
+func parseMediaState(current *client.MediaState, data map[string]interface{}) (*client.MediaState, bool) {
+	media := &client.MediaState{}
+	if current != nil {
+		*media = *current
+	}
+	flags := map[string]*bool{
+		"camera":       &media.Camera,
+		"microphone":   &media.Microphone,
+		"screen_share": &media.ScreenShare,
+	}
+	for key, flag := range flags {
+		value, exists := data[key]
+		if !exists {
+			continue
+		}
+		enabled, ok := value.(bool)
+		if !ok {
+			return nil, false
+		}
+		*flag = enabled
+	}
+	return media, true
+}
@@ -0,0 +1,58 @@
+package server
+
+import (
+	"github.com/shankarammai/Peer2PeerConnector/internal/client"
+	"github.com/shankarammai/Peer2PeerConnector/internal/logging"
+	"github.com/shankarammai/Peer2PeerConnector/internal/pow"
+	responsemessage "github.com/shankarammai/Peer2PeerConnector/internal/response"
+)
+
+// issueChallenge gives the client a new proof-of-work challenge, replacing its
+// previous one, and returns its description.
+func issueChallenge(client *client.Client) map[string]interface{} {
+	challenge := pow.NewChallenge()
+	mu.Lock()
+	client.Challenge = challenge
+	client.ChallengeExpires = clk.Now().Add(cfg().PowTTL)
+	mu.Unlock()
+	return map[string]interface{}{
+		"challenge":  challenge,
+		"difficulty": cfg().PowDifficulty,
+		"expires_in": cfg().PowTTL.Seconds(),
+	}
+}
+
+// handleGetChallengeMessage processes a "get_challenge" message.
+// It sends the client a proof-of-work challenge to solve before creating a room.
+func handleGetChallengeMessage(client *client.Client, msg map[string]interface{}) {
+	if cfg().PowDifficulty <= 0 {
+		client.Send(responsemessage.ErrorMessage("Unsupported_Event", map[string]interface{}{"message": "Proof-of-work is not enabled on this server."}))
+		return
+	}
+	client.Send(responsemessage.InfoMessage("Challenge", issueChallenge(client)))
+}
+
+// checkProofOfWork makes anonymous clients solve their challenge before creating
+// a room when proof-of-work is enabled. Each challenge is good for one room, a
+// missing or wrong proof is answered with a new challenge.
+func checkProofOfWork(client *client.Client, data map[string]interface{}) bool {
+	if cfg().PowDifficulty <= 0 || client.UserID != "" {
+		return true
+	}
+	nonce, _ := data["nonce"].(string)
+	mu.Lock()
+	challenge := client.Challenge
+	valid := challenge != "" && clk.Now().Before(client.ChallengeExpires) && pow.Verify(challenge, nonce, cfg().PowDifficulty)
+	if valid {
+		client.Challenge = ""
+	}
+	mu.Unlock()
+	if valid {
+		return true
+	}
+	logging.ForClient(client.Id).Debug("Missing or invalid proof-of-work")
+	details := issueChallenge(client)
+	details["message"] = "Solve the challenge and send the nonce with 'create_room'."
+	client.Send(responsemessage.ErrorMessage("Challenge_Required", details))
+	return false
+}
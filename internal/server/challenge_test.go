@@ -0,0 +1,50 @@
+package server
+
+import (
+	"testing"
+	"time"
+
+	"github.com/shankarammai/Peer2PeerConnector/internal/client"
+	"github.com/shankarammai/Peer2PeerConnector/internal/clock"
+	"github.com/shankarammai/Peer2PeerConnector/internal/config"
+	"github.com/shankarammai/Peer2PeerConnector/internal/pow"
+)
+
+func TestRefusedCreateRoomKeepsTheChallenge(t *testing.T) {
+	settings := *config.Default()
+	settings.PowDifficulty = 8
+	current.Store(&settings)
+	previous := clk
+	clk = clock.NewFake(time.Date(2024, 8, 10, 17, 0, 0, 0, time.UTC))
+	t.Cleanup(func() {
+		current.Store(nil)
+		clk = previous
+	})
+	fuzzClients()
+	transport := &recordingTransport{}
+	creator := &client.Client{Id: "creator", Connection: transport, Status: client.StatusOnline}
+	mu.Lock()
+	clients[creator.Id] = creator
+	mu.Unlock()
+	t.Cleanup(func() {
+		deleteRoom("solved", "ended")
+		removeClient(creator.Id)
+	})
+
+	challenge := issueChallenge(creator)["challenge"].(string)
+	nonce := pow.Solve(challenge, settings.PowDifficulty)
+	createRoom := func(roomId string) string {
+		handleCreateRoomMessage(creator, map[string]interface{}{"data": map[string]interface{}{"room": roomId, "nonce": nonce}})
+		sent := transport.written()
+		return sent[len(sent)-1]["event"].(string)
+	}
+	if got := createRoom("room"); got != "Duplicate_Room" {
+		t.Fatalf("creating an existing room answered %s, want Duplicate_Room", got)
+	}
+	if got := createRoom("solved"); got != "Room_Created" {
+		t.Fatalf("creating with the kept challenge answered %s, want Room_Created", got)
+	}
+	if got := createRoom("again"); got != "Challenge_Required" {
+		t.Errorf("reusing the challenge answered %s, want Challenge_Required", got)
+	}
+}
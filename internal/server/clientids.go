@@ -0,0 +1,100 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/shankarammai/Peer2PeerConnector/internal/authz"
+	"github.com/shankarammai/Peer2PeerConnector/internal/ids"
+	"github.com/shankarammai/Peer2PeerConnector/internal/protocol"
+)
+
+// maxIdPrefixLength leaves room for the generated part of a prefixed Id.
+const maxIdPrefixLength = 32
+
+var (
+	// newClientIdentifier makes the Ids of clients that don't ask for one.
+	newClientIdentifier ids.Generator = ids.ShortUUID
+
+	errInvalidClientId = errors.New("invalid client id")
+	errClientIdTaken   = errors.New("client id already in use")
+)
+
+// requestedClientId returns the Id of a connecting client: the one it asks for
+// with the id query parameter, a generated Id after the id_prefix parameter, or
+// a generated Id. Clients can only choose their Id with CustomClientIds set.
+func requestedClientId(request *http.Request) (string, error) {
+	query := request.URL.Query()
+	id, prefix := query.Get("id"), query.Get("id_prefix")
+	if !cfg().CustomClientIds || (id == "" && prefix == "") {
+		return newClientIdentifier(), nil
+	}
+	if id == "" {
+		if len(prefix) > maxIdPrefixLength || !ids.Valid(prefix) {
+			return "", errInvalidClientId
+		}
+		id = prefix + newClientIdentifier()
+	}
+	if !ids.Valid(id) {
+		return "", errInvalidClientId
+	}
+	if clientIdInUse(id) {
+		return "", errClientIdTaken
+	}
+	return id, nil
+}
+
+// clientIdInUse tells if id belongs to a connected client, to a dropped
+// client that can still resume its session, or to the creator, a moderator or
+// a member of a room, whose rights a newcomer must not inherit.
+func clientIdInUse(id string) bool {
+	mu.Lock()
+	_, connected := clients[id]
+	referenced := false
+	for _, roomItem := range rooms {
+		if roomItem.IsModerator(id) || slices.Contains(roomItem.GetClients(), id) {
+			referenced = true
+			break
+		}
+	}
+	mu.Unlock()
+	if connected || referenced || unackedHeld(id) {
+		return true
+	}
+	heldMembershipsMu.Lock()
+	defer heldMembershipsMu.Unlock()
+	for _, held := range heldMemberships {
+		if held.clientId == id {
+			return true
+		}
+	}
+	return false
+}
+
+// refuseClientId answers a connection whose requested Id can't be used, whose
+// access token was refused by the authorizer, that is banned, that asks for
+// an unknown protocol version or that the duplicate connection policy refuses. It returns false when err is nil.
+func refuseClientId(writer http.ResponseWriter, err error) bool {
+	switch {
+	case err == nil:
+		return false
+	case errors.Is(err, protocol.ErrUnsupportedVersion):
+		http.Error(writer, "Unsupported protocol version, use one of "+strings.Join(protocol.Versions(), ", "), http.StatusBadRequest)
+	case errors.Is(err, errBanned):
+		http.Error(writer, "Banned", http.StatusForbidden)
+	case errors.Is(err, authz.ErrUnauthenticated):
+		writer.Header().Set("WWW-Authenticate", "Bearer")
+		http.Error(writer, "Unauthorised", http.StatusUnauthorized)
+	case errors.Is(err, errClientIdTaken):
+		http.Error(writer, "Client id already in use", http.StatusConflict)
+	case errors.Is(err, errUserConnected):
+		http.Error(writer, "User already connected", http.StatusConflict)
+	case errors.Is(err, errDeviceTaken):
+		http.Error(writer, "Device already connected", http.StatusConflict)
+	default:
+		http.Error(writer, "Invalid client id, use 1 to 64 letters, digits, '-', '_' or '.'", http.StatusBadRequest)
+	}
+	return true
+}
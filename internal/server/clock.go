@@ -0,0 +1,52 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/shankarammai/Peer2PeerConnector/internal/clock"
+)
+
+// clk is the time of the room TTLs, grace periods, rate limits and heartbeats.
+var clk clock.Clock = clock.Real{}
+
+// SetClock replaces the clock of the server, e.g. with a clock.Fake so tests
+// move past expiries instead of sleeping. It must be called before Init, which
+// installs a fake clock itself with FAKE_CLOCK.
+func SetClock(custom clock.Clock) {
+	clk = custom
+}
+
+// handleClockRequest serves /admin/clock while FAKE_CLOCK is set: GET returns
+// the time of the server, POST moves it forward by the "advance" duration of
+// the body, firing the expiries due on the way.
+func handleClockRequest(writer http.ResponseWriter, request *http.Request) {
+	fake, ok := clk.(*clock.Fake)
+	if !ok {
+		http.NotFound(writer, request)
+		return
+	}
+	switch request.Method {
+	case http.MethodGet:
+	case http.MethodPost:
+		var body struct {
+			Advance string `json:"advance"`
+		}
+		if err := json.NewDecoder(http.MaxBytesReader(writer, request.Body, maxPollMessageSize)).Decode(&body); err != nil {
+			http.Error(writer, "Invalid body", http.StatusBadRequest)
+			return
+		}
+		advance, err := time.ParseDuration(body.Advance)
+		if err != nil || advance < 0 {
+			http.Error(writer, "'advance' must be a positive duration, e.g. 90s", http.StatusBadRequest)
+			return
+		}
+		fake.Advance(advance)
+		logger.Infof("Fake clock advanced by %s", advance)
+	default:
+		http.Error(writer, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	writeJSONResponse(writer, http.StatusOK, map[string]interface{}{"now": fake.Now()})
+}
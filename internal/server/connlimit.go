@@ -0,0 +1,116 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+	"slices"
+	"strconv"
+	"sync"
+	"sync/atomic"
+)
+
+// capacityHysteresis is how many percentage points the connections must fall
+// below a threshold before it counts as left, so a count hovering around the
+// threshold doesn't flood the autoscaler with events.
+const capacityHysteresis = 5
+
+var (
+	// webSocketConnections counts the open WebSocket connections.
+	webSocketConnections atomic.Int64
+	// rejectedConnections counts the connections refused over MaxConnections.
+	rejectedConnections atomic.Int64
+
+	// capacityLevel is the index in CapacityThresholds of the highest threshold
+	// reached, -1 below all of them.
+	capacityLevel = -1
+	capacityMu    sync.Mutex
+)
+
+// checkCapacityThresholds validates CAPACITY_THRESHOLDS and sorts them.
+func checkCapacityThresholds(thresholds []int) error {
+	for _, threshold := range thresholds {
+		if threshold <= 0 || threshold > 100 {
+			return errors.New("CAPACITY_THRESHOLDS must be percentages between 1 and 100")
+		}
+	}
+	slices.Sort(thresholds)
+	return nil
+}
+
+// acquireConnection reserves a slot for a new WebSocket connection. Over the
+// MaxConnections cap it answers 503 with a Retry-After hint and returns false,
+// otherwise the slot must be given back with releaseConnection.
+func acquireConnection(writer http.ResponseWriter) bool {
+	open := webSocketConnections.Add(1)
+	if cfg().MaxConnections > 0 && open > int64(cfg().MaxConnections) {
+		webSocketConnections.Add(-1)
+		rejectedConnections.Add(1)
+		writer.Header().Set("Retry-After", strconv.Itoa(int(cfg().ConnectionRetryAfter.Seconds())))
+		http.Error(writer, "Too many connections", http.StatusServiceUnavailable)
+		return false
+	}
+	updateCapacityLevel()
+	return true
+}
+
+// releaseConnection gives back the slot of a closed WebSocket connection.
+func releaseConnection() {
+	webSocketConnections.Add(-1)
+	updateCapacityLevel()
+}
+
+// capacityUsage returns the open connections in percent of MaxConnections, 0 without a cap.
+func capacityUsage() float64 {
+	if cfg().MaxConnections <= 0 {
+		return 0
+	}
+	return float64(webSocketConnections.Load()) * 100 / float64(cfg().MaxConnections)
+}
+
+// updateCapacityLevel emits a capacity event when the open connections cross
+// one of the capacity thresholds.
+func updateCapacityLevel() {
+	thresholds := cfg().CapacityThresholds
+	if cfg().MaxConnections <= 0 || len(thresholds) == 0 {
+		return
+	}
+	capacityMu.Lock()
+	usage := capacityUsage()
+	previous, level := capacityLevel, capacityLevel
+	for level+1 < len(thresholds) && usage >= float64(thresholds[level+1]) {
+		level++
+	}
+	for level >= 0 && usage < float64(thresholds[level]-capacityHysteresis) {
+		level--
+	}
+	capacityLevel = level
+	capacityMu.Unlock()
+
+	if level == previous {
+		return
+	}
+	event := map[string]interface{}{
+		"connections":     webSocketConnections.Load(),
+		"max_connections": cfg().MaxConnections,
+		"usage":           usage,
+	}
+	if level > previous {
+		event["threshold"] = thresholds[level]
+		logger.Warnf("Connections reached %d%% of the capacity", thresholds[level])
+		emitEvent(EventCapacityHigh, event)
+	} else {
+		event["threshold"] = thresholds[previous]
+		logger.Infof("Connections fell below %d%% of the capacity", thresholds[previous])
+		emitEvent(EventCapacityNormal, event)
+	}
+}
+
+// reachedThreshold returns the highest capacity threshold reached, 0 when none is.
+func reachedThreshold() int {
+	capacityMu.Lock()
+	defer capacityMu.Unlock()
+	if capacityLevel < 0 {
+		return 0
+	}
+	return cfg().CapacityThresholds[capacityLevel]
+}
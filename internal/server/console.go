@@ -0,0 +1,250 @@
+package server
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"runtime"
+	"runtime/debug"
+	"slices"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/shankarammai/Peer2PeerConnector/internal/client"
+)
+
+// consoleCommands are the commands of the debug console, by name.
+var consoleCommands = map[string]struct {
+	usage string
+	run   func(out io.Writer, arg string)
+}{
+	"clients":     {"clients                list the connected clients", consoleClients},
+	"client":      {"client <id>            show a client", consoleClient},
+	"rooms":       {"rooms                  list the rooms", consoleRooms},
+	"room":        {"room <id>              show a room", consoleRoom},
+	"queues":      {"queues                 show the HTTP session queues and pending relays", consoleQueues},
+	"runtime":     {"runtime                show the goroutines and memory", consoleRuntime},
+	"kick":        {"kick <id>              disconnect a client", consoleKick},
+	"delete-room": {"delete-room <id>       delete a room, its members are notified", consoleDeleteRoom},
+	"expire":      {"expire                 expire the rooms, sessions and idle clients now", consoleExpire},
+	"gc":          {"gc                     run the garbage collector and return memory to the OS", consoleGC},
+}
+
+// ServeConsole serves the debug console on a Unix socket (addr is a path) or a
+// loopback TCP address, for operators on the machine to inspect the in-memory
+// state and clean it up without the admin API. Connect with e.g.
+// "socat - UNIX-CONNECT:<path>" or "nc 127.0.0.1 <port>".
+func ServeConsole(addr string) error {
+	listener, err := listenConsole(addr)
+	if err != nil {
+		return err
+	}
+	logger.Info("Debug console listening on ", addr)
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			return err
+		}
+		go runConsole(conn)
+	}
+}
+
+// listenConsole refuses addresses reachable from other machines, the console
+// has no authentication.
+func listenConsole(addr string) (net.Listener, error) {
+	if strings.Contains(addr, "/") {
+		// a stale socket of a previous run would make Listen fail, any
+		// other file is left alone
+		if info, err := os.Lstat(addr); err == nil && info.Mode().Type() == os.ModeSocket {
+			os.Remove(addr)
+		}
+		listener, err := net.Listen("unix", addr)
+		if err != nil {
+			return nil, err
+		}
+		if err := os.Chmod(addr, 0o600); err != nil {
+			listener.Close()
+			return nil, err
+		}
+		return listener, nil
+	}
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+	if !isLoopback(host) {
+		return nil, errors.New("the debug console only listens on a Unix socket or a loopback address, not " + addr)
+	}
+	return net.Listen("tcp", addr)
+}
+
+// runConsole reads commands from the connection until "quit" or EOF.
+func runConsole(conn net.Conn) {
+	defer conn.Close()
+	logger.Info("Debug console session opened")
+	fmt.Fprintln(conn, `Peer2PeerConnector debug console, "help" lists the commands.`)
+	scanner := bufio.NewScanner(conn)
+	for fmt.Fprint(conn, "> "); scanner.Scan(); fmt.Fprint(conn, "> ") {
+		name, arg, _ := strings.Cut(strings.TrimSpace(scanner.Text()), " ")
+		arg = strings.TrimSpace(arg)
+		switch name {
+		case "":
+		case "quit", "exit":
+			return
+		case "help":
+			consoleHelp(conn)
+		default:
+			command, ok := consoleCommands[name]
+			if !ok {
+				fmt.Fprintf(conn, "unknown command %q\n", name)
+				continue
+			}
+			command.run(conn, arg)
+		}
+	}
+}
+
+func consoleHelp(out io.Writer) {
+	names := make([]string, 0, len(consoleCommands))
+	for name := range consoleCommands {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	for _, name := range names {
+		fmt.Fprintln(out, "  "+consoleCommands[name].usage)
+	}
+	fmt.Fprintln(out, "  quit                   close the console")
+}
+
+// consoleJSON prints v indented.
+func consoleJSON(out io.Writer, v interface{}) {
+	encoded, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		fmt.Fprintln(out, "error:", err)
+		return
+	}
+	fmt.Fprintln(out, string(encoded))
+}
+
+func consoleClients(out io.Writer, _ string) {
+	table := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(table, "CLIENT\tSTATUS\tCONNECTED\tLAST ACTIVE\tIN\tOUT")
+	for _, details := range describeClients() {
+		clientItem := consoleLookupClient(details["id"].(string))
+		if clientItem == nil {
+			continue
+		}
+		fmt.Fprintf(table, "%s\t%s\t%s\t%s ago\t%d\t%d\n", details["id"], details["status"],
+			details["connected_at"].(time.Time).Format(time.DateTime), clk.Now().Sub(clientItem.GetLastActive()).Round(time.Second),
+			clientItem.BytesIn(), clientItem.BytesOut())
+	}
+	table.Flush()
+}
+
+func consoleClient(out io.Writer, clientId string) {
+	for _, details := range describeClients() {
+		if details["id"] == clientId {
+			var memberOf []string
+			for _, roomItem := range roomsOfClient(clientId) {
+				memberOf = append(memberOf, roomItem.GetId())
+			}
+			details["rooms"] = memberOf
+			consoleJSON(out, details)
+			return
+		}
+	}
+	fmt.Fprintln(out, "no client", clientId)
+}
+
+func consoleRooms(out io.Writer, _ string) {
+	table := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(table, "ROOM\tNAME\tMEMBERS\tLOCKED\tPERSISTENT")
+	for _, details := range describeRooms() {
+		fmt.Fprintf(table, "%s\t%s\t%d\t%t\t%t\n", details["room"], details["name"], len(details["clients"].([]string)), details["locked"], details["persistent"])
+	}
+	table.Flush()
+}
+
+func consoleRoom(out io.Writer, roomId string) {
+	mu.Lock()
+	roomItem, exists := rooms[roomId]
+	mu.Unlock()
+	if !exists {
+		fmt.Fprintln(out, "no room", roomId)
+		return
+	}
+	consoleJSON(out, roomDetails(roomItem))
+}
+
+func consoleQueues(out io.Writer, _ string) {
+	sessionsMu.Lock()
+	table := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(table, "SESSION\tQUEUED\tIDLE")
+	for clientId, session := range sessions {
+		fmt.Fprintf(table, "%s\t%d\t%s\n", clientId, session.transport.queued(), clk.Now().Sub(session.transport.idleSince()).Round(time.Second))
+	}
+	sessionsMu.Unlock()
+	table.Flush()
+
+	candidateBatchesMu.Lock()
+	batches := len(candidateBatches)
+	candidateBatchesMu.Unlock()
+	fileRelaysMu.Lock()
+	relays := len(fileRelays)
+	fileRelaysMu.Unlock()
+	heldMembershipsMu.Lock()
+	held := len(heldMemberships)
+	heldMembershipsMu.Unlock()
+	fmt.Fprintf(out, "candidate batches: %d\nfile relays: %d\nheld memberships: %d\n", batches, relays, held)
+}
+
+func consoleRuntime(out io.Writer, _ string) {
+	var memory runtime.MemStats
+	runtime.ReadMemStats(&memory)
+	fmt.Fprintf(out, "goroutines: %d\nheap in use: %d KiB\nheap objects: %d\ngc cycles: %d\n",
+		runtime.NumGoroutine(), memory.HeapInuse>>10, memory.HeapObjects, memory.NumGC)
+}
+
+func consoleKick(out io.Writer, clientId string) {
+	clientItem := consoleLookupClient(clientId)
+	if clientItem == nil {
+		fmt.Fprintln(out, "no client", clientId)
+		return
+	}
+	disconnectClient(clientItem)
+	logger.Infof("Client %s disconnected from the debug console", clientId)
+	fmt.Fprintln(out, "disconnected", clientId)
+}
+
+func consoleDeleteRoom(out io.Writer, roomId string) {
+	if !adminDeleteRoom(roomId) {
+		fmt.Fprintln(out, "no room", roomId)
+		return
+	}
+	logger.Infof("Room %s deleted from the debug console", roomId)
+	fmt.Fprintln(out, "deleted", roomId)
+}
+
+func consoleExpire(out io.Writer, _ string) {
+	now := clk.Now()
+	expireRooms(now)
+	expireSessions(now)
+	disconnectIdleClients(now)
+	fmt.Fprintln(out, "done")
+}
+
+func consoleGC(out io.Writer, _ string) {
+	debug.FreeOSMemory()
+	consoleRuntime(out, "")
+}
+
+func consoleLookupClient(clientId string) *client.Client {
+	mu.Lock()
+	defer mu.Unlock()
+	return clients[clientId]
+}
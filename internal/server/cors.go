@@ -0,0 +1,58 @@
+package server
+
+import (
+	"net/http"
+	"slices"
+	"strings"
+)
+
+// corsMaxAge is how long browsers may cache the answer to a preflight request, in seconds.
+const corsMaxAge = "600"
+
+// withCORS lets the pages of CORS_ORIGINS call the endpoint from the browser.
+// Preflight requests are answered without calling the handler, so they need
+// no credentials. Requests from other origins are served without CORS headers,
+// the browser then keeps the response from the page.
+func withCORS(handler http.HandlerFunc) http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		if len(cfg().CORSOrigins) == 0 {
+			handler(writer, request)
+			return
+		}
+		header := writer.Header()
+		header.Add("Vary", "Origin")
+		origin := request.Header.Get("Origin")
+		anyOrigin := slices.Contains(cfg().CORSOrigins, "*")
+		if origin == "" || !anyOrigin && !originAllowed(request, origin, cfg().CORSOrigins) {
+			handler(writer, request)
+			return
+		}
+		if anyOrigin {
+			header.Set("Access-Control-Allow-Origin", "*")
+		} else {
+			header.Set("Access-Control-Allow-Origin", origin)
+		}
+		if request.Method == http.MethodOptions && request.Header.Get("Access-Control-Request-Method") != "" {
+			header.Add("Vary", "Access-Control-Request-Method, Access-Control-Request-Headers")
+			header.Set("Access-Control-Allow-Methods", strings.Join(cfg().CORSMethods, ", "))
+			header.Set("Access-Control-Allow-Headers", strings.Join(cfg().CORSHeaders, ", "))
+			header.Set("Access-Control-Max-Age", corsMaxAge)
+			writer.WriteHeader(http.StatusNoContent)
+			return
+		}
+		handler(writer, request)
+	}
+}
+
+// withAdminCORS lets the pages of CORS_ORIGINS call the admin API only with
+// CORS_ADMIN, the admin API is kept from other origins otherwise.
+func withAdminCORS(handler http.HandlerFunc) http.HandlerFunc {
+	withOrigins := withCORS(handler)
+	return func(writer http.ResponseWriter, request *http.Request) {
+		if cfg().CORSAdmin {
+			withOrigins(writer, request)
+			return
+		}
+		handler(writer, request)
+	}
+}
@@ -0,0 +1,117 @@
+package server
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/shankarammai/Peer2PeerConnector/internal/client"
+	"github.com/shankarammai/Peer2PeerConnector/internal/logging"
+	responsemessage "github.com/shankarammai/Peer2PeerConnector/internal/response"
+)
+
+const (
+	// dedupMaxIds bounds the message Ids remembered per client, the oldest are
+	// forgotten first.
+	dedupMaxIds = 1024
+	// maxMessageIdLength bounds the length of a "message_id".
+	maxMessageIdLength = 128
+)
+
+// seenId is a message Id and when it was received.
+type seenId struct {
+	id string
+	at time.Time
+}
+
+// seenIds are the message Ids a client sent within DEDUP_WINDOW.
+type seenIds struct {
+	at map[string]time.Time
+	// order lists the Ids oldest first.
+	order []seenId
+}
+
+var (
+	// recentIds are keyed by client Id, they outlive the connection so a
+	// message sent again after a reconnection is caught too.
+	recentIds   = make(map[string]*seenIds)
+	recentIdsMu sync.Mutex
+	// duplicatesDropped counts the messages dropped as duplicates.
+	duplicatesDropped atomic.Int64
+)
+
+// checkDuplicate tells if the message should be handled. A message whose
+// "message_id" the client already sent within DEDUP_WINDOW is a retransmission:
+// it is dropped and the client gets "Duplicate" instead, so a join isn't
+// processed or an offer relayed twice.
+func checkDuplicate(client *client.Client, msg map[string]interface{}) bool {
+	if cfg().DedupWindow <= 0 {
+		return true
+	}
+	raw, exists := msg["message_id"]
+	if !exists {
+		return true
+	}
+	messageId, ok := raw.(string)
+	if !ok || messageId == "" || len(messageId) > maxMessageIdLength {
+		client.Send(responsemessage.ErrorMessage("Invalid_Fields", map[string]interface{}{"message": "'message_id' field must be a string of at most 128 bytes."}))
+		return false
+	}
+
+	now := clk.Now()
+	recentIdsMu.Lock()
+	seen, exists := recentIds[client.GetClientId()]
+	if !exists {
+		seen = &seenIds{at: make(map[string]time.Time)}
+		recentIds[client.GetClientId()] = seen
+	}
+	at, duplicate := seen.at[messageId]
+	duplicate = duplicate && now.Sub(at) < cfg().DedupWindow
+	if !duplicate {
+		seen.remember(messageId, now)
+	}
+	recentIdsMu.Unlock()
+	if !duplicate {
+		return true
+	}
+
+	duplicatesDropped.Add(1)
+	logging.ForClient(client.Id).Debugf("Dropped duplicate %v %s", msg["event"], messageId)
+	client.Send(responsemessage.InfoMessage("Duplicate", map[string]interface{}{"message_id": messageId, "event": msg["event"]}))
+	return false
+}
+
+// remember records a message Id, forgetting the oldest past dedupMaxIds.
+func (seen *seenIds) remember(messageId string, now time.Time) {
+	seen.at[messageId] = now
+	seen.order = append(seen.order, seenId{id: messageId, at: now})
+	if len(seen.order) > dedupMaxIds {
+		seen.forget(1)
+	}
+}
+
+// forget drops the count oldest Ids, unless they were received again since.
+func (seen *seenIds) forget(count int) {
+	for _, oldest := range seen.order[:count] {
+		if seen.at[oldest.id].Equal(oldest.at) {
+			delete(seen.at, oldest.id)
+		}
+	}
+	seen.order = seen.order[count:]
+}
+
+// expireSeenIds forgets the message Ids received more than DEDUP_WINDOW ago.
+func expireSeenIds(now time.Time) {
+	recentIdsMu.Lock()
+	defer recentIdsMu.Unlock()
+	for clientId, seen := range recentIds {
+		expired := 0
+		for expired < len(seen.order) && now.Sub(seen.order[expired].at) >= cfg().DedupWindow {
+			expired++
+		}
+		seen.forget(expired)
+		if len(seen.order) == 0 {
+			delete(recentIds, clientId)
+		}
+	}
+}
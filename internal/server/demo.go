@@ -0,0 +1,18 @@
+package server
+
+import (
+	"io/fs"
+	"net/http"
+
+	"github.com/shankarammai/Peer2PeerConnector/public"
+)
+
+// demoHandler serves the embedded demo client below /demo/: a two-peer video
+// call using the protocol, to check a deployment end to end from a browser.
+func demoHandler() http.Handler {
+	files, err := fs.Sub(public.Demo, "demo")
+	if err != nil {
+		panic(err)
+	}
+	return http.StripPrefix("/demo/", http.FileServerFS(files))
+}
@@ -0,0 +1,150 @@
+package server
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"net/http/pprof"
+	"runtime"
+	"slices"
+	"strings"
+	"time"
+)
+
+// modulePrefix prefixes the functions of this module in stack traces.
+const modulePrefix = "github.com/shankarammai/Peer2PeerConnector/"
+
+// goroutineCount is the number of goroutines blocked in one function.
+type goroutineCount struct {
+	Function string `json:"function"`
+	Count    int    `json:"count"`
+}
+
+// handlePprofRequest serves the net/http/pprof profiles below prefix, e.g.
+// /admin/debug/pprof/heap.
+func handlePprofRequest(writer http.ResponseWriter, request *http.Request, prefix string) {
+	// the pprof handlers look the profile up below /debug/pprof/
+	request.URL.Path = "/debug/pprof/" + strings.TrimPrefix(request.URL.Path, prefix)
+	switch request.URL.Path {
+	case "/debug/pprof/cmdline":
+		pprof.Cmdline(writer, request)
+	case "/debug/pprof/profile":
+		pprof.Profile(writer, request)
+	case "/debug/pprof/symbol":
+		pprof.Symbol(writer, request)
+	case "/debug/pprof/trace":
+		pprof.Trace(writer, request)
+	default:
+		pprof.Index(writer, request)
+	}
+}
+
+// handleRuntimeRequest serves /admin/runtime: GET returns the goroutines,
+// grouped by where they are blocked, and the heap and garbage collector
+// figures, to tell a leak from a busy server.
+func handleRuntimeRequest(writer http.ResponseWriter, request *http.Request) {
+	if request.Method != http.MethodGet {
+		http.Error(writer, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var memory runtime.MemStats
+	runtime.ReadMemStats(&memory)
+	mu.Lock()
+	clientCount, roomCount := len(clients), len(rooms)
+	mu.Unlock()
+	var lastGC time.Time
+	if memory.LastGC > 0 {
+		lastGC = time.Unix(0, int64(memory.LastGC))
+	}
+	writeJSONResponse(writer, http.StatusOK, map[string]interface{}{
+		"go_version": runtime.Version(),
+		"gomaxprocs": runtime.GOMAXPROCS(0),
+		"clients":    clientCount,
+		"rooms":      roomCount,
+		"goroutines": runtime.NumGoroutine(),
+		"blocked_in": goroutinesByFunction(),
+		"heap": map[string]interface{}{
+			"alloc_bytes":    memory.HeapAlloc,
+			"inuse_bytes":    memory.HeapInuse,
+			"idle_bytes":     memory.HeapIdle,
+			"released_bytes": memory.HeapReleased,
+			"sys_bytes":      memory.HeapSys,
+			"objects":        memory.HeapObjects,
+		},
+		"stack_inuse_bytes": memory.StackInuse,
+		"gc": map[string]interface{}{
+			"cycles":            memory.NumGC,
+			"last":              lastGC,
+			"next_target_bytes": memory.NextGC,
+			"pause_total":       time.Duration(memory.PauseTotalNs).Seconds(),
+		},
+	})
+}
+
+// goroutinesByFunction counts the goroutines by the innermost function of
+// this module on their stack, or by the function they started in when none
+// is, most first.
+func goroutinesByFunction() []goroutineCount {
+	records := make([]runtime.StackRecord, runtime.NumGoroutine()+16)
+	count, ok := runtime.GoroutineProfile(records)
+	for !ok {
+		records = make([]runtime.StackRecord, count+16)
+		count, ok = runtime.GoroutineProfile(records)
+	}
+	counts := map[string]int{}
+	for _, record := range records[:count] {
+		frames := runtime.CallersFrames(record.Stack())
+		var blockedIn string
+		for {
+			frame, more := frames.Next()
+			if strings.HasPrefix(frame.Function, modulePrefix) {
+				blockedIn = frame.Function
+				break
+			}
+			if blockedIn == "" || frame.Function != "runtime.goexit" {
+				blockedIn = frame.Function
+			}
+			if !more {
+				break
+			}
+		}
+		counts[strings.TrimPrefix(blockedIn, modulePrefix)]++
+	}
+	grouped := make([]goroutineCount, 0, len(counts))
+	for function, count := range counts {
+		grouped = append(grouped, goroutineCount{Function: function, Count: count})
+	}
+	slices.SortFunc(grouped, func(a, b goroutineCount) int {
+		if a.Count != b.Count {
+			return b.Count - a.Count
+		}
+		return strings.Compare(a.Function, b.Function)
+	})
+	return grouped
+}
+
+// ServeDiagnostics serves the pprof profiles at /debug/pprof/ and the runtime
+// summary at /debug/runtime without authentication, on a loopback address so
+// only operators on the machine reach them.
+func ServeDiagnostics(addr string) error {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return err
+	}
+	if !isLoopback(host) {
+		return errors.New("the diagnostics only listen on a loopback address, not " + addr)
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", func(writer http.ResponseWriter, request *http.Request) {
+		handlePprofRequest(writer, request, "/debug/pprof/")
+	})
+	mux.HandleFunc("/debug/runtime", handleRuntimeRequest)
+	logger.Info("Diagnostics listening on ", addr)
+	return http.ListenAndServe(addr, LogRequests(mux))
+}
+
+// isLoopback tells if the host is localhost or a loopback IP.
+func isLoopback(host string) bool {
+	ip := net.ParseIP(host)
+	return host == "localhost" || (ip != nil && ip.IsLoopback())
+}
@@ -0,0 +1,49 @@
+package server
+
+import (
+	"context"
+	"errors"
+
+	"github.com/shankarammai/Peer2PeerConnector/internal/authz"
+	"github.com/shankarammai/Peer2PeerConnector/internal/directory"
+	"github.com/shankarammai/Peer2PeerConnector/internal/logging"
+)
+
+// userDirectory resolves the profile of authenticated clients, nil without one.
+var userDirectory directory.Directory
+
+// SetDirectory replaces the user directory chosen by the configuration, e.g.
+// with one backed by LDAP. It must be called after Init and before the server
+// starts accepting connections.
+func SetDirectory(custom directory.Directory) {
+	userDirectory = custom
+}
+
+// newDirectory returns the user directory configured by DIRECTORY_URL, nil when it is empty.
+func newDirectory() (directory.Directory, error) {
+	if cfg().DirectoryURL == "" {
+		return nil, nil
+	}
+	if _, ok := authorizer.(authz.Authenticator); !ok {
+		return nil, errors.New("DIRECTORY_URL needs an authorizer authenticating clients, like AUTHORIZER=jwt")
+	}
+	return directory.NewHTTP(cfg().DirectoryURL, cfg().DirectoryToken, cfg().DirectoryTimeout)
+}
+
+// lookupProfile resolves the profile of a connecting client. Failed lookups
+// don't refuse the connection, the client connects without a profile.
+func lookupProfile(ctx context.Context, clientId string, userId string) *directory.Profile {
+	if userDirectory == nil || userId == "" {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(ctx, cfg().DirectoryTimeout)
+	defer cancel()
+	profile, err := userDirectory.Lookup(ctx, userId)
+	switch {
+	case errors.Is(err, directory.ErrNotFound):
+		logging.ForClient(clientId).Debug("User not found in the directory: ", userId)
+	case err != nil:
+		logging.ForClient(clientId).Warn("Directory lookup failed: ", err)
+	}
+	return profile
+}
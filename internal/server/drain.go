@@ -0,0 +1,174 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+
+	"github.com/shankarammai/Peer2PeerConnector/internal/client"
+	"github.com/shankarammai/Peer2PeerConnector/internal/clock"
+	"github.com/shankarammai/Peer2PeerConnector/internal/logging"
+	responsemessage "github.com/shankarammai/Peer2PeerConnector/internal/response"
+)
+
+var (
+	// draining is set while the node is being drained, drainEndpoints are where
+	// its clients are sent to and drainDeadline closes the connections left
+	// after DRAIN_TIMEOUT.
+	draining       bool
+	drainEndpoints []string
+	drainDeadline  clock.Timer
+	drainMu        sync.Mutex
+)
+
+func isDraining() bool {
+	drainMu.Lock()
+	defer drainMu.Unlock()
+	return draining
+}
+
+// refuseWhileDraining turns new connections away while the node is drained.
+func refuseWhileDraining(writer http.ResponseWriter) bool {
+	if !isDraining() {
+		return false
+	}
+	writer.Header().Set("Retry-After", "30")
+	http.Error(writer, "Server is draining", http.StatusServiceUnavailable)
+	return true
+}
+
+// checkNotDraining reports to the client that rooms can't be created or joined
+// on a node that is drained.
+func checkNotDraining(client *client.Client) bool {
+	drainMu.Lock()
+	endpoints := drainEndpoints
+	active := draining
+	drainMu.Unlock()
+	if active {
+		client.Send(responsemessage.ErrorMessage("Draining", map[string]interface{}{
+			"message":   "This server is shutting down, reconnect to another one.",
+			"endpoints": endpoints,
+		}))
+	}
+	return !active
+}
+
+// startDrain stops new connections and joins on the node. With reconnect, it
+// then tells every connected client to reconnect to one of the endpoints and
+// closes the connections still open after DRAIN_TIMEOUT, without it the
+// clients stay until they leave on their own. The resume tokens are only known
+// to this node, so they aren't handed out.
+func startDrain(endpoints []string, reconnect bool) int {
+	drainMu.Lock()
+	draining = true
+	drainEndpoints = endpoints
+	drainMu.Unlock()
+	if !reconnect {
+		logging.Logger.Warn("Draining, refusing new sessions")
+		return 0
+	}
+
+	mu.Lock()
+	connected := make([]*client.Client, 0, len(clients))
+	for _, clientItem := range clients {
+		connected = append(connected, clientItem)
+	}
+	mu.Unlock()
+
+	logging.Logger.Warnf("Draining, sending %d clients to %v", len(connected), endpoints)
+	for _, clientItem := range connected {
+		clientItem.Send(responsemessage.InfoMessage("Reconnect_To", map[string]interface{}{"endpoints": endpoints}))
+	}
+	if cfg().DrainTimeout > 0 {
+		drainMu.Lock()
+		if drainDeadline != nil {
+			drainDeadline.Stop()
+		}
+		drainDeadline = clk.AfterFunc(cfg().DrainTimeout, closeDrainedConnections)
+		drainMu.Unlock()
+	}
+	return len(connected)
+}
+
+// closeDrainedConnections disconnects the clients still connected to a drained
+// node once DRAIN_TIMEOUT passed.
+func closeDrainedConnections() {
+	if !isDraining() {
+		return
+	}
+	mu.Lock()
+	connected := make([]*client.Client, 0, len(clients))
+	for _, clientItem := range clients {
+		connected = append(connected, clientItem)
+	}
+	mu.Unlock()
+	logging.Logger.Warnf("Drain timeout, closing %d connections", len(connected))
+	for _, clientItem := range connected {
+		disconnectClient(clientItem)
+	}
+}
+
+// stopDrain accepts connections and joins again.
+func stopDrain() {
+	drainMu.Lock()
+	draining = false
+	drainEndpoints = nil
+	if drainDeadline != nil {
+		drainDeadline.Stop()
+		drainDeadline = nil
+	}
+	drainMu.Unlock()
+	logging.Logger.Info("Drain cancelled")
+}
+
+// handleDrainRequest serves /admin/drain: GET tells whether the node is drained
+// and how loaded it is, POST starts draining the node, with the endpoints of the
+// body or DRAIN_ENDPOINTS, DELETE cancels it. A POST with "reconnect": false
+// only refuses new sessions, so an autoscaler can wait for the node to empty.
+func handleDrainRequest(writer http.ResponseWriter, request *http.Request) {
+	switch request.Method {
+	case http.MethodGet:
+		drainMu.Lock()
+		state := map[string]interface{}{
+			"draining":  draining,
+			"endpoints": drainEndpoints,
+		}
+		drainMu.Unlock()
+		mu.Lock()
+		state["clients"] = len(clients)
+		mu.Unlock()
+		state["connections"] = webSocketConnections.Load()
+		if cfg().MaxConnections > 0 {
+			state["max_connections"] = cfg().MaxConnections
+			state["usage"] = capacityUsage()
+		}
+		writeJSONResponse(writer, http.StatusOK, state)
+	case http.MethodPost:
+		var body struct {
+			Endpoints []string `json:"endpoints"`
+			Reconnect *bool    `json:"reconnect"`
+		}
+		if request.ContentLength != 0 {
+			if err := json.NewDecoder(http.MaxBytesReader(writer, request.Body, maxPollMessageSize)).Decode(&body); err != nil {
+				http.Error(writer, "Invalid body", http.StatusBadRequest)
+				return
+			}
+		}
+		if len(body.Endpoints) == 0 {
+			body.Endpoints = cfg().DrainEndpoints
+		}
+		reconnect := body.Reconnect == nil || *body.Reconnect
+		notified := startDrain(body.Endpoints, reconnect)
+		writeJSONResponse(writer, http.StatusOK, map[string]interface{}{
+			"draining":  true,
+			"endpoints": body.Endpoints,
+			"reconnect": reconnect,
+			"clients":   notified,
+		})
+	case http.MethodDelete:
+		stopDrain()
+		writeJSONResponse(writer, http.StatusOK, map[string]interface{}{"draining": false})
+	default:
+		http.Error(writer, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
@@ -0,0 +1,93 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/shankarammai/Peer2PeerConnector/internal/client"
+	"github.com/shankarammai/Peer2PeerConnector/internal/ids"
+	"github.com/shankarammai/Peer2PeerConnector/internal/logging"
+	responsemessage "github.com/shankarammai/Peer2PeerConnector/internal/response"
+)
+
+// Policies for a user connecting while already connected, see DUPLICATE_CONNECTIONS.
+const (
+	// duplicateDevices keeps every connection, each with its own device Id.
+	duplicateDevices = "devices"
+	// duplicateReject refuses the new connection.
+	duplicateReject = "reject"
+	// duplicateKick disconnects the old connections with a "Superseded" error.
+	duplicateKick = "kick"
+)
+
+var (
+	errUserConnected = errors.New("user already connected")
+	errDeviceTaken   = errors.New("device already connected")
+)
+
+// checkDuplicatePolicy validates DUPLICATE_CONNECTIONS.
+func checkDuplicatePolicy(policy string) error {
+	switch policy {
+	case duplicateDevices, duplicateReject, duplicateKick:
+		return nil
+	}
+	return fmt.Errorf("invalid DUPLICATE_CONNECTIONS %q, use devices, reject or kick", policy)
+}
+
+// claimUserConnection applies the duplicate connection policy to a connecting
+// client of an authenticated user, naming its device after the device query
+// parameter or generating one. It returns the connections the client
+// supersedes. mu must be held.
+func claimUserConnection(newClient *client.Client, device string) ([]*client.Client, error) {
+	if newClient.UserID == "" {
+		return nil, nil
+	}
+	var connected []*client.Client
+	for _, clientItem := range clients {
+		if clientItem.UserID == newClient.UserID {
+			connected = append(connected, clientItem)
+		}
+	}
+	switch cfg().DuplicateConnections {
+	case duplicateReject:
+		if len(connected) > 0 {
+			return nil, errUserConnected
+		}
+	case duplicateKick:
+		return connected, nil
+	default:
+		if device == "" {
+			device = newClientIdentifier()
+		} else if !ids.Valid(device) {
+			return nil, errInvalidClientId
+		}
+		for _, clientItem := range connected {
+			if clientItem.GetDevice() == device {
+				return nil, errDeviceTaken
+			}
+		}
+		newClient.Device = device
+	}
+	return nil, nil
+}
+
+// checkUserConnection tells if the duplicate connection policy would refuse
+// a connection of userId, so it is refused before the WebSocket upgrade.
+func checkUserConnection(userId string, device string) error {
+	mu.Lock()
+	defer mu.Unlock()
+	_, err := claimUserConnection(&client.Client{UserID: userId}, device)
+	return err
+}
+
+// supersede disconnects the connections replaced by a new connection of their user.
+func supersede(superseded []*client.Client, by *client.Client) {
+	for _, clientItem := range superseded {
+		logging.ForClient(clientItem.GetClientId()).Info("Connection superseded by ", by.GetClientId())
+		clientItem.Send(responsemessage.ErrorMessage("Superseded", map[string]interface{}{
+			"message": "You connected again from elsewhere.",
+			"client":  by.GetClientId(),
+		}))
+		disconnectClient(clientItem)
+	}
+}
@@ -0,0 +1,107 @@
+package server
+
+import (
+	"slices"
+
+	"github.com/shankarammai/Peer2PeerConnector/internal/client"
+	"github.com/shankarammai/Peer2PeerConnector/internal/logging"
+	responsemessage "github.com/shankarammai/Peer2PeerConnector/internal/response"
+)
+
+// checkEncrypted checks the "encrypted" flag of a relayed message. The payload
+// of an encrypted message is opaque to the server and relayed as is. Encrypted
+// room messages without a "key_epoch" are stamped with the current epoch of the room.
+func checkEncrypted(client *client.Client, msg map[string]interface{}) bool {
+	value, exists := msg["encrypted"]
+	if !exists {
+		return true
+	}
+	encrypted, ok := value.(bool)
+	if !ok {
+		client.Send(responsemessage.ErrorMessage("Invalid_Fields", map[string]interface{}{"message": "'encrypted' field must be a boolean."}))
+		return false
+	}
+	roomId, scoped := msg["room"].(string)
+	if _, stamped := msg["key_epoch"]; encrypted && scoped && !stamped {
+		mu.Lock()
+		if myRoom, exists := rooms[roomId]; exists {
+			msg["key_epoch"] = myRoom.GetKeyEpoch()
+		}
+		mu.Unlock()
+	}
+	return true
+}
+
+// handlePublishKeyMessage processes a "publish_key" message.
+// It stores the public key of the client, which is then part of the room
+// membership data, and relays it to every member of the rooms the client is in.
+func handlePublishKeyMessage(client *client.Client, msg map[string]interface{}) {
+	data, dataOk := msg["data"].(map[string]interface{})
+	if !dataOk {
+		client.Send(responsemessage.ErrorMessage("Missing_Fields", map[string]interface{}{"message": "'data' field is missing or is not object in the request."}))
+		return
+	}
+	publicKey, ok := data["key"].(string)
+	if !ok || publicKey == "" {
+		client.Send(responsemessage.ErrorMessage("Missing_Fields", map[string]interface{}{"message": "'data''key' field is missing or is not a string."}))
+		return
+	}
+
+	mu.Lock()
+	client.SetPublicKey(publicKey)
+	mu.Unlock()
+	logging.ForClient(client.Id).Debug("Public key published")
+
+	client.Send(responsemessage.InfoMessage("Key_Published", map[string]interface{}{"key": publicKey}))
+	for _, roomItem := range roomsOfClient(client.GetClientId()) {
+		update := responsemessage.UpdateMessage("Public_Key", map[string]interface{}{
+			"room":   roomItem.GetId(),
+			"client": client.GetClientId(),
+			"key":    publicKey,
+		})
+		for _, member := range connectedClients(roomItem.GetClients()) {
+			if member.GetClientId() != client.GetClientId() {
+				member.Send(update)
+			}
+		}
+	}
+}
+
+// handleRotateKeyMessage processes a "rotate_key" message.
+// It starts a new key epoch in the room and tells every member, so they can
+// switch to the key distributed for that epoch.
+func handleRotateKeyMessage(client *client.Client, msg map[string]interface{}) {
+	if !checkRoomInJSON(client, msg) {
+		return
+	}
+	data := msg["data"].(map[string]interface{})
+	roomId, _ := data["room"].(string)
+	from := client.GetClientId()
+
+	mu.Lock()
+	myRoom, exists := rooms[roomId]
+	if !exists {
+		mu.Unlock()
+		client.Send(responsemessage.ErrorMessage("Not_Found", map[string]interface{}{"message": "Room with Id " + roomId + " does not exist."}))
+		return
+	}
+	if !slices.Contains(myRoom.GetClients(), from) {
+		mu.Unlock()
+		client.Send(responsemessage.ErrorMessage("Unauthorised", map[string]interface{}{"message": "You need to be in the room to rotate its key."}))
+		return
+	}
+	epoch := myRoom.NextKeyEpoch()
+	members := slices.Clone(myRoom.GetClients())
+	mu.Unlock()
+	persistRoom(myRoom)
+	logging.ForRoom(from, roomId).Debug("Key epoch is now ", epoch)
+
+	update := responsemessage.UpdateMessage("Key_Epoch", map[string]interface{}{
+		"room":  roomId,
+		"from":  from,
+		"epoch": epoch,
+	})
+	for _, member := range connectedClients(members) {
+		member.Send(update)
+	}
+}
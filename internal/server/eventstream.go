@@ -0,0 +1,126 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/shankarammai/Peer2PeerConnector/internal/events"
+	"github.com/shankarammai/Peer2PeerConnector/internal/logging"
+)
+
+// EventRelayCounts is the periodic event carrying the number of relayed messages.
+// It is only sent to the event stream, webhooks would be hammered.
+const EventRelayCounts = "relay_counts"
+
+var (
+	eventSink events.Sink
+	// relayedMessages counts the messages relayed since the last relay_counts event.
+	relayedMessages atomic.Int64
+)
+
+// publishEvent sends an event to the event stream, if one is configured, and
+// to the operators following /admin/events.
+func publishEvent(eventType string, data map[string]interface{}) {
+	event := events.Event{Type: eventType, Data: data, Timestamp: time.Now()}
+	notifyEventSubscribers(event)
+	if eventSink == nil {
+		return
+	}
+	if err := eventSink.Publish(event); err != nil {
+		logging.Logger.Debug("Failed to publish event: ", err)
+	}
+}
+
+// eventSubscribers are the streams of /admin/events.
+var (
+	eventSubscribersMu sync.Mutex
+	eventSubscribers   = map[chan events.Event]struct{}{}
+)
+
+// subscribeEvents returns a channel receiving the events published from now on,
+// and the function ending the subscription.
+func subscribeEvents() (chan events.Event, func()) {
+	subscription := make(chan events.Event, 64)
+	eventSubscribersMu.Lock()
+	eventSubscribers[subscription] = struct{}{}
+	eventSubscribersMu.Unlock()
+	return subscription, func() {
+		eventSubscribersMu.Lock()
+		delete(eventSubscribers, subscription)
+		eventSubscribersMu.Unlock()
+	}
+}
+
+// notifyEventSubscribers hands the event to the subscribers, a subscriber that
+// doesn't keep up misses events rather than slowing the server down.
+func notifyEventSubscribers(event events.Event) {
+	eventSubscribersMu.Lock()
+	defer eventSubscribersMu.Unlock()
+	for subscription := range eventSubscribers {
+		select {
+		case subscription <- event:
+		default:
+		}
+	}
+}
+
+// serveAdminEvents streams the server events as Server-Sent Events until the
+// request is cancelled.
+func serveAdminEvents(writer http.ResponseWriter, request *http.Request) {
+	if request.Method != http.MethodGet {
+		http.Error(writer, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	flusher, ok := writer.(http.Flusher)
+	if !ok {
+		http.Error(writer, "Streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+	subscription, unsubscribe := subscribeEvents()
+	defer unsubscribe()
+
+	writer.Header().Set("Content-Type", "text/event-stream")
+	writer.Header().Set("Cache-Control", "no-store")
+	writer.Header().Set("X-Accel-Buffering", "no")
+	writer.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	keepAlive := time.NewTicker(sseKeepAlive)
+	defer keepAlive.Stop()
+	for {
+		select {
+		case event := <-subscription:
+			encoded, err := json.Marshal(event)
+			if err != nil {
+				continue
+			}
+			fmt.Fprintf(writer, "event: %s\ndata: %s\n\n", event.Type, encoded)
+		case <-keepAlive.C:
+			fmt.Fprint(writer, ": keep-alive\n\n")
+		case <-request.Context().Done():
+			return
+		}
+		flusher.Flush()
+	}
+}
+
+// countRelay records relayed messages for the relay_counts event.
+func countRelay(messages int) {
+	relayedMessages.Add(int64(messages))
+}
+
+// runRelayCounts periodically publishes the number of relayed messages.
+func runRelayCounts(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		publishEvent(EventRelayCounts, map[string]interface{}{
+			"messages": relayedMessages.Swap(0),
+			"interval": interval.Seconds(),
+		})
+	}
+}
@@ -0,0 +1,196 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"strconv"
+
+	"github.com/shankarammai/Peer2PeerConnector/internal/client"
+	"github.com/shankarammai/Peer2PeerConnector/internal/logging"
+	"github.com/shankarammai/Peer2PeerConnector/internal/protocol"
+)
+
+// member is the span of a member of a JSON object in a message.
+type member struct {
+	start, end int
+	// value is the span of the value, quotes included for strings.
+	value [2]int
+}
+
+// relayFast relays a plain Candidate or Message, with no other field than
+// "event", "to" and "data", between two JSON clients on the raw bytes: "to" is
+// replaced by "from" without decoding the message and encoding it again,
+// which spares the garbage collector during candidate storms. It returns false,
+// having done nothing, when the message needs the full handling.
+func relayFast(client *client.Client, message []byte) bool {
+	if client.GetCodec() != protocol.JSON || client.GetVersion() != protocol.V1 || cfg().LogPayloads || tapCount.Load() > 0 {
+		return false
+	}
+	event, to, data, ok := scanRelay(message)
+	if !ok {
+		return false
+	}
+	// the conversions below don't allocate, the strings are compared or looked up
+	var msgtype string
+	switch string(message[event.value[0]+1 : event.value[1]-1]) {
+	case MsgTypeCandidate:
+		msgtype = MsgTypeCandidate
+	case MsgTypeMessage:
+		msgtype = MsgTypeMessage
+	}
+	if msgtype == "" || (msgtype == MsgTypeCandidate && cfg().CandidateDebounce > 0) {
+		return false
+	}
+	mu.Lock()
+	targetClient, exists := clients[string(message[to.value[0]+1:to.value[1]-1])]
+	mu.Unlock()
+	if !exists || targetClient.GetCodec() != protocol.JSON || targetClient.Acks {
+		return false
+	}
+	targetID := targetClient.GetClientId()
+
+	if logging.DebugEnabled() {
+		logging.ForClient(client.Id).WithField(logging.FieldMessageType, msgtype).Debug("Message received")
+	}
+	markActive(client)
+	if !accountClientTraffic(client, len(message)) {
+		return true
+	}
+	if refuseBlocked(client, targetClient) {
+		return true
+	}
+	if !checkAuthorized(client, msgtype, authorizer.CanRelay(subjectOf(client), msgtype, "", targetID)) {
+		return true
+	}
+
+	var err error
+	if msgtype == MsgTypeCandidate {
+		err = relaySignal(client.GetClientId(), targetClient, func(seq uint64) error {
+			return targetClient.SendEncoded(rewriteRelay(message, event, data, client.GetClientId(), seq), relayPriority(msgtype))
+		})
+	} else {
+		err = targetClient.SendEncoded(rewriteRelay(message, event, data, client.GetClientId(), 0), relayPriority(msgtype))
+	}
+	if err != nil {
+		logging.ForClient(client.Id).Debugf("Failed to relay message to target client %s: %v", targetID, err)
+	} else {
+		countRelay(1)
+	}
+	return true
+}
+
+// rewriteRelay builds the relayed message: "from", "seq" unless it is 0, then
+// the "event" and "data" members of the message as they were received.
+func rewriteRelay(message []byte, event, data member, from string, seq uint64) []byte {
+	relayed := make([]byte, 0, len(message)+len(from)+28)
+	relayed = append(relayed, `{"from":"`...)
+	relayed = append(relayed, from...)
+	relayed = append(relayed, '"', ',')
+	if seq > 0 {
+		relayed = append(relayed, `"seq":`...)
+		relayed = strconv.AppendUint(relayed, seq, 10)
+		relayed = append(relayed, ',')
+	}
+	relayed = append(relayed, message[event.start:event.end]...)
+	if data.end > 0 {
+		relayed = append(relayed, ',')
+		relayed = append(relayed, message[data.start:data.end]...)
+	}
+	return append(relayed, '}')
+}
+
+// scanRelay finds the "event", "to" and "data" members of a message that has
+// no other member. "data" may be missing, "event" and "to" must be strings
+// without escapes.
+func scanRelay(message []byte) (event, to, data member, ok bool) {
+	if !json.Valid(message) {
+		return event, to, data, false
+	}
+	i := skipSpace(message, 0)
+	if i >= len(message) || message[i] != '{' {
+		return event, to, data, false
+	}
+	i = skipSpace(message, i+1)
+	for i < len(message) && message[i] != '}' {
+		if message[i] == ',' {
+			i = skipSpace(message, i+1)
+		}
+		start := i
+		keyEnd := skipValue(message, i)
+		key := message[i+1 : keyEnd-1]
+		i = skipSpace(message, keyEnd)
+		i = skipSpace(message, i+1) // the colon
+		valueEnd := skipValue(message, i)
+		found := member{start: start, end: valueEnd, value: [2]int{i, valueEnd}}
+		var slot *member
+		switch string(key) {
+		case "event":
+			slot = &event
+		case "to":
+			slot = &to
+		case "data":
+			slot = &data
+		default:
+			return event, to, data, false
+		}
+		if slot.end > 0 {
+			return event, to, data, false
+		}
+		*slot = found
+		i = skipSpace(message, valueEnd)
+	}
+	return event, to, data, isPlainString(message, event) && isPlainString(message, to)
+}
+
+// isPlainString tells if the value of the member is a string without escapes.
+func isPlainString(message []byte, found member) bool {
+	if found.end == 0 || message[found.value[0]] != '"' {
+		return false
+	}
+	return bytes.IndexByte(message[found.value[0]+1:found.value[1]-1], '\\') < 0
+}
+
+func skipSpace(message []byte, i int) int {
+	for i < len(message) && (message[i] == ' ' || message[i] == '\t' || message[i] == '\n' || message[i] == '\r') {
+		i++
+	}
+	return i
+}
+
+// skipValue returns the end of the JSON value starting at i, message must be
+// valid JSON.
+func skipValue(message []byte, i int) int {
+	depth := 0
+	for ; i < len(message); i++ {
+		switch message[i] {
+		case '"':
+			for i++; message[i] != '"'; i++ {
+				if message[i] == '\\' {
+					i++
+				}
+			}
+			if depth == 0 {
+				return i + 1
+			}
+		case '{', '[':
+			depth++
+		case '}', ']':
+			if depth == 0 {
+				return i
+			}
+			depth--
+			if depth == 0 {
+				return i + 1
+			}
+		case ',':
+			if depth == 0 {
+				return i
+			}
+		case ' ', '\t', '\n', '\r':
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return i
+}
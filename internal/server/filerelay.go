@@ -0,0 +1,297 @@
+package server
+
+import (
+	"encoding/base64"
+	"strings"
+	"sync"
+
+	"golang.org/x/time/rate"
+
+	"github.com/shankarammai/Peer2PeerConnector/internal/client"
+	"github.com/shankarammai/Peer2PeerConnector/internal/config"
+	"github.com/shankarammai/Peer2PeerConnector/internal/logging"
+	responsemessage "github.com/shankarammai/Peer2PeerConnector/internal/response"
+)
+
+// maxFileRelaysPerClient bounds the relayed transfers a client sends at once.
+const maxFileRelaysPerClient = 4
+
+// maxFileAcceptsPerClient bounds the accepted transfers a client awaits the
+// relay of.
+const maxFileAcceptsPerClient = 16
+
+// fileRelay is a file transfer whose chunks go through the server. Its chunks
+// are only relayed once the receiver accepted the transfer.
+type fileRelay struct {
+	from     string
+	to       string
+	size     int
+	sent     int
+	accepted bool
+}
+
+var (
+	// fileRelays are keyed by the sender Id and the transfer Id.
+	fileRelays = make(map[string]*fileRelay)
+	// fileAccepts map the transfers accepted before their relay started, keyed
+	// like fileRelays, to the receiver that accepted them.
+	fileAccepts = make(map[string]string)
+	// fileRelayLimiters bound the chunk bandwidth of each sending client, they
+	// are kept until the client disconnects.
+	fileRelayLimiters = make(map[string]*rate.Limiter)
+	fileRelaysMu      sync.Mutex
+)
+
+func fileRelayKey(from string, transferId string) string {
+	return from + "\x00" + transferId
+}
+
+// fileRelayLimit returns the rate and burst of the chunks a client relays.
+func fileRelayLimit(settings *config.Config) (rate.Limit, int) {
+	limit := rate.Limit(settings.FileRelayRate)
+	if settings.FileRelayRate <= 0 {
+		limit = rate.Inf
+	}
+	return limit, max(settings.FileRelayRate, settings.FileRelayChunkSize)
+}
+
+// retuneFileRelayLimiters applies a reloaded FILE_RELAY_RATE to the clients
+// already relaying files.
+func retuneFileRelayLimiters(settings *config.Config) {
+	limit, burst := fileRelayLimit(settings)
+	now := clk.Now()
+	fileRelaysMu.Lock()
+	defer fileRelaysMu.Unlock()
+	for _, limiter := range fileRelayLimiters {
+		limiter.SetLimitAt(now, limit)
+		limiter.SetBurstAt(now, burst)
+	}
+}
+
+// handleFileRelayMessage processes a "file_relay" message.
+// The offerer of a file whose data channel failed asks the server to relay the
+// chunks of the transfer, the receiver is told to expect them as "file_chunk" messages.
+func handleFileRelayMessage(client *client.Client, msg map[string]interface{}) {
+	if !cfg().FileRelayEnabled {
+		client.Send(responsemessage.ErrorMessage("Unsupported_Event", map[string]interface{}{"message": "File relay is disabled on this server."}))
+		return
+	}
+	targetClient, data, ok := fileRelayTarget(client, msg)
+	if !ok {
+		return
+	}
+	transferId, _ := data["id"].(string)
+	size, ok := intField(data, "size")
+	if !ok || size <= 0 {
+		client.Send(responsemessage.ErrorMessage("Invalid_File", map[string]interface{}{"message": "'data''size' must be the size of the file in bytes."}))
+		return
+	}
+	if size > cfg().FileRelayMaxSize {
+		client.Send(responsemessage.ErrorMessage("Limit_Exceeded", map[string]interface{}{
+			"message": "The file is too large to be relayed.",
+			"limit":   cfg().FileRelayMaxSize,
+		}))
+		return
+	}
+
+	from := client.GetClientId()
+	key := fileRelayKey(from, transferId)
+	fileRelaysMu.Lock()
+	transfers := 0
+	for _, transfer := range fileRelays {
+		if transfer.from == from {
+			transfers++
+		}
+	}
+	if _, restarted := fileRelays[key]; !restarted && transfers >= maxFileRelaysPerClient {
+		fileRelaysMu.Unlock()
+		client.Send(responsemessage.ErrorMessage("Limit_Exceeded", map[string]interface{}{
+			"message": "Too many relayed transfers at once.",
+			"limit":   maxFileRelaysPerClient,
+		}))
+		return
+	}
+	accepted := fileAccepts[key] == targetClient.GetClientId()
+	delete(fileAccepts, key)
+	fileRelays[key] = &fileRelay{from: from, to: targetClient.GetClientId(), size: size, accepted: accepted}
+	if _, exists := fileRelayLimiters[from]; !exists {
+		fileRelayLimiters[from] = rate.NewLimiter(fileRelayLimit(cfg()))
+	}
+	fileRelaysMu.Unlock()
+	logging.ForClient(from).Infof("Relaying file transfer %s to %s", transferId, targetClient.GetClientId())
+
+	details := map[string]interface{}{"id": transferId, "size": size, "chunk_size": cfg().FileRelayChunkSize}
+	targetClient.Send(map[string]interface{}{"event": MsgTypeFileRelay, "from": from, "data": details})
+	details["rate"] = cfg().FileRelayRate
+	client.Send(responsemessage.InfoMessage("File_Relay_Ready", details))
+}
+
+// handleFileChunkMessage processes a "file_chunk" message.
+// The chunk is relayed to the receiver of the transfer if it fits in the transfer
+// and in the bandwidth of the sender, it is refused with "Rate_Limited" otherwise
+// and has to be sent again after "retry_after" milliseconds.
+func handleFileChunkMessage(client *client.Client, msg map[string]interface{}) {
+	targetClient, data, ok := fileRelayTarget(client, msg)
+	if !ok {
+		return
+	}
+	transferId, _ := data["id"].(string)
+	length, chunkOk := chunkLength(data["chunk"])
+	offset, offsetOk := intField(data, "offset")
+	if !chunkOk || !offsetOk || length == 0 || length > cfg().FileRelayChunkSize || offset < 0 {
+		client.Send(responsemessage.ErrorMessage("Invalid_File", map[string]interface{}{
+			"message":    "'data''chunk' must hold up to 'chunk_size' bytes and 'data''offset' its position in the file.",
+			"chunk_size": cfg().FileRelayChunkSize,
+		}))
+		return
+	}
+
+	from := client.GetClientId()
+	key := fileRelayKey(from, transferId)
+	fileRelaysMu.Lock()
+	transfer, exists := fileRelays[key]
+	if !exists || transfer.to != targetClient.GetClientId() {
+		fileRelaysMu.Unlock()
+		client.Send(responsemessage.ErrorMessage("Not_Found", map[string]interface{}{"message": "No relayed transfer " + transferId + " to this client."}))
+		return
+	}
+	if !transfer.accepted {
+		fileRelaysMu.Unlock()
+		client.Send(responsemessage.ErrorMessage("Invalid_File", map[string]interface{}{"message": "The receiver hasn't accepted transfer " + transferId + "."}))
+		return
+	}
+	if offset+length > transfer.size || transfer.sent+length > transfer.size {
+		fileRelaysMu.Unlock()
+		client.Send(responsemessage.ErrorMessage("Invalid_File", map[string]interface{}{"message": "The chunk goes past the end of the file."}))
+		return
+	}
+	now := clk.Now()
+	reservation := fileRelayLimiters[from].ReserveN(now, length)
+	if delay := reservation.DelayFrom(now); delay > 0 {
+		reservation.Cancel()
+		fileRelaysMu.Unlock()
+		client.Send(responsemessage.ErrorMessage("Rate_Limited", map[string]interface{}{
+			"message":     "Chunks are sent faster than the relay allows.",
+			"id":          transferId,
+			"offset":      offset,
+			"retry_after": delay.Milliseconds(),
+		}))
+		return
+	}
+	transfer.sent += length
+	if transfer.sent == transfer.size {
+		removeFileRelay(key)
+	}
+	fileRelaysMu.Unlock()
+
+	if err := targetClient.SendAs(map[string]interface{}{"event": MsgTypeFileChunk, "from": from, "data": data}, relayPriority(MsgTypeFileChunk), nil); err != nil {
+		logging.ForClient(from).Debugf("Failed to relay file chunk to %s: %v", targetClient.GetClientId(), err)
+	} else {
+		countRelay(1)
+	}
+}
+
+// fileRelayTarget returns the receiver named by a file relay message and its
+// data, reporting a problem to the client otherwise.
+func fileRelayTarget(client *client.Client, msg map[string]interface{}) (*client.Client, map[string]interface{}, bool) {
+	targetID, ok := msg["to"].(string)
+	if !ok {
+		client.Send(responsemessage.ErrorMessage("Missing_Fields", map[string]interface{}{"message": "'to' field not found"}))
+		return nil, nil, false
+	}
+	data, ok := msg["data"].(map[string]interface{})
+	if transferId, isString := data["id"].(string); !ok || !isString || transferId == "" || len(transferId) > maxTransferIdLength {
+		client.Send(responsemessage.ErrorMessage("Invalid_File", map[string]interface{}{"message": "'data''id' must name the transfer in at most 64 characters."}))
+		return nil, nil, false
+	}
+
+	mu.Lock()
+	targetClient, exists := clients[targetID]
+	mu.Unlock()
+	if !exists {
+		client.Send(responsemessage.ErrorMessage("Not_Found", map[string]interface{}{"message": "Client with given " + targetID + " not found"}))
+		return nil, nil, false
+	}
+	if refuseBlocked(client, targetClient) {
+		return nil, nil, false
+	}
+	event, _ := msg["event"].(string)
+	if !checkAuthorized(client, event, authorizer.CanRelay(subjectOf(client), event, "", targetID)) {
+		return nil, nil, false
+	}
+	return targetClient, data, true
+}
+
+// chunkLength returns the size in bytes of a chunk, sent base64 encoded in JSON
+// or as raw bytes with the binary codecs.
+func chunkLength(chunk interface{}) (int, bool) {
+	switch value := chunk.(type) {
+	case string:
+		decoded, err := base64.StdEncoding.DecodeString(value)
+		return len(decoded), err == nil
+	case []byte:
+		return len(value), true
+	}
+	return 0, false
+}
+
+// removeFileRelay forgets a transfer. The caller must hold fileRelaysMu.
+func removeFileRelay(key string) {
+	delete(fileRelays, key)
+}
+
+// acceptFileRelay records that the client with Id to accepted the transfer
+// offered by the client with Id from, so its chunks can be relayed.
+func acceptFileRelay(from string, to string, transferId string) {
+	if !cfg().FileRelayEnabled {
+		return
+	}
+	key := fileRelayKey(from, transferId)
+	fileRelaysMu.Lock()
+	defer fileRelaysMu.Unlock()
+	if transfer, exists := fileRelays[key]; exists {
+		transfer.accepted = transfer.accepted || transfer.to == to
+		return
+	}
+	awaited := 0
+	for _, receiver := range fileAccepts {
+		if receiver == to {
+			awaited++
+		}
+	}
+	if awaited < maxFileAcceptsPerClient {
+		fileAccepts[key] = to
+	}
+}
+
+// cancelFileRelay forgets the relayed transfer from the client with Id from to
+// the client with Id to, when the receiver rejects it.
+func cancelFileRelay(from string, to string, transferId string) {
+	key := fileRelayKey(from, transferId)
+	fileRelaysMu.Lock()
+	defer fileRelaysMu.Unlock()
+	if fileAccepts[key] == to {
+		delete(fileAccepts, key)
+	}
+	if transfer, exists := fileRelays[key]; exists && transfer.to == to {
+		removeFileRelay(key)
+	}
+}
+
+// dropFileRelays cancels the relayed transfers sent or received by a client
+// that disconnected.
+func dropFileRelays(clientId string) {
+	fileRelaysMu.Lock()
+	defer fileRelaysMu.Unlock()
+	for key, transfer := range fileRelays {
+		if transfer.from == clientId || transfer.to == clientId {
+			removeFileRelay(key)
+		}
+	}
+	for key, receiver := range fileAccepts {
+		if receiver == clientId || strings.HasPrefix(key, clientId+"\x00") {
+			delete(fileAccepts, key)
+		}
+	}
+	delete(fileRelayLimiters, clientId)
+}
@@ -0,0 +1,55 @@
+package server
+
+import (
+	"strings"
+
+	"github.com/shankarammai/Peer2PeerConnector/internal/client"
+	responsemessage "github.com/shankarammai/Peer2PeerConnector/internal/response"
+)
+
+// Limits on the metadata of a file offer.
+const (
+	maxFileNameLength   = 255
+	maxFileHashLength   = 256
+	maxTransferIdLength = 64
+)
+
+// checkFileTransfer checks the metadata of "file_offer", "file_accept" and
+// "file_reject" messages and reports the problem to the client otherwise.
+// Every message names the transfer with an "id" chosen by the offerer, an offer
+// also describes the file with its "name", "size" in bytes and optional "hash",
+// e.g. "sha256:9f86d0...". The file itself goes over a data channel.
+func checkFileTransfer(client *client.Client, msg map[string]interface{}) bool {
+	if reason := fileTransferProblem(msg); reason != "" {
+		client.Send(responsemessage.ErrorMessage("Invalid_File", map[string]interface{}{"message": reason}))
+		return false
+	}
+	return true
+}
+
+// fileTransferProblem describes what is wrong with a file transfer message, it
+// returns an empty string for a valid message.
+func fileTransferProblem(msg map[string]interface{}) string {
+	data, ok := msg["data"].(map[string]interface{})
+	if !ok {
+		return "'data' field is missing or is not object in the request."
+	}
+	if id, ok := data["id"].(string); !ok || id == "" || len(id) > maxTransferIdLength {
+		return "'data''id' must name the transfer in at most 64 characters."
+	}
+	if msg["event"] != MsgTypeFileOffer {
+		return ""
+	}
+	if name, ok := data["name"].(string); !ok || name == "" || len(name) > maxFileNameLength || strings.ContainsAny(name, "/\\\x00") {
+		return "'data''name' must be a file name without a path, of at most 255 bytes."
+	}
+	if size, ok := intField(data, "size"); !ok || size < 0 {
+		return "'data''size' must be the size of the file in bytes."
+	}
+	if hash, exists := data["hash"]; exists {
+		if value, ok := hash.(string); !ok || value == "" || len(value) > maxFileHashLength {
+			return "'data''hash' must be a string, e.g. \"sha256:<hex digest>\"."
+		}
+	}
+	return ""
+}
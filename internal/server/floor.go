@@ -0,0 +1,98 @@
+package server
+
+import (
+	"slices"
+
+	"github.com/shankarammai/Peer2PeerConnector/internal/client"
+	"github.com/shankarammai/Peer2PeerConnector/internal/logging"
+	responsemessage "github.com/shankarammai/Peer2PeerConnector/internal/response"
+)
+
+// handleFloorMessage processes "request_floor", "grant_floor" and "release_floor"
+// messages. A room has at most one floor holder: members take the floor when it
+// is free, the creator and the moderators give it to a member, and the holder or
+// a moderator releases it. Every change is broadcast with "Floor_Changed".
+func handleFloorMessage(client *client.Client, msg map[string]interface{}) {
+	if !checkRoomInJSON(client, msg) {
+		return
+	}
+	data := msg["data"].(map[string]interface{})
+	roomId, _ := data["room"].(string)
+	from := client.GetClientId()
+	event, _ := msg["event"].(string)
+	target, _ := data["client"].(string)
+	if !checkAuthorized(client, event, authorizer.CanRelay(subjectOf(client), event, roomId, target)) {
+		return
+	}
+
+	mu.Lock()
+	myRoom, exists := rooms[roomId]
+	if !exists {
+		mu.Unlock()
+		client.Send(responsemessage.ErrorMessage("Not_Found", map[string]interface{}{"message": "Room with Id " + roomId + " does not exist."}))
+		return
+	}
+	if !slices.Contains(myRoom.GetClients(), from) {
+		mu.Unlock()
+		client.Send(responsemessage.ErrorMessage("Unauthorised", map[string]interface{}{"message": "You need to be in the room to take or give the floor."}))
+		return
+	}
+	holder := myRoom.GetFloorHolder()
+	handLowered := false
+	switch event {
+	case MsgTypeRequestFloor:
+		if holder == from {
+			mu.Unlock()
+			return
+		}
+		if holder != "" {
+			mu.Unlock()
+			client.Send(responsemessage.ErrorMessage("Floor_Taken", map[string]interface{}{
+				"message": "Client " + holder + " holds the floor.",
+				"room":    roomId,
+				"holder":  holder,
+			}))
+			return
+		}
+		myRoom.SetFloorHolder(from)
+	case MsgTypeGrantFloor:
+		if _, ok := data["client"].(string); !ok {
+			mu.Unlock()
+			client.Send(responsemessage.ErrorMessage("Missing_Fields", map[string]interface{}{"message": "'data''client' field is missing or is not a client Id."}))
+			return
+		}
+		if !myRoom.IsModerator(from) {
+			mu.Unlock()
+			client.Send(responsemessage.ErrorMessage("Unauthorised", map[string]interface{}{"message": "You need to moderate the room to give the floor."}))
+			return
+		}
+		if !slices.Contains(myRoom.GetClients(), target) {
+			mu.Unlock()
+			client.Send(responsemessage.ErrorMessage("Not_Found", map[string]interface{}{"message": "Client " + target + " is not in the room."}))
+			return
+		}
+		myRoom.SetFloorHolder(target)
+		// the hand of the member given the floor is answered
+		handLowered = myRoom.LowerHand(target)
+	case MsgTypeReleaseFloor:
+		if holder == "" {
+			mu.Unlock()
+			return
+		}
+		if holder != from && !myRoom.IsModerator(from) {
+			mu.Unlock()
+			client.Send(responsemessage.ErrorMessage("Unauthorised", map[string]interface{}{"message": "You need to hold the floor or moderate the room to release it."}))
+			return
+		}
+		myRoom.SetFloorHolder("")
+	}
+	holder = myRoom.GetFloorHolder()
+	mu.Unlock()
+
+	logging.ForRoom(from, roomId).Debugf("Floor holder is now %q after %s", holder, event)
+	notifyUpdateIntheRoom(roomId, "Floor_Changed")
+	if handLowered {
+		sendHandLowered(holder, roomId)
+		notifyHandQueue(myRoom)
+	}
+}
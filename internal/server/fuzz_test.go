@@ -0,0 +1,119 @@
+package server
+
+import (
+	"encoding/json"
+	"io"
+	"reflect"
+	"testing"
+
+	"github.com/shankarammai/Peer2PeerConnector/internal/client"
+	"github.com/shankarammai/Peer2PeerConnector/internal/logging"
+	"github.com/shankarammai/Peer2PeerConnector/internal/room"
+)
+
+// discardTransport is a connection dropping every message.
+type discardTransport struct{}
+
+func (discardTransport) WriteMessage(messageType int, data []byte) error { return nil }
+func (discardTransport) Close() error                                    { return nil }
+
+// fuzzClients registers a client and a peer sharing a room, so that fuzzed
+// messages reach the handlers past the membership checks.
+func fuzzClients() *client.Client {
+	logging.Logger.SetOutput(io.Discard)
+	fuzzer := &client.Client{Id: "fuzzer", Connection: discardTransport{}, Status: client.StatusOnline}
+	peer := &client.Client{Id: "peer", Connection: discardTransport{}, Status: client.StatusOnline}
+	mu.Lock()
+	clients[fuzzer.Id] = fuzzer
+	clients[peer.Id] = peer
+	if _, exists := rooms["room"]; !exists {
+		fuzzRoom := room.NewRoom("room", "Fuzz", fuzzer.Id)
+		fuzzRoom.AddClient(peer.Id)
+		rooms["room"] = fuzzRoom
+	}
+	mu.Unlock()
+	return fuzzer
+}
+
+func FuzzHandleMessage(f *testing.F) {
+	seeds := []string{
+		`{"event":"Create_Room","data":{"room":"room","name":1,"max_clients":"2","metadata":[]}}`,
+		`{"event":"Join_Room","data":{"room":"room","token":5,"password":{}}}`,
+		`{"event":"Join_Room","data":"room"}`,
+		`{"event":"Leave_Room","data":{"room":"room"}}`,
+		`{"event":"End_Room","data":{"room":["room"]}}`,
+		`{"event":"Connect","to":"peer","data":{"sdp":"v=0","Candidate":"c"}}`,
+		`{"event":"Connect","to":"peer","data":null}`,
+		`{"event":"Offer","to":"peer","room":"room","data":{"sdp":{"sdp":1}}}`,
+		`{"event":"Candidate","to":"peer","room":7,"data":{}}`,
+		`{"event":"Candidates","to":"peer","data":{"candidates":"c"}}`,
+		`{"event":"Message","to":"peer","encrypted":"yes","data":"hi"}`,
+		`{"event":"Message","to":["peer"],"data":"hi"}`,
+		`{"event":"Set_Status","data":{"status":1}}`,
+		`{"event":"Invite","data":{"room":"room","to":{}}}`,
+		`{"event":"Lock_Room","data":{"room":"room"}}`,
+		`{"event":"Get_Topology","data":{"room":"room"}}`,
+		`{"event":"Set_Capabilities","data":{"codecs":[1,2]}}`,
+		`{"event":"Publish_Key","data":{"key":[]}}`,
+		`{"event":"Rotate_Key","data":{"room":"room"}}`,
+		`{"event":"Report_Stats","data":{"room":"room","rtt":"1"}}`,
+		`{"event":"Broadcast","room":"room","data":null}`,
+		`{"event":"Broadcast_To_Tag","room":"room","tag":1}`,
+		`{"event":"Set_Moderator","data":{"room":"room","client":"peer","moderator":"no"}}`,
+		`{"event":"Approve_Join","data":{"room":"room","client":3}}`,
+		`{"event":"Set_Tags","data":{"tags":"a"}}`,
+		`{"event":null}`,
+		`[]`,
+		`null`,
+		`{`,
+	}
+	for _, seed := range seeds {
+		f.Add([]byte(seed))
+	}
+	fuzzer := fuzzClients()
+	f.Fuzz(func(t *testing.T, message []byte) {
+		handleMessage(fuzzer, message)
+	})
+}
+
+func FuzzDecodeEnvelope(f *testing.F) {
+	f.Add(encodeEnvelope("peer", []byte("payload")))
+	f.Add([]byte{})
+	f.Add([]byte{255, 'a'})
+	f.Fuzz(func(t *testing.T, frame []byte) {
+		targetID, payload, err := decodeEnvelope(frame)
+		if err != nil {
+			return
+		}
+		targetAgain, payloadAgain, err := decodeEnvelope(encodeEnvelope(targetID, payload))
+		if err != nil || targetAgain != targetID || string(payloadAgain) != string(payload) {
+			t.Fatalf("envelope does not round-trip: %q %q", targetID, payload)
+		}
+	})
+}
+
+func FuzzRewriteRelay(f *testing.F) {
+	f.Add([]byte(`{"event":"Candidate","to":"peer","data":{"candidate":"candidate:1 1 UDP 2122252543 192.168.1.2 49203 typ host"}}`))
+	f.Add([]byte(` { "data" : [1, "}", {"a":"\\\""}] , "to":"peer","event":"Message" } `))
+	f.Add([]byte(`{"event":"Message","to":"peer"}`))
+	f.Add([]byte(`{"event":"Message","to":"peer","to":"other","data":1}`))
+	f.Add([]byte(`{"event":"Message","to":"peer","room":"room","data":1}`))
+	f.Add([]byte(`{"event":"Mess\u0061ge","to":"peer","data":1}`))
+	f.Fuzz(func(t *testing.T, message []byte) {
+		event, _, data, ok := scanRelay(message)
+		if !ok {
+			return
+		}
+		var want, got map[string]interface{}
+		if err := json.Unmarshal(message, &want); err != nil {
+			t.Fatalf("scanned an invalid message: %v", err)
+		}
+		delete(want, "to")
+		want["from"] = "sender"
+		want["seq"] = float64(7)
+		relayed := rewriteRelay(message, event, data, "sender", 7)
+		if err := json.Unmarshal(relayed, &got); err != nil || !reflect.DeepEqual(got, want) {
+			t.Fatalf("rewrote %s into %s, want %v", message, relayed, want)
+		}
+	})
+}
@@ -0,0 +1,114 @@
+package server
+
+import (
+	"sync"
+
+	"github.com/shankarammai/Peer2PeerConnector/internal/client"
+	"github.com/shankarammai/Peer2PeerConnector/internal/clock"
+	"github.com/shankarammai/Peer2PeerConnector/internal/logging"
+	responsemessage "github.com/shankarammai/Peer2PeerConnector/internal/response"
+)
+
+// heldMembership is a dropped client whose room memberships are kept during
+// the reconnect grace period.
+type heldMembership struct {
+	clientId string
+	timer    clock.Timer
+}
+
+var (
+	// heldMemberships maps resume tokens to the dropped clients they resume.
+	heldMemberships   = make(map[string]*heldMembership)
+	heldMembershipsMu sync.Mutex
+)
+
+// holdMembership keeps the room memberships of a dropped client for the reconnect
+// grace period, the client is listed as disconnected in the meantime. It returns
+// false when there is nothing to hold and the client has to be removed at once,
+// as does a client the server disconnected.
+func holdMembership(clientId string) bool {
+	if cfg().ReconnectGrace <= 0 {
+		return false
+	}
+	memberOf := roomsOfClient(clientId)
+	mu.Lock()
+	droppedClient, exists := clients[clientId]
+	kicked := exists && droppedClient.Kicked
+	mu.Unlock()
+	if !exists || kicked || len(memberOf) == 0 {
+		return false
+	}
+
+	token := droppedClient.ResumeToken
+	removeClient(clientId)
+	heldMembershipsMu.Lock()
+	heldMemberships[token] = &heldMembership{
+		clientId: clientId,
+		timer:    clk.AfterFunc(cfg().ReconnectGrace, func() { releaseMembership(token) }),
+	}
+	heldMembershipsMu.Unlock()
+	logging.ForClient(clientId).Info("Holding room memberships for ", cfg().ReconnectGrace)
+
+	for _, roomItem := range memberOf {
+		notifyUpdateIntheRoom(roomItem.GetId(), "Client_Disconnected")
+	}
+	return true
+}
+
+// releaseMembership removes a client that did not come back within the grace period.
+func releaseMembership(token string) {
+	heldMembershipsMu.Lock()
+	held, exists := heldMemberships[token]
+	delete(heldMemberships, token)
+	heldMembershipsMu.Unlock()
+	if !exists {
+		return
+	}
+	logging.ForClient(held.clientId).Info("Reconnect grace period over")
+	removeClientFromRoom(held.clientId, false)
+	emitEvent(EventClientDisconnected, map[string]interface{}{"client": held.clientId})
+}
+
+// resumeMembership returns the Id of the dropped client the resume token was
+// issued to, if its grace period is still running. The memberships stay held
+// until claimMembership, so a connection refused in the meantime loses nothing.
+func resumeMembership(token string) (string, bool) {
+	if token == "" {
+		return "", false
+	}
+	heldMembershipsMu.Lock()
+	defer heldMembershipsMu.Unlock()
+	held, exists := heldMemberships[token]
+	if !exists {
+		return "", false
+	}
+	return held.clientId, true
+}
+
+// claimMembership ends the grace period of the dropped client the resume token
+// was issued to, once its new connection is accepted.
+func claimMembership(token string, clientId string) {
+	heldMembershipsMu.Lock()
+	defer heldMembershipsMu.Unlock()
+	held, exists := heldMemberships[token]
+	if !exists || held.clientId != clientId {
+		return
+	}
+	held.timer.Stop()
+	delete(heldMemberships, token)
+}
+
+// announceReconnection tells a resumed client and the members of its rooms that it is back.
+func announceReconnection(client *client.Client) {
+	memberOf := roomsOfClient(client.GetClientId())
+	roomIds := make([]string, 0, len(memberOf))
+	for _, roomItem := range memberOf {
+		roomIds = append(roomIds, roomItem.GetId())
+		notifyUpdateIntheRoom(roomItem.GetId(), "Client_Reconnected")
+	}
+	logging.ForClient(client.Id).Info("Client resumed its room memberships")
+	client.SendAs(responsemessage.InfoMessage("Session_Resumed", map[string]interface{}{
+		"id":    client.GetClientId(),
+		"rooms": roomIds,
+	}), sessionPriority, nil)
+}
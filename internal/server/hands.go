@@ -0,0 +1,95 @@
+package server
+
+import (
+	"slices"
+
+	"github.com/shankarammai/Peer2PeerConnector/internal/client"
+	"github.com/shankarammai/Peer2PeerConnector/internal/logging"
+	responsemessage "github.com/shankarammai/Peer2PeerConnector/internal/response"
+	"github.com/shankarammai/Peer2PeerConnector/internal/room"
+)
+
+// handleHandMessage processes "raise_hand" and "lower_hand" messages.
+// Members raise and lower their own hand, the creator and the moderators lower
+// the hand of a member by naming it in "client". The moderators get the queue
+// in a "Hand_Queue" message whenever it changes.
+func handleHandMessage(client *client.Client, msg map[string]interface{}) {
+	if !checkRoomInJSON(client, msg) {
+		return
+	}
+	data := msg["data"].(map[string]interface{})
+	roomId, _ := data["room"].(string)
+	from := client.GetClientId()
+	event, _ := msg["event"].(string)
+	target := from
+	if other, ok := data["client"].(string); ok && event == MsgTypeLowerHand {
+		target = other
+	}
+	if !checkAuthorized(client, event, authorizer.CanRelay(subjectOf(client), event, roomId, target)) {
+		return
+	}
+
+	mu.Lock()
+	myRoom, exists := rooms[roomId]
+	if !exists {
+		mu.Unlock()
+		client.Send(responsemessage.ErrorMessage("Not_Found", map[string]interface{}{"message": "Room with Id " + roomId + " does not exist."}))
+		return
+	}
+	if !slices.Contains(myRoom.GetClients(), from) {
+		mu.Unlock()
+		client.Send(responsemessage.ErrorMessage("Unauthorised", map[string]interface{}{"message": "You need to be in the room to raise or lower a hand."}))
+		return
+	}
+	if target != from && !myRoom.IsModerator(from) {
+		mu.Unlock()
+		client.Send(responsemessage.ErrorMessage("Unauthorised", map[string]interface{}{"message": "You need to moderate the room to lower the hand of another member."}))
+		return
+	}
+	var changed bool
+	if event == MsgTypeRaiseHand {
+		changed = myRoom.RaiseHand(target)
+	} else {
+		changed = myRoom.LowerHand(target)
+	}
+	position := slices.Index(myRoom.GetRaisedHands(), target) + 1
+	mu.Unlock()
+
+	if event == MsgTypeRaiseHand {
+		client.Send(responsemessage.InfoMessage("Hand_Raised", map[string]interface{}{"room": roomId, "position": position}))
+	} else {
+		sendHandLowered(target, roomId)
+	}
+	if changed {
+		logging.ForRoom(from, roomId).Debugf("%s hand of %s", event, target)
+		notifyHandQueue(myRoom)
+	}
+}
+
+// sendHandLowered tells clientId its hand was lowered in the room.
+func sendHandLowered(clientId string, roomId string) {
+	for _, member := range connectedClients([]string{clientId}) {
+		member.Send(responsemessage.InfoMessage("Hand_Lowered", map[string]interface{}{"room": roomId}))
+	}
+}
+
+// notifyHandQueue sends the hand queue of the room to its creator and moderators.
+func notifyHandQueue(myRoom *room.Room) {
+	mu.Lock()
+	queue := slices.Clone(myRoom.GetRaisedHands())
+	moderators := []string{}
+	for _, member := range myRoom.GetClients() {
+		if myRoom.IsModerator(member) {
+			moderators = append(moderators, member)
+		}
+	}
+	mu.Unlock()
+
+	update := responsemessage.UpdateMessage("Hand_Queue", map[string]interface{}{
+		"room":  myRoom.GetId(),
+		"queue": queue,
+	})
+	for _, moderator := range connectedClients(moderators) {
+		moderator.Send(update)
+	}
+}
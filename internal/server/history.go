@@ -0,0 +1,52 @@
+package server
+
+import (
+	"encoding/json"
+	"slices"
+
+	"github.com/shankarammai/Peer2PeerConnector/internal/client"
+	"github.com/shankarammai/Peer2PeerConnector/internal/logging"
+	responsemessage "github.com/shankarammai/Peer2PeerConnector/internal/response"
+	"github.com/shankarammai/Peer2PeerConnector/internal/room"
+)
+
+// recordRoomHistory retains a message broadcast in the room named by its "room"
+// field, if that room keeps history and the sender is a member.
+func recordRoomHistory(client *client.Client, msg map[string]interface{}) {
+	roomId, ok := msg["room"].(string)
+	if !ok {
+		return
+	}
+	encoded, err := json.Marshal(msg["data"])
+	if err != nil {
+		return
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	myRoom, exists := rooms[roomId]
+	if !exists || myRoom.GetHistory() == nil || !slices.Contains(myRoom.GetClients(), client.GetClientId()) {
+		return
+	}
+	myRoom.GetHistory().Add(client.GetClientId(), msg["data"], len(encoded))
+}
+
+// sendRoomHistory delivers the retained messages of the room to a client that just joined.
+func sendRoomHistory(client *client.Client, myRoom *room.Room) {
+	mu.Lock()
+	history := myRoom.GetHistory()
+	if history == nil {
+		mu.Unlock()
+		return
+	}
+	entries := history.Entries()
+	mu.Unlock()
+
+	err := client.Send(responsemessage.InfoMessage("Room_History", map[string]interface{}{
+		"room":     myRoom.GetId(),
+		"messages": entries,
+	}))
+	if err != nil {
+		logging.ForRoom(client.Id, myRoom.GetId()).Debug("Failed to send room history: ", err)
+	}
+}
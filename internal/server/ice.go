@@ -0,0 +1,102 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/lithammer/shortuuid"
+	"github.com/shankarammai/Peer2PeerConnector/internal/client"
+	"github.com/shankarammai/Peer2PeerConnector/internal/ice"
+	"github.com/shankarammai/Peer2PeerConnector/internal/logging"
+	responsemessage "github.com/shankarammai/Peer2PeerConnector/internal/response"
+	"github.com/shankarammai/Peer2PeerConnector/internal/room"
+)
+
+// regionPattern is the syntax of regions, like "eu-west".
+var regionPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{0,31}$`)
+
+// regionTurnURLs maps the regions to the TURN servers recommended there.
+var regionTurnURLs = map[string][]string{}
+
+// parseTurnRegions reads the "<region>=<url> <url>..." entries of TURN_REGION_URLS.
+func parseTurnRegions(entries []string) (map[string][]string, error) {
+	regions := make(map[string][]string, len(entries))
+	for _, entry := range entries {
+		region, urls, ok := strings.Cut(entry, "=")
+		region = strings.TrimSpace(region)
+		if !ok || !regionPattern.MatchString(region) || len(strings.Fields(urls)) == 0 {
+			return nil, fmt.Errorf("invalid TURN_REGION_URLS entry %q, use <region>=<url> <url>...", entry)
+		}
+		regions[region] = strings.Fields(urls)
+	}
+	return regions, nil
+}
+
+// iceServersFor returns the ICE server payload with TURN credentials bound to
+// user. The TURN servers of region replace the default ones when it has its own.
+func iceServersFor(user string, region string) map[string]interface{} {
+	turnURLs := cfg().TurnURLs
+	payload := map[string]interface{}{"ttl": int(cfg().TurnTTL.Seconds())}
+	if urls, ok := regionTurnURLs[region]; ok {
+		turnURLs = urls
+		payload["region"] = region
+	}
+	payload["ice_servers"] = ice.Servers(cfg().StunURLs, turnURLs, cfg().TurnSecret, cfg().TurnTTL, user)
+	return payload
+}
+
+// handleGetIceServersMessage processes a "get_ice_servers" message.
+// It replies with the configured ICE servers, TURN credentials are issued for
+// the requesting client. The TURN servers of the "region" in "data" are
+// preferred when it has its own.
+func handleGetIceServersMessage(client *client.Client, msg map[string]interface{}) {
+	data, _ := msg["data"].(map[string]interface{})
+	region, _ := data["region"].(string)
+	err := client.Send(responsemessage.InfoMessage("Ice_Servers", iceServersFor(client.GetClientId(), region)))
+	if err != nil {
+		logging.ForClient(client.Id).Debug("Failed to send ICE servers: ", err)
+	}
+}
+
+// sendRegionalIceServers recommends the TURN servers of the region of the room
+// to a client entering it, when the region has its own.
+func sendRegionalIceServers(client *client.Client, myRoom *room.Room) {
+	mu.Lock()
+	region := myRoom.GetRegion()
+	mu.Unlock()
+	if _, ok := regionTurnURLs[region]; !ok || cfg().TurnSecret == "" {
+		return
+	}
+	payload := iceServersFor(client.GetClientId(), region)
+	payload["room"] = myRoom.GetId()
+	client.Send(responsemessage.InfoMessage("Ice_Servers", payload))
+}
+
+// ServeIceServers serves the ICE server list over HTTP for clients that want the
+// servers before opening the WebSocket, "region" picks the TURN servers of a
+// region. It takes the same access token as the WebSocket when the authorizer
+// authenticates clients, and the TURN username is bound to its user. Anonymous
+// callers get credentials for a random user, they can't name one.
+func ServeIceServers(writer http.ResponseWriter, request *http.Request) {
+	if request.Method != http.MethodGet {
+		http.Error(writer, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	userId, _, err := authenticate(request)
+	if err != nil {
+		writer.Header().Set("WWW-Authenticate", "Bearer")
+		http.Error(writer, "Unauthorised", http.StatusUnauthorized)
+		return
+	}
+	if userId == "" {
+		userId = shortuuid.New()
+	}
+	writer.Header().Set("Content-Type", "application/json")
+	writer.Header().Set("Cache-Control", "no-store")
+	if err := json.NewEncoder(writer).Encode(iceServersFor(userId, request.URL.Query().Get("region"))); err != nil {
+		logger.Debug("Failed to write ICE servers: ", err)
+	}
+}
@@ -0,0 +1,68 @@
+package server
+
+import (
+	"time"
+
+	"github.com/shankarammai/Peer2PeerConnector/internal/client"
+	"github.com/shankarammai/Peer2PeerConnector/internal/logging"
+	responsemessage "github.com/shankarammai/Peer2PeerConnector/internal/response"
+)
+
+// markActive records that the client just sent a message.
+func markActive(client *client.Client) {
+	mu.Lock()
+	client.MarkActive(clk.Now())
+	mu.Unlock()
+}
+
+// disconnectIdleClients disconnects the clients that sent no message for the
+// idle timeout. Clients are warned with an "Idle_Warning" update beforehand,
+// any message they send keeps them connected.
+func disconnectIdleClients(now time.Time) {
+	if cfg().IdleTimeout <= 0 {
+		return
+	}
+	var idle []*client.Client
+	warned := make(map[*client.Client]time.Duration)
+	mu.Lock()
+	for _, clientItem := range clients {
+		idleFor := now.Sub(clientItem.GetLastActive())
+		if idleFor >= cfg().IdleTimeout {
+			idle = append(idle, clientItem)
+		} else if idleFor >= cfg().IdleTimeout-cfg().IdleWarning && !clientItem.IdleWarned {
+			clientItem.IdleWarned = true
+			warned[clientItem] = cfg().IdleTimeout - idleFor
+		}
+	}
+	mu.Unlock()
+
+	for clientItem, left := range warned {
+		clientItem.Send(responsemessage.UpdateMessage("Idle_Warning", map[string]interface{}{
+			"message":       "You will be disconnected unless you send a message.",
+			"disconnect_in": int(left.Seconds()),
+		}))
+	}
+	for _, clientItem := range idle {
+		logging.ForClient(clientItem.Id).Info("Disconnecting idle client")
+		clientItem.Send(responsemessage.UpdateMessage("Idle_Disconnect", map[string]interface{}{"message": "You were disconnected for inactivity."}))
+		disconnectClient(clientItem)
+	}
+}
+
+// disconnectClient closes the connection of the client, whatever its transport.
+// WebSocket clients are unregistered when their read loop ends.
+func disconnectClient(client *client.Client) {
+	mu.Lock()
+	client.Kicked = true
+	mu.Unlock()
+	sessionsMu.Lock()
+	session, isSession := sessions[client.GetClientId()]
+	sessionsMu.Unlock()
+	if isSession {
+		closeSession(session)
+		return
+	}
+	if err := client.Close(); err != nil {
+		logging.ForClient(client.Id).Debug("Failed to close connection: ", err)
+	}
+}
@@ -0,0 +1,77 @@
+package server
+
+import (
+	"slices"
+
+	"github.com/lithammer/shortuuid"
+	"github.com/shankarammai/Peer2PeerConnector/internal/client"
+	"github.com/shankarammai/Peer2PeerConnector/internal/logging"
+	responsemessage "github.com/shankarammai/Peer2PeerConnector/internal/response"
+)
+
+// handleInviteMessage processes an "invite" message.
+// A member of the room invites another client: the invitee receives the room details
+// and a single use token that lets it join without the room password.
+func handleInviteMessage(client *client.Client, msg map[string]interface{}) {
+	if !checkRoomInJSON(client, msg) {
+		return
+	}
+	data := msg["data"].(map[string]interface{})
+	roomId, _ := data["room"].(string)
+	from := client.GetClientId()
+
+	targetID, ok := data["to"].(string)
+	if !ok {
+		client.Send(responsemessage.ErrorMessage("Missing_Fields", map[string]interface{}{"message": "'to' field is missing in the request."}))
+		return
+	}
+
+	mu.Lock()
+	myRoom, exists := rooms[roomId]
+	if !exists {
+		mu.Unlock()
+		client.Send(responsemessage.ErrorMessage("Not_Found", map[string]interface{}{"message": "Room with Id " + roomId + " does not exist."}))
+		return
+	}
+	targetClient, targetExists := clients[targetID]
+	isMember := slices.Contains(myRoom.GetClients(), from)
+	targetIsMember := slices.Contains(myRoom.GetClients(), targetID)
+	mu.Unlock()
+
+	if !isMember {
+		client.Send(responsemessage.ErrorMessage("Unauthorised", map[string]interface{}{"message": "You need to be in the room to invite other clients."}))
+		return
+	}
+	if !targetExists {
+		client.Send(responsemessage.ErrorMessage("Not_Found", map[string]interface{}{"message": "Client with given " + targetID + " not found"}))
+		return
+	}
+	if targetIsMember {
+		client.Send(responsemessage.ErrorMessage("Already_Exists", map[string]interface{}{"message": "Client already exists in the room."}))
+		return
+	}
+	if refuseBlocked(client, targetClient) {
+		return
+	}
+	if !checkAuthorized(client, MsgTypeInvite, authorizer.CanRelay(subjectOf(client), MsgTypeInvite, roomId, targetID)) {
+		return
+	}
+
+	token := shortuuid.New()
+	mu.Lock()
+	myRoom.AddInvite(token, targetID)
+	mu.Unlock()
+	logging.ForRoom(from, roomId).Debugf("Invited client %s", targetID)
+
+	err := targetClient.Send(responsemessage.InfoMessage("Room_Invite", map[string]interface{}{
+		"room":  roomId,
+		"name":  myRoom.GetName(),
+		"from":  from,
+		"token": token,
+	}))
+	if err != nil {
+		logging.ForRoom(from, roomId).Debugf("Failed to send invite to %s: %v", targetID, err)
+		return
+	}
+	client.Send(responsemessage.InfoMessage("Invite_Sent", map[string]interface{}{"room": roomId, "to": targetID}))
+}
@@ -0,0 +1,43 @@
+package server
+
+import (
+	"time"
+
+	"github.com/shankarammai/Peer2PeerConnector/internal/logging"
+	"github.com/shankarammai/Peer2PeerConnector/internal/room"
+)
+
+// runJanitor periodically cleans up server state that expires with time.
+func runJanitor(interval time.Duration) {
+	ticker := clk.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.Chan() {
+		now := clk.Now()
+		expireRooms(now)
+		expireSessions(now)
+		disconnectIdleClients(now)
+		expireArchives(now)
+		expireBans(now)
+		expireSeenIds(now)
+		expireMissedCalls(now)
+	}
+}
+
+// expireRooms deletes the rooms whose TTL has passed, after notifying
+// their members with a "Room_Expired" update.
+func expireRooms(now time.Time) {
+	mu.Lock()
+	var expired []*room.Room
+	for _, roomItem := range rooms {
+		if roomItem.IsExpired(now) {
+			expired = append(expired, roomItem)
+		}
+	}
+	mu.Unlock()
+
+	for _, roomItem := range expired {
+		notifyUpdateIntheRoom(roomItem.GetId(), "Room_Expired")
+		deleteRoom(roomItem.GetId(), "expired")
+		logging.ForRoom(roomItem.GetCreator(), roomItem.GetId()).Info("Room expired")
+	}
+}
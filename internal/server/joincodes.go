@@ -0,0 +1,59 @@
+package server
+
+import (
+	"strings"
+
+	"github.com/shankarammai/Peer2PeerConnector/internal/ids"
+	"github.com/shankarammai/Peer2PeerConnector/internal/room"
+)
+
+// joinCodeLength is the length of the join codes of rooms.
+const joinCodeLength = 6
+
+// joinCodes maps the join codes to the Ids of their rooms, it is guarded by mu.
+var joinCodes = make(map[string]string)
+
+// assignJoinCode gives the room a join code no other room has, or registers
+// the code of a restored room. The caller must hold mu.
+func assignJoinCode(myRoom *room.Room) {
+	code := myRoom.GetJoinCode()
+	if owner, taken := joinCodes[code]; code == "" || (taken && owner != myRoom.GetId()) {
+		for {
+			code = ids.Code(joinCodeLength)
+			if _, taken := joinCodes[code]; !taken {
+				break
+			}
+		}
+		myRoom.SetJoinCode(code)
+	}
+	joinCodes[code] = myRoom.GetId()
+}
+
+// releaseJoinCode frees the join code of a deleted room. The caller must hold mu.
+func releaseJoinCode(myRoom *room.Room) {
+	if joinCodes[myRoom.GetJoinCode()] == myRoom.GetId() {
+		delete(joinCodes, myRoom.GetJoinCode())
+	}
+}
+
+// resolveJoinCode replaces the "code" of a "join_room" message by the Id of its
+// room, unless the message names the room. Codes are not case sensitive. An
+// unknown code is left for the room checks to report.
+func resolveJoinCode(msg map[string]interface{}) {
+	data, ok := msg["data"].(map[string]interface{})
+	if !ok {
+		return
+	}
+	code, ok := data["code"].(string)
+	if _, named := data["room"]; named || !ok {
+		return
+	}
+	mu.Lock()
+	roomId, exists := joinCodes[strings.ToUpper(strings.TrimSpace(code))]
+	mu.Unlock()
+	if exists {
+		data["room"] = roomId
+	} else {
+		data["room"] = code
+	}
+}
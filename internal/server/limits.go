@@ -0,0 +1,34 @@
+package server
+
+import (
+	"github.com/shankarammai/Peer2PeerConnector/internal/client"
+	"github.com/shankarammai/Peer2PeerConnector/internal/logging"
+	responsemessage "github.com/shankarammai/Peer2PeerConnector/internal/response"
+)
+
+// checkRoomLimits enforces the configured room caps before the client creates
+// or joins a room, and reports the exceeded limit to the client otherwise.
+func checkRoomLimits(client *client.Client, creating bool) bool {
+	mu.Lock()
+	roomCount := len(rooms)
+	mu.Unlock()
+	if creating && cfg().MaxRooms > 0 && roomCount >= cfg().MaxRooms {
+		logging.ForClient(client.Id).Warn("Room limit reached")
+		client.Send(responsemessage.ErrorMessage("Limit_Exceeded", map[string]interface{}{
+			"message": "The server cannot host more rooms.",
+			"limit":   "max_rooms",
+			"max":     cfg().MaxRooms,
+		}))
+		return false
+	}
+	if cfg().MaxRoomsPerClient > 0 && len(roomsOfClient(client.GetClientId())) >= cfg().MaxRoomsPerClient {
+		logging.ForClient(client.Id).Debug("Client room limit reached")
+		client.Send(responsemessage.ErrorMessage("Limit_Exceeded", map[string]interface{}{
+			"message": "You are in too many rooms, leave one first.",
+			"limit":   "max_rooms_per_client",
+			"max":     cfg().MaxRoomsPerClient,
+		}))
+		return false
+	}
+	return true
+}
@@ -0,0 +1,44 @@
+package server
+
+import (
+	"github.com/shankarammai/Peer2PeerConnector/internal/client"
+	"github.com/shankarammai/Peer2PeerConnector/internal/logging"
+	responsemessage "github.com/shankarammai/Peer2PeerConnector/internal/response"
+)
+
+// handleLockRoomMessage processes "lock_room" and "unlock_room" messages.
+// Only the creator can change the lock. While locked, joins are rejected unless the
+// client has an invite token; members already in the room are not affected.
+func handleLockRoomMessage(client *client.Client, msg map[string]interface{}) {
+	if !checkRoomInJSON(client, msg) {
+		return
+	}
+	data := msg["data"].(map[string]interface{})
+	roomId, _ := data["room"].(string)
+	from := client.GetClientId()
+	locked := msg["event"] == MsgTypeLockRoom
+
+	mu.Lock()
+	myRoom, exists := rooms[roomId]
+	if !exists {
+		mu.Unlock()
+		client.Send(responsemessage.ErrorMessage("Not_Found", map[string]interface{}{"message": "Room with Id " + roomId + " does not exist."}))
+		return
+	}
+	if myRoom.GetCreator() != from {
+		mu.Unlock()
+		client.Send(responsemessage.ErrorMessage("Unauthorised", map[string]interface{}{"message": "You need to be creator of room to lock or unlock it."}))
+		return
+	}
+	myRoom.SetLocked(locked)
+	mu.Unlock()
+	persistRoom(myRoom)
+
+	if locked {
+		logging.ForRoom(from, roomId).Info("Room locked")
+		notifyUpdateIntheRoom(roomId, "Room_Locked")
+	} else {
+		logging.ForRoom(from, roomId).Info("Room unlocked")
+		notifyUpdateIntheRoom(roomId, "Room_Unlocked")
+	}
+}
@@ -0,0 +1,97 @@
+package server
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/shankarammai/Peer2PeerConnector/internal/logging"
+)
+
+// maxPollMessageSize bounds the body of a message sent over HTTP.
+const maxPollMessageSize = 1 << 20
+
+// HandleLongPoll serves the REST + long-poll signaling gateway for clients that
+// can't open a WebSocket. It mirrors the WebSocket protocol:
+//
+//	POST /poll/connect     creates a client, returns its id and session token
+//	POST /poll/send        handles one message, the body is the same JSON as on the WebSocket
+//	GET  /poll/receive     waits for the messages sent to the client
+//	POST /poll/disconnect  disconnects the client
+func HandleLongPoll(writer http.ResponseWriter, request *http.Request) {
+	action := strings.TrimPrefix(request.URL.Path, "/poll/")
+	if action == "connect" {
+		if request.Method != http.MethodPost {
+			http.Error(writer, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		if refuseWhileDraining(writer) {
+			return
+		}
+		session, err := openSession(request)
+		if refuseClientId(writer, err) {
+			return
+		}
+		writeJSONResponse(writer, http.StatusOK, map[string]interface{}{
+			"id":    session.client.GetClientId(),
+			"token": session.token,
+		})
+		return
+	}
+
+	session, ok := sessionFromRequest(request)
+	if !ok {
+		http.Error(writer, "Unknown session", http.StatusUnauthorized)
+		return
+	}
+	session.transport.touch()
+
+	switch {
+	case action == "send" && request.Method == http.MethodPost:
+		handleSessionMessage(writer, request, session)
+	case action == "receive" && request.Method == http.MethodGet:
+		timeout := cfg().PollTimeout
+		if requested, err := time.ParseDuration(request.URL.Query().Get("timeout")); err == nil && requested < timeout {
+			timeout = requested
+		}
+		frames := session.transport.wait(timeout, request.Context().Done())
+		messages := make([]json.RawMessage, len(frames))
+		for i, frame := range frames {
+			messages[i] = frame
+		}
+		writeJSONResponse(writer, http.StatusOK, messages)
+	case action == "disconnect" && request.Method == http.MethodPost:
+		closeSession(session)
+		writer.WriteHeader(http.StatusNoContent)
+	default:
+		http.NotFound(writer, request)
+	}
+}
+
+// handleSessionMessage reads one message from the request body and processes it
+// as if it was received on a WebSocket.
+func handleSessionMessage(writer http.ResponseWriter, request *http.Request, session *httpSession) {
+	body, err := io.ReadAll(io.LimitReader(request.Body, maxPollMessageSize))
+	if err != nil {
+		http.Error(writer, "Could not read message", http.StatusBadRequest)
+		return
+	}
+	if !json.Valid(body) {
+		http.Error(writer, "Message must be JSON", http.StatusBadRequest)
+		return
+	}
+	handleMessage(session.client, body)
+	writer.WriteHeader(http.StatusAccepted)
+}
+
+// writeJSONResponse writes v as the JSON body of the response.
+func writeJSONResponse(writer http.ResponseWriter, status int, v interface{}) {
+	writer.Header().Set("Content-Type", "application/json")
+	writer.Header().Set("Cache-Control", "no-store")
+	writer.WriteHeader(status)
+	if err := json.NewEncoder(writer).Encode(v); err != nil {
+		logging.Logger.Debug("Failed to write response: ", err)
+	}
+}
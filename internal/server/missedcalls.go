@@ -0,0 +1,182 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/lithammer/shortuuid"
+	"github.com/shankarammai/Peer2PeerConnector/internal/client"
+	"github.com/shankarammai/Peer2PeerConnector/internal/directory"
+	"github.com/shankarammai/Peer2PeerConnector/internal/logging"
+	"github.com/shankarammai/Peer2PeerConnector/internal/push"
+	responsemessage "github.com/shankarammai/Peer2PeerConnector/internal/response"
+)
+
+// pushNotifier notifies offline users of their missed calls, nil without one.
+var pushNotifier push.Notifier
+
+// SetPushNotifier replaces the push provider chosen by the configuration, e.g.
+// with an FCM or APNs client. It must be called after Init and before the
+// server starts accepting connections.
+func SetPushNotifier(custom push.Notifier) {
+	pushNotifier = custom
+}
+
+// newPushNotifier returns the push provider configured by PUSH_WEBHOOK_URL, nil when it is empty.
+func newPushNotifier() push.Notifier {
+	if cfg().PushWebhookURL == "" {
+		return nil
+	}
+	return push.NewWebhook(cfg().PushWebhookURL, cfg().WebhookSecret, cfg().WebhookMaxRetries, cfg().WebhookTimeout)
+}
+
+// missedCall is a call invitation to a user with no connected device.
+type missedCall struct {
+	Id       string                 `json:"id"`
+	From     string                 `json:"from"`
+	FromUser string                 `json:"from_user,omitempty"`
+	Data     map[string]interface{} `json:"data,omitempty"`
+	At       time.Time              `json:"at"`
+}
+
+const (
+	// missedCallRetention is how long missed calls are kept, and how long a
+	// user is known after their last connection without a user directory.
+	missedCallRetention = 7 * 24 * time.Hour
+	// maxMissedCallUsers bounds the number of users with missed calls.
+	maxMissedCallUsers = 10000
+)
+
+var (
+	// missedCalls are keyed by the user Id of the callee, oldest first.
+	missedCalls = make(map[string][]missedCall)
+	// seenUsers are the users who connected lately, with the time they were
+	// last seen, they can miss calls when there is no user directory.
+	seenUsers     = make(map[string]time.Time)
+	missedCallsMu sync.Mutex
+)
+
+// missCall records a call to a user with no connected device, up to
+// MISSED_CALLS_LIMIT, and notifies the user through the push provider. The
+// caller gets "Call_Missed". It returns false when missed calls are disabled
+// and there is no push provider, when the caller is anonymous or when the
+// user is not known, the user is not found then.
+func missCall(client *client.Client, userId string, data map[string]interface{}) bool {
+	if cfg().MissedCallsLimit <= 0 && pushNotifier == nil {
+		return false
+	}
+	if client.UserID == "" || !knownUser(client.Id, userId) {
+		return false
+	}
+	missed := missedCall{
+		Id:       shortuuid.New(),
+		From:     client.GetClientId(),
+		FromUser: client.UserID,
+		Data:     data,
+		At:       clk.Now(),
+	}
+	if cfg().MissedCallsLimit > 0 {
+		missedCallsMu.Lock()
+		if _, ok := missedCalls[userId]; ok || len(missedCalls) < maxMissedCallUsers {
+			kept := append(missedCalls[userId], missed)
+			if len(kept) > cfg().MissedCallsLimit {
+				kept = kept[len(kept)-cfg().MissedCallsLimit:]
+			}
+			missedCalls[userId] = kept
+		}
+		missedCallsMu.Unlock()
+	}
+	logging.ForClient(client.Id).Debugf("Call %s missed by offline user %s", missed.Id, userId)
+
+	notified := false
+	if pushNotifier != nil {
+		notification := map[string]interface{}{"id": missed.Id, "from": missed.From, "from_user": missed.FromUser}
+		if data != nil {
+			notification["data"] = data
+		}
+		if err := pushNotifier.Notify(push.Notification{UserID: userId, Type: push.TypeMissedCall, Data: notification}); err != nil {
+			logging.ForClient(client.Id).Warn("Push notification of a missed call failed: ", err)
+		} else {
+			notified = true
+		}
+	}
+	client.Send(responsemessage.InfoMessage("Call_Missed", map[string]interface{}{
+		"id":       missed.Id,
+		"to_user":  userId,
+		"notified": notified,
+	}))
+	return true
+}
+
+// deliverMissedCalls sends a connecting client the calls its user missed, in
+// "Missed_Calls", and forgets them.
+func deliverMissedCalls(client *client.Client) {
+	if client.UserID == "" {
+		return
+	}
+	missedCallsMu.Lock()
+	missed := missedCalls[client.UserID]
+	delete(missedCalls, client.UserID)
+	seenUsers[client.UserID] = clk.Now()
+	missedCallsMu.Unlock()
+	if len(missed) == 0 {
+		return
+	}
+	client.Send(responsemessage.InfoMessage("Missed_Calls", map[string]interface{}{"calls": missed}))
+}
+
+// knownUser reports whether calls to the user can be missed: the user must be
+// in the user directory, or without one have connected in the last
+// missedCallRetention.
+func knownUser(clientId string, userId string) bool {
+	if userDirectory != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), cfg().DirectoryTimeout)
+		defer cancel()
+		_, err := userDirectory.Lookup(ctx, userId)
+		if err != nil && !errors.Is(err, directory.ErrNotFound) {
+			logging.ForClient(clientId).Warn("Directory lookup failed: ", err)
+		}
+		return err == nil
+	}
+	missedCallsMu.Lock()
+	defer missedCallsMu.Unlock()
+	_, ok := seenUsers[userId]
+	return ok
+}
+
+// seeUser records that the user of a disconnecting client was last seen now.
+func seeUser(userId string) {
+	if userId == "" {
+		return
+	}
+	missedCallsMu.Lock()
+	seenUsers[userId] = clk.Now()
+	missedCallsMu.Unlock()
+}
+
+// expireMissedCalls forgets the missed calls older than missedCallRetention
+// and the users not seen since.
+func expireMissedCalls(now time.Time) {
+	missedCallsMu.Lock()
+	defer missedCallsMu.Unlock()
+	for userId, missed := range missedCalls {
+		kept := missed[:0]
+		for _, call := range missed {
+			if now.Sub(call.At) < missedCallRetention {
+				kept = append(kept, call)
+			}
+		}
+		if len(kept) == 0 {
+			delete(missedCalls, userId)
+		} else {
+			missedCalls[userId] = kept
+		}
+	}
+	for userId, seen := range seenUsers {
+		if now.Sub(seen) >= missedCallRetention {
+			delete(seenUsers, userId)
+		}
+	}
+}
@@ -0,0 +1,48 @@
+package server
+
+import (
+	"sort"
+
+	"github.com/shankarammai/Peer2PeerConnector/internal/client"
+	responsemessage "github.com/shankarammai/Peer2PeerConnector/internal/response"
+)
+
+// Roles of a client in a room, as reported by "my_rooms".
+const (
+	RoleCreator   = "creator"
+	RoleModerator = "moderator"
+	RoleMember    = "member"
+)
+
+// handleMyRoomsMessage processes a "my_rooms" message.
+// It lists the rooms the client belongs to with its role and the member count,
+// so a reconnecting client can rebuild its state without keeping track of it.
+func handleMyRoomsMessage(client *client.Client, msg map[string]interface{}) {
+	from := client.GetClientId()
+	memberOf := roomsOfClient(from)
+
+	mu.Lock()
+	summaries := make([]map[string]interface{}, 0, len(memberOf))
+	for _, roomItem := range memberOf {
+		role := RoleMember
+		if roomItem.GetCreator() == from {
+			role = RoleCreator
+		} else if roomItem.IsModerator(from) {
+			role = RoleModerator
+		}
+		summaries = append(summaries, map[string]interface{}{
+			"room":        roomItem.GetId(),
+			"name":        roomItem.GetName(),
+			"role":        role,
+			"members":     len(roomItem.GetClients()),
+			"max_clients": roomItem.GetMaxClients(),
+			"region":      roomItem.GetRegion(),
+		})
+	}
+	mu.Unlock()
+	sort.Slice(summaries, func(i, j int) bool {
+		return summaries[i]["room"].(string) < summaries[j]["room"].(string)
+	})
+
+	client.Send(responsemessage.InfoMessage("My_Rooms", map[string]interface{}{"rooms": summaries}))
+}
@@ -0,0 +1,66 @@
+package server
+
+import (
+	"github.com/shankarammai/Peer2PeerConnector/internal/logging"
+	responsemessage "github.com/shankarammai/Peer2PeerConnector/internal/response"
+	"github.com/shankarammai/Peer2PeerConnector/internal/room"
+)
+
+// Roles assigned to the two sides of a peer connection.
+const (
+	RoleOfferer  = "offerer"
+	RoleAnswerer = "answerer"
+
+	// Perfect negotiation roles: on an offer collision the polite peer
+	// rolls back its own offer, the impolite peer ignores the incoming one.
+	RolePolite   = "polite"
+	RoleImpolite = "impolite"
+)
+
+// isInitiator reports whether clientId should create the offer towards peerId.
+// Picking the lexicographically smaller id lets both sides, and the server,
+// agree on the initiator without exchanging any message.
+func isInitiator(clientId string, peerId string) bool {
+	return clientId < peerId
+}
+
+// negotiationRole returns the perfect negotiation role of clientId towards peerId.
+// It uses the same ordering as isInitiator: the initiator is the impolite peer.
+func negotiationRole(clientId string, peerId string) string {
+	if isInitiator(clientId, peerId) {
+		return RoleImpolite
+	}
+	return RolePolite
+}
+
+// negotiateWithMembers tells the newcomer and every other connected member of the room
+// which of them creates the offer, so clients don't need their own glare handling.
+func negotiateWithMembers(myRoom *room.Room, newcomerId string) {
+	mu.Lock()
+	newcomer, connected := clients[newcomerId]
+	mu.Unlock()
+	if !connected {
+		return
+	}
+
+	for _, member := range connectedClients(myRoom.GetClients()) {
+		if member.GetClientId() == newcomerId {
+			continue
+		}
+		memberRole, newcomerRole := RoleAnswerer, RoleOfferer
+		if isInitiator(member.GetClientId(), newcomerId) {
+			memberRole, newcomerRole = RoleOfferer, RoleAnswerer
+		}
+		member.Send(responsemessage.InfoMessage("Negotiate", map[string]interface{}{
+			"room": myRoom.GetId(),
+			"peer": newcomerId,
+			"role": memberRole,
+		}))
+		newcomer.Send(responsemessage.InfoMessage("Negotiate", map[string]interface{}{
+			"room": myRoom.GetId(),
+			"peer": member.GetClientId(),
+			"role": newcomerRole,
+		}))
+	}
+	logging.ForRoom(newcomerId, myRoom.GetId()).Debug("Sent negotiation roles")
+}
@@ -0,0 +1,60 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/shankarammai/Peer2PeerConnector/internal/oidcauth"
+)
+
+// operators logs people in to the admin API and the docs, nil when OIDC is not configured.
+var operators *oidcauth.Provider
+
+// newOperators discovers the OIDC provider of the configuration.
+func newOperators() (*oidcauth.Provider, error) {
+	return oidcauth.New(context.Background(), oidcauth.Config{
+		Issuer:        cfg().OIDCIssuer,
+		ClientID:      cfg().OIDCClientID,
+		ClientSecret:  cfg().OIDCClientSecret,
+		RedirectURL:   cfg().OIDCRedirectURL,
+		GroupsClaim:   cfg().OIDCGroupsClaim,
+		SessionSecret: cfg().OIDCSessionSecret,
+		SessionTTL:    cfg().OIDCSessionTTL,
+	})
+}
+
+// checkOperator authorises a request to the admin API with the OIDC session of
+// an operator. Members of the admin groups may do everything, members of the
+// viewer groups may only read.
+func checkOperator(writer http.ResponseWriter, request *http.Request) bool {
+	session, ok := checkLoggedIn(writer, request)
+	if !ok {
+		return false
+	}
+	groups := cfg().OIDCAdminGroups
+	if request.Method == http.MethodGet || request.Method == http.MethodHead {
+		groups = append(slices.Clone(groups), cfg().OIDCViewerGroups...)
+	}
+	if !session.InGroup(groups) {
+		http.Error(writer, "Forbidden", http.StatusForbidden)
+		return false
+	}
+	return true
+}
+
+// checkLoggedIn returns the session of the operator, sending browsers to the
+// login and answering 401 to other requests without one.
+func checkLoggedIn(writer http.ResponseWriter, request *http.Request) (*oidcauth.Session, bool) {
+	session, ok := operators.Session(request)
+	if ok {
+		return session, true
+	}
+	if strings.Contains(request.Header.Get("Accept"), "text/html") {
+		operators.RedirectToLogin(writer, request)
+	} else {
+		http.Error(writer, "Unauthorised", http.StatusUnauthorized)
+	}
+	return nil, false
+}
@@ -0,0 +1,112 @@
+package server
+
+import (
+	"maps"
+	"sync"
+
+	"github.com/shankarammai/Peer2PeerConnector/internal/client"
+	"github.com/shankarammai/Peer2PeerConnector/internal/logging"
+)
+
+// signalStream numbers the signalling messages relayed from one client to
+// another, so the receiver can tell they arrive in order and none is missing.
+type signalStream struct {
+	// mu is held from numbering a message until it is queued for the target,
+	// the messages of a stream are queued in the order of their numbers.
+	mu  sync.Mutex
+	seq uint64
+}
+
+var (
+	// signalStreams are keyed by the sender and target Ids.
+	signalStreams   = make(map[[2]string]*signalStream)
+	signalStreamsMu sync.Mutex
+)
+
+// signalStreamOf returns the stream of the signalling from one client to another.
+func signalStreamOf(from, to string) *signalStream {
+	signalStreamsMu.Lock()
+	defer signalStreamsMu.Unlock()
+	stream, exists := signalStreams[[2]string{from, to}]
+	if !exists {
+		stream = &signalStream{}
+		signalStreams[[2]string{from, to}] = stream
+	}
+	return stream
+}
+
+// send queues a message with the next sequence number, which is given back
+// when write fails so the receiver sees no gap. stream.mu must be held.
+func (stream *signalStream) send(write func(seq uint64) error) error {
+	stream.seq++
+	if err := write(stream.seq); err != nil {
+		stream.seq--
+		return err
+	}
+	return nil
+}
+
+// relaySignal relays a signalling message from a client to the target with the
+// next "seq" of their stream, after the candidates still queued between them
+// by the debounce window, so an offer or answer never overtakes them.
+func relaySignal(from string, target *client.Client, write func(seq uint64) error) error {
+	stream := signalStreamOf(from, target.GetClientId())
+	stream.mu.Lock()
+	defer stream.mu.Unlock()
+	for _, batch := range takeCandidateBatches(from, target.GetClientId()) {
+		sendCandidateBatch(stream, batch)
+	}
+	return stream.send(write)
+}
+
+// sendSignal writes a decoded signalling message numbered seq to the target,
+// and holds it until acked when the target asked for acknowledgements, even
+// when the write fails: the target is dropping and may resume its session.
+func sendSignal(target *client.Client, msg map[string]interface{}, deadline *client.Deadline) func(seq uint64) error {
+	return func(seq uint64) error {
+		msg["seq"] = seq
+		if !target.Acks {
+			return target.SendAs(msg, client.PriorityHigh, deadline)
+		}
+		msg["message_id"] = newAckId()
+		if err := target.SendAs(msg, client.PriorityHigh, deadline); err != nil {
+			logging.ForClient(target.GetClientId()).Debugf("Holding %v until the session resumes: %v", msg["event"], err)
+		}
+		from, _ := msg["from"].(string)
+		retainUnacked(target, unackedMessage{
+			id:       msg["message_id"].(string),
+			message:  msg,
+			deadline: deadline,
+			from:     from,
+			renumber: func(seq uint64) interface{} {
+				renumbered := maps.Clone(msg)
+				renumbered["seq"] = seq
+				return renumbered
+			},
+		})
+		return nil
+	}
+}
+
+// resendSignal writes a held signalling message to a resumed client with the
+// next "seq" of the stream from its sender.
+func resendSignal(from string, target *client.Client, renumber func(seq uint64) interface{}) error {
+	stream := signalStreamOf(from, target.GetClientId())
+	stream.mu.Lock()
+	defer stream.mu.Unlock()
+	return stream.send(func(seq uint64) error {
+		return target.SendAs(renumber(seq), client.PriorityHigh, nil)
+	})
+}
+
+// dropSignalStreams forgets the streams from and to a disconnected client,
+// the numbering starts again at 1 once it connects again.
+func dropSignalStreams(clientId string) {
+	signalStreamsMu.Lock()
+	defer signalStreamsMu.Unlock()
+	for key := range signalStreams {
+		if key[0] == clientId || key[1] == clientId {
+			delete(signalStreams, key)
+		}
+	}
+}
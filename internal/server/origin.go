@@ -0,0 +1,38 @@
+package server
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/shankarammai/Peer2PeerConnector/internal/logging"
+)
+
+// checkOrigin allows WebSocket connections from the configured origins, all
+// connections are allowed when none is configured. The origin of the server is
+// derived from the forwarding headers of trusted proxies.
+func checkOrigin(request *http.Request) bool {
+	origin := request.Header.Get("Origin")
+	if len(cfg().AllowedOrigins) == 0 || origin == "" {
+		return true
+	}
+	if originAllowed(request, origin, cfg().AllowedOrigins) {
+		return true
+	}
+	logging.Logger.Debug("Refusing connection from origin ", origin)
+	return false
+}
+
+// originAllowed tells if origin is one of allowed, where "self" stands for the
+// origin of the server.
+func originAllowed(request *http.Request, origin string, allowed []string) bool {
+	self := proxies.Scheme(request) + "://" + proxies.Host(request)
+	for _, item := range allowed {
+		if item == "self" {
+			item = self
+		}
+		if strings.EqualFold(item, origin) {
+			return true
+		}
+	}
+	return false
+}
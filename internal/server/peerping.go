@@ -0,0 +1,130 @@
+package server
+
+import (
+	"sync"
+	"time"
+
+	"github.com/lithammer/shortuuid"
+	"github.com/shankarammai/Peer2PeerConnector/internal/client"
+	"github.com/shankarammai/Peer2PeerConnector/internal/logging"
+	responsemessage "github.com/shankarammai/Peer2PeerConnector/internal/response"
+)
+
+// peerPingTimeout is how long the server waits for the peer to answer a ping.
+const peerPingTimeout = 10 * time.Second
+
+// peerPing is a ping relayed to a peer, waiting for its pong.
+type peerPing struct {
+	from        string
+	to          string
+	clientTime  interface{}
+	receivedAt  time.Time
+	forwardedAt time.Time
+}
+
+var (
+	// peerPings are keyed by the ping Id.
+	peerPings   = make(map[string]*peerPing)
+	peerPingsMu sync.Mutex
+)
+
+// handlePingPeerMessage processes a "ping_peer" message.
+// The server relays the ping to the client "to" with an Id the peer answers
+// with "pong_peer", and timestamps both legs to tell the sender how long the
+// server and the peer took, see handlePongPeerMessage.
+func handlePingPeerMessage(client *client.Client, msg map[string]interface{}) {
+	receivedAt := time.Now()
+	targetID, ok := msg["to"].(string)
+	if !ok {
+		client.Send(responsemessage.ErrorMessage("Missing_Fields", map[string]interface{}{"message": "'to' field not found"}))
+		return
+	}
+	mu.Lock()
+	targetClient, exists := clients[targetID]
+	mu.Unlock()
+	if !exists {
+		client.Send(responsemessage.ErrorMessage("Not_Found", map[string]interface{}{"message": "Client with given " + targetID + " not found"}))
+		return
+	}
+	if refuseBlocked(client, targetClient) {
+		return
+	}
+	if !checkAuthorized(client, MsgTypePingPeer, authorizer.CanRelay(subjectOf(client), MsgTypePingPeer, "", targetID)) {
+		return
+	}
+
+	pingId := shortuuid.New()
+	ping := &peerPing{from: client.GetClientId(), to: targetID, receivedAt: receivedAt}
+	if data, ok := msg["data"].(map[string]interface{}); ok {
+		ping.clientTime = data["client_time"]
+	}
+	peerPingsMu.Lock()
+	peerPings[pingId] = ping
+	ping.forwardedAt = time.Now()
+	peerPingsMu.Unlock()
+	time.AfterFunc(peerPingTimeout, func() { expirePeerPing(pingId) })
+
+	targetClient.Send(responsemessage.InfoMessage("Ping_Peer", map[string]interface{}{
+		"id":   pingId,
+		"from": ping.from,
+	}))
+}
+
+// handlePongPeerMessage processes a "pong_peer" message answering the ping
+// "data""id". The sender of the ping receives a "Ping_Result" with the server
+// time of arrival and departure, and the round trip from the server to the peer.
+func handlePongPeerMessage(client *client.Client, msg map[string]interface{}) {
+	pongAt := time.Now()
+	data, _ := msg["data"].(map[string]interface{})
+	pingId, _ := data["id"].(string)
+	peerPingsMu.Lock()
+	ping, exists := peerPings[pingId]
+	if exists && ping.to == client.GetClientId() {
+		delete(peerPings, pingId)
+	}
+	peerPingsMu.Unlock()
+	if !exists || ping.to != client.GetClientId() {
+		client.Send(responsemessage.ErrorMessage("Not_Found", map[string]interface{}{"message": "No ping " + pingId + " waiting for this client."}))
+		return
+	}
+
+	for _, sender := range connectedClients([]string{ping.from}) {
+		result := map[string]interface{}{
+			"id":          pingId,
+			"peer":        ping.to,
+			"received_at": ping.receivedAt.UnixMilli(),
+			"peer_rtt":    milliseconds(pongAt.Sub(ping.forwardedAt)),
+		}
+		if ping.clientTime != nil {
+			result["client_time"] = ping.clientTime
+		}
+		sentAt := time.Now()
+		result["server_time"] = milliseconds(ping.forwardedAt.Sub(ping.receivedAt) + sentAt.Sub(pongAt))
+		result["sent_at"] = sentAt.UnixMilli()
+		sender.Send(responsemessage.InfoMessage("Ping_Result", result))
+	}
+}
+
+// expirePeerPing tells the sender of a ping the peer didn't answer in time.
+func expirePeerPing(pingId string) {
+	peerPingsMu.Lock()
+	ping, exists := peerPings[pingId]
+	delete(peerPings, pingId)
+	peerPingsMu.Unlock()
+	if !exists {
+		return
+	}
+	logging.ForClient(ping.from).Debugf("Peer %s did not answer ping %s", ping.to, pingId)
+	for _, sender := range connectedClients([]string{ping.from}) {
+		sender.Send(responsemessage.ErrorMessage("Ping_Timeout", map[string]interface{}{
+			"message": "Client " + ping.to + " did not answer the ping.",
+			"id":      pingId,
+			"peer":    ping.to,
+		}))
+	}
+}
+
+// milliseconds converts d to fractional milliseconds.
+func milliseconds(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
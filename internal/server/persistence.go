@@ -0,0 +1,56 @@
+package server
+
+import (
+	"github.com/shankarammai/Peer2PeerConnector/internal/logging"
+	"github.com/shankarammai/Peer2PeerConnector/internal/room"
+	"github.com/shankarammai/Peer2PeerConnector/internal/store"
+)
+
+// restoreRooms rebuilds the rooms map from the store. Clients don't survive a
+// restart, so the restored members show up as disconnected.
+func restoreRooms() error {
+	records, err := roomStore.LoadRooms()
+	if err != nil {
+		return err
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	for _, record := range records {
+		restored := record.ToRoom()
+		if record.History {
+			restored.EnableHistory(cfg().HistorySize, cfg().HistoryMaxBytes)
+		}
+		rooms[record.Id] = restored
+		indexRoom(restored)
+		assignJoinCode(restored)
+	}
+	logger.Infof("Restored %d persistent rooms", len(records))
+	return nil
+}
+
+// persistRoom saves the room definition if persistence is enabled and the room is persistent.
+func persistRoom(myRoom *room.Room) {
+	if roomStore == nil {
+		return
+	}
+	mu.Lock()
+	if !myRoom.IsPersistent() {
+		mu.Unlock()
+		return
+	}
+	record := store.RecordFromRoom(myRoom)
+	mu.Unlock()
+	if err := roomStore.SaveRoom(record); err != nil {
+		logging.ForRoom(record.Creator, record.Id).Error("Failed to persist room: ", err)
+	}
+}
+
+// unpersistRoom removes a deleted room from the store.
+func unpersistRoom(roomId string) {
+	if roomStore == nil {
+		return
+	}
+	if err := roomStore.DeleteRoom(roomId); err != nil {
+		logger.Errorf("Failed to delete room %s from the store: %v", roomId, err)
+	}
+}
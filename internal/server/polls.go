@@ -0,0 +1,168 @@
+package server
+
+import (
+	"slices"
+
+	"github.com/lithammer/shortuuid"
+	"github.com/shankarammai/Peer2PeerConnector/internal/client"
+	"github.com/shankarammai/Peer2PeerConnector/internal/logging"
+	responsemessage "github.com/shankarammai/Peer2PeerConnector/internal/response"
+	"github.com/shankarammai/Peer2PeerConnector/internal/room"
+)
+
+// Bounds of the polls, so a member can't make the room details grow without limit.
+const (
+	maxPollsPerRoom       = 20
+	maxPollOptions        = 10
+	maxPollQuestionLength = 256
+	maxPollOptionLength   = 128
+)
+
+// handleCreatePollMessage processes a "create_poll" message.
+// A member puts a question with 2 to maxPollOptions options to the room, the
+// members receive the new poll in a "Poll_Created" update.
+func handleCreatePollMessage(client *client.Client, msg map[string]interface{}) {
+	if !checkRoomInJSON(client, msg) {
+		return
+	}
+	data := msg["data"].(map[string]interface{})
+	roomId, _ := data["room"].(string)
+	from := client.GetClientId()
+	question, ok := data["question"].(string)
+	if !ok || question == "" || len([]rune(question)) > maxPollQuestionLength {
+		client.Send(responsemessage.ErrorMessage("Invalid_Poll", map[string]interface{}{"message": "'data''question' must be a text of at most 256 characters."}))
+		return
+	}
+	list, _ := data["options"].([]interface{})
+	options := make([]string, 0, len(list))
+	for _, item := range list {
+		if option, ok := item.(string); ok && option != "" && len([]rune(option)) <= maxPollOptionLength {
+			options = append(options, option)
+		}
+	}
+	if len(options) != len(list) || len(options) < 2 || len(options) > maxPollOptions {
+		client.Send(responsemessage.ErrorMessage("Invalid_Poll", map[string]interface{}{"message": "'data''options' must be 2 to 10 texts of at most 128 characters."}))
+		return
+	}
+	if !checkAuthorized(client, MsgTypeCreatePoll, authorizer.CanRelay(subjectOf(client), MsgTypeCreatePoll, roomId, "")) {
+		return
+	}
+
+	mu.Lock()
+	myRoom, exists := rooms[roomId]
+	if !exists {
+		mu.Unlock()
+		client.Send(responsemessage.ErrorMessage("Not_Found", map[string]interface{}{"message": "Room with Id " + roomId + " does not exist."}))
+		return
+	}
+	if !slices.Contains(myRoom.GetClients(), from) {
+		mu.Unlock()
+		client.Send(responsemessage.ErrorMessage("Unauthorised", map[string]interface{}{"message": "You need to be in the room to create a poll."}))
+		return
+	}
+	if len(myRoom.GetPolls()) >= maxPollsPerRoom {
+		mu.Unlock()
+		client.Send(responsemessage.ErrorMessage("Too_Many_Polls", map[string]interface{}{"message": "The room has reached its limit of polls."}))
+		return
+	}
+	poll := room.NewPoll(shortuuid.New(), question, options, from)
+	myRoom.AddPoll(poll)
+	results := poll.Results()
+	mu.Unlock()
+
+	logging.ForRoom(from, roomId).Debug("Poll created: ", poll.Id)
+	notifyPoll(myRoom, "Poll_Created", results)
+}
+
+// handlePollMessage processes "vote" and "close_poll" messages on the poll
+// named in "data""poll". Each member votes once for the index of an option,
+// the members receive the tally in a "Poll_Results" update after every vote.
+// The creator of the poll and the moderators close it, the members receive the
+// final tally in a "Poll_Closed" update.
+func handlePollMessage(client *client.Client, msg map[string]interface{}) {
+	if !checkRoomInJSON(client, msg) {
+		return
+	}
+	data := msg["data"].(map[string]interface{})
+	roomId, _ := data["room"].(string)
+	from := client.GetClientId()
+	pollId, _ := data["poll"].(string)
+	msgtype, _ := msg["event"].(string)
+	if !checkAuthorized(client, msgtype, authorizer.CanRelay(subjectOf(client), msgtype, roomId, "")) {
+		return
+	}
+
+	mu.Lock()
+	myRoom, exists := rooms[roomId]
+	if !exists {
+		mu.Unlock()
+		client.Send(responsemessage.ErrorMessage("Not_Found", map[string]interface{}{"message": "Room with Id " + roomId + " does not exist."}))
+		return
+	}
+	if !slices.Contains(myRoom.GetClients(), from) {
+		mu.Unlock()
+		client.Send(responsemessage.ErrorMessage("Unauthorised", map[string]interface{}{"message": "You need to be in the room to vote."}))
+		return
+	}
+	poll := myRoom.GetPoll(pollId)
+	if poll == nil {
+		mu.Unlock()
+		client.Send(responsemessage.ErrorMessage("Not_Found", map[string]interface{}{"message": "Poll " + pollId + " does not exist in the room."}))
+		return
+	}
+	if poll.Closed {
+		mu.Unlock()
+		client.Send(responsemessage.ErrorMessage("Poll_Closed", map[string]interface{}{"message": "Poll " + pollId + " is closed.", "poll": pollId}))
+		return
+	}
+	event := "Poll_Results"
+	if msgtype == MsgTypeVote {
+		option, ok := intField(data, "option")
+		if !ok || option < 0 || option >= len(poll.Options) {
+			mu.Unlock()
+			client.Send(responsemessage.ErrorMessage("Invalid_Vote", map[string]interface{}{"message": "'data''option' must be the index of an option of the poll."}))
+			return
+		}
+		if !poll.Vote(from, option) {
+			mu.Unlock()
+			client.Send(responsemessage.ErrorMessage("Already_Voted", map[string]interface{}{"message": "You already voted in poll " + pollId + ".", "poll": pollId}))
+			return
+		}
+	} else {
+		if poll.Creator != from && !myRoom.IsModerator(from) {
+			mu.Unlock()
+			client.Send(responsemessage.ErrorMessage("Unauthorised", map[string]interface{}{"message": "You need to have created the poll or moderate the room to close it."}))
+			return
+		}
+		poll.Closed = true
+		event = "Poll_Closed"
+	}
+	results := poll.Results()
+	mu.Unlock()
+
+	logging.ForRoom(from, roomId).Debugf("%s in poll %s", msgtype, pollId)
+	notifyPoll(myRoom, event, results)
+}
+
+// notifyPoll sends the tally of a poll to the members of the room.
+func notifyPoll(myRoom *room.Room, event string, results room.PollResults) {
+	update := responsemessage.UpdateMessage(event, map[string]interface{}{
+		"room": myRoom.GetId(),
+		"poll": results,
+	})
+	mu.Lock()
+	members := slices.Clone(myRoom.GetClients())
+	mu.Unlock()
+	for _, member := range connectedClients(members) {
+		member.Send(update)
+	}
+}
+
+// pollResults returns the tallies of the polls of the room, mu must be held.
+func pollResults(myRoom *room.Room) []room.PollResults {
+	results := make([]room.PollResults, 0, len(myRoom.GetPolls()))
+	for _, poll := range myRoom.GetPolls() {
+		results = append(results, poll.Results())
+	}
+	return results
+}
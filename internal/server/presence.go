@@ -0,0 +1,67 @@
+package server
+
+import (
+	"slices"
+	"strings"
+	"unicode"
+
+	"github.com/shankarammai/Peer2PeerConnector/internal/client"
+	"github.com/shankarammai/Peer2PeerConnector/internal/logging"
+	responsemessage "github.com/shankarammai/Peer2PeerConnector/internal/response"
+)
+
+// maxNameLength bounds the display name of a client, in characters.
+const maxNameLength = 64
+
+// presenceStatuses are the statuses accepted by "set_status".
+var presenceStatuses = []string{client.StatusOnline, client.StatusBusy, client.StatusAway, client.StatusDND}
+
+// handleSetStatusMessage processes a "set_status" message.
+// It validates and stores the new presence status of the client and broadcasts
+// a presence update to every room the client is in.
+func handleSetStatusMessage(client *client.Client, msg map[string]interface{}) {
+	data, dataOk := msg["data"].(map[string]interface{})
+	if !dataOk {
+		client.Send(responsemessage.ErrorMessage("Missing_Fields", map[string]interface{}{"message": "'data' field is missing or is not object in the request."}))
+		return
+	}
+	status, ok := data["status"].(string)
+	if !ok || !slices.Contains(presenceStatuses, status) {
+		client.Send(responsemessage.ErrorMessage("Invalid_Status", map[string]interface{}{
+			"message":  "'status' must be one of the supported statuses.",
+			"statuses": presenceStatuses,
+		}))
+		return
+	}
+
+	mu.Lock()
+	client.SetStatus(status)
+	mu.Unlock()
+	logging.ForClient(client.Id).Debug("Status changed to ", status)
+
+	for _, roomItem := range roomsOfClient(client.GetClientId()) {
+		update := responsemessage.UpdateMessage("Presence_Update", map[string]interface{}{
+			"room":   roomItem.GetId(),
+			"client": client.GetClientId(),
+			"status": status,
+		})
+		for _, member := range connectedClients(roomItem.GetClients()) {
+			member.Send(update)
+		}
+	}
+}
+
+// displayName cleans up the display name requested by a connecting client:
+// control characters are dropped and long names are cut to maxNameLength.
+func displayName(name string) string {
+	name = strings.TrimSpace(strings.Map(func(char rune) rune {
+		if unicode.IsControl(char) {
+			return -1
+		}
+		return char
+	}, name))
+	if runes := []rune(name); len(runes) > maxNameLength {
+		name = string(runes[:maxNameLength])
+	}
+	return name
+}
@@ -0,0 +1,69 @@
+package server
+
+import (
+	"encoding/base64"
+	"html/template"
+	"net/http"
+	"strings"
+
+	"github.com/shankarammai/Peer2PeerConnector/public"
+	qrcode "github.com/skip2/go-qrcode"
+)
+
+// qrCodeSize is the width and height of the QR codes, in pixels.
+const qrCodeSize = 256
+
+// joinTemplate renders the page of a join code.
+var joinTemplate = template.Must(template.ParseFS(public.Templates, "join.html"))
+
+// ServeRoomQRCode serves /room/{code}/qr: a page showing the QR code of a room
+// join code, to scan with a phone, and the deep link it encodes. With
+// ?format=png only the QR code image is returned.
+func ServeRoomQRCode(writer http.ResponseWriter, request *http.Request) {
+	code := strings.ToUpper(request.PathValue("code"))
+	mu.Lock()
+	_, exists := joinCodes[code]
+	mu.Unlock()
+	if !exists {
+		http.NotFound(writer, request)
+		return
+	}
+
+	link := joinLink(code)
+	content := link
+	if content == "" {
+		content = code
+	}
+	image, err := qrcode.Encode(content, qrcode.Medium, qrCodeSize)
+	if err != nil {
+		http.Error(writer, "Could not render QR code", http.StatusInternalServerError)
+		return
+	}
+	writer.Header().Set("Cache-Control", "no-store")
+	if request.URL.Query().Get("format") == "png" {
+		writer.Header().Set("Content-Type", "image/png")
+		writer.Write(image)
+		return
+	}
+
+	data := struct {
+		Code   string
+		Link   template.URL
+		QRCode string
+		Size   int
+	}{
+		Code:   code,
+		Link:   template.URL(link),
+		QRCode: base64.StdEncoding.EncodeToString(image),
+		Size:   qrCodeSize,
+	}
+	if err := joinTemplate.Execute(writer, data); err != nil {
+		http.Error(writer, "Could not execute template", http.StatusInternalServerError)
+	}
+}
+
+// joinLink returns the deep link joining the room with the join code, built
+// from JoinURL. It is empty when no JoinURL is configured.
+func joinLink(code string) string {
+	return strings.ReplaceAll(cfg().JoinURL, "{code}", code)
+}
@@ -0,0 +1,47 @@
+package server
+
+import (
+	"github.com/shankarammai/Peer2PeerConnector/internal/client"
+	"github.com/shankarammai/Peer2PeerConnector/internal/logging"
+	responsemessage "github.com/shankarammai/Peer2PeerConnector/internal/response"
+)
+
+// handleRecordingMessage processes "recording_started" and "recording_stopped" messages.
+// Only the creator can announce a recording. The flag is part of the room details,
+// so members joining while the room is recorded know it before sending any media.
+func handleRecordingMessage(client *client.Client, msg map[string]interface{}) {
+	if !checkRoomInJSON(client, msg) {
+		return
+	}
+	data := msg["data"].(map[string]interface{})
+	roomId, _ := data["room"].(string)
+	from := client.GetClientId()
+	event, _ := msg["event"].(string)
+	recording := event == MsgTypeRecordingStarted
+	if !checkAuthorized(client, event, authorizer.CanRelay(subjectOf(client), event, roomId, "")) {
+		return
+	}
+
+	mu.Lock()
+	myRoom, exists := rooms[roomId]
+	if !exists {
+		mu.Unlock()
+		client.Send(responsemessage.ErrorMessage("Not_Found", map[string]interface{}{"message": "Room with Id " + roomId + " does not exist."}))
+		return
+	}
+	if myRoom.GetCreator() != from {
+		mu.Unlock()
+		client.Send(responsemessage.ErrorMessage("Unauthorised", map[string]interface{}{"message": "You need to be creator of room to start or stop a recording."}))
+		return
+	}
+	myRoom.SetRecording(recording)
+	mu.Unlock()
+
+	if recording {
+		logging.ForRoom(from, roomId).Info("Recording started")
+		notifyUpdateIntheRoom(roomId, "Recording_Started")
+	} else {
+		logging.ForRoom(from, roomId).Info("Recording stopped")
+		notifyUpdateIntheRoom(roomId, "Recording_Stopped")
+	}
+}
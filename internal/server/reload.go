@@ -0,0 +1,102 @@
+package server
+
+import (
+	"net/http"
+	"reflect"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/shankarammai/Peer2PeerConnector/internal/config"
+	"github.com/shankarammai/Peer2PeerConnector/internal/logging"
+)
+
+// reloadable are the settings applied by a reload, by variable and Config
+// field name. The others are read when the server starts and need a restart.
+var reloadable = []struct{ name, field string }{
+	{"LOG_LEVEL", "LogLevel"},
+	{"ALLOWED_ORIGINS", "AllowedOrigins"},
+	{"CORS_ORIGINS", "CORSOrigins"},
+	{"MAX_CONNECTIONS", "MaxConnections"},
+	{"MAX_ROOMS", "MaxRooms"},
+	{"MAX_ROOMS_PER_CLIENT", "MaxRoomsPerClient"},
+	{"TYPING_INTERVAL", "TypingInterval"},
+	{"FILE_RELAY_RATE", "FileRelayRate"},
+	{"CLIENT_QUOTA_SOFT", "ClientQuotaSoft"},
+	{"CLIENT_QUOTA_HARD", "ClientQuotaHard"},
+	{"ROOM_QUOTA_SOFT", "RoomQuotaSoft"},
+	{"ROOM_QUOTA_HARD", "RoomQuotaHard"},
+}
+
+// reloadMu serializes the reloads, so none of them is lost.
+var reloadMu sync.Mutex
+
+// ReloadConfig loads the environment and CONFIG_FILE again and applies the
+// reloadable settings, the connections and rooms are kept. It returns the
+// names of the changed settings. Nothing changes when the configuration is
+// invalid.
+func ReloadConfig() ([]string, error) {
+	reloadMu.Lock()
+	defer reloadMu.Unlock()
+	loaded, err := config.Load()
+	if err != nil {
+		return nil, err
+	}
+	next := *cfg()
+	var changed []string
+	for _, setting := range reloadable {
+		field := reflect.ValueOf(&next).Elem().FieldByName(setting.field)
+		value := reflect.ValueOf(loaded).Elem().FieldByName(setting.field)
+		if !reflect.DeepEqual(field.Interface(), value.Interface()) {
+			field.Set(value)
+			changed = append(changed, setting.name)
+		}
+	}
+	if slices.Contains(changed, "LOG_LEVEL") {
+		if err := logging.Configure(next.LogLevel, next.LogFormat); err != nil {
+			return nil, err
+		}
+	}
+	current.Store(&next)
+	if slices.Contains(changed, "FILE_RELAY_RATE") {
+		retuneFileRelayLimiters(&next)
+	}
+	logger.Infof("Configuration reloaded, changed %v", changed)
+	return changed, nil
+}
+
+// reloadableSettings returns the current reloadable settings by variable name.
+func reloadableSettings() map[string]interface{} {
+	settings := reflect.ValueOf(cfg()).Elem()
+	described := make(map[string]interface{}, len(reloadable))
+	for _, setting := range reloadable {
+		value := settings.FieldByName(setting.field).Interface()
+		if duration, ok := value.(time.Duration); ok {
+			value = duration.String()
+		}
+		described[setting.name] = value
+	}
+	return described
+}
+
+// handleConfigRequest serves /admin/config: GET returns the reloadable
+// settings, POST reloads them and returns the changed ones too.
+func handleConfigRequest(writer http.ResponseWriter, request *http.Request) {
+	switch request.Method {
+	case http.MethodGet:
+		writeJSONResponse(writer, http.StatusOK, map[string]interface{}{"settings": reloadableSettings()})
+	case http.MethodPost:
+		changed, err := ReloadConfig()
+		if err != nil {
+			logger.Warn("Configuration not reloaded: ", err)
+			http.Error(writer, "Invalid configuration: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		writeJSONResponse(writer, http.StatusOK, map[string]interface{}{
+			"changed":  append([]string{}, changed...),
+			"settings": reloadableSettings(),
+		})
+	default:
+		http.Error(writer, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
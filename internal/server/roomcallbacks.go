@@ -0,0 +1,41 @@
+package server
+
+import (
+	"net/url"
+	"slices"
+
+	"github.com/shankarammai/Peer2PeerConnector/internal/room"
+	"github.com/shankarammai/Peer2PeerConnector/internal/webhook"
+)
+
+// Events posted to the callback URL of a room.
+const (
+	CallbackClientJoined = "client_joined"
+	CallbackClientLeft   = "client_left"
+	CallbackRoomDeleted  = "room_deleted"
+)
+
+// roomCallbacks delivers the events of rooms created with a callback URL.
+var roomCallbacks *webhook.Dispatcher
+
+// validCallbackURL tells if rooms may post their events to rawURL: an http or
+// https URL on one of the hosts allowed by RoomCallbackHosts.
+func validCallbackURL(rawURL string) bool {
+	parsed, err := url.Parse(rawURL)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") {
+		return false
+	}
+	return slices.Contains(cfg().RoomCallbackHosts, parsed.Hostname())
+}
+
+// notifyRoomCallback posts an event of the room to its callback URL, if it has one.
+func notifyRoomCallback(myRoom *room.Room, eventType string, data map[string]interface{}) {
+	mu.Lock()
+	callbackURL := myRoom.GetCallbackURL()
+	mu.Unlock()
+	if callbackURL == "" {
+		return
+	}
+	data["room"] = myRoom.GetId()
+	roomCallbacks.EmitTo(callbackURL, eventType, data)
+}
@@ -0,0 +1,104 @@
+package server
+
+import (
+	"net"
+	"net/http"
+
+	"github.com/gorilla/websocket"
+)
+
+// NewHandler returns the HTTP handler serving every endpoint of the server,
+// but the admin API and the metrics when they have listeners of their own.
+func NewHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", handleRequest)
+	mux.HandleFunc("/ice-servers", withCORS(ServeIceServers))
+	mux.HandleFunc("/poll/", withCORS(HandleLongPoll))
+	mux.HandleFunc("/sse", withCORS(HandleSSE))
+	mux.HandleFunc("/sse/", withCORS(HandleSSE))
+	// the docs are not served in place of the endpoints moved to another listener
+	if cfg().AdminAddr == "" {
+		mux.HandleFunc("/admin/", withAdminCORS(HandleAdmin))
+	} else {
+		mux.Handle("/admin/", http.NotFoundHandler())
+	}
+	if cfg().MetricsAddr == "" {
+		mux.HandleFunc("/metrics", ServeMetrics)
+	} else {
+		mux.Handle("/metrics", http.NotFoundHandler())
+	}
+	mux.HandleFunc("GET /room/{code}/qr", ServeRoomQRCode)
+	mux.HandleFunc("GET /spec", withCORS(ServeSpec))
+	mux.HandleFunc("GET /spec/", withCORS(ServeSpec))
+	mux.HandleFunc("GET /sdk/", ServeSDK)
+	if cfg().DemoEnabled {
+		mux.Handle("GET /demo/", demoHandler())
+	}
+	handleOperatorLogin(mux)
+	return LogRequests(mux)
+}
+
+// NewListenerHandlers returns the handlers of the admin API and the metrics by
+// address, when ADMIN_ADDR or METRICS_ADDR gives them listeners of their own.
+// They share one listener when both name the same address. Like on the main
+// listener, both need the admin token.
+func NewListenerHandlers() map[string]http.Handler {
+	for _, addr := range []string{cfg().AdminAddr, cfg().MetricsAddr} {
+		warnPlainListener(addr)
+	}
+	muxes := make(map[string]*http.ServeMux)
+	muxFor := func(addr string) *http.ServeMux {
+		if muxes[addr] == nil {
+			muxes[addr] = http.NewServeMux()
+		}
+		return muxes[addr]
+	}
+	if cfg().AdminAddr != "" {
+		mux := muxFor(cfg().AdminAddr)
+		mux.HandleFunc("/admin/", withAdminCORS(HandleAdmin))
+		// operators are sent to the login of the listener they called
+		handleOperatorLogin(mux)
+	}
+	if cfg().MetricsAddr != "" {
+		muxFor(cfg().MetricsAddr).HandleFunc("/metrics", ServeMetrics)
+	}
+	handlers := make(map[string]http.Handler, len(muxes))
+	for addr, mux := range muxes {
+		handlers[addr] = LogRequests(mux)
+	}
+	return handlers
+}
+
+// warnPlainListener warns when a listener of its own serves plain HTTP on an
+// address reachable from other machines, the admin token then crosses the
+// network in the clear.
+func warnPlainListener(addr string) {
+	if addr == "" {
+		return
+	}
+	host, _, err := net.SplitHostPort(addr)
+	if err == nil && isLoopback(host) {
+		return
+	}
+	logger.Warnf("%s serves plain HTTP on a non-loopback address, the admin token is sent in the clear: keep it on a private network or behind a TLS proxy", addr)
+}
+
+// handleOperatorLogin serves the operator login when OIDC is configured.
+func handleOperatorLogin(mux *http.ServeMux) {
+	if operators == nil {
+		return
+	}
+	mux.HandleFunc("GET /auth/login", operators.HandleLogin)
+	mux.HandleFunc("GET /auth/callback", operators.HandleCallback)
+	mux.HandleFunc("GET /auth/logout", operators.HandleLogout)
+}
+
+// handleRequest serves WebSocket on wss:// and the docs on http://
+func handleRequest(writer http.ResponseWriter, request *http.Request) {
+	// Check if the request is using WebSocket
+	if websocket.IsWebSocketUpgrade(request) {
+		HandleWebSocketConnection(writer, request)
+	} else {
+		withCORS(ServerDocs)(writer, request)
+	}
+}
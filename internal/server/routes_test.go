@@ -0,0 +1,45 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/shankarammai/Peer2PeerConnector/internal/config"
+)
+
+func TestNewListenerHandlers(t *testing.T) {
+	t.Cleanup(func() { current.Store(nil) })
+	tests := []struct {
+		adminAddr, metricsAddr string
+		handlers               int
+	}{
+		{"", "", 0},
+		{"127.0.0.1:9090", "127.0.0.1:9100", 2},
+		{"localhost:9090", "localhost:9090", 1},
+		{"10.0.0.5:9090", "", 1},
+		{":9090", "0.0.0.0:9100", 2},
+	}
+	for _, test := range tests {
+		settings := *config.Default()
+		settings.AdminAddr, settings.MetricsAddr = test.adminAddr, test.metricsAddr
+		settings.AdminToken = "admin-token"
+		current.Store(&settings)
+		handlers := NewListenerHandlers()
+		if len(handlers) != test.handlers {
+			t.Errorf("%q %q: %d handlers, want %d", test.adminAddr, test.metricsAddr, len(handlers), test.handlers)
+		}
+		// the listeners of their own need the admin token like the main one
+		for _, endpoint := range []struct{ addr, path string }{{test.adminAddr, "/admin/stats"}, {test.metricsAddr, "/metrics"}} {
+			addr, path := endpoint.addr, endpoint.path
+			if addr == "" {
+				continue
+			}
+			recorder := httptest.NewRecorder()
+			handlers[addr].ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, path, nil))
+			if recorder.Code != http.StatusUnauthorized {
+				t.Errorf("%s%s without the admin token: %d, want %d", addr, path, recorder.Code, http.StatusUnauthorized)
+			}
+		}
+	}
+}
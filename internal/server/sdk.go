@@ -0,0 +1,36 @@
+package server
+
+import (
+	"net/http"
+	"sync"
+
+	"github.com/shankarammai/Peer2PeerConnector/internal/jsgen"
+	"github.com/shankarammai/Peer2PeerConnector/internal/protocol"
+)
+
+// sdkFiles are the generated client SDK files by path, generated on first use.
+var sdkFiles = sync.OnceValue(func() map[string][]byte {
+	return map[string][]byte{
+		"/sdk/p2pconnector.js":   jsgen.JavaScript(protocol.Current.Name(), clientMessages),
+		"/sdk/p2pconnector.d.ts": jsgen.TypeScript(protocol.Current.Name(), clientMessages),
+	}
+})
+
+// ServeSDK serves the JavaScript client SDK generated from the protocol messages
+// at /sdk/p2pconnector.js, and its TypeScript declarations at /sdk/p2pconnector.d.ts.
+// Pages on any origin may import it.
+func ServeSDK(writer http.ResponseWriter, request *http.Request) {
+	content, ok := sdkFiles()[request.URL.Path]
+	if !ok {
+		http.NotFound(writer, request)
+		return
+	}
+	if request.URL.Path == "/sdk/p2pconnector.js" {
+		writer.Header().Set("Content-Type", "text/javascript; charset=utf-8")
+	} else {
+		writer.Header().Set("Content-Type", "application/typescript; charset=utf-8")
+	}
+	writer.Header().Set("Access-Control-Allow-Origin", "*")
+	writer.Header().Set("Cache-Control", "no-cache")
+	writer.Write(content)
+}
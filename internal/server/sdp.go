@@ -0,0 +1,54 @@
+package server
+
+import (
+	"errors"
+
+	"github.com/shankarammai/Peer2PeerConnector/internal/client"
+	"github.com/shankarammai/Peer2PeerConnector/internal/logging"
+	responsemessage "github.com/shankarammai/Peer2PeerConnector/internal/response"
+	"github.com/shankarammai/Peer2PeerConnector/internal/sdp"
+)
+
+// sessionDescription finds the SDP in the data of an offer or answer: the data
+// itself, its "sdp" field or the "sdp" field of an RTCSessionDescription in "sdp".
+func sessionDescription(data interface{}) (string, bool) {
+	if description, ok := data.(string); ok {
+		return description, true
+	}
+	fields, ok := data.(map[string]interface{})
+	if !ok {
+		return "", false
+	}
+	switch description := fields["sdp"].(type) {
+	case string:
+		return description, true
+	case map[string]interface{}:
+		return sessionDescription(description)
+	}
+	return "", false
+}
+
+// checkSDP validates the SDP in data when SDP validation is enabled and reports
+// a rejected SDP to the client with the reason.
+func checkSDP(client *client.Client, data interface{}) bool {
+	if !cfg().SDPValidation {
+		return true
+	}
+	description, found := sessionDescription(data)
+	var err error = &sdp.Error{Reason: sdp.ReasonMalformed, Message: "No SDP found in the message."}
+	if found {
+		err = sdp.Validate(description, sdp.Policy{MaxSize: cfg().SDPMaxSize, Codecs: cfg().SDPCodecs})
+	}
+	if err == nil {
+		return true
+	}
+
+	logging.ForClient(client.Id).Debug("Rejected SDP: ", err)
+	details := map[string]interface{}{"message": err.Error()}
+	var sdpErr *sdp.Error
+	if errors.As(err, &sdpErr) {
+		details["reason"] = sdpErr.Reason
+	}
+	client.Send(responsemessage.ErrorMessage("Invalid_SDP", details))
+	return false
+}
@@ -0,0 +1,86 @@
+package server
+
+import (
+	"github.com/shankarammai/Peer2PeerConnector/internal/client"
+	responsemessage "github.com/shankarammai/Peer2PeerConnector/internal/response"
+	"github.com/shankarammai/Peer2PeerConnector/internal/room"
+)
+
+// Page sizes of "find_room".
+const (
+	defaultFindRoomLimit = 20
+	maxFindRoomLimit     = 100
+)
+
+// roomIndex finds public rooms by name, it is guarded by mu.
+var roomIndex = room.NewIndex()
+
+// indexRoom adds a public room to the index. The caller must hold mu.
+func indexRoom(myRoom *room.Room) {
+	if myRoom.IsPublic() {
+		roomIndex.Add(myRoom.GetId(), myRoom.GetName())
+	}
+}
+
+// handleFindRoomMessage processes a "find_room" message.
+// It searches the public rooms whose name starts with the "query", or contains
+// it when "match" is "substring", and returns a page of them sorted by name.
+func handleFindRoomMessage(client *client.Client, msg map[string]interface{}) {
+	data, dataOk := msg["data"].(map[string]interface{})
+	if !dataOk {
+		client.Send(responsemessage.ErrorMessage("Missing_Fields", map[string]interface{}{"message": "'data' field is missing or is not object in the request."}))
+		return
+	}
+	query, ok := data["query"].(string)
+	if !ok {
+		client.Send(responsemessage.ErrorMessage("Missing_Fields", map[string]interface{}{"message": "'data''query' field is missing or is not a string."}))
+		return
+	}
+	match, _ := data["match"].(string)
+	if match == "" {
+		match = "prefix"
+	}
+	offset, _ := intField(data, "offset")
+	limit, ok := intField(data, "limit")
+	if !ok {
+		limit = defaultFindRoomLimit
+	}
+	if (match != "prefix" && match != "substring") || offset < 0 || limit < 1 || limit > maxFindRoomLimit {
+		client.Send(responsemessage.ErrorMessage("Invalid_Fields", map[string]interface{}{
+			"message":   "'match' must be \"prefix\" or \"substring\", 'offset' positive and 'limit' between 1 and 100.",
+			"max_limit": maxFindRoomLimit,
+		}))
+		return
+	}
+
+	mu.Lock()
+	var roomIds []string
+	var total int
+	if match == "substring" {
+		roomIds, total = roomIndex.Substring(query, offset, limit)
+	} else {
+		roomIds, total = roomIndex.Prefix(query, offset, limit)
+	}
+	found := make([]map[string]interface{}, 0, len(roomIds))
+	for _, roomId := range roomIds {
+		roomItem := rooms[roomId]
+		found = append(found, map[string]interface{}{
+			"room":        roomItem.GetId(),
+			"name":        roomItem.GetName(),
+			"members":     len(roomItem.GetClients()),
+			"max_clients": roomItem.GetMaxClients(),
+			"protected":   roomItem.HasPassword(),
+			"locked":      roomItem.IsLocked(),
+			"approval":    roomItem.RequiresApproval(),
+			"region":      roomItem.GetRegion(),
+		})
+	}
+	mu.Unlock()
+
+	client.Send(responsemessage.InfoMessage("Rooms_Found", map[string]interface{}{
+		"rooms":  found,
+		"total":  total,
+		"offset": offset,
+		"limit":  limit,
+	}))
+}
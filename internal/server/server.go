@@ -2,54 +2,130 @@ package server
 
 import (
 	"bytes"
-	"encoding/json"
 	"errors"
+	"fmt"
 	"html/template"
+	"maps"
 	"net/http"
 	"os"
 	"slices"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/gorilla/websocket"
 	"github.com/lithammer/shortuuid"
+	"github.com/shankarammai/Peer2PeerConnector/docs"
 	"github.com/shankarammai/Peer2PeerConnector/internal/client"
+	"github.com/shankarammai/Peer2PeerConnector/internal/clock"
+	"github.com/shankarammai/Peer2PeerConnector/internal/config"
+	"github.com/shankarammai/Peer2PeerConnector/internal/events"
+	"github.com/shankarammai/Peer2PeerConnector/internal/geo"
+	"github.com/shankarammai/Peer2PeerConnector/internal/ids"
+	"github.com/shankarammai/Peer2PeerConnector/internal/logging"
+	"github.com/shankarammai/Peer2PeerConnector/internal/protocol"
+	"github.com/shankarammai/Peer2PeerConnector/internal/proxy"
+	"github.com/shankarammai/Peer2PeerConnector/internal/recorder"
 	responsemessage "github.com/shankarammai/Peer2PeerConnector/internal/response"
 	"github.com/shankarammai/Peer2PeerConnector/internal/room"
-	"github.com/sirupsen/logrus"
+	"github.com/shankarammai/Peer2PeerConnector/internal/store"
+	"github.com/shankarammai/Peer2PeerConnector/internal/webhook"
+	"github.com/shankarammai/Peer2PeerConnector/public"
 	"github.com/yuin/goldmark"
 	highlighting "github.com/yuin/goldmark-highlighting"
 	"github.com/yuin/goldmark/renderer/html"
 )
 
-var logger = &logrus.Logger{
-	Out:   os.Stderr,
-	Level: logrus.DebugLevel,
-	Formatter: &logrus.TextFormatter{
-		DisableColors:   false,
-		TimestampFormat: "2024-01-02 15:04:05",
-		FullTimestamp:   true,
-		ForceColors:     true,
-	},
-}
+var logger = logging.Logger
+
+// StatusDisconnected is the member status of clients that are in a room but not connected.
+const StatusDisconnected = "disconnected"
+
+// maxRoomLifetime bounds the "expires_in" of a room, in seconds, so the expiry
+// time can't overflow.
+const maxRoomLifetime = 365 * 24 * 60 * 60
+
+// Events sent to the configured webhooks.
+const (
+	EventClientConnected    = "client_connected"
+	EventClientDisconnected = "client_disconnected"
+	EventRoomCreated        = "room_created"
+	EventRoomDeleted        = "room_deleted"
+	EventRoomFull           = "room_full"
+	EventCapacityHigh       = "capacity_high"
+	EventCapacityNormal     = "capacity_normal"
+	EventSlowConsumer       = "slow_consumer"
+)
 
 const (
-	MsgTypeConnect    = "Connect"
-	MsgTypeCreateRoom = "Create_Room"
-	MsgTypeJoinRoom   = "Join_Room"
-	MsgTypeLeaveRoom  = "Leave_Room"
-	MsgTypeEndRoom    = "End_Room"
-	MsgTypeOffer      = "Offer"
-	MsgTypeAnswer     = "Answer"
-	MsgTypeCandidate  = "Candidate"
-	MsgTypeMessage    = "Message"
+	MsgTypeConnect          = "Connect"
+	MsgTypeCreateRoom       = "Create_Room"
+	MsgTypeJoinRoom         = "Join_Room"
+	MsgTypeLeaveRoom        = "Leave_Room"
+	MsgTypeEndRoom          = "End_Room"
+	MsgTypeOffer            = "Offer"
+	MsgTypeAnswer           = "Answer"
+	MsgTypeCandidate        = "Candidate"
+	MsgTypeCandidates       = "Candidates"
+	MsgTypeMessage          = "Message"
+	MsgTypeGetIceServers    = "Get_Ice_Servers"
+	MsgTypeSetStatus        = "Set_Status"
+	MsgTypeInvite           = "Invite"
+	MsgTypeLockRoom         = "Lock_Room"
+	MsgTypeUnlockRoom       = "Unlock_Room"
+	MsgTypeGetTopology      = "Get_Topology"
+	MsgTypeSetCapabilities  = "Set_Capabilities"
+	MsgTypePublishKey       = "Publish_Key"
+	MsgTypeRotateKey        = "Rotate_Key"
+	MsgTypeReportStats      = "Report_Stats"
+	MsgTypeBroadcast        = "Broadcast"
+	MsgTypeSetModerator     = "Set_Moderator"
+	MsgTypeApproveJoin      = "Approve_Join"
+	MsgTypeRejectJoin       = "Reject_Join"
+	MsgTypeSetTags          = "Set_Tags"
+	MsgTypeBroadcastToTag   = "Broadcast_To_Tag"
+	MsgTypeBlockClient      = "Block_Client"
+	MsgTypeUnblockClient    = "Unblock_Client"
+	MsgTypeRoomMessage      = "Room_Message"
+	MsgTypeTypingStart      = "Typing_Start"
+	MsgTypeTypingStop       = "Typing_Stop"
+	MsgTypeFileOffer        = "File_Offer"
+	MsgTypeFileAccept       = "File_Accept"
+	MsgTypeFileReject       = "File_Reject"
+	MsgTypeFileRelay        = "File_Relay"
+	MsgTypeFileChunk        = "File_Chunk"
+	MsgTypeMediaState       = "Media_State"
+	MsgTypeRecordingStarted = "Recording_Started"
+	MsgTypeRecordingStopped = "Recording_Stopped"
+	MsgTypeMyRooms          = "My_Rooms"
+	MsgTypeFindRoom         = "Find_Room"
+	MsgTypeGetChallenge     = "Get_Challenge"
+	MsgTypeRequestFloor     = "Request_Floor"
+	MsgTypeGrantFloor       = "Grant_Floor"
+	MsgTypeReleaseFloor     = "Release_Floor"
+	MsgTypeRaiseHand        = "Raise_Hand"
+	MsgTypeLowerHand        = "Lower_Hand"
+	MsgTypeCreatePoll       = "Create_Poll"
+	MsgTypeVote             = "Vote"
+	MsgTypeClosePoll        = "Close_Poll"
+	MsgTypeTimeSync         = "Time_Sync"
+	MsgTypePingPeer         = "Ping_Peer"
+	MsgTypePongPeer         = "Pong_Peer"
+	MsgTypeCallInvite       = "Call_Invite"
+	MsgTypeCallRinging      = "Call_Ringing"
+	MsgTypeCallAccept       = "Call_Accept"
+	MsgTypeCallReject       = "Call_Reject"
+	MsgTypeCallCancel       = "Call_Cancel"
+	MsgTypeCallEnd          = "Call_End"
+	MsgTypeSetBusyPolicy    = "Set_Busy_Policy"
+	MsgTypeAck              = "Ack"
 )
 
 var upgrader = websocket.Upgrader{
 	ReadBufferSize:  2048,
 	WriteBufferSize: 2048,
-	CheckOrigin: func(r *http.Request) bool {
-		return true // Allow all connections by default
-	},
+	Subprotocols:    protocol.Subprotocols(),
+	CheckOrigin:     checkOrigin,
 }
 
 var (
@@ -58,18 +134,154 @@ var (
 	mu      sync.Mutex
 )
 
-// ServerDocs serves the Markdown documentation as an HTML page.
-// It reads the Markdown file located at "docs/docs.md", converts it to HTML using Goldmark,
-// and then renders it using an HTML template located at "public/index.html".
-func ServerDocs(writer http.ResponseWriter, request *http.Request) {
-	// Load the Markdown file
-	mdFile := "docs/docs.md"
-	mdContent, err := os.ReadFile(mdFile)
+var (
+	// current is the configuration of the server, swapped whole by a reload.
+	current   atomic.Pointer[config.Config]
+	webhooks  *webhook.Dispatcher
+	roomStore store.Store
+	proxies   *proxy.Resolver
+	locator   *geo.Locator
+)
+
+// defaults is the configuration until Init is called.
+var defaults = config.Default()
+
+// cfg returns the current configuration. Read it once when settings must
+// agree, a reload can happen between two calls.
+func cfg() *config.Config {
+	if settings := current.Load(); settings != nil {
+		return settings
+	}
+	return defaults
+}
+
+// Init applies the configuration to the server. It must be called before
+// the server starts accepting connections.
+func Init(settings *config.Config) error {
+	current.Store(settings)
+	if cfg().RecordSessionsDir != "" {
+		if err := os.MkdirAll(cfg().RecordSessionsDir, 0o700); err != nil {
+			return err
+		}
+		logger.Warn("RECORD_SESSIONS_DIR is set, the messages of every WebSocket connection are written to ", cfg().RecordSessionsDir)
+	}
+	if cfg().FakeClock {
+		clk = clock.NewFake(time.Now())
+		logger.Warn("FAKE_CLOCK is set, the time only moves through /admin/clock")
+	}
+	upgrader.EnableCompression = cfg().CompressionEnabled
+	var err error
+	webhooks = webhook.NewDispatcher(cfg().WebhookURLs, cfg().WebhookSecret, cfg().WebhookMaxRetries, cfg().WebhookTimeout)
+	roomCallbacks = webhook.NewDispatcher(nil, cfg().WebhookSecret, cfg().WebhookMaxRetries, cfg().WebhookTimeout)
+
+	if docsPage, err = renderDocs(); err != nil {
+		return err
+	}
+	if newClientIdentifier, err = ids.NewGenerator(cfg().ClientIdFormat); err != nil {
+		return err
+	}
+	if authorizer, err = newAuthorizer(); err != nil {
+		return err
+	}
+	if userDirectory, err = newDirectory(); err != nil {
+		return err
+	}
+	pushNotifier = newPushNotifier()
+	if cfg().OIDCIssuer != "" {
+		if operators, err = newOperators(); err != nil {
+			return err
+		}
+	} else if cfg().OIDCProtectDocs {
+		return errors.New("OIDC_PROTECT_DOCS needs OIDC_ISSUER")
+	}
+	if cfg().MessageWorkers < 1 || cfg().InboundQueueDepth < 1 {
+		return errors.New("MESSAGE_WORKERS and INBOUND_QUEUE_DEPTH must be at least 1")
+	}
+	resolver, err := proxy.NewResolver(cfg().TrustedProxies)
 	if err != nil {
-		http.Error(writer, "Could not read Markdown file", http.StatusInternalServerError)
-		return
+		return err
+	}
+	proxies = resolver
+	if cfg().ClientInfoEnabled && cfg().GeoIPDatabase != "" {
+		if locator, err = geo.Open(cfg().GeoIPDatabase); err != nil {
+			return err
+		}
+	}
+
+	if regionTurnURLs, err = parseTurnRegions(cfg().TurnRegionURLs); err != nil {
+		return err
+	}
+	if err := checkCapacityThresholds(cfg().CapacityThresholds); err != nil {
+		return err
+	}
+	if err := checkDuplicatePolicy(cfg().DuplicateConnections); err != nil {
+		return err
+	}
+
+	if cfg().RoomTemplatesFile != "" {
+		if roomTemplates, err = loadRoomTemplates(cfg().RoomTemplatesFile); err != nil {
+			return err
+		}
+	}
+
+	if cfg().StorePath != "" {
+		boltStore, err := store.OpenBolt(cfg().StorePath)
+		if err != nil {
+			return err
+		}
+		roomStore = boltStore
+		if err := restoreRooms(); err != nil {
+			return err
+		}
+		if err := restoreBans(); err != nil {
+			return err
+		}
+		if err := restoreArchives(); err != nil {
+			return err
+		}
+	}
+
+	if cfg().EventStreamURL != "" {
+		sink, err := events.ConnectNATS(cfg().EventStreamURL, cfg().EventStreamSubject)
+		if err != nil {
+			return err
+		}
+		eventSink = sink
+		if cfg().EventStreamInterval > 0 {
+			go runRelayCounts(cfg().EventStreamInterval)
+		}
+	}
+
+	if cfg().JanitorInterval > 0 {
+		go runJanitor(cfg().JanitorInterval)
+	}
+	startWorkers(cfg().MessageWorkers)
+	return nil
+}
+
+// emitEvent notifies the configured webhooks and the event stream about a server event.
+func emitEvent(eventType string, data map[string]interface{}) {
+	webhooks.Emit(eventType, data)
+	publishEvent(eventType, data)
+}
+
+// docsPage is the documentation rendered by renderDocs.
+var docsPage []byte
+
+// ServerDocs serves the Markdown documentation as an HTML page, rendered once at startup.
+func ServerDocs(writer http.ResponseWriter, request *http.Request) {
+	if cfg().OIDCProtectDocs && operators != nil {
+		if _, ok := checkLoggedIn(writer, request); !ok {
+			return
+		}
 	}
+	writer.Header().Set("Content-Type", "text/html; charset=utf-8")
+	writer.Write(docsPage)
+}
 
+// renderDocs converts the embedded Markdown documentation to HTML using Goldmark,
+// and renders it in the embedded "index.html" template.
+func renderDocs() ([]byte, error) {
 	// Convert Markdown to HTML using Goldmark
 	var buf bytes.Buffer
 	md := goldmark.New(
@@ -84,17 +296,14 @@ func ServerDocs(writer http.ResponseWriter, request *http.Request) {
 			),
 		),
 	)
-
-	if err := md.Convert(mdContent, &buf); err != nil {
-		http.Error(writer, "Could not convert Markdown to HTML", http.StatusInternalServerError)
-		return
+	if err := md.Convert(docs.Markdown, &buf); err != nil {
+		return nil, fmt.Errorf("could not convert the docs to HTML: %w", err)
 	}
 
 	// Load and parse the HTML template
-	tmpl, err := template.ParseFiles("public/index.html")
+	tmpl, err := template.ParseFS(public.Templates, "index.html")
 	if err != nil {
-		http.Error(writer, "Could not parse template", http.StatusInternalServerError)
-		return
+		return nil, fmt.Errorf("could not parse the docs template: %w", err)
 	}
 
 	// Execute the template with the HTML content
@@ -103,11 +312,11 @@ func ServerDocs(writer http.ResponseWriter, request *http.Request) {
 	}{
 		Content: template.HTML(buf.String()), // Safely inject the HTML content
 	}
-
-	if err := tmpl.Execute(writer, data); err != nil {
-		http.Error(writer, "Could not execute template", http.StatusInternalServerError)
-		return
+	var page bytes.Buffer
+	if err := tmpl.Execute(&page, data); err != nil {
+		return nil, fmt.Errorf("could not execute the docs template: %w", err)
 	}
+	return page.Bytes(), nil
 }
 
 // HandleWebSocketConnection handles WebSocket connections.
@@ -115,54 +324,202 @@ func ServerDocs(writer http.ResponseWriter, request *http.Request) {
 // and starts reading messages from the client. It also handles client disconnection
 // and cleans up resources.
 func HandleWebSocketConnection(writer http.ResponseWriter, request *http.Request) {
+	if refuseWhileDraining(writer) || !acquireConnection(writer) {
+		return
+	}
+	defer releaseConnection()
+	// Client connected add to clients with new Id seperating all clients,
+	// unless it resumes the Id of a connection that dropped
+	clientId, resumed := resumeMembership(request.URL.Query().Get("resume"))
+	if !resumed {
+		clientId, resumed = resumeUnacked(request.URL.Query().Get("resume"))
+	}
+	if !resumed {
+		var err error
+		clientId, err = requestedClientId(request)
+		if refuseClientId(writer, err) {
+			return
+		}
+	}
+	userId, claims, err := authenticate(request)
+	if refuseClientId(writer, err) {
+		return
+	}
+	if refuseClientId(writer, checkUserConnection(userId, request.URL.Query().Get("device"))) {
+		return
+	}
+	version, err := protocol.ForVersion(request.URL.Query().Get("version"))
+	if refuseClientId(writer, err) {
+		return
+	}
+	if isBanned("", clientId, proxies.ClientIP(request)) {
+		refuseClientId(writer, errBanned)
+		return
+	}
 	connection, error := upgrader.Upgrade(writer, request, nil)
 	if error != nil {
 		logger.Error("Failed to upgrade connection")
 		return
 	}
-	logger.Infof("Connection from: %s \n", connection.RemoteAddr())
-
-	// Client connected add to clients with new Id seperating all clients
-	clientId := shortuuid.New()
+	clientLogger := logging.ForClient(clientId)
+	clientLogger.Infof("Connection from: %s (%s)", proxies.ClientIP(request), proxies.Scheme(request))
+	if cfg().CompressionEnabled {
+		if err := connection.SetCompressionLevel(cfg().CompressionLevel); err != nil {
+			clientLogger.Warn("Invalid compression level: ", err)
+		}
+	}
 	client := &client.Client{
-		Id:         clientId,
-		Connection: connection,
+		Id:                   clientId,
+		Connection:           connection,
+		Status:               client.StatusOnline,
+		Codec:                protocol.ForSubprotocol(connection.Subprotocol()),
+		Version:              version,
+		UserID:               userId,
+		Claims:               claims,
+		Profile:              lookupProfile(request.Context(), clientId, userId),
+		Observe:              tapOutgoing,
+		OutboundDepth:        cfg().OutboundQueueDepth,
+		OutboundMax:          cfg().OutboundQueueMax,
+		OnSlowConsumer:       warnSlowConsumer,
+		OnOverflow:           disconnectSlowConsumer,
+		Acks:                 wantsAcks(request),
+		CompressionThreshold: cfg().CompressionThreshold,
+	}
+	sessionRecorder := startRecording(clientId, request, connection)
+	if sessionRecorder != nil {
+		defer sessionRecorder.Close()
+		client.Connection = &recordedConnection{Conn: connection, recorder: sessionRecorder}
+	}
+	client.Start()
+	if resumed {
+		// the held signalling is numbered again from 1 on the new connection
+		dropSignalStreams(clientId)
+	}
+	if err := registerClient(client, request); err != nil {
+		clientLogger.Warn("Connection refused: ", err)
+		connection.WriteControl(websocket.CloseMessage,
+			websocket.FormatCloseMessage(websocket.ClosePolicyViolation, err.Error()), time.Now().Add(time.Second))
+		client.Close()
+		return
+	}
+	if resumed {
+		announceReconnection(client)
+		retransmitUnacked(client)
 	}
-	//Adding client to clients map.
-	mu.Lock()
-	clients[clientId] = client
-	mu.Unlock()
-	logger.Info("Client Added : ", clientId)
 
 	//need and closed the connection and clean up
 	defer func() {
-		removeClientFromRoom(clientId, true)
-		err := connection.Close()
+		unregisterClient(clientId)
+		err := client.Close()
 		if err != nil {
-			logger.Error("Failed to close WebSocket connection:", err)
+			clientLogger.Error("Failed to close WebSocket connection: ", err)
 		}
-		logger.Info("WebSocket connection closed for client :", clientId)
+		clientLogger.Info("WebSocket connection closed")
 	}()
 
-	// send the clientId back to client
-	error = connection.WriteJSON(responsemessage.InfoMessage(
-		"Client_Details",
-		map[string]interface{}{"id": clientId},
-	))
-	if error != nil {
-		logger.Error("Write Json Error", error)
-	}
-
-	// Read messages from all the client and create go routines for them
+	// Read messages from the client, the workers handle them in order
+	messages := newInbox(client, cfg().InboundQueueDepth)
 	for {
-		_, message, err := connection.ReadMessage()
+		messageType, message, err := connection.ReadMessage()
 		if err != nil {
-			logger.Error("Read error:", err)
+			clientLogger.Error("Read error: ", err)
 			break
 		}
-		// Handle all messages
-		go handleMessage(client, message)
+		if sessionRecorder != nil {
+			sessionRecorder.Record(recorder.In, messageType, message)
+		}
+		// Handle all messages, binary frames are relayed as is unless
+		// the client negotiated a binary codec for its messages.
+		if messageType == websocket.BinaryMessage && client.GetCodec().FrameType() != websocket.BinaryMessage {
+			messages.push(handleBinaryMessage, message)
+		} else {
+			messages.push(handleMessage, message)
+		}
+	}
+}
+
+// registerClient adds a newly connected client to the clients map,
+// notifies the webhooks and sends the client its details and the calls its
+// user missed. It fails if another client took the Id in the meantime, or if
+// the duplicate connection policy refuses another connection of the user.
+func registerClient(client *client.Client, request *http.Request) error {
+	remoteAddr := proxies.ClientIP(request)
+	var location *geo.Location
+	if cfg().ClientInfoEnabled {
+		location = locator.Lookup(remoteAddr)
+	}
+
+	//Adding client to clients map.
+	mu.Lock()
+	if _, taken := clients[client.GetClientId()]; taken {
+		mu.Unlock()
+		return errClientIdTaken
+	}
+	superseded, err := claimUserConnection(client, request.URL.Query().Get("device"))
+	if err != nil {
+		mu.Unlock()
+		return err
+	}
+	claimMembership(request.URL.Query().Get("resume"), client.GetClientId())
+	client.MarkActive(clk.Now())
+	client.Name = displayName(request.URL.Query().Get("name"))
+	client.ResumeToken = shortuuid.New()
+	client.ConnectedAt = clk.Now()
+	client.IP = remoteAddr
+	if cfg().ClientInfoEnabled {
+		client.RemoteAddr = remoteAddr
+		client.UserAgent = request.UserAgent()
+		client.Location = location
+	}
+	clients[client.GetClientId()] = client
+	mu.Unlock()
+	logging.ForClient(client.Id).Info("Client added")
+	emitEvent(EventClientConnected, map[string]interface{}{"client": client.GetClientId(), "remote_addr": remoteAddr})
+
+	// send the clientId back to client
+	details := map[string]interface{}{
+		"id":           client.GetClientId(),
+		"resume_token": client.ResumeToken,
+		"version":      client.GetVersion().Name(),
+		"versions":     protocol.Versions(),
+	}
+	if client.GetProfile() != nil {
+		details["profile"] = client.GetProfile()
 	}
+	if client.GetDevice() != "" {
+		details["device"] = client.GetDevice()
+	}
+	if client.Acks {
+		details["acks"] = true
+	}
+	err = client.SendAs(responsemessage.InfoMessage("Client_Details", details), sessionPriority, nil)
+	if err != nil {
+		logging.ForClient(client.Id).Error("Write Json Error: ", err)
+	}
+	supersede(superseded, client)
+	deliverMissedCalls(client)
+	return nil
+}
+
+// unregisterClient removes a disconnected client from its rooms and from the clients map.
+func unregisterClient(clientId string) {
+	withdrawJoinRequests(clientId)
+	dropCalls(clientId)
+	dropFileRelays(clientId)
+	dropSignalStreams(clientId)
+	holdUnacked(clientId)
+	mu.Lock()
+	var userId string
+	if leaving, ok := clients[clientId]; ok {
+		userId = leaving.UserID
+	}
+	mu.Unlock()
+	seeUser(userId)
+	if holdMembership(clientId) {
+		return
+	}
+	removeClientFromRoom(clientId, true)
+	emitEvent(EventClientDisconnected, map[string]interface{}{"client": clientId})
 }
 
 // removeClient removes a client from the clients map by its client ID.
@@ -172,16 +529,41 @@ func removeClient(clientID string) {
 	mu.Lock()
 	delete(clients, clientID)
 	mu.Unlock()
-	logger.Info("Client removed:  %s \n", clientID)
+	logging.ForClient(clientID).Info("Client removed")
 }
 
 // handleMessage processes incoming messages from clients based on their event.
 // It routes the messages to appropriate handlers for connection, room management, and relaying messages.
 func handleMessage(client *client.Client, message []byte) {
+	if relayFast(client, message) {
+		return
+	}
 	var json_msg map[string]interface{}
-	parseErr := json.Unmarshal(message, &json_msg)
+	parseErr := client.GetCodec().Unmarshal(message, &json_msg)
 	if parseErr != nil {
-		logger.Error("Failed to parse JSON: ", message)
+		logging.ForClient(client.Id).Errorf("Failed to parse %s message: %v", client.GetCodec().Name(), parseErr)
+		if client.GetCodec().FrameType() == websocket.BinaryMessage {
+			// binary envelopes can't be told apart from the messages
+			client.Send(responsemessage.ErrorMessage("Invalid_Message", map[string]interface{}{"message": "Binary frames must hold " + client.GetCodec().Name() + " messages, binary envelopes need the json encoding"}))
+		}
+		return
+	}
+	if json_msg, parseErr = client.GetVersion().Decode(json_msg); parseErr != nil {
+		logging.ForClient(client.Id).Debugf("Failed to decode version %s message: %v", client.GetVersion().Name(), parseErr)
+		client.Send(responsemessage.ErrorMessage("Invalid_Message", map[string]interface{}{"message": parseErr.Error()}))
+		return
+	}
+	entry := logging.ForClient(client.Id).WithField(logging.FieldMessageType, json_msg["event"])
+	if cfg().LogPayloads {
+		entry = entry.WithField(logging.FieldPayload, json_msg)
+	}
+	entry.Debug("Message received")
+	tapIncoming(client, json_msg, len(message))
+	markActive(client)
+	if !accountTraffic(client, json_msg, len(message)) {
+		return
+	}
+	if !checkDuplicate(client, json_msg) {
 		return
 	}
 
@@ -196,10 +578,79 @@ func handleMessage(client *client.Client, message []byte) {
 		handleLeaveRoomMessage(client, json_msg)
 	case MsgTypeEndRoom:
 		handleEndRoomMessage(client, json_msg)
-	case MsgTypeOffer, MsgTypeAnswer, MsgTypeCandidate, MsgTypeMessage:
+	case MsgTypeOffer, MsgTypeAnswer, MsgTypeCandidate, MsgTypeCandidates, MsgTypeMessage,
+		MsgTypeFileOffer, MsgTypeFileAccept, MsgTypeFileReject:
 		relayMessageToTarget(client, json_msg)
+	case MsgTypeGetIceServers:
+		handleGetIceServersMessage(client, json_msg)
+	case MsgTypeSetStatus:
+		handleSetStatusMessage(client, json_msg)
+	case MsgTypeInvite:
+		handleInviteMessage(client, json_msg)
+	case MsgTypeLockRoom, MsgTypeUnlockRoom:
+		handleLockRoomMessage(client, json_msg)
+	case MsgTypeRecordingStarted, MsgTypeRecordingStopped:
+		handleRecordingMessage(client, json_msg)
+	case MsgTypeFindRoom:
+		handleFindRoomMessage(client, json_msg)
+	case MsgTypeMyRooms:
+		handleMyRoomsMessage(client, json_msg)
+	case MsgTypeGetChallenge:
+		handleGetChallengeMessage(client, json_msg)
+	case MsgTypeGetTopology:
+		handleGetTopologyMessage(client, json_msg)
+	case MsgTypeSetCapabilities:
+		handleSetCapabilitiesMessage(client, json_msg)
+	case MsgTypeMediaState:
+		handleMediaStateMessage(client, json_msg)
+	case MsgTypePublishKey:
+		handlePublishKeyMessage(client, json_msg)
+	case MsgTypeRotateKey:
+		handleRotateKeyMessage(client, json_msg)
+	case MsgTypeReportStats:
+		handleReportStatsMessage(client, json_msg)
+	case MsgTypeBroadcast, MsgTypeBroadcastToTag, MsgTypeRoomMessage:
+		handleBroadcastMessage(client, json_msg)
+	case MsgTypeSetModerator:
+		handleSetModeratorMessage(client, json_msg)
+	case MsgTypeApproveJoin, MsgTypeRejectJoin:
+		handleJoinDecisionMessage(client, json_msg)
+	case MsgTypeSetTags:
+		handleSetTagsMessage(client, json_msg)
+	case MsgTypeFileRelay:
+		handleFileRelayMessage(client, json_msg)
+	case MsgTypeFileChunk:
+		handleFileChunkMessage(client, json_msg)
+	case MsgTypeTypingStart, MsgTypeTypingStop:
+		handleTypingMessage(client, json_msg)
+	case MsgTypeBlockClient, MsgTypeUnblockClient:
+		handleBlockMessage(client, json_msg)
+	case MsgTypeRequestFloor, MsgTypeGrantFloor, MsgTypeReleaseFloor:
+		handleFloorMessage(client, json_msg)
+	case MsgTypeRaiseHand, MsgTypeLowerHand:
+		handleHandMessage(client, json_msg)
+	case MsgTypeCreatePoll:
+		handleCreatePollMessage(client, json_msg)
+	case MsgTypeVote, MsgTypeClosePoll:
+		handlePollMessage(client, json_msg)
+	case MsgTypeTimeSync:
+		handleTimeSyncMessage(client, json_msg)
+	case MsgTypePingPeer:
+		handlePingPeerMessage(client, json_msg)
+	case MsgTypePongPeer:
+		handlePongPeerMessage(client, json_msg)
+	case MsgTypeCallInvite:
+		handleCallInviteMessage(client, json_msg)
+	case MsgTypeCallRinging, MsgTypeCallAccept, MsgTypeCallReject, MsgTypeCallCancel:
+		handleCallMessage(client, json_msg)
+	case MsgTypeCallEnd:
+		handleCallEndMessage(client, json_msg)
+	case MsgTypeSetBusyPolicy:
+		handleSetBusyPolicyMessage(client, json_msg)
+	case MsgTypeAck:
+		handleAckMessage(client, json_msg)
 	default:
-		client.GetConnection().WriteJSON(responsemessage.ErrorMessage("Unsupported_Event", map[string]interface{}{
+		client.Send(responsemessage.ErrorMessage("Unsupported_Event", map[string]interface{}{
 			"events": []string{
 				MsgTypeConnect,
 				MsgTypeCreateRoom,
@@ -209,7 +660,59 @@ func handleMessage(client *client.Client, message []byte) {
 				MsgTypeOffer,
 				MsgTypeAnswer,
 				MsgTypeCandidate,
+				MsgTypeCandidates,
 				MsgTypeMessage,
+				MsgTypeGetIceServers,
+				MsgTypeSetStatus,
+				MsgTypeInvite,
+				MsgTypeLockRoom,
+				MsgTypeUnlockRoom,
+				MsgTypeGetTopology,
+				MsgTypeSetCapabilities,
+				MsgTypePublishKey,
+				MsgTypeRotateKey,
+				MsgTypeReportStats,
+				MsgTypeBroadcast,
+				MsgTypeSetModerator,
+				MsgTypeApproveJoin,
+				MsgTypeRejectJoin,
+				MsgTypeSetTags,
+				MsgTypeBroadcastToTag,
+				MsgTypeBlockClient,
+				MsgTypeUnblockClient,
+				MsgTypeRoomMessage,
+				MsgTypeTypingStart,
+				MsgTypeTypingStop,
+				MsgTypeFileOffer,
+				MsgTypeFileAccept,
+				MsgTypeFileReject,
+				MsgTypeFileRelay,
+				MsgTypeFileChunk,
+				MsgTypeMediaState,
+				MsgTypeRecordingStarted,
+				MsgTypeRecordingStopped,
+				MsgTypeMyRooms,
+				MsgTypeFindRoom,
+				MsgTypeGetChallenge,
+				MsgTypeRequestFloor,
+				MsgTypeGrantFloor,
+				MsgTypeReleaseFloor,
+				MsgTypeRaiseHand,
+				MsgTypeLowerHand,
+				MsgTypeCreatePoll,
+				MsgTypeVote,
+				MsgTypeClosePoll,
+				MsgTypeTimeSync,
+				MsgTypePingPeer,
+				MsgTypePongPeer,
+				MsgTypeCallInvite,
+				MsgTypeCallRinging,
+				MsgTypeCallAccept,
+				MsgTypeCallReject,
+				MsgTypeCallCancel,
+				MsgTypeCallEnd,
+				MsgTypeSetBusyPolicy,
+				MsgTypeAck,
 			},
 		},
 		))
@@ -233,8 +736,21 @@ func handleConnectMessage(client *client.Client, message map[string]interface{})
 	targetClient, exists := clients[targetID]
 	mu.Unlock()
 	if !exists {
-		logger.Debugf("Target client %s not found \n.", targetID)
-		client.GetConnection().WriteJSON(responsemessage.ErrorMessage("Not_Found", map[string]interface{}{"message": "Client with given " + targetID + " not found"}))
+		// a dropped client may resume its session, its signalling is held
+		targetClient, exists = unackedClient(targetID)
+	}
+	if !exists {
+		logging.ForClient(client.Id).Debugf("Target client %s not found", targetID)
+		client.Send(responsemessage.ErrorMessage("Not_Found", map[string]interface{}{"message": "Client with given " + targetID + " not found"}))
+		return
+	}
+	if refuseBlocked(client, targetClient) {
+		return
+	}
+	if !checkAuthorized(client, MsgTypeConnect, authorizer.CanRelay(subjectOf(client), MsgTypeConnect, "", targetID)) {
+		return
+	}
+	if refuseBusy(client, targetClient, MsgTypeConnect) {
 		return
 	}
 
@@ -242,7 +758,7 @@ func handleConnectMessage(client *client.Client, message map[string]interface{})
 	data, ok := message["data"].(map[string]interface{})
 	if !ok {
 		logger.Debugf("'data' field is missing or not a map")
-		client.GetConnection().WriteJSON(responsemessage.ErrorMessage("Missing_Fields", map[string]interface{}{"message": "'data' field is missing or is not object in the request."}))
+		client.Send(responsemessage.ErrorMessage("Missing_Fields", map[string]interface{}{"message": "'data' field is missing or is not object in the request."}))
 		return
 	}
 
@@ -250,14 +766,17 @@ func handleConnectMessage(client *client.Client, message map[string]interface{})
 	sdp, sdpExists := data["sdp"]
 	if !sdpExists {
 		logger.Debug("'data''sdp' field is missing or nil")
-		client.GetConnection().WriteJSON(responsemessage.ErrorMessage("Missing_Fields", map[string]interface{}{"message": "'data''sdp' field is missing in the request."}))
+		client.Send(responsemessage.ErrorMessage("Missing_Fields", map[string]interface{}{"message": "'data''sdp' field is missing in the request."}))
+		return
+	}
+	if !checkSDP(client, data) {
 		return
 	}
 
 	// Check if "candidate" exists
 	candidate, candidateExists := data[MsgTypeCandidate]
 	if !candidateExists {
-		client.GetConnection().WriteJSON(responsemessage.ErrorMessage("Missing_Fields", map[string]interface{}{"message": "'data''sdp' field is missing in the request."}))
+		client.Send(responsemessage.ErrorMessage("Missing_Fields", map[string]interface{}{"message": "'data''sdp' field is missing in the request."}))
 		logger.Debug("'data''candidate' field is missing or nil")
 		return
 	}
@@ -265,14 +784,42 @@ func handleConnectMessage(client *client.Client, message map[string]interface{})
 	connectMsg := map[string]interface{}{
 		"event": MsgTypeOffer,
 		"from":  client.Id,
+		"role":  negotiationRole(targetID, client.Id),
 		"data": map[string]interface{}{
 			"sdp":       sdp,
 			"candidate": candidate,
 		},
 	}
-	if err := targetClient.GetConnection().WriteJSON(responsemessage.InfoMessage(MsgTypeOffer, connectMsg)); err != nil {
-		logger.Debugf("Failed to send connect request to target client %s: %v \n.", targetID, err)
+	// numbered like a relayed offer, the candidates sent next are relayed after it
+	err := relaySignal(client.Id, targetClient, func(seq uint64) error {
+		connectMsg["seq"] = seq
+		offer := responsemessage.InfoMessage(MsgTypeOffer, connectMsg)
+		if err := targetClient.SendAs(offer, relayPriority(MsgTypeOffer), nil); err != nil && !targetClient.Acks {
+			return err
+		}
+		retainUnacked(targetClient, unackedMessage{
+			id:      offer.MessageID,
+			message: offer,
+			from:    client.Id,
+			renumber: func(seq uint64) interface{} {
+				renumbered := offer
+				data := maps.Clone(connectMsg)
+				data["seq"] = seq
+				renumbered.Data = data
+				return renumbered
+			},
+		})
+		return nil
+	})
+	if err != nil {
+		logging.ForClient(client.Id).Debugf("Failed to send connect request to target client %s: %v", targetID, err)
+		return
 	}
+	// the sender learns its own role for the perfect negotiation with the target
+	client.Send(responsemessage.InfoMessage("Negotiation_Role", map[string]interface{}{
+		"peer": targetID,
+		"role": negotiationRole(client.Id, targetID),
+	}))
 }
 
 // handleCreateRoomMessage processes a "create_room" message.
@@ -284,7 +831,11 @@ func handleCreateRoomMessage(client *client.Client, msg map[string]interface{})
 	data, dataOk := msg["data"].(map[string]interface{})
 	if !dataOk {
 		logger.Debug("'data' field is missing or not a map")
-		client.GetConnection().WriteJSON(responsemessage.ErrorMessage("Missing_Fields", map[string]interface{}{"message": "'data' field is missing or is not object in the request."}))
+		client.Send(responsemessage.ErrorMessage("Missing_Fields", map[string]interface{}{"message": "'data' field is missing or is not object in the request."}))
+		return
+	}
+	// settings bundled in a template, optional
+	if data, dataOk = applyRoomTemplate(client, data); !dataOk {
 		return
 	}
 
@@ -294,6 +845,9 @@ func handleCreateRoomMessage(client *client.Client, msg map[string]interface{})
 	}
 
 	from := client.GetClientId()
+	if !checkAuthorized(client, MsgTypeCreateRoom, authorizer.CanCreateRoom(subjectOf(client), roomId)) {
+		return
+	}
 
 	//get the room name if exits, optional
 	roomName, ok := data["name"].(string)
@@ -301,35 +855,130 @@ func handleCreateRoomMessage(client *client.Client, msg map[string]interface{})
 		roomName = ""
 	}
 
+	// limit on clients in the room, optional
+	maxClients, ok := intField(data, "max_clients")
+	if !ok || maxClients < 0 {
+		maxClients = 0
+	}
+
+	// password to join the room, optional
+	password, _ := data["password"].(string)
+
+	// lifetime of the room in seconds, optional
+	expiresIn, ok := intField(data, "expires_in")
+	if !ok || expiresIn < 0 {
+		expiresIn = 0
+	}
+	expiresIn = min(expiresIn, maxRoomLifetime)
+
+	// keep the room across restarts, optional
+	persistent, _ := data["persistent"].(bool)
+	metadata, _ := data["metadata"].(map[string]interface{})
+
+	// retain messages for new joiners, optional
+	history, _ := data["history"].(bool)
+
+	// let the server pick who offers to whom on join, optional
+	autoNegotiate, _ := data["auto_negotiate"].(bool)
+
+	// only the creator and moderators may broadcast, optional
+	announceOnly, _ := data["announce_only"].(bool)
+
+	// end the room once the creator is gone, optional
+	endWithCreator, _ := data["end_when_creator_leaves"].(bool)
+
+	// joining clients wait for a moderator to approve them, optional
+	requireApproval, _ := data["approval"].(bool)
+
+	// the room can be found by name, optional
+	public, _ := data["public"].(bool)
+
+	// URL receiving the events of the room, optional
+	callbackURL, _ := data["callback_url"].(string)
+	if callbackURL != "" && !validCallbackURL(callbackURL) {
+		client.Send(responsemessage.ErrorMessage("Invalid_Callback", map[string]interface{}{"message": "'callback_url' must be an http(s) URL on a host allowed by the server."}))
+		return
+	}
+
+	// where the members are, optional
+	region, _ := data["region"].(string)
+	if region != "" && !regionPattern.MatchString(region) {
+		client.Send(responsemessage.ErrorMessage("Invalid_Region", map[string]interface{}{"message": "'region' must be lowercase letters, digits and dashes, like eu-west."}))
+		return
+	}
+
+	if !checkNotDraining(client) || !checkRoomLimits(client, true) {
+		return
+	}
+
 	// check if room Id already exists
 	// is it better to expose this id already exist or give new id?
 	mu.Lock()
-	myRoom, exists := rooms[roomId]
+	_, exists := rooms[roomId]
 	mu.Unlock()
-	if !exists {
-		//if does not exist create one and add it
-		mu.Lock()
-		myRoom = room.NewRoom(roomId, roomName, from)
-		rooms[roomId] = myRoom
+	if exists {
+		duplicateRoom(client, from, roomId)
+		return
+	}
+	// the challenge is used up last, so a request refused anyway doesn't cost a new one
+	if !checkProofOfWork(client, data) {
+		return
+	}
+
+	myRoom := room.NewRoom(roomId, roomName, from)
+	myRoom.SetMaxClients(maxClients)
+	if expiresIn > 0 {
+		myRoom.SetExpiry(clk.Now().Add(time.Duration(expiresIn) * time.Second))
+	}
+	myRoom.SetPersistent(persistent)
+	myRoom.SetMetadata(metadata)
+	if history {
+		myRoom.EnableHistory(cfg().HistorySize, cfg().HistoryMaxBytes)
+	}
+	myRoom.SetAutoNegotiate(autoNegotiate)
+	myRoom.SetAnnounceOnly(announceOnly)
+	myRoom.SetEndsWithCreator(endWithCreator)
+	myRoom.SetRequireApproval(requireApproval)
+	myRoom.SetPublic(public)
+	myRoom.SetCallbackURL(callbackURL)
+	myRoom.SetRegion(region)
+	if err := myRoom.SetPassword(password); err != nil {
+		logging.ForRoom(from, roomId).Error("Failed to hash room password: ", err)
+		return
+	}
+	mu.Lock()
+	// another client may have taken the Id meanwhile
+	if _, exists := rooms[roomId]; exists {
 		mu.Unlock()
-		logger.Info("Creating room with ID: ", roomId)
-	} else {
-		logger.Debug("Failed to create room (Already exists) ID: ", roomId)
-		client.GetConnection().WriteJSON(
-			responsemessage.ErrorMessage(
-				"Duplicate_Room", map[string]interface{}{"message": roomId + " already exist"}))
+		duplicateRoom(client, from, roomId)
 		return
 	}
+	rooms[roomId] = myRoom
+	indexRoom(myRoom)
+	assignJoinCode(myRoom)
+	mu.Unlock()
+	logging.ForRoom(from, roomId).Info("Room created")
+	persistRoom(myRoom)
+	emitEvent(EventRoomCreated, map[string]interface{}{"room": roomId, "name": roomName, "creator": from})
 
 	// if we created room
 	// now send all the client id in this room to all clients
-	err := client.GetConnection().WriteJSON(responsemessage.InfoMessage("Room_Created", map[string]interface{}{"clients": myRoom.GetClients(), "room": roomId, "name": myRoom.GetName()}))
+	err := client.Send(responsemessage.InfoMessage("Room_Created", roomDetails(myRoom)))
 	if err != nil {
-		logger.Debug("Failed to send all clients details to: ", client.Id)
+		logging.ForRoom(from, roomId).Debug("Failed to send all clients details")
 	}
+	sendRegionalIceServers(client, myRoom)
 
 }
 
+// duplicateRoom tells client that roomId is taken.
+func duplicateRoom(client *client.Client, from string, roomId string) {
+	logging.ForRoom(from, roomId).Debug("Failed to create room (Already exists)")
+	client.Send(
+		responsemessage.ErrorMessage(
+			"Duplicate_Room", map[string]interface{}{"message": roomId + " already exist"}))
+}
+
 // handleEndRoomMessage processes an "end_room" message.
 // It verifies the client's permission to delete the room, sends a notification to
 // all clients in the room, and removes the room from the rooms map if it is empty.
@@ -340,21 +989,32 @@ func handleEndRoomMessage(client *client.Client, msg map[string]interface{}) {
 	data := msg["data"].(map[string]interface{})
 	from := client.GetClientId()
 	roomId, _ := data["room"].(string)
-	room := rooms[roomId]
+	mu.Lock()
+	room, exists := rooms[roomId]
+	var creator string
+	if exists {
+		creator = room.GetCreator()
+	}
+	mu.Unlock()
+	if !exists {
+		client.Send(responsemessage.ErrorMessage("Not_Found", map[string]interface{}{"message": "Room with Id " + roomId + " does not exist."}))
+		return
+	}
 
-	if room.GetCreator() != from {
-		logger.Debug("You don't have permissions to delete room: ", roomId)
-		client.GetConnection().WriteJSON(responsemessage.ErrorMessage("Unauthorised", map[string]interface{}{"message": "You need to be creator of room to delete it."}))
+	if !checkAuthorized(client, MsgTypeEndRoom, authorizer.CanEndRoom(subjectOf(client), roomId)) {
+		return
+	}
+	if creator != from {
+		logging.ForRoom(from, roomId).Debug("You don't have permissions to delete room")
+		client.Send(responsemessage.ErrorMessage("Unauthorised", map[string]interface{}{"message": "You need to be creator of room to delete it."}))
 		return
 	}
 
 	notifyUpdateIntheRoom(roomId, "Room_Deleted")
 
 	// after all the checks actually delete the room
-	mu.Lock()
-	delete(rooms, roomId)
-	mu.Unlock()
-	logger.Info("Room Deleted: ", roomId)
+	deleteRoom(roomId, "ended")
+	logging.ForRoom(from, roomId).Info("Room deleted")
 
 }
 
@@ -362,27 +1022,99 @@ func handleEndRoomMessage(client *client.Client, msg map[string]interface{}) {
 // It checks if the room exists, verifies that the client is not already in the room,
 // adds the client to the room, and notifies all clients in the room about the new client.
 func handleJoinRoomMessage(client *client.Client, msg map[string]interface{}) {
+	resolveJoinCode(msg)
 	if !checkRoomInJSON(client, msg) {
 		return
 	}
 	data := msg["data"].(map[string]interface{})
 	// room exist here
 	roomId, _ := data["room"].(string)
-	// room should exist as well
-	myRoom := rooms[roomId]
 	from := client.GetClientId()
+	mu.Lock()
+	myRoom, exists := rooms[roomId]
+	var member, full bool
+	var maxClients int
+	if exists {
+		member = slices.Contains(myRoom.GetClients(), from)
+		full = myRoom.IsFull()
+		maxClients = myRoom.GetMaxClients()
+	}
+	mu.Unlock()
+	if !exists {
+		client.Send(responsemessage.ErrorMessage("Not_Found", map[string]interface{}{"message": "Room with Id " + roomId + " does not exist."}))
+		return
+	}
+	if !checkAuthorized(client, MsgTypeJoinRoom, authorizer.CanJoinRoom(subjectOf(client), roomId)) || !checkNotBanned(client, roomId) {
+		return
+	}
 
 	// check client already in the room.
-	if slices.Contains(myRoom.GetClients(), roomId) {
-		client.GetConnection().WriteJSON(responsemessage.ErrorMessage("Already_Exists", map[string]interface{}{"message": "Client already exists in the room."}))
+	if member {
+		client.Send(responsemessage.ErrorMessage("Already_Exists", map[string]interface{}{"message": "Client already exists in the room."}))
+		return
+	} else if full {
+		logging.ForRoom(from, roomId).Debug("Room is full")
+		client.Send(responsemessage.ErrorMessage("Room_Full", map[string]interface{}{"message": "Room " + roomId + " is full."}))
+		emitEvent(EventRoomFull, map[string]interface{}{"room": roomId, "client": from, "max_clients": maxClients})
+		return
+	} else if !checkNotDraining(client) || !checkRoomLimits(client, false) {
 		return
 	} else {
+		// an invite token issued for this client bypasses the lock and the password
+		token, _ := data["token"].(string)
+		password, _ := data["password"].(string)
+		invited := false
 		mu.Lock()
-		myRoom.AddClient(from)
-		logger.Infof("Client (%s) added to Room (%s)", from, roomId)
+		if token != "" {
+			invited = myRoom.UseInvite(token, from)
+		}
+		locked := myRoom.IsLocked()
+		passwordHash := myRoom.PasswordHash
 		mu.Unlock()
-		// notify all clients in this room about the new clients in the room.
-		notifyUpdateIntheRoom(roomId, "Client_Added")
+		if !invited && locked {
+			logging.ForRoom(from, roomId).Debug("Room is locked")
+			client.Send(responsemessage.ErrorMessage("Room_Locked", map[string]interface{}{"message": "Room " + roomId + " is locked."}))
+			return
+		}
+		if !invited && !room.CheckPasswordHash(passwordHash, password) {
+			logging.ForRoom(from, roomId).Debug("Invalid room password")
+			client.Send(responsemessage.ErrorMessage("Invalid_Password", map[string]interface{}{"message": "Password is missing or incorrect."}))
+			return
+		}
+
+		mu.Lock()
+		approval := !invited && myRoom.RequiresApproval() && !myRoom.IsModerator(from)
+		mu.Unlock()
+		if approval {
+			requestJoinApproval(client, myRoom)
+			return
+		}
+		admitClient(client, myRoom)
+	}
+}
+
+// admitClient adds the client to the room and brings it up to date, unless the
+// room was deleted in the meantime.
+func admitClient(client *client.Client, myRoom *room.Room) {
+	from := client.GetClientId()
+	mu.Lock()
+	if rooms[myRoom.GetId()] != myRoom {
+		mu.Unlock()
+		client.Send(responsemessage.ErrorMessage("Not_Found", map[string]interface{}{"message": "Room with Id " + myRoom.GetId() + " does not exist."}))
+		return
+	}
+	myRoom.AddClient(from)
+	autoNegotiate := myRoom.IsAutoNegotiate()
+	logging.ForRoom(from, myRoom.GetId()).Info("Client added to room")
+	mu.Unlock()
+	persistRoom(myRoom)
+	notifyRoomCallback(myRoom, CallbackClientJoined, map[string]interface{}{"client": from})
+	// notify all clients in this room about the new clients in the room.
+	notifyUpdateIntheRoom(myRoom.GetId(), "Client_Added")
+	sendRegionalIceServers(client, myRoom)
+	sendRoomHistory(client, myRoom)
+	if autoNegotiate {
+		negotiateWithMembers(myRoom, from)
 	}
 }
 
@@ -398,21 +1130,29 @@ func handleLeaveRoomMessage(client *client.Client, msg map[string]interface{}) {
 	// room should exist here
 	roomId, _ := data["room"].(string)
 	//check if client in room
-	room := rooms[roomId]
-	if slices.Contains(room.GetClients(), from) {
+	mu.Lock()
+	room, exists := rooms[roomId]
+	member := exists && slices.Contains(room.GetClients(), from)
+	mu.Unlock()
+	if !exists {
+		client.Send(responsemessage.ErrorMessage("Not_Found", map[string]interface{}{"message": "Room with Id " + roomId + " does not exist."}))
+		return
+	}
+	if member {
 		removeClientFromRoom(from, false, roomId)
-		client.GetConnection().WriteJSON(responsemessage.InfoMessage("Room_Left", map[string]interface{}{"room": roomId}))
+		client.Send(responsemessage.InfoMessage("Room_Left", map[string]interface{}{"room": roomId}))
 	} else {
-		client.GetConnection().WriteJSON(responsemessage.ErrorMessage("Not_Found", map[string]interface{}{"message": "Client does not exists in the room."}))
+		client.Send(responsemessage.ErrorMessage("Not_Found", map[string]interface{}{"message": "Client does not exists in the room."}))
 	}
-	logger.Infof("%s left room %s \n", from, room.GetId())
+	logging.ForRoom(from, roomId).Info("Client left room")
 
 	//if room is empty delete it.
-	if len(room.GetClients()) == 0 {
-		mu.Lock()
-		delete(rooms, roomId)
-		mu.Unlock()
-		logger.Infof("room %s deleted as it was empty \n", roomId)
+	mu.Lock()
+	empty := rooms[roomId] == room && len(room.GetClients()) == 0 && !room.IsPersistent()
+	mu.Unlock()
+	if empty {
+		deleteRoom(roomId, "empty")
+		logging.ForRoom(from, roomId).Info("Room deleted as it was empty")
 	}
 }
 
@@ -424,42 +1164,145 @@ func checkRoomInJSON(client *client.Client, msg map[string]interface{}) bool {
 	data, dataOk := msg["data"].(map[string]interface{})
 	if !dataOk {
 		logger.Debug("'data' field is missing or not a map")
-		client.GetConnection().WriteJSON(responsemessage.ErrorMessage("Missing_Fields", map[string]interface{}{"message": "'data' field is missing or is not object in the request."}))
+		client.Send(responsemessage.ErrorMessage("Missing_Fields", map[string]interface{}{"message": "'data' field is missing or is not object in the request."}))
 		return false
 	}
 	// check if room exist
 	roomId, ok := data["room"].(string)
 	if !ok {
 		logger.Debug("You need room Id to join room.")
-		client.GetConnection().WriteJSON(responsemessage.ErrorMessage("Missing_Fields", map[string]interface{}{"message": "'room' field is missing in the request."}))
+		client.Send(responsemessage.ErrorMessage("Missing_Fields", map[string]interface{}{"message": "'room' field is missing in the request."}))
 		return false
 	}
 
 	// check if room with given exists, if yes then add.
+	mu.Lock()
 	_, exists := rooms[roomId]
+	mu.Unlock()
 	if !exists {
-		logger.Debugf("Room does not exist: %s\n", roomId)
-		client.GetConnection().WriteJSON(responsemessage.ErrorMessage("Not_Found", map[string]interface{}{"message": "Room with Id " + roomId + " does not exist."}))
+		logging.ForRoom(client.Id, roomId).Debug("Room does not exist")
+		client.Send(responsemessage.ErrorMessage("Not_Found", map[string]interface{}{"message": "Room with Id " + roomId + " does not exist."}))
 		return false
 	}
 	return true
 
 }
 
+// intField reads a numeric field from a decoded message as an int.
+// JSON decodes numbers as float64 while MessagePack and CBOR keep integer types.
+func intField(data map[string]interface{}, key string) (int, bool) {
+	switch value := data[key].(type) {
+	case float64:
+		return int(value), true
+	case float32:
+		return int(value), true
+	case int:
+		return value, true
+	case int8:
+		return int(value), true
+	case int16:
+		return int(value), true
+	case int32:
+		return int(value), true
+	case int64:
+		return int(value), true
+	case uint8:
+		return int(value), true
+	case uint16:
+		return int(value), true
+	case uint32:
+		return int(value), true
+	case uint64:
+		return int(value), true
+	}
+	return 0, false
+}
+
+// floatField reads a numeric field of data as a float64.
+func floatField(data map[string]interface{}, key string) (float64, bool) {
+	switch value := data[key].(type) {
+	case float64:
+		return value, true
+	case float32:
+		return float64(value), true
+	}
+	if value, ok := intField(data, key); ok {
+		return float64(value), true
+	}
+	return 0, false
+}
+
+// deleteRoom removes the room from the rooms map and notifies the webhooks.
+// The reason tells why the room went away, e.g. "ended" or "empty".
+func deleteRoom(roomId string, reason string) {
+	mu.Lock()
+	myRoom, exists := rooms[roomId]
+	var summary map[string]interface{}
+	var archived *store.ArchiveRecord
+	if exists {
+		releaseJoinCode(myRoom)
+		summary = summarizeRoom(myRoom, reason)
+		archived = archiveRecord(myRoom, reason, summary)
+	}
+	delete(rooms, roomId)
+	roomIndex.Remove(roomId)
+	mu.Unlock()
+	if exists {
+		notifyRoomCallback(myRoom, CallbackRoomDeleted, map[string]interface{}{"reason": reason})
+		unpersistRoom(roomId)
+		dropRoomStats(roomId)
+		emitEvent(EventRoomDeleted, map[string]interface{}{"room": roomId, "reason": reason})
+		emitRoomSummary(summary)
+		archiveRoom(archived)
+	}
+}
+
+// checkRoomMembers checks that roomId names an existing room that both the client
+// and the target are members of, and reports the problem to the client otherwise.
+func checkRoomMembers(client *client.Client, roomIdValue interface{}, targetID string) bool {
+	roomId, ok := roomIdValue.(string)
+	if !ok {
+		client.Send(responsemessage.ErrorMessage("Missing_Fields", map[string]interface{}{"message": "'room' field must be a room Id."}))
+		return false
+	}
+	mu.Lock()
+	myRoom, exists := rooms[roomId]
+	bothMembers := exists &&
+		slices.Contains(myRoom.GetClients(), client.GetClientId()) &&
+		slices.Contains(myRoom.GetClients(), targetID)
+	mu.Unlock()
+
+	if !exists {
+		client.Send(responsemessage.ErrorMessage("Not_Found", map[string]interface{}{"message": "Room with Id " + roomId + " does not exist."}))
+		return false
+	}
+	if !bothMembers {
+		logging.ForRoom(client.Id, roomId).Debugf("Refusing to relay to %s outside the room", targetID)
+		client.Send(responsemessage.ErrorMessage("Unauthorised", map[string]interface{}{"message": "Both clients need to be in room " + roomId + "."}))
+		return false
+	}
+	return true
+}
+
 // relayMessageToTarget forwards a message to the target client specified in the message.
 // It ensures that the target client exists and relays the message, handling various events.
+// Messages addressed to a user with "to_user" instead are fanned out by relayToUser.
 func relayMessageToTarget(client *client.Client, msg map[string]interface{}) {
 	targetID, ok := msg["to"].(string)
+	if userId, toUser := msg["to_user"].(string); !ok && toUser {
+		relayToUser(client, msg, userId)
+		return
+	}
 	if !ok {
 		logger.Debug("'to' not found in message.")
-		client.GetConnection().WriteJSON(responsemessage.ErrorMessage("Missing_Fields", map[string]interface{}{"message": "'to' field not found"}))
+		client.Send(responsemessage.ErrorMessage("Missing_Fields", map[string]interface{}{"message": "'to' field not found"}))
 		return
 	}
 
 	msgtype, ok2 := msg["event"].(string)
 	if !ok2 {
 		logger.Debug("'event' not found in message.")
-		client.GetConnection().WriteJSON(responsemessage.ErrorMessage("Missing_Fields", map[string]interface{}{"message": "'event' field not found"}))
+		client.Send(responsemessage.ErrorMessage("Missing_Fields", map[string]interface{}{"message": "'event' field not found"}))
 		return
 	}
 
@@ -467,39 +1310,214 @@ func relayMessageToTarget(client *client.Client, msg map[string]interface{}) {
 	targetClient, exists := clients[targetID]
 	mu.Unlock()
 	if !exists {
-		logger.Debugf("Target client %s not found. \n", targetID)
-		client.GetConnection().WriteJSON(responsemessage.ErrorMessage("Not_Found", map[string]interface{}{"message": "Client with given " + targetID + " not found"}))
+		// a dropped client may resume its session, its signalling is held
+		targetClient, exists = unackedClient(targetID)
+	}
+	if !exists {
+		logging.ForClient(client.Id).Debugf("Target client %s not found", targetID)
+		client.Send(responsemessage.ErrorMessage("Not_Found", map[string]interface{}{"message": "Client with given " + targetID + " not found"}))
+		return
+	}
+
+	if refuseBlocked(client, targetClient) {
+		return
+	}
+	roomId, _ := msg["room"].(string)
+	if !checkAuthorized(client, msgtype, authorizer.CanRelay(subjectOf(client), msgtype, roomId, targetID)) {
+		return
+	}
+
+	// with "room" addressing both clients must be members of the room
+	if _, scoped := msg["room"]; scoped && !checkRoomMembers(client, msg["room"], targetID) {
+		return
+	}
+
+	if msgtype == MsgTypeCandidates && !checkCandidates(client, msg) {
+		return
+	}
+	if !checkEncrypted(client, msg) {
 		return
 	}
+	ttl, ok := checkTTL(client, msg)
+	if !ok {
+		return
+	}
+	deadline := relayDeadline(client.GetClientId(), targetID, msgtype, ttl)
+	if (msgtype == MsgTypeOffer || msgtype == MsgTypeAnswer) && !checkSDP(client, msg["data"]) {
+		return
+	}
+	if (msgtype == MsgTypeFileOffer || msgtype == MsgTypeFileAccept || msgtype == MsgTypeFileReject) && !checkFileTransfer(client, msg) {
+		return
+	}
+	switch msgtype {
+	case MsgTypeFileAccept:
+		transferId, _ := msg["data"].(map[string]interface{})["id"].(string)
+		acceptFileRelay(targetID, client.GetClientId(), transferId)
+	case MsgTypeFileReject:
+		transferId, _ := msg["data"].(map[string]interface{})["id"].(string)
+		cancelFileRelay(targetID, client.GetClientId(), transferId)
+	}
 
 	switch msgtype {
-	case MsgTypeOffer, MsgTypeAnswer, MsgTypeCandidate, MsgTypeMessage:
+	case MsgTypeCandidate:
+		delete(msg, "to")
+		msg["from"] = client.GetClientId()
+		if cfg().CandidateDebounce > 0 {
+			queueCandidate(targetClient, msg, deadline)
+		} else if err := relaySignal(client.GetClientId(), targetClient, sendSignal(targetClient, msg, deadline)); err != nil {
+			logging.ForClient(client.Id).Debugf("Failed to relay candidate to target client %s: %v", targetID, err)
+		} else {
+			countRelay(1)
+		}
+	case MsgTypeOffer, MsgTypeAnswer, MsgTypeCandidates:
+		delete(msg, "to")
+		msg["from"] = client.GetClientId()
+		if err := relaySignal(client.GetClientId(), targetClient, sendSignal(targetClient, msg, deadline)); err != nil {
+			logging.ForClient(client.Id).Debugf("Failed to relay %s to target client %s: %v", msgtype, targetID, err)
+		} else {
+			countRelay(1)
+		}
+	case MsgTypeMessage, MsgTypeFileOffer, MsgTypeFileAccept, MsgTypeFileReject:
 		delete(msg, "to")
 		msg["from"] = client.GetClientId()
-		if err := targetClient.GetConnection().WriteJSON(msg); err != nil {
-			logger.Debugf("Failed to relay message to target client %s: %v \n", targetID, err)
+		if err := targetClient.SendAs(msg, relayPriority(msgtype), deadline); err != nil {
+			logging.ForClient(client.Id).Debugf("Failed to relay message to target client %s: %v", targetID, err)
+		} else {
+			countRelay(1)
 		}
 	default:
 		logger.Debug("Unsupportedevent: ", msg["event"])
 	}
 }
 
+// sessionPriority is the outbound lane of the messages opening or resuming a
+// session, so they are written before the messages relayed in the meantime.
+const sessionPriority = client.PriorityHigh
+
+// relayPriority is the outbound lane of a relayed message: the signalling
+// setting up a call jumps ahead of chat and files on a congested connection.
+func relayPriority(event string) client.Priority {
+	switch event {
+	case MsgTypeOffer, MsgTypeAnswer, MsgTypeCandidate, MsgTypeCandidates:
+		return client.PriorityHigh
+	case MsgTypeMessage, MsgTypeBroadcast, MsgTypeBroadcastToTag, MsgTypeRoomMessage,
+		MsgTypeTypingStart, MsgTypeTypingStop,
+		MsgTypeFileOffer, MsgTypeFileAccept, MsgTypeFileReject, MsgTypeFileChunk:
+		return client.PriorityLow
+	}
+	return client.PriorityNormal
+}
+
 // notifyUpdateIntheRoom sends an update notification to all clients in the specified room.
 // It informs clients about changes such as client addition or removal.
 func notifyUpdateIntheRoom(roomId string, message string) {
+	mu.Lock()
 	room, ok := rooms[roomId]
+	var members []string
+	if ok {
+		members = slices.Clone(room.GetClients())
+	}
+	mu.Unlock()
 	if !ok {
 		logger.Debug("Room Id not found: ", roomId)
+		return
 	}
+	retapRoom(roomId)
 	// notify all clients in this room about the update
-	for _, clientIdItem := range room.GetClients() {
-		clientInRoom, ok := clients[clientIdItem]
-		if ok {
-			clientInRoom.GetConnection().WriteJSON(responsemessage.UpdateMessage(
-				message,
-				map[string]interface{}{"clients": room.GetClients(), "room": room.GetId(), "name": room.GetName()}))
+	update := responsemessage.UpdateMessage(message, roomDetails(room))
+	for _, clientInRoom := range connectedClients(members) {
+		clientInRoom.Send(update)
+	}
+}
+
+// roomDetails builds the room payload shared by room responses and updates.
+// "members" carries per client details such as the presence status.
+func roomDetails(room *room.Room) map[string]interface{} {
+	mu.Lock()
+	defer mu.Unlock()
+	members := make([]map[string]interface{}, 0, len(room.GetClients()))
+	for _, clientId := range room.GetClients() {
+		member := map[string]interface{}{"id": clientId, "status": StatusDisconnected}
+		if clientInRoom, ok := clients[clientId]; ok {
+			member["status"] = clientInRoom.GetStatus()
+			if clientInRoom.GetName() != "" {
+				member["name"] = clientInRoom.GetName()
+			}
+			if clientInRoom.GetProfile() != nil {
+				member["profile"] = clientInRoom.GetProfile()
+			}
+			if clientInRoom.GetCapabilities() != nil {
+				member["capabilities"] = clientInRoom.GetCapabilities()
+			}
+			if clientInRoom.GetMedia() != nil {
+				member["media"] = clientInRoom.GetMedia()
+			}
+			if len(clientInRoom.GetTags()) > 0 {
+				member["tags"] = clientInRoom.GetTags()
+			}
+			if clientInRoom.GetPublicKey() != "" {
+				member["public_key"] = clientInRoom.GetPublicKey()
+			}
 		}
+		members = append(members, member)
 	}
+	details := map[string]interface{}{
+		"clients":                 room.GetClients(),
+		"members":                 members,
+		"room":                    room.GetId(),
+		"name":                    room.GetName(),
+		"protected":               room.HasPassword(),
+		"locked":                  room.IsLocked(),
+		"persistent":              room.IsPersistent(),
+		"history":                 room.GetHistory() != nil,
+		"auto_negotiate":          room.IsAutoNegotiate(),
+		"key_epoch":               room.GetKeyEpoch(),
+		"announce_only":           room.IsAnnounceOnly(),
+		"end_when_creator_leaves": room.EndsWithCreator(),
+		"approval":                room.RequiresApproval(),
+		"moderators":              append([]string{}, room.GetModerators()...),
+		"recording":               room.IsRecording(),
+		"public":                  room.IsPublic(),
+		"join_code":               room.GetJoinCode(),
+		"floor":                   room.GetFloorHolder(),
+		"region":                  room.GetRegion(),
+	}
+	if room.GetMetadata() != nil {
+		details["metadata"] = room.GetMetadata()
+	}
+	if len(room.GetPolls()) > 0 {
+		details["polls"] = pollResults(room)
+	}
+	if left, expires := room.TimeLeft(clk.Now()); expires {
+		details["expires_in"] = int(left.Seconds())
+	}
+	return details
+}
+
+// roomsOfClient returns the rooms the client is a member of.
+func roomsOfClient(clientId string) []*room.Room {
+	mu.Lock()
+	defer mu.Unlock()
+	var memberOf []*room.Room
+	for _, roomItem := range rooms {
+		if slices.Contains(roomItem.GetClients(), clientId) {
+			memberOf = append(memberOf, roomItem)
+		}
+	}
+	return memberOf
+}
+
+// connectedClients resolves client ids to the clients that are currently connected.
+func connectedClients(clientIds []string) []*client.Client {
+	mu.Lock()
+	defer mu.Unlock()
+	connected := make([]*client.Client, 0, len(clientIds))
+	for _, clientId := range clientIds {
+		if clientItem, ok := clients[clientId]; ok {
+			connected = append(connected, clientItem)
+		}
+	}
+	return connected
 }
 
 // removeClientFromRoom removes a client from a specified room or all rooms if no room ID is provided.
@@ -510,44 +1528,57 @@ func removeClientFromRoom(clientId string, deleteClient bool, roomIds ...string)
 		return false, errors.New("invalid args passed, second argument should be roomId.")
 	}
 	if len(roomIds) == 1 {
+		mu.Lock()
 		room, ok := rooms[roomIds[0]]
+		_, ok2 := clients[clientId]
+		mu.Unlock()
 		if !ok {
 			logger.Debug("Room Id not found: ", roomIds[0])
 		}
-		_, ok2 := clients[clientId]
 		if !ok2 {
 			logger.Debug("Client Id not found", clientId)
 		}
-		// first remove client from the room
-		mu.Lock()
-		room.RemoveClient(clientId)
-		mu.Unlock()
+		if ok && !endRoomWithCreator(room, clientId) {
+			// first remove client from the room
+			mu.Lock()
+			raisedHand := slices.Contains(room.GetRaisedHands(), clientId)
+			room.RemoveClient(clientId)
+			mu.Unlock()
+			persistRoom(room)
+			notifyRoomCallback(room, CallbackClientLeft, map[string]interface{}{"client": clientId})
 
-		// notify all clients in this room about the update
-		notifyUpdateIntheRoom(roomIds[0], "Client_Removed")
+			// notify all clients in this room about the update
+			notifyUpdateIntheRoom(roomIds[0], "Client_Removed")
+			if raisedHand {
+				notifyHandQueue(room)
+			}
+		}
 	}
 	// if we did not pass room Id we have to find from which room to delete
 	// if client closed it's connection, we need to find of they are in room if yes delete
-	if len(roomIds) == 0 && len(rooms) > 0 {
-		logger.Debug("Searching and deleting client from room")
-		for _, roomItem := range rooms {
-			for _, clientInRoom := range roomItem.GetClients() {
-				if clientInRoom == clientId {
-					notifyUpdateIntheRoom(roomItem.GetId(), "Client_Removed")
-					mu.Lock()
-					roomItem.RemoveClient(clientId)
-					mu.Unlock()
-					//delete room if clients empty
-					if len(roomItem.GetClients()) == 0 {
-						mu.Lock()
-						delete(rooms, roomItem.GetId())
-						mu.Unlock()
-						logger.Infof("Room %s deleted because it was empty", roomItem.GetId())
-					}
-					break
-				}
+	// the rooms are listed first, deleting them while ranging over the map would race
+	if len(roomIds) == 0 {
+		logging.ForClient(clientId).Debug("Searching and deleting client from room")
+		for _, roomItem := range roomsOfClient(clientId) {
+			if endRoomWithCreator(roomItem, clientId) {
+				continue
+			}
+			notifyUpdateIntheRoom(roomItem.GetId(), "Client_Removed")
+			mu.Lock()
+			raisedHand := slices.Contains(roomItem.GetRaisedHands(), clientId)
+			roomItem.RemoveClient(clientId)
+			empty := len(roomItem.GetClients()) == 0 && !roomItem.IsPersistent()
+			mu.Unlock()
+			persistRoom(roomItem)
+			notifyRoomCallback(roomItem, CallbackClientLeft, map[string]interface{}{"client": clientId})
+			if raisedHand {
+				notifyHandQueue(roomItem)
+			}
+			//delete room if clients empty
+			if empty {
+				deleteRoom(roomItem.GetId(), "empty")
+				logging.ForRoom(clientId, roomItem.GetId()).Info("Room deleted because it was empty")
 			}
-
 		}
 	}
 	if deleteClient {
@@ -555,3 +1586,19 @@ func removeClientFromRoom(clientId string, deleteClient bool, roomIds ...string)
 	}
 	return true, nil
 }
+
+// endRoomWithCreator ends the room when clientId is its creator and the room
+// was created with "end_when_creator_leaves", so it doesn't run headless. It
+// returns false when the room goes on.
+func endRoomWithCreator(myRoom *room.Room, clientId string) bool {
+	mu.Lock()
+	ends := myRoom.GetCreator() == clientId && myRoom.EndsWithCreator()
+	mu.Unlock()
+	if !ends {
+		return false
+	}
+	notifyUpdateIntheRoom(myRoom.GetId(), "Room_Deleted")
+	deleteRoom(myRoom.GetId(), "creator_left")
+	logging.ForRoom(clientId, myRoom.GetId()).Info("Room ended as its creator left")
+	return true
+}
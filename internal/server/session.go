@@ -0,0 +1,260 @@
+package server
+
+import (
+	"crypto/subtle"
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/lithammer/shortuuid"
+	"github.com/shankarammai/Peer2PeerConnector/internal/client"
+	"github.com/shankarammai/Peer2PeerConnector/internal/logging"
+	"github.com/shankarammai/Peer2PeerConnector/internal/protocol"
+)
+
+var (
+	errTransportClosed = errors.New("transport closed")
+	errQueueFull       = errors.New("outgoing queue is full")
+	errBinaryFrame     = errors.New("binary frames can't be delivered over HTTP")
+)
+
+// queueTransport buffers the messages of a client connected over HTTP until
+// the client fetches them.
+type queueTransport struct {
+	mu       sync.Mutex
+	frames   []queuedFrame
+	ready    chan struct{}
+	closed   chan struct{}
+	maxQueue int
+	lastSeen time.Time
+}
+
+func newQueueTransport(maxQueue int) *queueTransport {
+	return &queueTransport{
+		ready:    make(chan struct{}, 1),
+		closed:   make(chan struct{}),
+		maxQueue: maxQueue,
+		lastSeen: clk.Now(),
+	}
+}
+
+// queuedFrame is a message waiting to be fetched, dropped when its deadline
+// passes first.
+type queuedFrame struct {
+	data     []byte
+	deadline *client.Deadline
+}
+
+func (transport *queueTransport) WriteMessage(messageType int, data []byte) error {
+	return transport.WriteMessageBefore(messageType, data, nil)
+}
+
+// WriteMessageBefore queues a message the client must fetch before the deadline.
+func (transport *queueTransport) WriteMessageBefore(messageType int, data []byte, deadline *client.Deadline) error {
+	if messageType == websocket.BinaryMessage {
+		return errBinaryFrame
+	}
+	transport.mu.Lock()
+	defer transport.mu.Unlock()
+	select {
+	case <-transport.closed:
+		return errTransportClosed
+	default:
+	}
+	if transport.maxQueue > 0 && len(transport.frames) >= transport.maxQueue {
+		return errQueueFull
+	}
+	transport.frames = append(transport.frames, queuedFrame{data: data, deadline: deadline})
+	select {
+	case transport.ready <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+func (transport *queueTransport) Close() error {
+	transport.mu.Lock()
+	defer transport.mu.Unlock()
+	select {
+	case <-transport.closed:
+	default:
+		close(transport.closed)
+	}
+	return nil
+}
+
+// take returns the queued frames and empties the queue. The frames whose
+// deadline passed are dropped.
+func (transport *queueTransport) take() [][]byte {
+	transport.mu.Lock()
+	transport.lastSeen = clk.Now()
+	queued := transport.frames
+	transport.frames = nil
+	transport.mu.Unlock()
+
+	frames := make([][]byte, 0, len(queued))
+	for _, frame := range queued {
+		if frame.deadline.Passed() {
+			frame.deadline.Expired()
+			continue
+		}
+		frames = append(frames, frame.data)
+	}
+	return frames
+}
+
+// wait blocks until frames are queued, the transport closes, done is closed or timeout elapses.
+func (transport *queueTransport) wait(timeout time.Duration, done <-chan struct{}) [][]byte {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	for {
+		if frames := transport.take(); len(frames) > 0 {
+			return frames
+		}
+		select {
+		case <-transport.ready:
+		case <-transport.closed:
+			return transport.take()
+		case <-done:
+			return nil
+		case <-timer.C:
+			return nil
+		}
+	}
+}
+
+func (transport *queueTransport) isClosed() bool {
+	select {
+	case <-transport.closed:
+		return true
+	default:
+		return false
+	}
+}
+
+// touch records that the client is still polling.
+func (transport *queueTransport) touch() {
+	transport.mu.Lock()
+	transport.lastSeen = clk.Now()
+	transport.mu.Unlock()
+}
+
+func (transport *queueTransport) idleSince() time.Time {
+	transport.mu.Lock()
+	defer transport.mu.Unlock()
+	return transport.lastSeen
+}
+
+// queued returns the number of frames waiting to be fetched.
+func (transport *queueTransport) queued() int {
+	transport.mu.Lock()
+	defer transport.mu.Unlock()
+	return len(transport.frames)
+}
+
+// httpSession is a client connected through an HTTP fallback transport.
+// Requests of the session are authenticated with its token.
+type httpSession struct {
+	client    *client.Client
+	transport *queueTransport
+	token     string
+}
+
+var (
+	sessions   = make(map[string]*httpSession)
+	sessionsMu sync.Mutex
+)
+
+// openSession registers a new client reached through a queueTransport.
+func openSession(request *http.Request) (*httpSession, error) {
+	clientId, err := requestedClientId(request)
+	if err != nil {
+		return nil, err
+	}
+	userId, claims, err := authenticate(request)
+	if err != nil {
+		return nil, err
+	}
+	if isBanned("", clientId, proxies.ClientIP(request)) {
+		return nil, errBanned
+	}
+	version, err := protocol.ForVersion(request.URL.Query().Get("version"))
+	if err != nil {
+		return nil, err
+	}
+	transport := newQueueTransport(cfg().PollQueueSize)
+	session := &httpSession{
+		client: &client.Client{
+			Id:         clientId,
+			Connection: transport,
+			Status:     client.StatusOnline,
+			Codec:      protocol.JSON,
+			Version:    version,
+			UserID:     userId,
+			Claims:     claims,
+			Profile:    lookupProfile(request.Context(), clientId, userId),
+			Observe:    tapOutgoing,
+		},
+		transport: transport,
+		token:     shortuuid.New(),
+	}
+	if err := registerClient(session.client, request); err != nil {
+		return nil, err
+	}
+	sessionsMu.Lock()
+	sessions[session.client.GetClientId()] = session
+	sessionsMu.Unlock()
+	return session, nil
+}
+
+// closeSession disconnects the client of the session.
+func closeSession(session *httpSession) {
+	sessionsMu.Lock()
+	_, exists := sessions[session.client.GetClientId()]
+	delete(sessions, session.client.GetClientId())
+	sessionsMu.Unlock()
+	if !exists {
+		return
+	}
+	session.transport.Close()
+	unregisterClient(session.client.GetClientId())
+	logging.ForClient(session.client.Id).Info("HTTP session closed")
+}
+
+// sessionFromRequest finds the session named by the "id" query parameter and checks
+// the token passed as a bearer token or in the "token" query parameter.
+func sessionFromRequest(request *http.Request) (*httpSession, bool) {
+	token := request.URL.Query().Get("token")
+	if header := request.Header.Get("Authorization"); strings.HasPrefix(header, "Bearer ") {
+		token = strings.TrimPrefix(header, "Bearer ")
+	}
+	sessionsMu.Lock()
+	session, exists := sessions[request.URL.Query().Get("id")]
+	sessionsMu.Unlock()
+	if !exists || subtle.ConstantTimeCompare([]byte(session.token), []byte(token)) != 1 {
+		return nil, false
+	}
+	return session, true
+}
+
+// expireSessions closes the sessions whose client stopped polling.
+func expireSessions(now time.Time) {
+	if cfg().PollSessionTimeout <= 0 {
+		return
+	}
+	sessionsMu.Lock()
+	var expired []*httpSession
+	for _, session := range sessions {
+		if now.Sub(session.transport.idleSince()) > cfg().PollSessionTimeout {
+			expired = append(expired, session)
+		}
+	}
+	sessionsMu.Unlock()
+	for _, session := range expired {
+		logging.ForClient(session.client.Id).Info("HTTP session expired")
+		closeSession(session)
+	}
+}
@@ -0,0 +1,42 @@
+package server
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/shankarammai/Peer2PeerConnector/internal/logging"
+	"github.com/shankarammai/Peer2PeerConnector/internal/recorder"
+)
+
+// recordedConnection is a WebSocket connection whose frames sent to the client are recorded.
+type recordedConnection struct {
+	*websocket.Conn
+	recorder *recorder.Recorder
+}
+
+func (connection *recordedConnection) WriteMessage(messageType int, data []byte) error {
+	connection.recorder.Record(recorder.Out, messageType, data)
+	return connection.Conn.WriteMessage(messageType, data)
+}
+
+// startRecording records the WebSocket connection of the client in
+// RECORD_SESSIONS_DIR, it returns nil when sessions aren't recorded or the
+// recording can't be created.
+func startRecording(clientId string, request *http.Request, connection *websocket.Conn) *recorder.Recorder {
+	if cfg().RecordSessionsDir == "" {
+		return nil
+	}
+	sessionRecorder, err := recorder.Create(cfg().RecordSessionsDir, recorder.Header{
+		Client:      clientId,
+		Query:       request.URL.RawQuery,
+		Subprotocol: connection.Subprotocol(),
+		StartedAt:   time.Now(),
+	})
+	if err != nil {
+		logging.ForClient(clientId).Warn("Failed to record the session: ", err)
+		return nil
+	}
+	logging.ForClient(clientId).Debug("Recording the session in ", sessionRecorder.Name())
+	return sessionRecorder
+}
@@ -0,0 +1,362 @@
+package server
+
+import (
+	"net/http"
+	"sync"
+
+	"github.com/shankarammai/Peer2PeerConnector/internal/client"
+	"github.com/shankarammai/Peer2PeerConnector/internal/ice"
+	"github.com/shankarammai/Peer2PeerConnector/internal/protocol"
+	responsemessage "github.com/shankarammai/Peer2PeerConnector/internal/response"
+	"github.com/shankarammai/Peer2PeerConnector/internal/spec"
+	"github.com/shankarammai/Peer2PeerConnector/internal/store"
+)
+
+// The types below describe the messages clients send, for the spec. The
+// handlers read the same fields from the decoded JSON.
+
+// dataMessage is a message carrying its fields in "data".
+type dataMessage[T any] struct {
+	Data T `json:"data" spec:"required"`
+}
+
+// relayMessage is a message relayed to the client "to".
+type relayMessage[T any] struct {
+	To   string `json:"to" spec:"required" doc:"Id of the receiving client."`
+	Data T      `json:"data"`
+}
+
+// signalMessage is a message relayed to the client "to" with an optional time to live.
+type signalMessage[T any] struct {
+	To    string `json:"to" spec:"required" doc:"Id of the receiving client."`
+	TTLMs int    `json:"ttl_ms,omitempty" doc:"Milliseconds within which the message must be delivered, it is dropped otherwise."`
+	Data  T      `json:"data"`
+}
+
+// userMessage is a message relayed to the client "to" or to the devices of the user "to_user".
+type userMessage[T any] struct {
+	To     string `json:"to,omitempty" doc:"Id of the receiving client."`
+	ToUser string `json:"to_user,omitempty" doc:"User Id whose connected devices all receive the message, instead of \"to\"."`
+	TTLMs  int    `json:"ttl_ms,omitempty" doc:"Milliseconds within which the message must be delivered, it is dropped otherwise."`
+	Data   T      `json:"data"`
+}
+
+// callInviteMessage rings the client "to" or the devices of the user "to_user".
+type callInviteMessage struct {
+	To     string         `json:"to,omitempty" doc:"Id of the receiving client."`
+	ToUser string         `json:"to_user,omitempty" doc:"User Id whose connected devices all ring, instead of \"to\"."`
+	Data   callInviteData `json:"data"`
+}
+
+// roomBroadcast is a message sent to the members of a room.
+type roomBroadcast struct {
+	Room string      `json:"room" spec:"required"`
+	Data interface{} `json:"data"`
+}
+
+type tagBroadcast struct {
+	Room string      `json:"room" spec:"required"`
+	Tag  string      `json:"tag" spec:"required" doc:"Only the members carrying the tag receive the message."`
+	Data interface{} `json:"data"`
+}
+
+type roomRef struct {
+	Room string `json:"room" spec:"required"`
+}
+
+type roomMember struct {
+	Room   string `json:"room" spec:"required"`
+	Client string `json:"client" spec:"required"`
+}
+
+type lowerHandData struct {
+	Room   string `json:"room" spec:"required"`
+	Client string `json:"client,omitempty" doc:"Member whose hand a moderator lowers, the client itself when missing."`
+}
+
+type createPollData struct {
+	Room     string   `json:"room" spec:"required"`
+	Question string   `json:"question" spec:"required"`
+	Options  []string `json:"options" spec:"required" doc:"2 to 10 options."`
+}
+
+type voteData struct {
+	Room   string `json:"room" spec:"required"`
+	Poll   string `json:"poll" spec:"required"`
+	Option int    `json:"option" spec:"required" doc:"Index of the option, from 0."`
+}
+
+type pollRef struct {
+	Room string `json:"room" spec:"required"`
+	Poll string `json:"poll" spec:"required"`
+}
+
+type timeSyncData struct {
+	ClientTime interface{} `json:"client_time,omitempty" doc:"Echoed in the reply, usually the client clock when sending."`
+}
+
+type pingRef struct {
+	Id string `json:"id" spec:"required" doc:"Id of the ping received in Ping_Peer."`
+}
+
+type iceServersRequest struct {
+	Data struct {
+		Region string `json:"region,omitempty" doc:"Region whose TURN servers are preferred."`
+	} `json:"data,omitempty"`
+}
+
+type callInviteData struct {
+	Room string `json:"room,omitempty" doc:"Room the call takes place in, for the authorizer. The other fields are passed on to the callees."`
+}
+
+type callRef struct {
+	Id     string `json:"id" spec:"required" doc:"Id of the call received in Call_Invite or Call_Pending."`
+	Reason string `json:"reason,omitempty" doc:"Passed on with Call_Reject."`
+}
+
+type ackData struct {
+	MessageIds []string `json:"message_ids" spec:"required" doc:"message_id of the received messages."`
+}
+
+type clientRef struct {
+	Client string `json:"client" spec:"required"`
+}
+
+type candidatesData struct {
+	Candidates []interface{} `json:"candidates" spec:"required"`
+}
+
+type connectData struct {
+	SDP string `json:"sdp" spec:"required"`
+}
+
+type createRoomData struct {
+	Room                 string                 `json:"room" spec:"required"`
+	Name                 string                 `json:"name,omitempty"`
+	MaxClients           int                    `json:"max_clients,omitempty" doc:"0 means no limit."`
+	Password             string                 `json:"password,omitempty"`
+	ExpiresIn            int                    `json:"expires_in,omitempty" doc:"Seconds until the room is deleted."`
+	Persistent           bool                   `json:"persistent,omitempty"`
+	Metadata             map[string]interface{} `json:"metadata,omitempty"`
+	History              bool                   `json:"history,omitempty"`
+	AutoNegotiate        bool                   `json:"auto_negotiate,omitempty"`
+	AnnounceOnly         bool                   `json:"announce_only,omitempty"`
+	Approval             bool                   `json:"approval,omitempty"`
+	EndWhenCreatorLeaves bool                   `json:"end_when_creator_leaves,omitempty" doc:"Ends the room when the creator leaves or is gone past the reconnect grace period."`
+	Public               bool                   `json:"public,omitempty"`
+	CallbackURL          string                 `json:"callback_url,omitempty"`
+	Region               string                 `json:"region,omitempty" doc:"Where the members are, like eu-west."`
+	Nonce                string                 `json:"nonce,omitempty" doc:"Solution of the proof-of-work challenge, on servers requiring one."`
+}
+
+type joinRoomData struct {
+	Room     string `json:"room,omitempty"`
+	Code     string `json:"code,omitempty" doc:"Join code of the room, instead of its Id."`
+	Password string `json:"password,omitempty"`
+	Token    string `json:"token,omitempty" doc:"Invite token of a locked room."`
+}
+
+type inviteData struct {
+	Room string `json:"room" spec:"required"`
+	To   string `json:"to" spec:"required"`
+}
+
+type setStatusData struct {
+	Status string `json:"status" spec:"required"`
+}
+
+type publishKeyData struct {
+	Key string `json:"key" spec:"required"`
+}
+
+type setModeratorData struct {
+	Room      string `json:"room" spec:"required"`
+	Client    string `json:"client" spec:"required"`
+	Moderator *bool  `json:"moderator,omitempty" doc:"false revokes the rights, true when missing."`
+}
+
+type setTagsData struct {
+	Tags []string `json:"tags" spec:"required"`
+}
+
+type reportStatsData struct {
+	Room       string  `json:"room" spec:"required"`
+	RTT        float64 `json:"rtt"`
+	PacketLoss float64 `json:"packet_loss"`
+	Bitrate    float64 `json:"bitrate"`
+}
+
+type fileOfferData struct {
+	Id   string `json:"id" spec:"required"`
+	Name string `json:"name" spec:"required"`
+	Size int64  `json:"size" spec:"required"`
+	Hash string `json:"hash,omitempty"`
+}
+
+type fileRef struct {
+	Id string `json:"id" spec:"required"`
+}
+
+type fileRelayData struct {
+	Id   string `json:"id" spec:"required"`
+	Size int64  `json:"size" spec:"required"`
+}
+
+type fileChunkData struct {
+	Id     string `json:"id" spec:"required"`
+	Offset int64  `json:"offset" spec:"required"`
+	Chunk  string `json:"chunk" spec:"required" doc:"Base64 encoded bytes."`
+}
+
+type findRoomData struct {
+	Query  string `json:"query" spec:"required"`
+	Match  string `json:"match,omitempty"`
+	Offset int    `json:"offset,omitempty"`
+	Limit  int    `json:"limit,omitempty"`
+}
+
+// clientMessages lists the messages of the protocol, in the order of the docs.
+var clientMessages = []spec.Message{
+	{Name: MsgTypeConnect, Summary: "Starts a connection with another client.", Payload: relayMessage[connectData]{}},
+	{Name: MsgTypeOffer, Summary: "Relays a WebRTC offer.", Payload: signalMessage[interface{}]{}},
+	{Name: MsgTypeAnswer, Summary: "Relays a WebRTC answer.", Payload: signalMessage[interface{}]{}},
+	{Name: MsgTypeCandidate, Summary: "Relays an ICE candidate.", Payload: signalMessage[interface{}]{}},
+	{Name: MsgTypeCandidates, Summary: "Relays a list of ICE candidates.", Payload: signalMessage[candidatesData]{}},
+	{Name: MsgTypeMessage, Summary: "Relays data to another client or to the devices of a user.", Payload: userMessage[interface{}]{}},
+	{Name: MsgTypeCreateRoom, Summary: "Creates a room.", Payload: dataMessage[createRoomData]{}},
+	{Name: MsgTypeJoinRoom, Summary: "Joins a room.", Payload: dataMessage[joinRoomData]{}},
+	{Name: MsgTypeLeaveRoom, Summary: "Leaves a room.", Payload: dataMessage[roomRef]{}},
+	{Name: MsgTypeEndRoom, Summary: "Ends a room.", Payload: dataMessage[roomRef]{}},
+	{Name: MsgTypeGetIceServers, Summary: "Gets the STUN/TURN servers.", Payload: iceServersRequest{}},
+	{Name: MsgTypeSetStatus, Summary: "Sets the presence status.", Payload: dataMessage[setStatusData]{}},
+	{Name: MsgTypeInvite, Summary: "Invites a client to a room.", Payload: dataMessage[inviteData]{}},
+	{Name: MsgTypeLockRoom, Summary: "Locks a room.", Payload: dataMessage[roomRef]{}},
+	{Name: MsgTypeUnlockRoom, Summary: "Unlocks a room.", Payload: dataMessage[roomRef]{}},
+	{Name: MsgTypeGetTopology, Summary: "Gets the recommended peer-connection graph of a room.", Payload: dataMessage[roomRef]{}},
+	{Name: MsgTypeSetCapabilities, Summary: "Registers the media and codecs of the client.", Payload: dataMessage[client.Capabilities]{}},
+	{Name: MsgTypePublishKey, Summary: "Publishes the end-to-end encryption public key.", Payload: dataMessage[publishKeyData]{}},
+	{Name: MsgTypeRotateKey, Summary: "Starts a new key epoch in a room.", Payload: dataMessage[roomRef]{}},
+	{Name: MsgTypeBroadcast, Summary: "Sends data to the other members of a room.", Payload: roomBroadcast{}},
+	{Name: MsgTypeSetModerator, Summary: "Grants or revokes moderation rights.", Payload: dataMessage[setModeratorData]{}},
+	{Name: MsgTypeApproveJoin, Summary: "Admits a client waiting to join.", Payload: dataMessage[roomMember]{}},
+	{Name: MsgTypeRejectJoin, Summary: "Turns away a client waiting to join.", Payload: dataMessage[roomMember]{}},
+	{Name: MsgTypeSetTags, Summary: "Labels the client with tags.", Payload: dataMessage[setTagsData]{}},
+	{Name: MsgTypeBroadcastToTag, Summary: "Sends data to the members of a room carrying a tag.", Payload: tagBroadcast{}},
+	{Name: MsgTypeBlockClient, Summary: "Stops receiving messages from a client.", Payload: dataMessage[clientRef]{}},
+	{Name: MsgTypeUnblockClient, Summary: "Receives messages from a blocked client again.", Payload: dataMessage[clientRef]{}},
+	{Name: MsgTypeRoomMessage, Summary: "Sends a chat message to the members of a room.", Payload: roomBroadcast{}},
+	{Name: MsgTypeTypingStart, Summary: "Tells a room the client is typing.", Payload: roomRef{}},
+	{Name: MsgTypeTypingStop, Summary: "Tells a room the client stopped typing.", Payload: roomRef{}},
+	{Name: MsgTypeFileOffer, Summary: "Offers a file to a client.", Payload: signalMessage[fileOfferData]{}},
+	{Name: MsgTypeFileAccept, Summary: "Accepts a file offer.", Payload: signalMessage[fileRef]{}},
+	{Name: MsgTypeFileReject, Summary: "Declines a file offer.", Payload: signalMessage[fileRef]{}},
+	{Name: MsgTypeFileRelay, Summary: "Relays a file through the server.", Payload: relayMessage[fileRelayData]{}},
+	{Name: MsgTypeFileChunk, Summary: "Sends a chunk of a relayed file.", Payload: relayMessage[fileChunkData]{}},
+	{Name: MsgTypeMediaState, Summary: "Tells the rooms which media the client sends.", Payload: dataMessage[client.MediaState]{}},
+	{Name: MsgTypeRecordingStarted, Summary: "Tells the members that the room is recorded.", Payload: dataMessage[roomRef]{}},
+	{Name: MsgTypeRecordingStopped, Summary: "Tells the members that the recording stopped.", Payload: dataMessage[roomRef]{}},
+	{Name: MsgTypeMyRooms, Summary: "Lists the rooms of the client.", Payload: struct{}{}},
+	{Name: MsgTypeFindRoom, Summary: "Searches the public rooms by name.", Payload: dataMessage[findRoomData]{}},
+	{Name: MsgTypeGetChallenge, Summary: "Gets a proof-of-work challenge.", Payload: struct{}{}},
+	{Name: MsgTypeReportStats, Summary: "Reports the connection statistics of the client in a room.", Payload: dataMessage[reportStatsData]{}},
+	{Name: MsgTypeRequestFloor, Summary: "Takes the floor of a room when it is free.", Payload: dataMessage[roomRef]{}},
+	{Name: MsgTypeGrantFloor, Summary: "Gives the floor of a room to a member.", Payload: dataMessage[roomMember]{}},
+	{Name: MsgTypeReleaseFloor, Summary: "Releases the floor of a room.", Payload: dataMessage[roomRef]{}},
+	{Name: MsgTypeRaiseHand, Summary: "Raises the hand of the client in a room.", Payload: dataMessage[roomRef]{}},
+	{Name: MsgTypeLowerHand, Summary: "Lowers the hand of the client, or of a member for moderators.", Payload: dataMessage[lowerHandData]{}},
+	{Name: MsgTypeCreatePoll, Summary: "Puts a poll to the members of a room.", Payload: dataMessage[createPollData]{}},
+	{Name: MsgTypeVote, Summary: "Votes for an option of a poll.", Payload: dataMessage[voteData]{}},
+	{Name: MsgTypeClosePoll, Summary: "Closes a poll.", Payload: dataMessage[pollRef]{}},
+	{Name: MsgTypeTimeSync, Summary: "Gets the server time to estimate the clock offset.", Payload: dataMessage[timeSyncData]{}},
+	{Name: MsgTypePingPeer, Summary: "Measures the signalling latency to a peer through the server.", Payload: relayMessage[timeSyncData]{}},
+	{Name: MsgTypePongPeer, Summary: "Answers a ping of a peer.", Payload: dataMessage[pingRef]{}},
+	{Name: MsgTypeCallInvite, Summary: "Rings a client or the devices of a user.", Payload: callInviteMessage{}},
+	{Name: MsgTypeCallRinging, Summary: "Tells the caller the invitation rings.", Payload: dataMessage[callRef]{}},
+	{Name: MsgTypeCallAccept, Summary: "Accepts a call.", Payload: dataMessage[callRef]{}},
+	{Name: MsgTypeCallReject, Summary: "Rejects a call.", Payload: dataMessage[callRef]{}},
+	{Name: MsgTypeCallCancel, Summary: "Cancels a call that rings.", Payload: dataMessage[callRef]{}},
+	{Name: MsgTypeCallEnd, Summary: "Hangs up an accepted call.", Payload: dataMessage[callRef]{}},
+	{Name: MsgTypeSetBusyPolicy, Summary: "Tells when the server answers calls to the client with Busy.", Payload: dataMessage[client.BusyPolicy]{}},
+	{Name: MsgTypeAck, Summary: "Acknowledges high priority messages received with acks=true.", Payload: dataMessage[ackData]{}},
+}
+
+// connectionQuery lists the query parameters of the WebSocket connection.
+var connectionQuery = []string{"id", "resume", "access_token", "version", "device", "acks"}
+
+// sessionQuery lists the query parameters opening a long polling or SSE session.
+var sessionQuery = []string{"id", "access_token", "version", "device"}
+
+// httpEndpoints lists the HTTP endpoints besides the WebSocket.
+var httpEndpoints = []spec.Endpoint{
+	{Method: http.MethodGet, Path: "/ice-servers", Summary: "Returns the STUN/TURN servers.", Query: []string{"access_token", "region"}, Response: struct {
+		IceServers []ice.Server `json:"ice_servers"`
+		TTL        int          `json:"ttl"`
+	}{}},
+	{Method: http.MethodPost, Path: "/poll/connect", Summary: "Creates a long polling client.", Query: sessionQuery, Response: struct {
+		Id    string `json:"id"`
+		Token string `json:"token"`
+	}{}},
+	{Method: http.MethodPost, Path: "/poll/send", Summary: "Handles one message of a long polling client.", Query: []string{"id", "token"}},
+	{Method: http.MethodGet, Path: "/poll/receive", Summary: "Waits for the messages of a long polling client.", Query: []string{"id", "token", "timeout"}, Response: []responsemessage.Message{}},
+	{Method: http.MethodPost, Path: "/poll/disconnect", Summary: "Disconnects a long polling client.", Query: []string{"id", "token"}},
+	{Method: http.MethodGet, Path: "/sse", Summary: "Streams the messages of a client as server-sent events.", Query: append(sessionQuery, "token")},
+	{Method: http.MethodPost, Path: "/sse/send", Summary: "Handles one message of an SSE client.", Query: []string{"id", "token"}},
+	{Method: http.MethodGet, Path: "/room/{code}/qr", Summary: "Returns the QR code of a join code as PNG."},
+	{Method: http.MethodGet, Path: "/metrics", Summary: "Returns the metrics in the Prometheus text format.", Admin: true},
+	{Method: http.MethodGet, Path: "/admin/stats", Summary: "Returns the connection statistics of each room.", Admin: true},
+	{Method: http.MethodGet, Path: "/admin/clients", Summary: "Returns the connected clients.", Admin: true},
+	{Method: http.MethodGet, Path: "/admin/bandwidth", Summary: "Returns the bytes relayed per client and per room.", Admin: true},
+	{Method: http.MethodGet, Path: "/admin/summaries", Summary: "Returns the activity summaries of the last deleted rooms.", Admin: true},
+	{Method: http.MethodGet, Path: "/admin/archive", Summary: "Lists the archived rooms, newest first.", Admin: true, Query: []string{"room"}, Response: struct {
+		Archives []store.ArchiveRecord `json:"archives"`
+	}{}},
+	{Method: http.MethodGet, Path: "/admin/archive/{id}", Summary: "Returns an archived room.", Admin: true, Response: store.ArchiveRecord{}},
+	{Method: http.MethodDelete, Path: "/admin/archive/{id}", Summary: "Deletes an archived room.", Admin: true},
+	{Method: http.MethodGet, Path: "/admin/drain", Summary: "Tells whether the node is drained and how loaded it is.", Admin: true},
+	{Method: http.MethodPost, Path: "/admin/drain", Summary: "Drains the node.", Admin: true},
+	{Method: http.MethodDelete, Path: "/admin/drain", Summary: "Cancels the drain.", Admin: true},
+	{Method: http.MethodGet, Path: "/admin/bans", Summary: "Lists the bans.", Admin: true, Query: []string{"room"}, Response: struct {
+		Bans []store.BanRecord `json:"bans"`
+	}{}},
+	{Method: http.MethodPost, Path: "/admin/bans", Summary: "Adds a ban.", Admin: true, Request: banRequest{}, Response: store.BanRecord{}},
+	{Method: http.MethodDelete, Path: "/admin/bans/{id}", Summary: "Lifts a ban.", Admin: true},
+	{Method: http.MethodGet, Path: "/admin/rooms", Summary: "Lists the rooms.", Admin: true},
+	{Method: http.MethodDelete, Path: "/admin/rooms/{id}", Summary: "Deletes a room.", Admin: true},
+	{Method: http.MethodGet, Path: "/admin/events", Summary: "Streams the server events as server-sent events.", Admin: true},
+	{Method: http.MethodGet, Path: "/admin/tap", Summary: "Streams copies of the frames of a client or room over WebSocket.", Query: []string{"client", "room", "redact", "duration"}, Admin: true},
+	{Method: http.MethodGet, Path: "/admin/clock", Summary: "Returns the time of the fake clock.", Admin: true},
+	{Method: http.MethodPost, Path: "/admin/clock", Summary: "Advances the fake clock.", Admin: true},
+}
+
+var specInfo = spec.Info{
+	Title:       "Peer2PeerConnector",
+	Version:     protocol.Current.Name(),
+	Description: "Signalling server for WebRTC peer-to-peer connections.",
+}
+
+// specDocuments are generated on first use, the messages don't change at run time.
+var specDocuments = sync.OnceValue(func() map[string]interface{} {
+	return map[string]interface{}{
+		"asyncapi": spec.AsyncAPI(specInfo, connectionQuery, clientMessages, responsemessage.Message{}),
+		"openapi":  spec.OpenAPI(specInfo, httpEndpoints),
+	}
+})
+
+// ServeSpec serves the AsyncAPI document of the WebSocket protocol at /spec and
+// /spec/asyncapi.json, and the OpenAPI document of the HTTP endpoints at
+// /spec/openapi.json.
+func ServeSpec(writer http.ResponseWriter, request *http.Request) {
+	if cfg().OIDCProtectDocs && operators != nil {
+		if _, ok := checkLoggedIn(writer, request); !ok {
+			return
+		}
+	}
+	switch request.URL.Path {
+	case "/spec", "/spec/asyncapi.json":
+		writeJSONResponse(writer, http.StatusOK, specDocuments()["asyncapi"])
+	case "/spec/openapi.json":
+		writeJSONResponse(writer, http.StatusOK, specDocuments()["openapi"])
+	default:
+		http.NotFound(writer, request)
+	}
+}
@@ -0,0 +1,92 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/shankarammai/Peer2PeerConnector/internal/logging"
+)
+
+// sseKeepAlive is how often a comment is written to idle streams so proxies keep them open.
+const sseKeepAlive = 15 * time.Second
+
+// HandleSSE serves the Server-Sent Events fallback transport:
+//
+//	GET  /sse       opens the event stream, a new client unless id and token name an existing session
+//	POST /sse/send  handles one message, the body is the same JSON as on the WebSocket
+//
+// The first event of a new stream is a "session" event with the client id and token
+// needed to send messages and to reopen the stream after a network failure.
+func HandleSSE(writer http.ResponseWriter, request *http.Request) {
+	switch {
+	case request.URL.Path == "/sse" && request.Method == http.MethodGet:
+		streamEvents(writer, request)
+	case request.URL.Path == "/sse/send" && request.Method == http.MethodPost:
+		session, ok := sessionFromRequest(request)
+		if !ok {
+			http.Error(writer, "Unknown session", http.StatusUnauthorized)
+			return
+		}
+		session.transport.touch()
+		handleSessionMessage(writer, request, session)
+	default:
+		http.NotFound(writer, request)
+	}
+}
+
+// streamEvents writes the messages of the client as SSE "data" events until the
+// request is cancelled or the client is disconnected.
+func streamEvents(writer http.ResponseWriter, request *http.Request) {
+	flusher, ok := writer.(http.Flusher)
+	if !ok {
+		http.Error(writer, "Streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	session, resumed := sessionFromRequest(request)
+	if !resumed {
+		if refuseWhileDraining(writer) {
+			return
+		}
+		var err error
+		if session, err = openSession(request); refuseClientId(writer, err) {
+			return
+		}
+	}
+
+	writer.Header().Set("Content-Type", "text/event-stream")
+	writer.Header().Set("Cache-Control", "no-store")
+	writer.Header().Set("Connection", "keep-alive")
+	writer.Header().Set("X-Accel-Buffering", "no")
+	writer.WriteHeader(http.StatusOK)
+
+	details, _ := json.Marshal(map[string]interface{}{"id": session.client.GetClientId(), "token": session.token})
+	fmt.Fprintf(writer, "event: session\ndata: %s\n\n", details)
+	flusher.Flush()
+	logging.ForClient(session.client.Id).Info("SSE stream opened")
+
+	done := request.Context().Done()
+	for {
+		frames := session.transport.wait(sseKeepAlive, done)
+		if len(frames) == 0 {
+			fmt.Fprint(writer, ": keep-alive\n\n")
+		}
+		for _, frame := range frames {
+			fmt.Fprintf(writer, "data: %s\n\n", frame)
+		}
+		flusher.Flush()
+
+		select {
+		case <-done:
+			// the session stays open until it times out so the client can reopen the stream
+			logging.ForClient(session.client.Id).Info("SSE stream closed")
+			return
+		default:
+		}
+		if session.transport.isClosed() {
+			return
+		}
+	}
+}
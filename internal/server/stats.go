@@ -0,0 +1,116 @@
+package server
+
+import (
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/shankarammai/Peer2PeerConnector/internal/client"
+	responsemessage "github.com/shankarammai/Peer2PeerConnector/internal/response"
+)
+
+// statsReport is the latest getStats summary pushed by a client for a room.
+type statsReport struct {
+	RTT        float64
+	PacketLoss float64
+	Bitrate    float64
+	At         time.Time
+}
+
+// roomStatsSummary aggregates the latest reports of the members of a room.
+type roomStatsSummary struct {
+	Reporters  int       `json:"reporters"`
+	RTT        float64   `json:"rtt_ms"`
+	PacketLoss float64   `json:"packet_loss"`
+	Bitrate    float64   `json:"bitrate"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+var (
+	// roomStats maps room ids to the latest report of each member.
+	roomStats = make(map[string]map[string]statsReport)
+	statsMu   sync.Mutex
+)
+
+// handleReportStatsMessage processes a "report_stats" message.
+// It stores the connection statistics of the client for the room, they are
+// aggregated per room by the admin API and the metrics endpoint.
+func handleReportStatsMessage(client *client.Client, msg map[string]interface{}) {
+	if !checkRoomInJSON(client, msg) {
+		return
+	}
+	data := msg["data"].(map[string]interface{})
+	roomId, _ := data["room"].(string)
+
+	mu.Lock()
+	myRoom, exists := rooms[roomId]
+	member := exists && slices.Contains(myRoom.GetClients(), client.GetClientId())
+	mu.Unlock()
+	if !exists {
+		client.Send(responsemessage.ErrorMessage("Not_Found", map[string]interface{}{"message": "Room with Id " + roomId + " does not exist."}))
+		return
+	}
+	if !member {
+		client.Send(responsemessage.ErrorMessage("Unauthorised", map[string]interface{}{"message": "You need to be in the room to report its stats."}))
+		return
+	}
+
+	rtt, rttOk := floatField(data, "rtt")
+	packetLoss, lossOk := floatField(data, "packet_loss")
+	bitrate, bitrateOk := floatField(data, "bitrate")
+	if !rttOk || !lossOk || !bitrateOk || rtt < 0 || packetLoss < 0 || packetLoss > 1 || bitrate < 0 {
+		client.Send(responsemessage.ErrorMessage("Invalid_Stats", map[string]interface{}{"message": "'rtt' (ms) and 'bitrate' (bits/s) must be positive numbers and 'packet_loss' a ratio between 0 and 1."}))
+		return
+	}
+
+	statsMu.Lock()
+	defer statsMu.Unlock()
+	if roomStats[roomId] == nil {
+		roomStats[roomId] = make(map[string]statsReport)
+	}
+	roomStats[roomId][client.GetClientId()] = statsReport{RTT: rtt, PacketLoss: packetLoss, Bitrate: bitrate, At: time.Now()}
+}
+
+// summarizeRoomStats aggregates the reports of the current members of every room.
+func summarizeRoomStats() map[string]roomStatsSummary {
+	mu.Lock()
+	members := make(map[string][]string, len(rooms))
+	for roomId, roomItem := range rooms {
+		members[roomId] = slices.Clone(roomItem.GetClients())
+	}
+	mu.Unlock()
+
+	statsMu.Lock()
+	defer statsMu.Unlock()
+	summaries := make(map[string]roomStatsSummary)
+	for roomId, reports := range roomStats {
+		var summary roomStatsSummary
+		for clientId, report := range reports {
+			if !slices.Contains(members[roomId], clientId) {
+				continue
+			}
+			summary.Reporters++
+			summary.RTT += report.RTT
+			summary.PacketLoss += report.PacketLoss
+			summary.Bitrate += report.Bitrate
+			if report.At.After(summary.UpdatedAt) {
+				summary.UpdatedAt = report.At
+			}
+		}
+		if summary.Reporters == 0 {
+			continue
+		}
+		summary.RTT /= float64(summary.Reporters)
+		summary.PacketLoss /= float64(summary.Reporters)
+		summary.Bitrate /= float64(summary.Reporters)
+		summaries[roomId] = summary
+	}
+	return summaries
+}
+
+// dropRoomStats forgets the reports of a deleted room.
+func dropRoomStats(roomId string) {
+	statsMu.Lock()
+	delete(roomStats, roomId)
+	statsMu.Unlock()
+}
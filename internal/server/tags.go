@@ -0,0 +1,53 @@
+package server
+
+import (
+	"slices"
+
+	"github.com/shankarammai/Peer2PeerConnector/internal/client"
+	"github.com/shankarammai/Peer2PeerConnector/internal/logging"
+	responsemessage "github.com/shankarammai/Peer2PeerConnector/internal/response"
+)
+
+// Limits on the tags of a client.
+const (
+	maxTags      = 32
+	maxTagLength = 64
+)
+
+// handleSetTagsMessage processes a "set_tags" message.
+// It replaces the tags of the client, which are then part of the room membership
+// data and can be targeted with "broadcast_to_tag".
+func handleSetTagsMessage(client *client.Client, msg map[string]interface{}) {
+	data, dataOk := msg["data"].(map[string]interface{})
+	if !dataOk {
+		client.Send(responsemessage.ErrorMessage("Missing_Fields", map[string]interface{}{"message": "'data' field is missing or is not object in the request."}))
+		return
+	}
+	values, ok := data["tags"].([]interface{})
+	tags := []string{}
+	for _, value := range values {
+		tag, isString := value.(string)
+		if !isString || tag == "" || len(tag) > maxTagLength {
+			ok = false
+			break
+		}
+		if !slices.Contains(tags, tag) {
+			tags = append(tags, tag)
+		}
+	}
+	if !ok || len(tags) > maxTags {
+		client.Send(responsemessage.ErrorMessage("Invalid_Tags", map[string]interface{}{
+			"message":    "'tags' must be a list of non-empty strings.",
+			"max_tags":   maxTags,
+			"max_length": maxTagLength,
+		}))
+		return
+	}
+
+	mu.Lock()
+	client.SetTags(tags)
+	mu.Unlock()
+	logging.ForClient(client.Id).Debug("Tags set: ", tags)
+
+	client.Send(responsemessage.InfoMessage("Tags_Set", map[string]interface{}{"tags": tags}))
+}
@@ -0,0 +1,220 @@
+package server
+
+import (
+	"net/http"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/shankarammai/Peer2PeerConnector/internal/client"
+	"github.com/shankarammai/Peer2PeerConnector/internal/redact"
+)
+
+// Bounds of the debug taps, they cost a copy of every frame they follow.
+const (
+	maxTaps            = 8
+	defaultTapDuration = 5 * time.Minute
+	maxTapDuration     = time.Hour
+	tapBufferSize      = 256
+	// defaultTapRedaction hides everything but the shape of the messages.
+	defaultTapRedaction = "sdp,candidates,secrets,content"
+)
+
+// debugTap streams copies of the frames of a client, or of the members of a
+// room, to an operator.
+type debugTap struct {
+	client string
+	room   string
+	// members are the members of the room, guarded by tapsMu.
+	members []string
+	filter  *redact.Filter
+	frames  chan tapFrame
+	dropped atomic.Int64
+}
+
+// tapFrame is the copy of a frame sent to the operator.
+type tapFrame struct {
+	Client    string    `json:"client"`
+	Direction string    `json:"direction"`
+	At        time.Time `json:"at"`
+	Size      int       `json:"size"`
+	Binary    bool      `json:"binary,omitempty"`
+	// Message is the redacted message, binary frames only tell their size.
+	Message map[string]interface{} `json:"message,omitempty"`
+	// Dropped counts the frames dropped before this one because the operator was too slow.
+	Dropped int64 `json:"dropped,omitempty"`
+}
+
+var (
+	taps     = make(map[*debugTap]bool)
+	tapsMu   sync.Mutex
+	tapCount atomic.Int32
+)
+
+var tapUpgrader = websocket.Upgrader{ReadBufferSize: 1024, WriteBufferSize: 4096}
+
+// tapOutgoing copies a frame written to the client to the taps following it.
+func tapOutgoing(client *client.Client, frameType int, data []byte) {
+	if tapCount.Load() == 0 || !isTapped(client.GetClientId()) {
+		return
+	}
+	var msg map[string]interface{}
+	if frameType == client.GetCodec().FrameType() {
+		client.GetCodec().Unmarshal(data, &msg)
+	}
+	copyToTaps(client.GetClientId(), "out", msg, len(data))
+}
+
+// tapIncoming copies a message or binary frame of size bytes received from the
+// client to the taps following it, msg is nil for binary frames.
+func tapIncoming(client *client.Client, msg map[string]interface{}, size int) {
+	if tapCount.Load() == 0 || !isTapped(client.GetClientId()) {
+		return
+	}
+	copyToTaps(client.GetClientId(), "in", msg, size)
+}
+
+func isTapped(clientId string) bool {
+	tapsMu.Lock()
+	defer tapsMu.Unlock()
+	for tap := range taps {
+		if tap.client == clientId || slices.Contains(tap.members, clientId) {
+			return true
+		}
+	}
+	return false
+}
+
+func copyToTaps(clientId string, direction string, msg map[string]interface{}, size int) {
+	at := clk.Now()
+	tapsMu.Lock()
+	defer tapsMu.Unlock()
+	for tap := range taps {
+		if tap.client != clientId && !slices.Contains(tap.members, clientId) {
+			continue
+		}
+		frame := tapFrame{Client: clientId, Direction: direction, At: at, Size: size, Binary: msg == nil}
+		if msg != nil {
+			frame.Message = tap.filter.Apply(msg)
+		}
+		select {
+		case tap.frames <- frame:
+		default:
+			tap.dropped.Add(1)
+		}
+	}
+}
+
+// retapRoom follows the members of the room in its taps, after they changed.
+func retapRoom(roomId string) {
+	if tapCount.Load() == 0 {
+		return
+	}
+	mu.Lock()
+	var members []string
+	if roomItem, exists := rooms[roomId]; exists {
+		members = slices.Clone(roomItem.GetClients())
+	}
+	mu.Unlock()
+	tapsMu.Lock()
+	for tap := range taps {
+		if tap.room == roomId {
+			tap.members = members
+		}
+	}
+	tapsMu.Unlock()
+}
+
+// serveTap serves /admin/tap: the WebSocket connection receives a copy of every
+// frame of the client "client", or of the members of the room "room", for
+// "duration" (5m). The fields of the kinds in "redact" are hidden, all of them
+// unless "redact=none" is given.
+func serveTap(writer http.ResponseWriter, request *http.Request) {
+	query := request.URL.Query()
+	tap := &debugTap{client: query.Get("client"), room: query.Get("room"), frames: make(chan tapFrame, tapBufferSize)}
+	if (tap.client == "") == (tap.room == "") {
+		http.Error(writer, "Tap either a 'client' or a 'room'", http.StatusBadRequest)
+		return
+	}
+	redaction := defaultTapRedaction
+	if query.Has("redact") {
+		redaction = query.Get("redact")
+	}
+	var err error
+	if tap.filter, err = redact.Parse(redaction); err != nil {
+		http.Error(writer, err.Error(), http.StatusBadRequest)
+		return
+	}
+	duration := defaultTapDuration
+	if query.Has("duration") {
+		if duration, err = time.ParseDuration(query.Get("duration")); err != nil || duration <= 0 || duration > maxTapDuration {
+			http.Error(writer, "'duration' must be a duration of at most 1h", http.StatusBadRequest)
+			return
+		}
+	}
+	mu.Lock()
+	_, clientExists := clients[tap.client]
+	roomItem, roomExists := rooms[tap.room]
+	if roomExists {
+		tap.members = slices.Clone(roomItem.GetClients())
+	}
+	mu.Unlock()
+	if !clientExists && !roomExists {
+		http.Error(writer, "No such client or room", http.StatusNotFound)
+		return
+	}
+
+	tapsMu.Lock()
+	if len(taps) >= maxTaps {
+		tapsMu.Unlock()
+		http.Error(writer, "Too many taps", http.StatusServiceUnavailable)
+		return
+	}
+	taps[tap] = true
+	tapCount.Add(1)
+	tapsMu.Unlock()
+	defer func() {
+		tapsMu.Lock()
+		delete(taps, tap)
+		tapCount.Add(-1)
+		tapsMu.Unlock()
+	}()
+
+	connection, err := tapUpgrader.Upgrade(writer, request, nil)
+	if err != nil {
+		return
+	}
+	defer connection.Close()
+	logger.Warnf("Tap opened on client %q room %q for %s, redacting %q", tap.client, tap.room, duration, redaction)
+	defer logger.Infof("Tap closed on client %q room %q", tap.client, tap.room)
+
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		for {
+			if _, _, err := connection.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+	expired := make(chan struct{})
+	expiry := clk.AfterFunc(duration, func() { close(expired) })
+	defer expiry.Stop()
+	for {
+		select {
+		case frame := <-tap.frames:
+			frame.Dropped = tap.dropped.Swap(0)
+			if err := connection.WriteJSON(frame); err != nil {
+				return
+			}
+		case <-expired:
+			connection.WriteControl(websocket.CloseMessage,
+				websocket.FormatCloseMessage(websocket.CloseNormalClosure, "tap expired"), time.Now().Add(time.Second))
+			return
+		case <-closed:
+			return
+		}
+	}
+}
@@ -0,0 +1,118 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/shankarammai/Peer2PeerConnector/internal/client"
+	responsemessage "github.com/shankarammai/Peer2PeerConnector/internal/response"
+)
+
+// Password policies of a room template.
+const (
+	passwordOptional = "optional"
+	passwordRequired = "required"
+)
+
+// roomTemplate is a named bundle of "create_room" settings configured by the
+// operator. Unset fields leave the setting to the client.
+type roomTemplate struct {
+	MaxClients    *int  `json:"max_clients"`
+	ExpiresIn     *int  `json:"expires_in"`
+	Persistent    *bool `json:"persistent"`
+	History       *bool `json:"history"`
+	AutoNegotiate *bool `json:"auto_negotiate"`
+	AnnounceOnly  *bool `json:"announce_only"`
+	Approval      *bool `json:"approval"`
+	// EndWhenCreatorLeaves mirrors the "end_when_creator_leaves" setting.
+	EndWhenCreatorLeaves *bool `json:"end_when_creator_leaves"`
+	// PasswordPolicy is "required" when rooms of the template must have a
+	// password of at least PasswordMinLength bytes.
+	PasswordPolicy    string `json:"password_policy"`
+	PasswordMinLength int    `json:"password_min_length"`
+}
+
+// roomTemplates are the templates rooms can be created from, by name.
+var roomTemplates = map[string]roomTemplate{}
+
+// loadRoomTemplates reads the room templates from a JSON file mapping the
+// template names to their settings.
+func loadRoomTemplates(path string) (map[string]roomTemplate, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(content))
+	decoder.DisallowUnknownFields()
+	templates := map[string]roomTemplate{}
+	if err := decoder.Decode(&templates); err != nil {
+		return nil, fmt.Errorf("invalid room templates %s: %w", path, err)
+	}
+	for name, template := range templates {
+		switch template.PasswordPolicy {
+		case "", passwordOptional, passwordRequired:
+		default:
+			return nil, fmt.Errorf("invalid password policy %q of room template %q", template.PasswordPolicy, name)
+		}
+	}
+	return templates, nil
+}
+
+// settings returns the "create_room" fields set by the template.
+func (template roomTemplate) settings() map[string]interface{} {
+	settings := map[string]interface{}{}
+	if template.MaxClients != nil {
+		settings["max_clients"] = *template.MaxClients
+	}
+	if template.ExpiresIn != nil {
+		settings["expires_in"] = *template.ExpiresIn
+	}
+	flags := map[string]*bool{
+		"persistent":              template.Persistent,
+		"history":                 template.History,
+		"auto_negotiate":          template.AutoNegotiate,
+		"announce_only":           template.AnnounceOnly,
+		"approval":                template.Approval,
+		"end_when_creator_leaves": template.EndWhenCreatorLeaves,
+	}
+	for key, flag := range flags {
+		if flag != nil {
+			settings[key] = *flag
+		}
+	}
+	return settings
+}
+
+// applyRoomTemplate fills the settings of a "create_room" message from the
+// template it names, the settings sent by the client take precedence. It reports
+// an unknown template or a password breaking the policy of the template to the
+// client and returns false.
+func applyRoomTemplate(client *client.Client, data map[string]interface{}) (map[string]interface{}, bool) {
+	value, named := data["template"]
+	if !named {
+		return data, true
+	}
+	name, _ := value.(string)
+	template, exists := roomTemplates[name]
+	if !exists {
+		client.Send(responsemessage.ErrorMessage("Not_Found", map[string]interface{}{"message": "Room template " + name + " does not exist."}))
+		return nil, false
+	}
+
+	password, _ := data["password"].(string)
+	if template.PasswordPolicy == passwordRequired && (password == "" || len(password) < template.PasswordMinLength) {
+		client.Send(responsemessage.ErrorMessage("Invalid_Password", map[string]interface{}{
+			"message":    "Rooms created from template " + name + " need a password.",
+			"min_length": template.PasswordMinLength,
+		}))
+		return nil, false
+	}
+
+	merged := template.settings()
+	for key, setting := range data {
+		merged[key] = setting
+	}
+	return merged, true
+}
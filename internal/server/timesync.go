@@ -0,0 +1,30 @@
+package server
+
+import (
+	"time"
+
+	"github.com/shankarammai/Peer2PeerConnector/internal/client"
+	responsemessage "github.com/shankarammai/Peer2PeerConnector/internal/response"
+)
+
+// serverStart is the origin of the monotonic times sent with "time_sync".
+var serverStart = time.Now()
+
+// handleTimeSyncMessage processes a "time_sync" message.
+// It echoes the "client_time" of the client with the server wall clock when the
+// message was received and when the reply was sent, in Unix milliseconds, so the
+// client can estimate its clock offset and the round trip as NTP does. The
+// monotonic time, in nanoseconds since the server started, doesn't jump when the
+// server wall clock is adjusted.
+func handleTimeSyncMessage(client *client.Client, msg map[string]interface{}) {
+	receivedAt := time.Now()
+	reply := map[string]interface{}{
+		"received_at": receivedAt.UnixMilli(),
+		"monotonic":   receivedAt.Sub(serverStart).Nanoseconds(),
+	}
+	if data, ok := msg["data"].(map[string]interface{}); ok {
+		reply["client_time"] = data["client_time"]
+	}
+	reply["sent_at"] = time.Now().UnixMilli()
+	client.Send(responsemessage.InfoMessage("Time_Sync", reply))
+}
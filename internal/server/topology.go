@@ -0,0 +1,98 @@
+package server
+
+import (
+	"slices"
+
+	"github.com/shankarammai/Peer2PeerConnector/internal/client"
+	responsemessage "github.com/shankarammai/Peer2PeerConnector/internal/response"
+)
+
+// Peer-connection topologies recommended by "get_topology".
+const (
+	TopologyMesh = "mesh"
+	TopologyStar = "star"
+)
+
+// topologyEdge is a peer connection of the recommended graph.
+type topologyEdge struct {
+	Offerer  string `json:"offerer"`
+	Answerer string `json:"answerer"`
+}
+
+// recommendTopology returns the topology for the members, the host of a star
+// (empty for a mesh) and the peer connections to open. Up to maxMeshPeers members
+// connect in a full mesh, larger rooms form a star around the preferred host.
+func recommendTopology(members []string, preferredHost string, maxMeshPeers int) (string, string, []topologyEdge) {
+	edges := []topologyEdge{}
+	if len(members) <= maxMeshPeers {
+		for i, first := range members {
+			for _, second := range members[i+1:] {
+				edges = append(edges, newTopologyEdge(first, second))
+			}
+		}
+		return TopologyMesh, "", edges
+	}
+
+	host := members[0]
+	if slices.Contains(members, preferredHost) {
+		host = preferredHost
+	}
+	for _, member := range members {
+		if member != host {
+			edges = append(edges, newTopologyEdge(host, member))
+		}
+	}
+	return TopologyStar, host, edges
+}
+
+func newTopologyEdge(first string, second string) topologyEdge {
+	if isInitiator(first, second) {
+		return topologyEdge{Offerer: first, Answerer: second}
+	}
+	return topologyEdge{Offerer: second, Answerer: first}
+}
+
+// handleGetTopologyMessage processes a "get_topology" message.
+// It returns the recommended peer-connection graph of the room: a full mesh for
+// small rooms, a star around the creator (or the first member) for larger ones.
+func handleGetTopologyMessage(client *client.Client, msg map[string]interface{}) {
+	if !checkRoomInJSON(client, msg) {
+		return
+	}
+	data := msg["data"].(map[string]interface{})
+	roomId, _ := data["room"].(string)
+
+	mu.Lock()
+	myRoom, exists := rooms[roomId]
+	if !exists {
+		mu.Unlock()
+		client.Send(responsemessage.ErrorMessage("Not_Found", map[string]interface{}{"message": "Room with Id " + roomId + " does not exist."}))
+		return
+	}
+	members := slices.Clone(myRoom.GetClients())
+	creator := myRoom.GetCreator()
+	mu.Unlock()
+
+	if !slices.Contains(members, client.GetClientId()) {
+		client.Send(responsemessage.ErrorMessage("Unauthorised", map[string]interface{}{"message": "You need to be in the room to get its topology."}))
+		return
+	}
+
+	// only connected members take part in the graph
+	connected := []string{}
+	for _, member := range connectedClients(members) {
+		connected = append(connected, member.GetClientId())
+	}
+	topology, host, edges := recommendTopology(connected, creator, cfg().MeshMaxPeers)
+
+	response := map[string]interface{}{
+		"room":           roomId,
+		"topology":       topology,
+		"edges":          edges,
+		"mesh_max_peers": cfg().MeshMaxPeers,
+	}
+	if host != "" {
+		response["host"] = host
+	}
+	client.Send(responsemessage.InfoMessage("Topology", response))
+}
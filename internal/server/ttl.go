@@ -0,0 +1,48 @@
+package server
+
+import (
+	"time"
+
+	"github.com/shankarammai/Peer2PeerConnector/internal/client"
+	"github.com/shankarammai/Peer2PeerConnector/internal/logging"
+	responsemessage "github.com/shankarammai/Peer2PeerConnector/internal/response"
+)
+
+// checkTTL reads the "ttl_ms" of a relayed message, the milliseconds within
+// which it must be delivered, and removes it from the message. It returns 0 for
+// messages without one, and reports invalid values to the client.
+func checkTTL(client *client.Client, msg map[string]interface{}) (time.Duration, bool) {
+	if _, exists := msg["ttl_ms"]; !exists {
+		return 0, true
+	}
+	ttl, ok := intField(msg, "ttl_ms")
+	if !ok || ttl <= 0 {
+		client.Send(responsemessage.ErrorMessage("Invalid_Fields", map[string]interface{}{"message": "'ttl_ms' field must be a positive number of milliseconds."}))
+		return 0, false
+	}
+	delete(msg, "ttl_ms")
+	return time.Duration(ttl) * time.Millisecond, true
+}
+
+// relayDeadline returns the deadline of a message relayed with a time to live,
+// nil without one. When the message can't be delivered in time, because the
+// connection of the target is congested or the target doesn't fetch it, it is
+// dropped and the sender receives an "Expired" error.
+func relayDeadline(sender string, target string, event string, ttl time.Duration) *client.Deadline {
+	if ttl <= 0 {
+		return nil
+	}
+	return &client.Deadline{
+		At:  clk.Now().Add(ttl),
+		Now: clk.Now,
+		Expired: func() {
+			logging.ForClient(sender).Debugf("%s to %s expired after %s", event, target, ttl)
+			sendToClients([]string{sender}, responsemessage.ErrorMessage("Expired", map[string]interface{}{
+				"message": event + " was not delivered within its ttl_ms.",
+				"to":      target,
+				"event":   event,
+				"ttl_ms":  ttl.Milliseconds(),
+			}))
+		},
+	}
+}
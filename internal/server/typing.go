@@ -0,0 +1,90 @@
+package server
+
+import (
+	"slices"
+	"time"
+
+	"github.com/shankarammai/Peer2PeerConnector/internal/client"
+	"github.com/shankarammai/Peer2PeerConnector/internal/logging"
+	responsemessage "github.com/shankarammai/Peer2PeerConnector/internal/response"
+)
+
+// handleTypingMessage processes "typing_start" and "typing_stop" messages.
+// The indicator is relayed to the other members of the room and forgotten:
+// it is not kept in the room history. A client's "typing_start" is relayed at
+// most once per TypingInterval in a room, and "typing_stop" only after a relayed
+// "typing_start", extra indicators are dropped without an error.
+func handleTypingMessage(client *client.Client, msg map[string]interface{}) {
+	roomId, ok := msg["room"].(string)
+	if !ok {
+		client.Send(responsemessage.ErrorMessage("Missing_Fields", map[string]interface{}{"message": "'room' field is missing or is not a room Id."}))
+		return
+	}
+	from := client.GetClientId()
+	event, _ := msg["event"].(string)
+
+	mu.Lock()
+	myRoom, exists := rooms[roomId]
+	var members []string
+	var member, allowed bool
+	if exists {
+		members = slices.Clone(myRoom.GetClients())
+		member = slices.Contains(members, from)
+		allowed = !myRoom.IsAnnounceOnly() || myRoom.IsModerator(from)
+	}
+	relay := exists && member && allowed && typingAllowed(client, roomId, event, clk.Now())
+	name := client.GetName()
+	mu.Unlock()
+
+	if !exists {
+		client.Send(responsemessage.ErrorMessage("Not_Found", map[string]interface{}{"message": "Room with Id " + roomId + " does not exist."}))
+		return
+	}
+	if !member || !allowed {
+		client.Send(responsemessage.ErrorMessage("Unauthorised", map[string]interface{}{"message": "You are not allowed to send messages in room " + roomId + "."}))
+		return
+	}
+	if !checkAuthorized(client, event, authorizer.CanRelay(subjectOf(client), event, roomId, "")) {
+		return
+	}
+	if !relay {
+		logging.ForRoom(from, roomId).Debug("Dropping typing indicator")
+		return
+	}
+
+	indicator := map[string]interface{}{
+		"event": event,
+		"from":  from,
+		"name":  name,
+		"room":  roomId,
+	}
+	for _, memberClient := range connectedClients(members) {
+		if memberClient.GetClientId() == from {
+			continue
+		}
+		mu.Lock()
+		blocked := memberClient.HasBlocked(from)
+		mu.Unlock()
+		if !blocked {
+			memberClient.SendAs(indicator, relayPriority(event), nil)
+		}
+	}
+}
+
+// typingAllowed applies the typing indicator rate limit of the client in the
+// room and records a relayed indicator. The caller must hold mu.
+func typingAllowed(client *client.Client, roomId string, event string, now time.Time) bool {
+	last, typing := client.Typing[roomId]
+	if event == MsgTypeTypingStop {
+		delete(client.Typing, roomId)
+		return typing
+	}
+	if typing && now.Sub(last) < cfg().TypingInterval {
+		return false
+	}
+	if client.Typing == nil {
+		client.Typing = make(map[string]time.Time)
+	}
+	client.Typing[roomId] = now
+	return true
+}
@@ -0,0 +1,94 @@
+package server
+
+import (
+	"slices"
+
+	"github.com/shankarammai/Peer2PeerConnector/internal/client"
+	"github.com/shankarammai/Peer2PeerConnector/internal/logging"
+	responsemessage "github.com/shankarammai/Peer2PeerConnector/internal/response"
+)
+
+// userAddressable are the messages that can be addressed to a user with
+// "to_user". Signalling messages belong to one peer connection, so they are
+// always sent to a client.
+var userAddressable = []string{MsgTypeMessage}
+
+// userClients returns the connected clients of userId, other than except.
+func userClients(userId string, except string) []*client.Client {
+	mu.Lock()
+	defer mu.Unlock()
+	var devices []*client.Client
+	for _, clientItem := range clients {
+		if clientItem.UserID == userId && clientItem.GetClientId() != except {
+			devices = append(devices, clientItem)
+		}
+	}
+	slices.SortFunc(devices, func(a, b *client.Client) int { return a.ConnectedAt.Compare(b.ConnectedAt) })
+	return devices
+}
+
+// relayToUser fans a message addressed with "to_user" out to every connected
+// device of the user, e.g. so an incoming call rings on all of them. Devices
+// that blocked the sender, that the authorizer doesn't let the sender reach or,
+// with "room" addressing, that aren't in the room are skipped. The sender gets
+// a "Not_Found" error when no device is left.
+func relayToUser(client *client.Client, msg map[string]interface{}, userId string) {
+	msgtype, _ := msg["event"].(string)
+	if !slices.Contains(userAddressable, msgtype) {
+		client.Send(responsemessage.ErrorMessage("Invalid_Target", map[string]interface{}{
+			"message": "'to_user' can't address " + msgtype + ", use 'to'.",
+			"events":  userAddressable,
+		}))
+		return
+	}
+	if !checkEncrypted(client, msg) {
+		return
+	}
+	ttl, ok := checkTTL(client, msg)
+	if !ok {
+		return
+	}
+	roomId, scoped := msg["room"].(string)
+
+	delete(msg, "to")
+	msg["from"] = client.GetClientId()
+	delivered := 0
+	for _, device := range userClients(userId, client.GetClientId()) {
+		if blockedBy(device, client.GetClientId()) {
+			continue
+		}
+		if authorizer.CanRelay(subjectOf(client), msgtype, roomId, device.GetClientId()) != nil {
+			continue
+		}
+		if scoped && !inRoom(roomId, client.GetClientId(), device.GetClientId()) {
+			continue
+		}
+		if err := device.SendAs(msg, relayPriority(msgtype), relayDeadline(client.GetClientId(), device.GetClientId(), msgtype, ttl)); err != nil {
+			logging.ForClient(client.Id).Debugf("Failed to relay message to device %s: %v", device.GetClientId(), err)
+			continue
+		}
+		delivered++
+	}
+	if delivered == 0 {
+		client.Send(responsemessage.ErrorMessage("Not_Found", map[string]interface{}{"message": "User " + userId + " has no connected device to receive the message."}))
+		return
+	}
+	countRelay(delivered)
+	logging.ForClient(client.Id).Debugf("%s relayed to %d devices of user %s", msgtype, delivered, userId)
+}
+
+// inRoom tells if the clients are all members of the room.
+func inRoom(roomId string, clientIds ...string) bool {
+	mu.Lock()
+	defer mu.Unlock()
+	myRoom, exists := rooms[roomId]
+	if !exists {
+		return false
+	}
+	for _, clientId := range clientIds {
+		if !slices.Contains(myRoom.GetClients(), clientId) {
+			return false
+		}
+	}
+	return true
+}
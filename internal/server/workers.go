@@ -0,0 +1,74 @@
+package server
+
+import (
+	"sync/atomic"
+
+	"github.com/shankarammai/Peer2PeerConnector/internal/client"
+)
+
+// inboundMessage is a frame read from a connection with its handler.
+type inboundMessage struct {
+	handler func(*client.Client, []byte)
+	message []byte
+}
+
+// inbox holds the frames of a connection until a worker handles them. A
+// connection is on at most one worker at a time, so its frames are handled in
+// the order they were read.
+type inbox struct {
+	client   *client.Client
+	messages chan inboundMessage
+	// scheduled is set while the inbox waits for or is on a worker.
+	scheduled atomic.Bool
+}
+
+// readyInboxes are the inboxes waiting for a worker.
+var readyInboxes chan *inbox
+
+// startWorkers starts the workers handling the frames of the WebSocket
+// connections.
+func startWorkers(count int) {
+	readyInboxes = make(chan *inbox, count)
+	for i := 0; i < count; i++ {
+		go runWorker()
+	}
+}
+
+func runWorker() {
+	for ready := range readyInboxes {
+		ready.drain()
+	}
+}
+
+func newInbox(client *client.Client, depth int) *inbox {
+	return &inbox{client: client, messages: make(chan inboundMessage, depth)}
+}
+
+// push queues a frame and hands the inbox to a worker unless one has it. It
+// blocks while the inbox is full, so a client sending faster than its frames
+// are handled stops being read.
+func (in *inbox) push(handler func(*client.Client, []byte), message []byte) {
+	in.messages <- inboundMessage{handler: handler, message: message}
+	if in.scheduled.CompareAndSwap(false, true) {
+		readyInboxes <- in
+	}
+}
+
+// drain handles the queued frames until the inbox is empty.
+func (in *inbox) drain() {
+	for {
+		for empty := false; !empty; {
+			select {
+			case queued := <-in.messages:
+				queued.handler(in.client, queued.message)
+			default:
+				empty = true
+			}
+		}
+		in.scheduled.Store(false)
+		// a frame pushed before the flag was cleared found the inbox scheduled
+		if len(in.messages) == 0 || !in.scheduled.CompareAndSwap(false, true) {
+			return
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package server
+
+import (
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/shankarammai/Peer2PeerConnector/internal/client"
+)
+
+// testWorkers starts count workers for the duration of the test.
+func testWorkers(t *testing.T, count int) {
+	startWorkers(count)
+	ready := readyInboxes
+	t.Cleanup(func() { close(ready) })
+}
+
+func TestInboxHandlesFramesInOrder(t *testing.T) {
+	testWorkers(t, 4)
+	const frames = 200
+	handled := make([][]int, 3)
+	var done, pushers sync.WaitGroup
+	for i := range handled {
+		in := newInbox(&client.Client{Id: "inbox-" + strconv.Itoa(i)}, 4)
+		done.Add(frames)
+		handler := func(_ *client.Client, message []byte) {
+			// a connection is on one worker at a time, the race detector
+			// catches concurrent handlers
+			frame, _ := strconv.Atoi(string(message))
+			handled[i] = append(handled[i], frame)
+			done.Done()
+		}
+		pushers.Add(1)
+		go func() {
+			defer pushers.Done()
+			for frame := 0; frame < frames; frame++ {
+				in.push(handler, []byte(strconv.Itoa(frame)))
+			}
+		}()
+	}
+	done.Wait()
+	pushers.Wait()
+
+	for i, frames := range handled {
+		for want, frame := range frames {
+			if frame != want {
+				t.Fatalf("inbox %d handled frame %d in position %d", i, frame, want)
+			}
+		}
+	}
+}
+
+func TestInboxPushBlocksWhileFull(t *testing.T) {
+	testWorkers(t, 1)
+	in := newInbox(&client.Client{Id: "slow"}, 1)
+	started, release := make(chan struct{}, 3), make(chan struct{})
+	handler := func(*client.Client, []byte) {
+		started <- struct{}{}
+		<-release
+	}
+
+	in.push(handler, []byte("handled"))
+	<-started
+	in.push(handler, []byte("queued"))
+	pushed := make(chan struct{})
+	go func() {
+		in.push(handler, []byte("blocked"))
+		close(pushed)
+	}()
+	select {
+	case <-pushed:
+		t.Fatal("push returned while the inbox is full")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(release)
+	select {
+	case <-pushed:
+	case <-time.After(time.Second):
+		t.Fatal("push still blocked once the frames were handled")
+	}
+}
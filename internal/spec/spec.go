@@ -0,0 +1,229 @@
+// Package spec generates the AsyncAPI document of the WebSocket protocol and the
+// OpenAPI document of the HTTP endpoints from the Go types of the messages, so
+// the published spec can't drift from the structs the server reads and writes.
+package spec
+
+import (
+	"reflect"
+	"strings"
+	"time"
+)
+
+// Message describes a message a client sends on the WebSocket.
+type Message struct {
+	// Name is the value of the "event" field.
+	Name    string
+	Summary string
+	// Payload is a value of the type of the message, without its "event" field.
+	Payload interface{}
+}
+
+// Endpoint describes an HTTP endpoint.
+type Endpoint struct {
+	Method  string
+	Path    string
+	Summary string
+	// Admin tells if the endpoint needs the admin token or an operator session.
+	Admin bool
+	// Query lists the query parameters.
+	Query []string
+	// Request and Response are values of the types of the JSON bodies, nil when
+	// the endpoint has none.
+	Request  interface{}
+	Response interface{}
+}
+
+// Info describes the API in both documents.
+type Info struct {
+	Title       string
+	Version     string
+	Description string
+}
+
+// AsyncAPI returns the AsyncAPI document of the WebSocket protocol. Clients
+// publish the messages, and subscribe to the messages of the type of reply.
+func AsyncAPI(info Info, query []string, messages []Message, reply interface{}) map[string]interface{} {
+	components := map[string]interface{}{}
+	oneOf := make([]interface{}, 0, len(messages))
+	for _, message := range messages {
+		payload := Schema(message.Payload)
+		if payload["properties"] == nil {
+			payload["properties"] = map[string]interface{}{}
+		}
+		payload["properties"].(map[string]interface{})["event"] = map[string]interface{}{"type": "string", "const": message.Name}
+		payload["properties"].(map[string]interface{})["message_id"] = map[string]interface{}{
+			"type":        "string",
+			"maxLength":   128,
+			"description": "Id chosen by the client, a message sent again with the same Id is dropped.",
+		}
+		payload["required"] = append([]string{"event"}, required(payload)...)
+		components[message.Name] = map[string]interface{}{
+			"name":    message.Name,
+			"summary": message.Summary,
+			"payload": payload,
+		}
+		oneOf = append(oneOf, map[string]interface{}{"$ref": "#/components/messages/" + message.Name})
+	}
+	components["Server_Message"] = map[string]interface{}{
+		"name":    "Server_Message",
+		"summary": "A reply or update from the server, \"event\" tells which one.",
+		"payload": Schema(reply),
+	}
+	return map[string]interface{}{
+		"asyncapi":           "2.6.0",
+		"info":               map[string]interface{}{"title": info.Title, "version": info.Version, "description": info.Description},
+		"defaultContentType": "application/json",
+		"channels": map[string]interface{}{
+			"/": map[string]interface{}{
+				"bindings":  map[string]interface{}{"ws": map[string]interface{}{"query": stringProperties(query)}},
+				"publish":   map[string]interface{}{"message": map[string]interface{}{"oneOf": oneOf}},
+				"subscribe": map[string]interface{}{"message": map[string]interface{}{"$ref": "#/components/messages/Server_Message"}},
+			},
+		},
+		"components": map[string]interface{}{"messages": components},
+	}
+}
+
+// OpenAPI returns the OpenAPI document of the HTTP endpoints.
+func OpenAPI(info Info, endpoints []Endpoint) map[string]interface{} {
+	paths := map[string]interface{}{}
+	for _, endpoint := range endpoints {
+		operation := map[string]interface{}{
+			"summary":   endpoint.Summary,
+			"responses": map[string]interface{}{"200": response(endpoint.Response)},
+		}
+		if endpoint.Admin {
+			operation["security"] = []interface{}{map[string]interface{}{"bearer": []string{}}}
+		}
+		parameters := pathParameters(endpoint.Path)
+		for _, name := range endpoint.Query {
+			parameters = append(parameters, map[string]interface{}{"name": name, "in": "query", "schema": map[string]interface{}{"type": "string"}})
+		}
+		if len(parameters) > 0 {
+			operation["parameters"] = parameters
+		}
+		if endpoint.Request != nil {
+			operation["requestBody"] = map[string]interface{}{
+				"required": true,
+				"content":  map[string]interface{}{"application/json": map[string]interface{}{"schema": Schema(endpoint.Request)}},
+			}
+		}
+		item, _ := paths[endpoint.Path].(map[string]interface{})
+		if item == nil {
+			item = map[string]interface{}{}
+			paths[endpoint.Path] = item
+		}
+		item[strings.ToLower(endpoint.Method)] = operation
+	}
+	return map[string]interface{}{
+		"openapi": "3.0.3",
+		"info":    map[string]interface{}{"title": info.Title, "version": info.Version, "description": info.Description},
+		"paths":   paths,
+		"components": map[string]interface{}{
+			"securitySchemes": map[string]interface{}{"bearer": map[string]interface{}{"type": "http", "scheme": "bearer"}},
+		},
+	}
+}
+
+func response(body interface{}) map[string]interface{} {
+	if body == nil {
+		return map[string]interface{}{"description": "OK"}
+	}
+	return map[string]interface{}{
+		"description": "OK",
+		"content":     map[string]interface{}{"application/json": map[string]interface{}{"schema": Schema(body)}},
+	}
+}
+
+// pathParameters returns the parameters of the {name} segments of a path.
+func pathParameters(path string) []interface{} {
+	var parameters []interface{}
+	for _, segment := range strings.Split(path, "/") {
+		if name, ok := strings.CutPrefix(segment, "{"); ok {
+			parameters = append(parameters, map[string]interface{}{
+				"name": strings.TrimSuffix(name, "}"), "in": "path", "required": true, "schema": map[string]interface{}{"type": "string"},
+			})
+		}
+	}
+	return parameters
+}
+
+func stringProperties(names []string) map[string]interface{} {
+	properties := map[string]interface{}{}
+	for _, name := range names {
+		properties[name] = map[string]interface{}{"type": "string"}
+	}
+	return map[string]interface{}{"type": "object", "properties": properties}
+}
+
+func required(schema map[string]interface{}) []string {
+	names, _ := schema["required"].([]string)
+	return names
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// Schema returns the JSON schema of the type of v. Struct fields are named by
+// their json tag and described by their doc tag, the ones tagged
+// spec:"required" are required.
+func Schema(v interface{}) map[string]interface{} {
+	if v == nil {
+		return map[string]interface{}{}
+	}
+	return schemaOf(reflect.TypeOf(v))
+}
+
+func schemaOf(t reflect.Type) map[string]interface{} {
+	if t == timeType {
+		return map[string]interface{}{"type": "string", "format": "date-time"}
+	}
+	switch t.Kind() {
+	case reflect.Pointer:
+		return schemaOf(t.Elem())
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		return map[string]interface{}{"type": "array", "items": schemaOf(t.Elem())}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": schemaOf(t.Elem())}
+	case reflect.Struct:
+		return structSchema(t)
+	}
+	// interface{} fields take any value
+	return map[string]interface{}{}
+}
+
+func structSchema(t reflect.Type) map[string]interface{} {
+	properties := map[string]interface{}{}
+	var names []string
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if !field.IsExported() || name == "-" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		property := schemaOf(field.Type)
+		if doc := field.Tag.Get("doc"); doc != "" {
+			property["description"] = doc
+		}
+		properties[name] = property
+		if field.Tag.Get("spec") == "required" {
+			names = append(names, name)
+		}
+	}
+	schema := map[string]interface{}{"type": "object", "properties": properties}
+	if len(names) > 0 {
+		schema["required"] = names
+	}
+	return schema
+}
@@ -0,0 +1,137 @@
+package store
+
+import (
+	"encoding/json"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+var (
+	roomsBucket    = []byte("rooms")
+	bansBucket     = []byte("bans")
+	archivesBucket = []byte("archives")
+)
+
+// BoltStore is a Store backed by an embedded BoltDB file.
+type BoltStore struct {
+	db *bolt.DB
+}
+
+// OpenBolt opens (or creates) the BoltDB file at path.
+func OpenBolt(path string) (*BoltStore, error) {
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return nil, err
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, bucket := range [][]byte{roomsBucket, bansBucket, archivesBucket} {
+			if _, err := tx.CreateBucketIfNotExists(bucket); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &BoltStore{db: db}, nil
+}
+
+func (store *BoltStore) SaveRoom(record RoomRecord) error {
+	value, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	return store.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(roomsBucket).Put([]byte(record.Id), value)
+	})
+}
+
+func (store *BoltStore) DeleteRoom(roomId string) error {
+	return store.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(roomsBucket).Delete([]byte(roomId))
+	})
+}
+
+func (store *BoltStore) LoadRooms() ([]RoomRecord, error) {
+	var records []RoomRecord
+	err := store.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(roomsBucket).ForEach(func(key []byte, value []byte) error {
+			var record RoomRecord
+			if err := json.Unmarshal(value, &record); err != nil {
+				return err
+			}
+			records = append(records, record)
+			return nil
+		})
+	})
+	return records, err
+}
+
+func (store *BoltStore) SaveBan(record BanRecord) error {
+	value, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	return store.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(bansBucket).Put([]byte(record.Id), value)
+	})
+}
+
+func (store *BoltStore) DeleteBan(banId string) error {
+	return store.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(bansBucket).Delete([]byte(banId))
+	})
+}
+
+func (store *BoltStore) LoadBans() ([]BanRecord, error) {
+	var records []BanRecord
+	err := store.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(bansBucket).ForEach(func(key []byte, value []byte) error {
+			var record BanRecord
+			if err := json.Unmarshal(value, &record); err != nil {
+				return err
+			}
+			records = append(records, record)
+			return nil
+		})
+	})
+	return records, err
+}
+
+func (store *BoltStore) SaveArchive(record ArchiveRecord) error {
+	value, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	return store.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(archivesBucket).Put([]byte(record.Id), value)
+	})
+}
+
+func (store *BoltStore) DeleteArchive(archiveId string) error {
+	return store.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(archivesBucket).Delete([]byte(archiveId))
+	})
+}
+
+func (store *BoltStore) LoadArchives() ([]ArchiveRecord, error) {
+	var records []ArchiveRecord
+	err := store.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(archivesBucket).ForEach(func(key []byte, value []byte) error {
+			var record ArchiveRecord
+			if err := json.Unmarshal(value, &record); err != nil {
+				return err
+			}
+			records = append(records, record)
+			return nil
+		})
+	})
+	return records, err
+}
+
+func (store *BoltStore) Close() error {
+	return store.db.Close()
+}
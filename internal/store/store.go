@@ -0,0 +1,132 @@
+package store
+
+import (
+	"slices"
+	"time"
+
+	"github.com/shankarammai/Peer2PeerConnector/internal/room"
+)
+
+// RoomRecord is the persisted definition of a room.
+type RoomRecord struct {
+	Id           string                 `json:"id"`
+	Name         string                 `json:"name"`
+	Creator      string                 `json:"creator"`
+	Members      []string               `json:"members"`
+	MaxClients   int                    `json:"max_clients,omitempty"`
+	PasswordHash string                 `json:"password_hash,omitempty"`
+	Locked       bool                   `json:"locked,omitempty"`
+	ExpiresAt    time.Time              `json:"expires_at,omitempty"`
+	Metadata     map[string]interface{} `json:"metadata,omitempty"`
+	Persistent   bool                   `json:"persistent"`
+	History      bool                   `json:"history,omitempty"`
+	// AutoNegotiate mirrors the room setting of the same name.
+	AutoNegotiate        bool     `json:"auto_negotiate,omitempty"`
+	KeyEpoch             int      `json:"key_epoch,omitempty"`
+	AnnounceOnly         bool     `json:"announce_only,omitempty"`
+	Moderators           []string `json:"moderators,omitempty"`
+	EndWhenCreatorLeaves bool     `json:"end_when_creator_leaves,omitempty"`
+	RequireApproval      bool     `json:"require_approval,omitempty"`
+	Public               bool     `json:"public,omitempty"`
+	JoinCode             string   `json:"join_code,omitempty"`
+	CallbackURL          string   `json:"callback_url,omitempty"`
+	// CreatedAt keeps the duration of the room summary right across restarts.
+	CreatedAt time.Time `json:"created_at,omitempty"`
+	Region    string    `json:"region,omitempty"`
+}
+
+// BanRecord is a ban of a client Id or an address, from a room or from the server.
+type BanRecord struct {
+	Id string `json:"id"`
+	// Room is the room the ban applies to, empty for a ban from the server.
+	Room     string `json:"room,omitempty"`
+	ClientId string `json:"client_id,omitempty"`
+	// IP is an address or a CIDR range.
+	IP        string    `json:"ip,omitempty"`
+	Reason    string    `json:"reason,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	// ExpiresAt is when the ban is lifted, zero means never.
+	ExpiresAt time.Time `json:"expires_at,omitempty"`
+}
+
+// ArchiveRecord is a deleted room kept for the operators until ExpiresAt.
+type ArchiveRecord struct {
+	// Id tells apart the archives of rooms reusing the same room Id.
+	Id        string                 `json:"id"`
+	Room      RoomRecord             `json:"room"`
+	Reason    string                 `json:"reason"`
+	DeletedAt time.Time              `json:"deleted_at"`
+	ExpiresAt time.Time              `json:"expires_at"`
+	Summary   map[string]interface{} `json:"summary,omitempty"`
+}
+
+// Store persists room definitions, bans and archived rooms so they survive a restart.
+type Store interface {
+	SaveRoom(record RoomRecord) error
+	DeleteRoom(roomId string) error
+	LoadRooms() ([]RoomRecord, error)
+	SaveBan(record BanRecord) error
+	DeleteBan(banId string) error
+	LoadBans() ([]BanRecord, error)
+	SaveArchive(record ArchiveRecord) error
+	DeleteArchive(archiveId string) error
+	LoadArchives() ([]ArchiveRecord, error)
+	Close() error
+}
+
+// RecordFromRoom copies the persisted fields of myRoom. The caller must hold
+// the lock protecting the room.
+func RecordFromRoom(myRoom *room.Room) RoomRecord {
+	return RoomRecord{
+		Id:                   myRoom.Id,
+		Name:                 myRoom.Name,
+		Creator:              myRoom.Creator,
+		Members:              slices.Clone(myRoom.Clients),
+		MaxClients:           myRoom.MaxClients,
+		PasswordHash:         myRoom.PasswordHash,
+		Locked:               myRoom.Locked,
+		ExpiresAt:            myRoom.ExpiresAt,
+		Metadata:             myRoom.Metadata,
+		Persistent:           myRoom.Persistent,
+		History:              myRoom.History != nil,
+		AutoNegotiate:        myRoom.AutoNegotiate,
+		KeyEpoch:             myRoom.KeyEpoch,
+		AnnounceOnly:         myRoom.AnnounceOnly,
+		Moderators:           slices.Clone(myRoom.Moderators),
+		EndWhenCreatorLeaves: myRoom.EndWhenCreatorLeaves,
+		RequireApproval:      myRoom.RequireApproval,
+		Public:               myRoom.Public,
+		JoinCode:             myRoom.JoinCode,
+		CallbackURL:          myRoom.CallbackURL,
+		CreatedAt:            myRoom.Activity.CreatedAt,
+		Region:               myRoom.Region,
+	}
+}
+
+// ToRoom rebuilds the room described by the record.
+func (record RoomRecord) ToRoom() *room.Room {
+	myRoom := room.NewRoom(record.Id, record.Name, record.Creator)
+	myRoom.Clients = slices.Clone(record.Members)
+	myRoom.MaxClients = record.MaxClients
+	myRoom.PasswordHash = record.PasswordHash
+	myRoom.Locked = record.Locked
+	myRoom.ExpiresAt = record.ExpiresAt
+	myRoom.Metadata = record.Metadata
+	myRoom.Persistent = record.Persistent
+	myRoom.AutoNegotiate = record.AutoNegotiate
+	myRoom.KeyEpoch = record.KeyEpoch
+	myRoom.AnnounceOnly = record.AnnounceOnly
+	myRoom.Moderators = slices.Clone(record.Moderators)
+	myRoom.EndWhenCreatorLeaves = record.EndWhenCreatorLeaves
+	myRoom.RequireApproval = record.RequireApproval
+	myRoom.Public = record.Public
+	myRoom.JoinCode = record.JoinCode
+	myRoom.CallbackURL = record.CallbackURL
+	myRoom.Region = record.Region
+	// the timeline restarts with the members known at the restart
+	myRoom.Activity = room.Activity{CreatedAt: record.CreatedAt, PeakMembers: len(record.Members)}
+	if record.CreatedAt.IsZero() {
+		myRoom.Activity.CreatedAt = time.Now()
+	}
+	return myRoom
+}
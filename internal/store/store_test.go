@@ -0,0 +1,106 @@
+package store
+
+import (
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/shankarammai/Peer2PeerConnector/internal/room"
+)
+
+func openTestStore(t *testing.T) *BoltStore {
+	t.Helper()
+	roomStore, err := OpenBolt(filepath.Join(t.TempDir(), "rooms.db"))
+	if err != nil {
+		t.Fatalf("open store: %v", err)
+	}
+	t.Cleanup(func() { roomStore.Close() })
+	return roomStore
+}
+
+func TestRoomRoundTrip(t *testing.T) {
+	roomStore := openTestStore(t)
+	created := time.Date(2024, 8, 10, 17, 0, 0, 0, time.UTC)
+	original := room.NewRoom("standup", "Standup", "alice")
+	original.AddClient("bob")
+	original.AddClient("carol")
+	// the creator left, the restored room must not list it again
+	original.RemoveClient("alice")
+	original.MaxClients = 8
+	original.Locked = true
+	original.Persistent = true
+	original.Metadata = map[string]interface{}{"team": "42"}
+	original.Moderators = []string{"bob"}
+	original.JoinCode = "ABC123"
+	original.Region = "eu"
+	original.Activity.CreatedAt = created
+	if err := original.SetPassword("secret"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := roomStore.SaveRoom(RecordFromRoom(original)); err != nil {
+		t.Fatalf("save room: %v", err)
+	}
+	records, err := roomStore.LoadRooms()
+	if err != nil || len(records) != 1 {
+		t.Fatalf("load rooms: %d records, %v", len(records), err)
+	}
+	restored := records[0].ToRoom()
+
+	if !slices.Equal(restored.Clients, []string{"bob", "carol"}) {
+		t.Errorf("members %v, want [bob carol]", restored.Clients)
+	}
+	if restored.Id != "standup" || restored.Name != "Standup" || restored.Creator != "alice" {
+		t.Errorf("restored %s %q by %s", restored.Id, restored.Name, restored.Creator)
+	}
+	if restored.MaxClients != 8 || !restored.Locked || !restored.Persistent || restored.JoinCode != "ABC123" || restored.Region != "eu" {
+		t.Errorf("settings not restored: %+v", restored)
+	}
+	if restored.Metadata["team"] != "42" || !slices.Equal(restored.Moderators, []string{"bob"}) {
+		t.Errorf("metadata %v and moderators %v not restored", restored.Metadata, restored.Moderators)
+	}
+	if !restored.CheckPassword("secret") || restored.CheckPassword("guess") {
+		t.Error("password not restored")
+	}
+	if !restored.Activity.CreatedAt.Equal(created) || restored.Activity.PeakMembers != 2 {
+		t.Errorf("activity created %s with %d peak members", restored.Activity.CreatedAt, restored.Activity.PeakMembers)
+	}
+
+	if err := roomStore.DeleteRoom("standup"); err != nil {
+		t.Fatalf("delete room: %v", err)
+	}
+	if records, err := roomStore.LoadRooms(); err != nil || len(records) != 0 {
+		t.Errorf("%d rooms left after delete, %v", len(records), err)
+	}
+}
+
+func TestBanAndArchiveRoundTrip(t *testing.T) {
+	roomStore := openTestStore(t)
+	ban := BanRecord{Id: "ban-1", Room: "standup", IP: "10.0.0.0/8", Reason: "spam", CreatedAt: time.Date(2024, 8, 10, 17, 0, 0, 0, time.UTC)}
+	if err := roomStore.SaveBan(ban); err != nil {
+		t.Fatalf("save ban: %v", err)
+	}
+	bans, err := roomStore.LoadBans()
+	if err != nil || len(bans) != 1 || bans[0].IP != ban.IP || bans[0].Reason != ban.Reason || !bans[0].CreatedAt.Equal(ban.CreatedAt) {
+		t.Fatalf("load bans: %+v, %v", bans, err)
+	}
+
+	archive := ArchiveRecord{Id: "archive-1", Room: RoomRecord{Id: "standup", Members: []string{"bob"}}, Reason: "ended"}
+	if err := roomStore.SaveArchive(archive); err != nil {
+		t.Fatalf("save archive: %v", err)
+	}
+	archives, err := roomStore.LoadArchives()
+	if err != nil || len(archives) != 1 || archives[0].Room.Id != "standup" || !slices.Equal(archives[0].Room.Members, []string{"bob"}) {
+		t.Fatalf("load archives: %+v, %v", archives, err)
+	}
+	if err := roomStore.DeleteBan("ban-1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := roomStore.DeleteArchive("archive-1"); err != nil {
+		t.Fatal(err)
+	}
+	if bans, _ := roomStore.LoadBans(); len(bans) != 0 {
+		t.Errorf("%d bans left after delete", len(bans))
+	}
+}
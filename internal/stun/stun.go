@@ -0,0 +1,105 @@
+package stun
+
+import (
+	"encoding/binary"
+	"errors"
+	"net"
+
+	"github.com/shankarammai/Peer2PeerConnector/internal/logging"
+)
+
+// Values from RFC 5389 needed to answer binding requests.
+const (
+	headerSize          = 20
+	magicCookie         = 0x2112A442
+	typeBindingRequest  = 0x0001
+	typeBindingResponse = 0x0101
+	attrXorMappedAddr   = 0x0020
+	familyIPv4          = 0x01
+	familyIPv6          = 0x02
+)
+
+var (
+	errNotBindingRequest = errors.New("not a STUN binding request")
+	errBadLength         = errors.New("STUN message length doesn't match the packet size")
+)
+
+// ListenAndServe answers STUN binding requests on the UDP address addr.
+// It only implements the binding method, which is all browsers need to
+// discover their server reflexive candidates.
+func ListenAndServe(addr string) error {
+	conn, err := net.ListenPacket("udp", addr)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	logging.Logger.Info("Starting STUN server at: ", conn.LocalAddr())
+	return Serve(conn)
+}
+
+// Serve answers binding requests received on conn until reading fails.
+func Serve(conn net.PacketConn) error {
+	buf := make([]byte, 1500)
+	for {
+		n, remote, err := conn.ReadFrom(buf)
+		if err != nil {
+			return err
+		}
+		udpAddr, ok := remote.(*net.UDPAddr)
+		if !ok {
+			continue
+		}
+		response, err := BindingResponse(buf[:n], udpAddr)
+		if err != nil {
+			logging.Logger.Debugf("Ignoring STUN packet from %s: %v", remote, err)
+			continue
+		}
+		if _, err := conn.WriteTo(response, remote); err != nil {
+			logging.Logger.Debugf("Failed to send STUN response to %s: %v", remote, err)
+		}
+	}
+}
+
+// BindingResponse builds the success response for the binding request in packet,
+// reporting addr back to the sender as its XOR-MAPPED-ADDRESS.
+func BindingResponse(packet []byte, addr *net.UDPAddr) ([]byte, error) {
+	if len(packet) < headerSize ||
+		binary.BigEndian.Uint16(packet[0:2]) != typeBindingRequest ||
+		binary.BigEndian.Uint32(packet[4:8]) != magicCookie {
+		return nil, errNotBindingRequest
+	}
+	// the length counts the attributes following the header, padded to 4 bytes
+	if length := int(binary.BigEndian.Uint16(packet[2:4])); length != len(packet)-headerSize || length%4 != 0 {
+		return nil, errBadLength
+	}
+	transactionId := packet[8:20]
+
+	family := byte(familyIPv4)
+	ip := addr.IP.To4()
+	if ip == nil {
+		family = familyIPv6
+		ip = addr.IP.To16()
+	}
+
+	// XOR-MAPPED-ADDRESS value: reserved, family, port and address xor'ed with the cookie
+	// (and the transaction id for IPv6).
+	value := make([]byte, 4+len(ip))
+	value[1] = family
+	binary.BigEndian.PutUint16(value[2:4], uint16(addr.Port)^uint16(magicCookie>>16))
+	xorKey := make([]byte, 16)
+	binary.BigEndian.PutUint32(xorKey[0:4], magicCookie)
+	copy(xorKey[4:], transactionId)
+	for i := range ip {
+		value[4+i] = ip[i] ^ xorKey[i]
+	}
+
+	response := make([]byte, headerSize+4+len(value))
+	binary.BigEndian.PutUint16(response[0:2], typeBindingResponse)
+	binary.BigEndian.PutUint16(response[2:4], uint16(4+len(value)))
+	binary.BigEndian.PutUint32(response[4:8], magicCookie)
+	copy(response[8:20], transactionId)
+	binary.BigEndian.PutUint16(response[20:22], attrXorMappedAddr)
+	binary.BigEndian.PutUint16(response[22:24], uint16(len(value)))
+	copy(response[24:], value)
+	return response, nil
+}
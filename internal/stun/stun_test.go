@@ -0,0 +1,116 @@
+package stun
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net"
+	"testing"
+	"time"
+)
+
+var transactionId = []byte("0123456789ab")
+
+// bindingRequest returns a binding request carrying attributes bytes of attributes.
+func bindingRequest(attributes int) []byte {
+	packet := make([]byte, headerSize+attributes)
+	binary.BigEndian.PutUint16(packet[0:2], typeBindingRequest)
+	binary.BigEndian.PutUint16(packet[2:4], uint16(attributes))
+	binary.BigEndian.PutUint32(packet[4:8], magicCookie)
+	copy(packet[8:20], transactionId)
+	return packet
+}
+
+// mappedAddress decodes the XOR-MAPPED-ADDRESS of a binding response.
+func mappedAddress(t *testing.T, response []byte) *net.UDPAddr {
+	t.Helper()
+	if binary.BigEndian.Uint16(response[0:2]) != typeBindingResponse ||
+		int(binary.BigEndian.Uint16(response[2:4])) != len(response)-headerSize ||
+		!bytes.Equal(response[8:20], transactionId) ||
+		binary.BigEndian.Uint16(response[20:22]) != attrXorMappedAddr {
+		t.Fatalf("malformed response %x", response)
+	}
+	value := response[24:]
+	xorKey := make([]byte, 16)
+	binary.BigEndian.PutUint32(xorKey[0:4], magicCookie)
+	copy(xorKey[4:], transactionId)
+	ip := make(net.IP, len(value)-4)
+	for i := range ip {
+		ip[i] = value[4+i] ^ xorKey[i]
+	}
+	port := binary.BigEndian.Uint16(value[2:4]) ^ uint16(magicCookie>>16)
+	return &net.UDPAddr{IP: ip, Port: int(port)}
+}
+
+func TestBindingResponse(t *testing.T) {
+	for _, addr := range []*net.UDPAddr{
+		{IP: net.ParseIP("203.0.113.7"), Port: 54321},
+		{IP: net.ParseIP("2001:db8::1"), Port: 3478},
+	} {
+		for _, attributes := range []int{0, 8} {
+			response, err := BindingResponse(bindingRequest(attributes), addr)
+			if err != nil {
+				t.Fatalf("%s: %v", addr, err)
+			}
+			if mapped := mappedAddress(t, response); !mapped.IP.Equal(addr.IP) || mapped.Port != addr.Port {
+				t.Errorf("mapped address %s, want %s", mapped, addr)
+			}
+		}
+	}
+}
+
+func TestBindingResponseRejects(t *testing.T) {
+	addr := &net.UDPAddr{IP: net.ParseIP("203.0.113.7"), Port: 54321}
+	tests := []struct {
+		name   string
+		packet func() []byte
+		err    error
+	}{
+		{"short", func() []byte { return bindingRequest(0)[:19] }, errNotBindingRequest},
+		{"other method", func() []byte {
+			packet := bindingRequest(0)
+			binary.BigEndian.PutUint16(packet[0:2], typeBindingResponse)
+			return packet
+		}, errNotBindingRequest},
+		{"no magic cookie", func() []byte {
+			packet := bindingRequest(0)
+			packet[4] = 0
+			return packet
+		}, errNotBindingRequest},
+		{"trailing bytes", func() []byte { return append(bindingRequest(0), 0, 0, 0, 0) }, errBadLength},
+		{"length past the packet", func() []byte { return bindingRequest(8)[:headerSize+4] }, errBadLength},
+		{"unpadded length", func() []byte { return bindingRequest(6) }, errBadLength},
+	}
+	for _, test := range tests {
+		if _, err := BindingResponse(test.packet(), addr); err != test.err {
+			t.Errorf("%s: error %v, want %v", test.name, err, test.err)
+		}
+	}
+}
+
+func TestServe(t *testing.T) {
+	server, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Skip("no UDP on loopback: ", err)
+	}
+	defer server.Close()
+	go Serve(server)
+
+	conn, err := net.DialUDP("udp", nil, server.LocalAddr().(*net.UDPAddr))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	if _, err := conn.Write(bindingRequest(0)); err != nil {
+		t.Fatal(err)
+	}
+	response := make([]byte, 1500)
+	n, err := conn.Read(response)
+	if err != nil {
+		t.Fatal(err)
+	}
+	local := conn.LocalAddr().(*net.UDPAddr)
+	if mapped := mappedAddress(t, response[:n]); !mapped.IP.Equal(local.IP) || mapped.Port != local.Port {
+		t.Errorf("mapped address %s, want %s", mapped, local)
+	}
+}
@@ -0,0 +1,178 @@
+package webhook
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/shankarammai/Peer2PeerConnector/internal/logging"
+)
+
+// Headers set on every webhook delivery.
+const (
+	HeaderEvent     = "X-Webhook-Event"
+	HeaderSignature = "X-Webhook-Signature"
+)
+
+// Event is the JSON body posted to webhook URLs.
+type Event struct {
+	Type      string                 `json:"type"`
+	Data      map[string]interface{} `json:"data"`
+	Timestamp time.Time              `json:"timestamp"`
+}
+
+// Bounds of the deliveries in progress, so a slow or unreachable endpoint
+// can't pile up goroutines.
+const (
+	// deliveryWorkers is the number of deliveries posted at the same time.
+	deliveryWorkers = 8
+	// deliveryQueue is the number of deliveries waiting for a worker, past it
+	// new ones are dropped.
+	deliveryQueue = 1024
+)
+
+// Dispatcher delivers events to a fixed set of URLs in the background.
+// Failed deliveries are retried with an exponential backoff.
+type Dispatcher struct {
+	urls       []string
+	secret     string
+	maxRetries int
+	backoff    time.Duration
+	httpClient *http.Client
+	// queue holds the deliveries waiting for one of the workers, started once
+	// by the first delivery.
+	queue chan delivery
+	start sync.Once
+}
+
+// delivery is an event waiting to be posted to url.
+type delivery struct {
+	url       string
+	eventType string
+	body      []byte
+}
+
+// NewDispatcher creates a dispatcher posting to urls. An empty secret disables signing.
+func NewDispatcher(urls []string, secret string, maxRetries int, timeout time.Duration) *Dispatcher {
+	return &Dispatcher{
+		urls:       urls,
+		secret:     secret,
+		maxRetries: maxRetries,
+		backoff:    500 * time.Millisecond,
+		httpClient: &http.Client{Timeout: timeout, CheckRedirect: refuseRedirect},
+		queue:      make(chan delivery, deliveryQueue),
+	}
+}
+
+// refuseRedirect keeps deliveries on the URL they were posted to: a redirect
+// could send the server to an address the URL checks don't allow, like one of
+// the internal network. The redirect answer is a failed delivery.
+func refuseRedirect(*http.Request, []*http.Request) error {
+	return http.ErrUseLastResponse
+}
+
+// Emit sends the event to every configured URL without blocking the caller.
+func (dispatcher *Dispatcher) Emit(eventType string, data map[string]interface{}) {
+	if dispatcher == nil || len(dispatcher.urls) == 0 {
+		return
+	}
+	body, err := json.Marshal(Event{Type: eventType, Data: data, Timestamp: time.Now()})
+	if err != nil {
+		logging.Logger.Error("Failed to encode webhook event: ", err)
+		return
+	}
+	for _, url := range dispatcher.urls {
+		dispatcher.enqueue(delivery{url: url, eventType: eventType, body: body})
+	}
+}
+
+// EmitTo sends the event to url rather than the configured URLs, without
+// blocking the caller.
+func (dispatcher *Dispatcher) EmitTo(url string, eventType string, data map[string]interface{}) {
+	if dispatcher == nil {
+		return
+	}
+	body, err := json.Marshal(Event{Type: eventType, Data: data, Timestamp: time.Now()})
+	if err != nil {
+		logging.Logger.Error("Failed to encode webhook event: ", err)
+		return
+	}
+	dispatcher.enqueue(delivery{url: url, eventType: eventType, body: body})
+}
+
+// enqueue queues the delivery for the workers, or drops it when the queue is
+// full. It returns false when the delivery was dropped.
+func (dispatcher *Dispatcher) enqueue(pending delivery) bool {
+	dispatcher.start.Do(func() {
+		for range deliveryWorkers {
+			go dispatcher.work()
+		}
+	})
+	select {
+	case dispatcher.queue <- pending:
+		return true
+	default:
+		logging.Logger.Warnf("Webhook queue is full, dropping %s to %s", pending.eventType, pending.url)
+		return false
+	}
+}
+
+// work delivers the queued events one after the other.
+func (dispatcher *Dispatcher) work() {
+	for pending := range dispatcher.queue {
+		dispatcher.deliver(pending.url, pending.eventType, pending.body)
+	}
+}
+
+// deliver posts body to url, retrying until it succeeds or the retries run out.
+func (dispatcher *Dispatcher) deliver(url string, eventType string, body []byte) {
+	wait := dispatcher.backoff
+	for attempt := 0; attempt <= dispatcher.maxRetries; attempt++ {
+		if attempt > 0 {
+			time.Sleep(wait)
+			wait *= 2
+		}
+		err := dispatcher.post(url, eventType, body)
+		if err == nil {
+			return
+		}
+		logging.Logger.Debugf("Webhook delivery of %s to %s failed (attempt %d): %v", eventType, url, attempt+1, err)
+	}
+	logging.Logger.Warnf("Giving up webhook delivery of %s to %s", eventType, url)
+}
+
+func (dispatcher *Dispatcher) post(url string, eventType string, body []byte) error {
+	request, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set(HeaderEvent, eventType)
+	if dispatcher.secret != "" {
+		request.Header.Set(HeaderSignature, Sign(dispatcher.secret, body))
+	}
+
+	response, err := dispatcher.httpClient.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	if response.StatusCode < 200 || response.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status %s", response.Status)
+	}
+	return nil
+}
+
+// Sign returns the signature header value for body: "sha256=" followed by
+// the hex encoded HMAC-SHA256 of the body keyed with secret.
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
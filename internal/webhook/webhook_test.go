@@ -0,0 +1,80 @@
+package webhook
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestPostSignsTheEvent(t *testing.T) {
+	received := make(chan *http.Request, 1)
+	var body []byte
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		body, _ = io.ReadAll(request.Body)
+		received <- request
+	}))
+	defer server.Close()
+
+	dispatcher := NewDispatcher(nil, "secret", 0, time.Second)
+	if err := dispatcher.post(server.URL, "room_created", []byte(`{"type":"room_created"}`)); err != nil {
+		t.Fatalf("post: %v", err)
+	}
+	request := <-received
+	if request.Header.Get(HeaderEvent) != "room_created" || request.Header.Get(HeaderSignature) != Sign("secret", body) {
+		t.Errorf("headers %v", request.Header)
+	}
+}
+
+func TestPostRefusesRedirects(t *testing.T) {
+	var internalHits atomic.Int32
+	internal := httptest.NewServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+		internalHits.Add(1)
+	}))
+	defer internal.Close()
+	allowed := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		http.Redirect(writer, request, internal.URL, http.StatusTemporaryRedirect)
+	}))
+	defer allowed.Close()
+
+	dispatcher := NewDispatcher(nil, "", 0, time.Second)
+	if err := dispatcher.post(allowed.URL, "client_joined", []byte(`{}`)); err == nil {
+		t.Error("redirected delivery reported as delivered")
+	}
+	if hits := internalHits.Load(); hits != 0 {
+		t.Errorf("redirect followed to %s, %d requests", internal.URL, hits)
+	}
+}
+
+func TestEnqueueDropsWhenFull(t *testing.T) {
+	posted := make(chan struct{}, deliveryWorkers)
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+		select {
+		case posted <- struct{}{}:
+		default:
+		}
+		<-release
+	}))
+	defer server.Close()
+	defer close(release)
+
+	dispatcher := NewDispatcher([]string{server.URL}, "", 0, time.Minute)
+	for range deliveryWorkers {
+		dispatcher.Emit("client_connected", nil)
+	}
+	// every worker is blocked on a delivery, the next ones wait in the queue
+	for range deliveryWorkers {
+		<-posted
+	}
+	for index := range deliveryQueue {
+		if !dispatcher.enqueue(delivery{url: server.URL, eventType: "client_connected"}) {
+			t.Fatalf("delivery %d dropped with room in the queue", index)
+		}
+	}
+	if dispatcher.enqueue(delivery{url: server.URL, eventType: "client_connected"}) {
+		t.Error("delivery queued past the bound")
+	}
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"crypto/tls"
+	"errors"
+	"net"
+	"net/http"
+	"slices"
+
+	"github.com/shankarammai/Peer2PeerConnector/internal/config"
+	"github.com/shankarammai/Peer2PeerConnector/internal/server"
+	"golang.org/x/crypto/acme/autocert"
+)
+
+// serve runs the web server: plain HTTP on HTTP_ADDR, or HTTPS when TLS is
+// configured, with certificates from Let's Encrypt or from files.
+func serve(cfg *config.Config, handler http.Handler) error {
+	autoTLS := len(cfg.TLSDomains) > 0
+	fileTLS := cfg.TLSCertFile != "" || cfg.TLSKeyFile != ""
+	switch {
+	case autoTLS && fileTLS:
+		return errors.New("set either TLS_DOMAINS or TLS_CERT_FILE and TLS_KEY_FILE, not both")
+	case fileTLS:
+		if cfg.TLSCertFile == "" || cfg.TLSKeyFile == "" {
+			return errors.New("TLS_CERT_FILE and TLS_KEY_FILE go together")
+		}
+		go redirectToHTTPS(cfg, redirectHandler(cfg.TLSAddr))
+		logger.Info("Starting Web Server with TLS at: ", cfg.TLSAddr)
+		return newHTTPServer(cfg, cfg.TLSAddr, handler).ListenAndServeTLS(cfg.TLSCertFile, cfg.TLSKeyFile)
+	case autoTLS:
+		manager := &autocert.Manager{
+			Prompt:     autocert.AcceptTOS,
+			HostPolicy: autocert.HostWhitelist(cfg.TLSDomains...),
+			Cache:      autocert.DirCache(cfg.TLSCacheDir),
+			Email:      cfg.TLSEmail,
+		}
+		// the HTTP listener answers the ACME challenges and redirects the rest
+		go redirectToHTTPS(cfg, manager.HTTPHandler(nil))
+		logger.Infof("Starting Web Server with TLS for %v at: %s", cfg.TLSDomains, cfg.TLSAddr)
+		httpServer := newHTTPServer(cfg, cfg.TLSAddr, handler)
+		httpServer.TLSConfig = manager.TLSConfig()
+		if !cfg.HTTP2Enabled {
+			httpServer.TLSConfig.NextProtos = slices.DeleteFunc(httpServer.TLSConfig.NextProtos, func(proto string) bool { return proto == "h2" })
+		}
+		return httpServer.ListenAndServeTLS("", "")
+	default:
+		logger.Info("Starting Web Server at: ", cfg.HTTPAddr)
+		return newHTTPServer(cfg, cfg.HTTPAddr, handler).ListenAndServe()
+	}
+}
+
+// newHTTPServer returns a server for addr with the configured timeouts and
+// limits, so slow or oversized requests can't hold connections open.
+// WebSocket connections are not affected once upgraded.
+func newHTTPServer(cfg *config.Config, addr string, handler http.Handler) *http.Server {
+	httpServer := &http.Server{
+		Addr:              addr,
+		Handler:           handler,
+		ReadHeaderTimeout: cfg.HTTPReadHeaderTimeout,
+		IdleTimeout:       cfg.HTTPIdleTimeout,
+		MaxHeaderBytes:    cfg.HTTPMaxHeaderBytes,
+	}
+	if !cfg.HTTP2Enabled {
+		// a non nil empty map turns off the HTTP/2 support of net/http
+		httpServer.TLSNextProto = make(map[string]func(*http.Server, *tls.Conn, http.Handler))
+	}
+	return httpServer
+}
+
+// serveListener serves handler over plain HTTP on a listener of its own, for
+// the admin API and the metrics.
+func serveListener(cfg *config.Config, addr string, handler http.Handler) {
+	logger.Info("Starting listener at: ", addr)
+	HandleErrorLine(newHTTPServer(cfg, addr, handler).ListenAndServe())
+}
+
+// redirectToHTTPS serves handler on the plain HTTP address, unless it is "off".
+func redirectToHTTPS(cfg *config.Config, handler http.Handler) {
+	if cfg.TLSRedirectAddr == "off" {
+		return
+	}
+	HandleErrorLine(newHTTPServer(cfg, cfg.TLSRedirectAddr, server.LogRequests(handler)).ListenAndServe())
+}
+
+// redirectHandler sends plain HTTP requests to the same URL over HTTPS on the
+// port of tlsAddr.
+func redirectHandler(tlsAddr string) http.Handler {
+	_, port, _ := net.SplitHostPort(tlsAddr)
+	return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		host := request.Host
+		if hostname, _, err := net.SplitHostPort(host); err == nil {
+			host = hostname
+		}
+		if port != "" && port != "443" {
+			host = net.JoinHostPort(host, port)
+		}
+		http.Redirect(writer, request, "https://"+host+request.URL.RequestURI(), http.StatusMovedPermanently)
+	})
+}
@@ -1,38 +1,73 @@
 package main
 
 import (
-	"net/http"
 	"os"
+	"os/signal"
 	"runtime"
+	"syscall"
 
-	"github.com/gorilla/websocket"
+	"github.com/shankarammai/Peer2PeerConnector/internal/config"
+	"github.com/shankarammai/Peer2PeerConnector/internal/logging"
+	"github.com/shankarammai/Peer2PeerConnector/internal/redact"
 	"github.com/shankarammai/Peer2PeerConnector/internal/server"
-	"github.com/sirupsen/logrus"
+	"github.com/shankarammai/Peer2PeerConnector/internal/stun"
 )
-var logger = &logrus.Logger{
-	Out:   os.Stdout,
-	Level: logrus.DebugLevel,
-	Formatter: &logrus.TextFormatter{
-		DisableColors: false,
-		TimestampFormat : "2006-01-02 15:04:05",
-		FullTimestamp:true,
-		ForceColors: true,
-	},
-}
+
+var logger = logging.Logger
 
 func main() {
-	logger.Info("Starting Web Server at port: 8080")
-	http.HandleFunc("/", handleRequest)
-	HandleErrorLine(http.ListenAndServe(":8080", nil))
+	cfg, err := config.Load()
+	if err != nil {
+		logger.Fatal("Invalid configuration: ", err)
+	}
+	if err := logging.Configure(cfg.LogLevel, cfg.LogFormat); err != nil {
+		logger.Fatal("Invalid logging configuration: ", err)
+	}
+	logFilter, err := redact.Parse(cfg.LogRedact)
+	if err != nil {
+		logger.Fatal("Invalid LOG_REDACT: ", err)
+	}
+	logFilter.MaxLength = cfg.LogMaxFieldLength
+	logging.Redact(logFilter)
+	if err := server.Init(cfg); err != nil {
+		logger.Fatal("Failed to initialise server: ", err)
+	}
+
+	if cfg.StunServerEnabled {
+		go func() {
+			HandleErrorLine(stun.ListenAndServe(cfg.StunServerAddr))
+		}()
+	}
+
+	if cfg.DebugConsole != "" {
+		go func() {
+			HandleErrorLine(server.ServeConsole(cfg.DebugConsole))
+		}()
+	}
+
+	if cfg.DiagnosticsAddr != "" {
+		go func() {
+			HandleErrorLine(server.ServeDiagnostics(cfg.DiagnosticsAddr))
+		}()
+	}
+
+	go reloadOnHangup()
+
+	for addr, handler := range server.NewListenerHandlers() {
+		go serveListener(cfg, addr, handler)
+	}
+
+	HandleErrorLine(serve(cfg, server.NewHandler()))
 }
 
-// handleWebRequest serves WebSocket on wss:// and Swagger docs on http://
-func handleRequest(w http.ResponseWriter, r *http.Request) {
-	// Check if the request is using WebSocket
-	if websocket.IsWebSocketUpgrade(r) {
-		server.HandleWebSocketConnection(w, r)
-	} else {
-		server.ServerDocs(w, r)
+// reloadOnHangup reloads the configuration on every SIGHUP.
+func reloadOnHangup() {
+	hangups := make(chan os.Signal, 1)
+	signal.Notify(hangups, syscall.SIGHUP)
+	for range hangups {
+		if _, err := server.ReloadConfig(); err != nil {
+			logger.Warn("Configuration not reloaded: ", err)
+		}
 	}
 }
 
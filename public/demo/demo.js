@@ -0,0 +1,182 @@
+// Two-peer video call over the signalling protocol of the server. The client
+// joining second calls the one already in the room.
+"use strict";
+
+const roomInput = document.getElementById("room");
+const joinButton = document.getElementById("join-button");
+const leaveButton = document.getElementById("leave-button");
+const localVideo = document.getElementById("local");
+const remoteVideo = document.getElementById("remote");
+
+let socket;
+let myId;
+let roomId;
+let peerId;
+let peerConnection;
+let localStream;
+let iceServers = [];
+
+function log(text) {
+    const line = document.createElement("div");
+    line.textContent = new Date().toLocaleTimeString() + "  " + text;
+    document.getElementById("log").prepend(line);
+}
+
+function send(event, fields) {
+    socket.send(JSON.stringify(Object.assign({ event: event }, fields)));
+}
+
+function connect() {
+    const scheme = location.protocol === "https:" ? "wss://" : "ws://";
+    socket = new WebSocket(scheme + location.host + "/");
+    socket.onopen = () => log("Connected to " + location.host);
+    socket.onclose = () => {
+        log("Disconnected from the server");
+        joinButton.disabled = true;
+        hangUp();
+    };
+    socket.onmessage = (message) => handle(JSON.parse(message.data));
+}
+
+function handle(msg) {
+    const data = msg.data || {};
+    if (msg.type === "error") {
+        if (msg.event === "Duplicate_Room") {
+            send("Join_Room", { data: { room: roomId } });
+            return;
+        }
+        log("Error " + msg.event + ": " + (data.message || JSON.stringify(data)));
+        return;
+    }
+    switch (msg.event) {
+    case "Client_Details":
+        myId = data.id;
+        log("My client Id is " + myId);
+        send("Get_Ice_Servers", {});
+        joinButton.disabled = false;
+        break;
+    case "Ice_Servers":
+        iceServers = data.ice_servers || [];
+        break;
+    case "Room_Created":
+        log("Created room " + data.room + ", waiting for a peer");
+        break;
+    case "Client_Added":
+        if (data.room !== roomId) {
+            break;
+        }
+        log("Room " + roomId + " has " + data.clients.length + " client(s)");
+        // the newest member calls the first other one
+        if (!peerConnection && data.clients.length >= 2 && data.clients[data.clients.length - 1] === myId) {
+            call(data.clients.find((id) => id !== myId));
+        }
+        break;
+    case "Client_Removed":
+        if (data.room === roomId && peerId && !data.clients.includes(peerId)) {
+            log("Peer " + peerId + " left");
+            hangUp();
+        }
+        break;
+    case "Offer":
+        answer(msg.from, data);
+        break;
+    case "Answer":
+        if (peerConnection && msg.from === peerId) {
+            peerConnection.setRemoteDescription(data);
+        }
+        break;
+    case "Candidate":
+        if (peerConnection && msg.from === peerId) {
+            peerConnection.addIceCandidate(data);
+        }
+        break;
+    case "Candidates":
+        if (peerConnection && msg.from === peerId) {
+            (data.candidates || []).forEach((candidate) => peerConnection.addIceCandidate(candidate));
+        }
+        break;
+    }
+}
+
+function newPeerConnection(remoteId) {
+    peerId = remoteId;
+    peerConnection = new RTCPeerConnection({ iceServers: iceServers });
+    localStream.getTracks().forEach((track) => peerConnection.addTrack(track, localStream));
+    peerConnection.onicecandidate = (event) => {
+        if (event.candidate) {
+            send("Candidate", { to: peerId, data: event.candidate.toJSON() });
+        }
+    };
+    peerConnection.ontrack = (event) => {
+        remoteVideo.srcObject = event.streams[0];
+    };
+    const connection = peerConnection;
+    connection.onconnectionstatechange = () => {
+        log("Peer connection " + connection.connectionState);
+        if (connection.connectionState === "failed" && connection === peerConnection) {
+            hangUp();
+        }
+    };
+}
+
+async function call(remoteId) {
+    log("Calling " + remoteId);
+    newPeerConnection(remoteId);
+    const offer = await peerConnection.createOffer();
+    await peerConnection.setLocalDescription(offer);
+    send("Offer", { to: remoteId, data: { type: offer.type, sdp: offer.sdp } });
+}
+
+async function answer(remoteId, offer) {
+    if (peerConnection) {
+        log("Ignoring an offer from " + remoteId + ", already in a call");
+        return;
+    }
+    log("Answering " + remoteId);
+    newPeerConnection(remoteId);
+    await peerConnection.setRemoteDescription(offer);
+    const reply = await peerConnection.createAnswer();
+    await peerConnection.setLocalDescription(reply);
+    send("Answer", { to: remoteId, data: { type: reply.type, sdp: reply.sdp } });
+}
+
+function hangUp() {
+    if (peerConnection) {
+        peerConnection.close();
+    }
+    peerConnection = null;
+    peerId = null;
+    remoteVideo.srcObject = null;
+}
+
+document.getElementById("join").onsubmit = async (event) => {
+    event.preventDefault();
+    try {
+        localStream = localStream || await navigator.mediaDevices.getUserMedia({ video: true, audio: true });
+    } catch (err) {
+        log("No camera or microphone: " + err.message);
+        return;
+    }
+    localVideo.srcObject = localStream;
+    roomId = roomInput.value.trim();
+    send("Create_Room", { data: { room: roomId } });
+    joinButton.disabled = true;
+    leaveButton.disabled = false;
+};
+
+leaveButton.onclick = () => {
+    send("Leave_Room", { data: { room: roomId } });
+    hangUp();
+    roomId = null;
+    joinButton.disabled = false;
+    leaveButton.disabled = true;
+};
+
+// leave explicitly, so the peer learns it right away
+window.addEventListener("pagehide", () => {
+    if (roomId && socket.readyState === WebSocket.OPEN) {
+        send("Leave_Room", { data: { room: roomId } });
+    }
+});
+
+connect();
@@ -0,0 +1,15 @@
+// Package public embeds the HTML templates of the pages served by the server,
+// and the demo client.
+package public
+
+import "embed"
+
+// Templates holds the HTML templates, by file name.
+//
+//go:embed *.html
+var Templates embed.FS
+
+// Demo holds the demo client, in the "demo" directory.
+//
+//go:embed demo
+var Demo embed.FS